@@ -2,8 +2,11 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -11,12 +14,24 @@ import (
 	"time"
 
 	"github.com/VanGoghDev/gophermart/internal/config"
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	grpcserver "github.com/VanGoghDev/gophermart/internal/grpc/server"
+	"github.com/VanGoghDev/gophermart/internal/handlers/balance/posttransfer"
 	"github.com/VanGoghDev/gophermart/internal/lib/logger/sl"
 	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/metrics"
 	"github.com/VanGoghDev/gophermart/internal/router"
+	"github.com/VanGoghDev/gophermart/internal/servertls"
 	"github.com/VanGoghDev/gophermart/internal/services/accrual"
 	"github.com/VanGoghDev/gophermart/internal/services/accrual/orderspool"
+	"github.com/VanGoghDev/gophermart/internal/services/auth/hasher"
+	"github.com/VanGoghDev/gophermart/internal/services/auth/oidc"
+	"github.com/VanGoghDev/gophermart/internal/services/auth/policy"
+	"github.com/VanGoghDev/gophermart/internal/services/events"
+	"github.com/VanGoghDev/gophermart/internal/services/health"
+	"github.com/VanGoghDev/gophermart/internal/services/ratelimit"
 	"github.com/VanGoghDev/gophermart/internal/storage"
+	"github.com/VanGoghDev/gophermart/internal/tracing"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -55,11 +70,41 @@ func run() error {
 	slog := logger.New(cfg.Env)
 	slog.DebugContext(ctx, "server started", "address", cfg.Address)
 
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Config{
+		Exporter:    cfg.TracingExporter,
+		File:        cfg.TracingFile,
+		SampleRatio: cfg.TracingSampleRatio,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to init tracing: %w", err)
+	}
+	g.Go(func() error {
+		<-ctx.Done()
+
+		// Сбрасываем накопленные спаны, пока экспортёр ещё доступен.
+		shutdownCtx, cancelShutdownCtx := context.WithTimeout(context.Background(), timeoutServerShutdown)
+		defer cancelShutdownCtx()
+		if err := shutdownTracing(shutdownCtx); err != nil {
+			slog.ErrorContext(ctx, "failed to shutdown tracing", sl.Err(err))
+		}
+		return nil
+	})
+
 	s, err := storage.New(ctx, slog, cfg.DSN)
 	if err != nil {
 		return fmt.Errorf("failed to init storage: %w", err)
 	}
 
+	if len(cfg.AdminLogins) > 0 {
+		promoted, err := s.SetRole(ctx, cfg.AdminLogins, models.RoleAdmin)
+		if err != nil {
+			return fmt.Errorf("failed to grant admin role: %w", err)
+		}
+		slog.InfoContext(ctx, "admin role granted", "requested", len(cfg.AdminLogins), "promoted", promoted)
+	}
+
+	// Пул закрываем только после остановки HTTP-сервера, иначе запросы, дослуживаемые при Shutdown, упадут.
+	httpStopped := make(chan struct{})
 	g.Go(func() error {
 		wg.Add(1)
 		defer wg.Done()
@@ -67,15 +112,96 @@ func run() error {
 		defer slog.DebugContext(ctx, "closed DB")
 
 		<-ctx.Done()
+		<-httpStopped
 
 		s.Close()
 		return nil
 	})
 
-	rtr := router.New(slog, s, cfg.Secret, cfg.TokenExpires)
+	credPolicy, err := policy.New(
+		cfg.LoginMinLength,
+		cfg.LoginMaxLength,
+		cfg.LoginCharset,
+		cfg.PasswordMinLength,
+		cfg.PasswordMinCharClasses,
+		cfg.PasswordRejectCommon,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to init credential policy: %w", err)
+	}
+
+	passHasher, err := hasher.New(cfg.PasswordHashAlgorithm, hasher.Argon2Params{
+		Memory:      uint32(cfg.Argon2Memory),
+		Iterations:  uint32(cfg.Argon2Iterations),
+		Parallelism: uint8(cfg.Argon2Parallelism),
+	}, cfg.BcryptCost)
+	if err != nil {
+		return fmt.Errorf("failed to init password hasher: %w", err)
+	}
+
+	broker := events.New(events.DefaultHistorySize, events.DefaultHistoryTTL)
+
+	m := metrics.New()
+	m.MustRegister(metrics.NewOrdersCollector(slog, s), metrics.NewPoolCollector(s))
+
+	rateLimits, err := parseRateLimits(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to parse rate limits: %w", err)
+	}
+	var rateLimitStore ratelimit.Store
+	switch cfg.RateLimitStore {
+	case "memory":
+		rateLimitStore = ratelimit.NewMemory()
+	case "postgres":
+		pgStore := ratelimit.NewPostgres(s)
+		rateLimitStore = pgStore
+		g.Go(func() error {
+			pruneRateLimits(ctx, slog, pgStore)
+			return nil
+		})
+	default:
+		return fmt.Errorf("unknown rate limit store %q", cfg.RateLimitStore)
+	}
+
+	checker := health.New(health.DefaultTimeout)
+	checker.Add("database", s.Ping)
+	checker.Add("migrations", s.CheckMigrations)
+
+	routerOpts := []router.Option{
+		router.WithCredentialPolicy(credPolicy),
+		router.WithPasswordHasher(passHasher),
+		router.WithTOTPIssuer(cfg.TOTPIssuer),
+		router.WithBulkOrdersLimit(cfg.BulkOrdersLimit),
+		router.WithExportLimit(cfg.ExportLimit),
+		router.WithTransferLimits(posttransfer.Limits{
+			PerTransfer: cfg.TransferMaxAmount,
+			Daily:       cfg.TransferDailyLimit,
+		}),
+		router.WithEvents(broker),
+		router.WithRateLimits(rateLimitStore, rateLimits),
+		router.WithMetrics(m),
+		router.WithHealth(checker),
+	}
+	if cfg.OIDCIssuer != "" {
+		provider, err := oidc.New(ctx, cfg.OIDCIssuer,
+			cfg.OIDCClientID, cfg.OIDCClientSecret, cfg.OIDCRedirectURL, cfg.OIDCScopes)
+		if err != nil {
+			return fmt.Errorf("failed to init oidc provider: %w", err)
+		}
+		routerOpts = append(routerOpts, router.WithOIDC(provider))
+	}
+
+	rtr := router.New(slog, s, cfg.Secret, cfg.TokenExpires, routerOpts...)
 
 	oPool := orderspool.New(slog, s, cfg.AccrualTimeout)
-	accrl := accrual.New(slog, oPool, s, cfg.AccrualAddress, cfg.WorkersCount)
+	accrl := accrual.New(slog, oPool, s, broker, cfg.AccrualAddress, cfg.WorkersCount, m)
+	checker.Add("accrual", accrl.Ping)
+	checker.Add("dispatcher", func(context.Context) error {
+		if !accrl.Running() {
+			return errors.New("dispatcher is not running")
+		}
+		return nil
+	})
 
 	g.Go(func() error {
 		err := accrl.RunService(ctx, g, &wg)
@@ -85,13 +211,35 @@ func run() error {
 		return nil
 	})
 
+	reloader, err := newCertReloader(ctx, slog, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to init tls: %w", err)
+	}
+	if reloader != nil {
+		g.Go(func() error {
+			reloader.Watch(ctx, cfg.TLSReloadInterval)
+			return nil
+		})
+	}
+
 	srv := &http.Server{
 		Addr:    cfg.Address,
 		Handler: rtr,
 	}
+	if reloader != nil {
+		srv.TLSConfig, err = servertls.ServerConfig(servertls.Config{
+			MinVersion:   cfg.TLSMinVersion,
+			CipherSuites: cfg.TLSCipherSuites,
+		}, reloader.GetCertificate)
+		if err != nil {
+			return fmt.Errorf("failed to init tls config: %w", err)
+		}
+	}
+	// Открытые потоки событий иначе держали бы Shutdown до таймаута.
+	srv.RegisterOnShutdown(broker.Close)
 	g.Go(func() error {
 		wg.Add(1)
-		err = srv.ListenAndServe()
+		err = listenAndServe(srv)
 		if err != nil {
 			return fmt.Errorf("failed to run http server: %w", err)
 		}
@@ -101,8 +249,13 @@ func run() error {
 	g.Go(func() error {
 		wg.Add(1)
 		defer wg.Done()
+		defer close(httpStopped)
 
 		<-ctx.Done()
+		// /readyz сразу начинает отвечать 503, и балансировщик успевает вывести сервер до Shutdown.
+		checker.Drain()
+		slog.InfoContext(ctx, "server is draining", "delay", cfg.ShutdownDrainDelay)
+		time.Sleep(cfg.ShutdownDrainDelay)
 		slog.InfoContext(ctx, "server has been shutdown")
 
 		shutdownTimeoutCtx, cancelShutdownTimeoutCtx := context.WithTimeout(context.Background(), timeoutServerShutdown)
@@ -113,9 +266,158 @@ func run() error {
 		return nil
 	})
 
+	if cfg.AdminAddress != "" {
+		adminSrv := &http.Server{
+			Addr:    cfg.AdminAddress,
+			Handler: router.NewAdmin(slog, m, checker),
+		}
+		if reloader != nil {
+			adminSrv.TLSConfig, err = servertls.ServerConfig(servertls.Config{
+				MinVersion:   cfg.TLSMinVersion,
+				CipherSuites: cfg.TLSCipherSuites,
+				ClientCAFile: cfg.AdminTLSClientCA,
+			}, reloader.GetCertificate)
+			if err != nil {
+				return fmt.Errorf("failed to init admin tls config: %w", err)
+			}
+		}
+		slog.DebugContext(ctx, "admin server started", "address", cfg.AdminAddress,
+			"tls", adminSrv.TLSConfig != nil, "mtls", cfg.AdminTLSClientCA != "")
+		g.Go(func() error {
+			err := listenAndServe(adminSrv)
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				return fmt.Errorf("failed to run admin server: %w", err)
+			}
+			return nil
+		})
+
+		g.Go(func() error {
+			<-ctx.Done()
+			// Пока основной сервер дослуживает запросы, оркестратор должен видеть 503 от /readyz.
+			<-httpStopped
+
+			shutdownCtx, cancelShutdownCtx := context.WithTimeout(context.Background(), timeoutServerShutdown)
+			defer cancelShutdownCtx()
+			if err := adminSrv.Shutdown(shutdownCtx); err != nil {
+				slog.ErrorContext(ctx, "failed to shutdown admin server", sl.Err(err))
+			}
+			return nil
+		})
+	}
+
+	if cfg.GRPCAddress != "" {
+		grpcSrv := grpcserver.New(slog, s, broker, cfg.Secret, cfg.TokenExpires,
+			grpcserver.WithCredentialPolicy(credPolicy),
+			grpcserver.WithPasswordHasher(passHasher),
+			grpcserver.WithRateLimits(rateLimitStore, rateLimits),
+		)
+		lis, err := net.Listen("tcp", cfg.GRPCAddress)
+		if err != nil {
+			return fmt.Errorf("failed to listen grpc address: %w", err)
+		}
+		slog.DebugContext(ctx, "grpc server started", "address", cfg.GRPCAddress)
+
+		g.Go(func() error {
+			if err := grpcSrv.Serve(lis); err != nil {
+				return fmt.Errorf("failed to run grpc server: %w", err)
+			}
+			return nil
+		})
+
+		g.Go(func() error {
+			<-ctx.Done()
+
+			// Потоки WatchOrders завершаются вместе с брокером, иначе GracefulStop ждал бы их.
+			broker.Close()
+			stopped := make(chan struct{})
+			go func() {
+				grpcSrv.GracefulStop()
+				close(stopped)
+			}()
+			select {
+			case <-stopped:
+			case <-time.After(timeoutServerShutdown):
+				grpcSrv.Stop()
+			}
+			slog.InfoContext(ctx, "grpc server has been shutdown")
+			return nil
+		})
+	}
+
 	if err := g.Wait(); err != nil {
 		return fmt.Errorf("failed to wait group: %w", err)
 	}
 
 	return nil
 }
+
+// newCertReloader loads the certificate of the HTTP listeners, generating a development one first
+// if asked to. It returns nil if TLS is not configured.
+func newCertReloader(ctx context.Context, log *slog.Logger, cfg *config.Config) (*servertls.Reloader, error) {
+	if cfg.TLSCertFile == "" {
+		return nil, nil //nolint:nilnil // plaintext HTTP is a valid configuration
+	}
+	if cfg.TLSDevCert {
+		host, _, _ := net.SplitHostPort(cfg.Address)
+		created, err := servertls.EnsureDevCert(cfg.TLSCertFile, cfg.TLSKeyFile, host)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate dev certificate: %w", err)
+		}
+		if created {
+			log.WarnContext(ctx, "self-signed dev certificate generated, do not use it in production",
+				"cert", cfg.TLSCertFile)
+		}
+	}
+	reloader, err := servertls.NewReloader(log, cfg.TLSCertFile, cfg.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load certificate: %w", err)
+	}
+	return reloader, nil
+}
+
+// listenAndServe serves HTTPS with HTTP/2 if the server has a TLS config and plaintext HTTP/1.1 otherwise.
+func listenAndServe(srv *http.Server) error {
+	if srv.TLSConfig != nil {
+		// Сертификат берётся из TLSConfig.GetCertificate.
+		return srv.ListenAndServeTLS("", "") //nolint:wrapcheck // wrapped by the caller
+	}
+	return srv.ListenAndServe() //nolint:wrapcheck // wrapped by the caller
+}
+
+func parseRateLimits(cfg *config.Config) (limits ratelimit.Limits, err error) {
+	if limits.Auth, err = ratelimit.ParseLimit(cfg.RateLimitAuth); err != nil {
+		return ratelimit.Limits{}, fmt.Errorf("RATE_LIMIT_AUTH: %w", err)
+	}
+	if limits.Orders, err = ratelimit.ParseLimit(cfg.RateLimitOrders); err != nil {
+		return ratelimit.Limits{}, fmt.Errorf("RATE_LIMIT_ORDERS: %w", err)
+	}
+	if limits.API, err = ratelimit.ParseLimit(cfg.RateLimitAPI); err != nil {
+		return ratelimit.Limits{}, fmt.Errorf("RATE_LIMIT_API: %w", err)
+	}
+	return limits, nil
+}
+
+const (
+	rateLimitPruneInterval = 10 * time.Minute
+	// rateLimitIdle must be longer than any configured period, otherwise a bucket could be dropped before it refills.
+	rateLimitIdle = time.Hour
+)
+
+// pruneRateLimits deletes buckets of clients that went away until ctx is done.
+func pruneRateLimits(ctx context.Context, log *slog.Logger, store *ratelimit.Postgres) {
+	ticker := time.NewTicker(rateLimitPruneInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := store.Prune(ctx, rateLimitIdle)
+			if err != nil {
+				log.ErrorContext(ctx, "failed to prune rate limits", sl.Err(err))
+				continue
+			}
+			log.DebugContext(ctx, "rate limits pruned", "deleted", n)
+		}
+	}
+}
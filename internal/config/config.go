@@ -9,6 +9,11 @@ import (
 	"github.com/caarlos0/env"
 )
 
+const (
+	defaultDevCertFile = "tls/dev-cert.pem"
+	defaultDevKeyFile  = "tls/dev-key.pem"
+)
+
 type Config struct {
 	Address             string        `env:"RUN_ADDRESS"`
 	Env                 string        `env:"ENV"`
@@ -19,6 +24,70 @@ type Config struct {
 	AccrualTimeout      time.Duration `env:"ACCRUALL_TIMEOUT"`
 	AccrualRetryTimeout time.Duration `env:"ACCRUAL_RETRY_TIMEOUT"`
 	WorkersCount        int32         `env:"WORKERS_COUNT"`
+
+	LoginMinLength         int    `env:"LOGIN_MIN_LENGTH" envDefault:"3"`
+	LoginMaxLength         int    `env:"LOGIN_MAX_LENGTH" envDefault:"64"`
+	LoginCharset           string `env:"LOGIN_CHARSET" envDefault:"a-zA-Z0-9._@-"`
+	PasswordMinLength      int    `env:"PASSWORD_MIN_LENGTH" envDefault:"8"`
+	PasswordMinCharClasses int    `env:"PASSWORD_MIN_CHAR_CLASSES" envDefault:"2"`
+	PasswordRejectCommon   bool   `env:"PASSWORD_REJECT_COMMON" envDefault:"true"`
+
+	PasswordHashAlgorithm string `env:"PASSWORD_HASH_ALGORITHM" envDefault:"argon2id"`
+	Argon2Memory          int    `env:"ARGON2_MEMORY" envDefault:"65536"`
+	Argon2Iterations      int    `env:"ARGON2_ITERATIONS" envDefault:"3"`
+	Argon2Parallelism     int    `env:"ARGON2_PARALLELISM" envDefault:"2"`
+	BcryptCost            int    `env:"BCRYPT_COST" envDefault:"10"`
+
+	AdminLogins []string `env:"ADMIN_LOGINS" envSeparator:","`
+
+	TOTPIssuer string `env:"TOTP_ISSUER" envDefault:"Gophermart"`
+
+	// OIDC вход включается, если задан issuer.
+	OIDCIssuer       string   `env:"OIDC_ISSUER"`
+	OIDCClientID     string   `env:"OIDC_CLIENT_ID"`
+	OIDCClientSecret string   `env:"OIDC_CLIENT_SECRET"`
+	OIDCRedirectURL  string   `env:"OIDC_REDIRECT_URL"`
+	OIDCScopes       []string `env:"OIDC_SCOPES" envSeparator:"," envDefault:"email,profile"`
+
+	BulkOrdersLimit int `env:"BULK_ORDERS_LIMIT" envDefault:"1000"`
+
+	// Каждая выгрузка держит соединение с базой, пока клиент её скачивает; 0 снимает ограничение.
+	ExportLimit int `env:"EXPORT_LIMIT" envDefault:"2"`
+
+	// Лимиты переводов баллов между пользователями; 0 отключает лимит.
+	TransferMaxAmount  float64 `env:"TRANSFER_MAX_AMOUNT" envDefault:"10000"`
+	TransferDailyLimit float64 `env:"TRANSFER_DAILY_LIMIT" envDefault:"50000"`
+
+	// gRPC API включается, если задан адрес.
+	GRPCAddress string `env:"GRPC_ADDRESS"`
+
+	// Сколько /readyz отвечает 503 перед остановкой сервера, чтобы балансировщик успел его вывести.
+	ShutdownDrainDelay time.Duration `env:"SHUTDOWN_DRAIN_DELAY" envDefault:"2s"`
+
+	// Служебный листенер с /metrics, /healthz и /readyz; наружу его не публикуют.
+	AdminAddress string `env:"ADMIN_ADDRESS" envDefault:"localhost:9090"`
+
+	// HTTPS включается, если заданы сертификат и ключ. Они перечитываются при изменении файлов.
+	// TLS_DEV_CERT выпускает самоподписанный сертификат, если файлов ещё нет; только для разработки.
+	TLSCertFile       string        `env:"TLS_CERT_FILE"`
+	TLSKeyFile        string        `env:"TLS_KEY_FILE"`
+	TLSDevCert        bool          `env:"TLS_DEV_CERT"`
+	TLSMinVersion     string        `env:"TLS_MIN_VERSION" envDefault:"1.2"`
+	TLSCipherSuites   []string      `env:"TLS_CIPHER_SUITES" envSeparator:","`
+	TLSReloadInterval time.Duration `env:"TLS_RELOAD_INTERVAL" envDefault:"10s"`
+	// Клиенты служебного листенера должны предъявить сертификат, подписанный этим CA (mTLS).
+	AdminTLSClientCA string `env:"ADMIN_TLS_CLIENT_CA"`
+
+	// Экспорт трасс: none, otlp (настраивается стандартными OTEL_EXPORTER_OTLP_*), stdout или file.
+	TracingExporter    string  `env:"TRACING_EXPORTER" envDefault:"none"`
+	TracingFile        string  `env:"TRACING_FILE" envDefault:"traces.jsonl"`
+	TracingSampleRatio float64 `env:"TRACING_SAMPLE_RATIO" envDefault:"1"`
+
+	// Лимиты в формате "запросы/период[:burst]", например "60/1m:10"; "0" отключает лимит.
+	RateLimitStore  string `env:"RATE_LIMIT_STORE" envDefault:"memory"`
+	RateLimitAuth   string `env:"RATE_LIMIT_AUTH" envDefault:"20/1m"`
+	RateLimitOrders string `env:"RATE_LIMIT_ORDERS" envDefault:"60/1m"`
+	RateLimitAPI    string `env:"RATE_LIMIT_API" envDefault:"600/1m"`
 }
 
 func New() (config *Config, err error) {
@@ -28,7 +97,8 @@ func New() (config *Config, err error) {
 		return nil, fmt.Errorf("failed to parse config %w", err)
 	}
 
-	var flagAddress, flagDsn, flagAccrualAddress, flagSecret string
+	var flagAddress, flagDsn, flagAccrualAddress, flagSecret, flagGRPCAddress, flagAdminAddress,
+		flagTLSCertFile, flagTLSKeyFile string
 	var flagTokenExpires, defaultTokenLifeTime, flagAccrualTimeout, defaultAccrualTimeout,
 		flagWorkersCount, flagAccrualRetryTimeout int64
 	defaultTokenLifeTime = 3
@@ -37,6 +107,10 @@ func New() (config *Config, err error) {
 	flag.StringVar(&flagDsn, "d", "", "db connection string")
 	flag.StringVar(&flagAccrualAddress, "r", "", "accrual address")
 	flag.StringVar(&flagSecret, "s", "secret", "token secret")
+	flag.StringVar(&flagGRPCAddress, "g", "", "grpc address and port")
+	flag.StringVar(&flagAdminAddress, "m", "", "admin (metrics) address and port")
+	flag.StringVar(&flagTLSCertFile, "c", "", "tls certificate file")
+	flag.StringVar(&flagTLSKeyFile, "k", "", "tls key file")
 	flag.Int64Var(&flagTokenExpires, "e", defaultTokenLifeTime, "token expires (hours)")
 	flag.Int64Var(&flagAccrualTimeout, "t", defaultAccrualTimeout, "timeout for accrual requests (seconds)")
 	flag.Int64Var(&flagWorkersCount, "w", 1, "number of workers")
@@ -49,6 +123,22 @@ func New() (config *Config, err error) {
 		cfg.Address = flagAddress
 	}
 
+	if flagGRPCAddress != "" {
+		cfg.GRPCAddress = flagGRPCAddress
+	}
+
+	if flagAdminAddress != "" {
+		cfg.AdminAddress = flagAdminAddress
+	}
+
+	if flagTLSCertFile != "" {
+		cfg.TLSCertFile = flagTLSCertFile
+	}
+
+	if flagTLSKeyFile != "" {
+		cfg.TLSKeyFile = flagTLSKeyFile
+	}
+
 	if flagDsn != "" {
 		cfg.DSN = flagDsn
 	}
@@ -81,5 +171,22 @@ func New() (config *Config, err error) {
 		return &Config{}, errors.New("db connection string not set")
 	}
 
+	if cfg.TLSDevCert {
+		if cfg.TLSCertFile == "" {
+			cfg.TLSCertFile = defaultDevCertFile
+		}
+		if cfg.TLSKeyFile == "" {
+			cfg.TLSKeyFile = defaultDevKeyFile
+		}
+	}
+
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return &Config{}, errors.New("tls certificate and key must be set together")
+	}
+
+	if cfg.AdminTLSClientCA != "" && cfg.TLSCertFile == "" {
+		return &Config{}, errors.New("admin client CA requires tls certificate and key")
+	}
+
 	return &cfg, nil
 }
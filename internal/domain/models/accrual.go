@@ -5,3 +5,11 @@ type Accrual struct {
 	Status   OrderStatus `json:"status"`
 	Accrual  float64     `json:"accrual"`
 }
+
+// AccrualResult describes what changed for the user when an accrual was applied.
+type AccrualResult struct {
+	UserLogin      string
+	PreviousStatus OrderStatus
+	Order          Order
+	Balance        Balance
+}
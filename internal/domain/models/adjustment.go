@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// BalanceAdjustment is a manual balance change made by an admin. Every adjustment is kept as an audit record.
+type BalanceAdjustment struct {
+	CreatedAt         time.Time `json:"-"`
+	CreatedAtFormated string    `json:"created_at"`
+	UserLogin         string    `json:"user_login"`
+	AdminLogin        string    `json:"admin_login"`
+	Reason            string    `json:"reason"`
+	ID                int64     `json:"id"`
+	Amount            float64   `json:"amount"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+type Scope string
+
+const (
+	ScopeOrdersWrite Scope = "orders:write"
+	ScopeBalanceRead Scope = "balance:read"
+	ScopeWithdraw    Scope = "withdraw"
+)
+
+// Scopes lists every scope an API key may be granted.
+var Scopes = []Scope{ScopeOrdersWrite, ScopeBalanceRead, ScopeWithdraw}
+
+// APIKey is a personal key for scripted access. Only the hash of the key is stored.
+type APIKey struct {
+	CreatedAt          time.Time  `json:"-"`
+	LastUsedAt         *time.Time `json:"-"`
+	CreatedAtFormated  string     `json:"created_at"`
+	LastUsedAtFormated string     `json:"last_used_at,omitempty"`
+	UserLogin          string     `json:"-"`
+	Name               string     `json:"name"`
+	Prefix             string     `json:"prefix"`
+	KeyHash            string     `json:"-"`
+	Scopes             []Scope    `json:"scopes"`
+	ID                 int64      `json:"id"`
+}
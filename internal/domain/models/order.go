@@ -19,4 +19,21 @@ type Order struct {
 	UserLogin          string      `json:"-"`
 	Status             OrderStatus `json:"status"`
 	Accrual            float64     `json:"accrual,omitempty"`
+	// TraceParent связывает обработку заказа с трассой его загрузки.
+	TraceParent string `json:"-"`
+}
+
+// UploadResult is the outcome of uploading one number in a bulk upload.
+type UploadResult string
+
+const (
+	UploadAccepted     UploadResult = "accepted"
+	UploadAlreadyYours UploadResult = "already_yours"
+	UploadConflict     UploadResult = "conflict"
+	UploadInvalid      UploadResult = "invalid"
+)
+
+type OrderUpload struct {
+	Number string       `json:"number"`
+	Result UploadResult `json:"result"`
 }
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// Session is a login of a user on some device. Tokens bound to a revoked session are rejected.
+type Session struct {
+	CreatedAt          time.Time `json:"-"`
+	LastUsedAt         time.Time `json:"-"`
+	CreatedAtFormated  string    `json:"created_at"`
+	LastUsedAtFormated string    `json:"last_used_at"`
+	UserLogin          string    `json:"-"`
+	Device             string    `json:"device,omitempty"`
+	UserAgent          string    `json:"user_agent,omitempty"`
+	IP                 string    `json:"ip,omitempty"`
+	ID                 int64     `json:"id"`
+	Current            bool      `json:"current"`
+}
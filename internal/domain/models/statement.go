@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// Kinds of statement entries.
+const (
+	EntryAccrual     = "accrual"
+	EntryWithdrawal  = "withdrawal"
+	EntryAdjustment  = "adjustment"
+	EntryTransferIn  = "transfer_in"
+	EntryTransferOut = "transfer_out"
+
+	// EntryOpening and EntryClosing carry the balances at the ends of an exported statement.
+	EntryOpening = "opening_balance"
+	EntryClosing = "closing_balance"
+)
+
+// StatementEntry is one change of the balance.
+type StatementEntry struct {
+	At         time.Time `json:"-"`
+	AtFormated string    `json:"at"`
+	Type       string    `json:"type"`
+	// Reference is the order number for accruals and withdrawals, the adjustment ID for adjustments
+	// and the other user's login for transfers.
+	Reference string `json:"reference"`
+	// Amount is positive for credits and negative for debits.
+	Amount float64 `json:"amount"`
+	// Balance is the running balance after the entry.
+	Balance float64 `json:"balance"`
+}
+
+// Statement lists the balance changes in [From, To) in chronological order.
+type Statement struct {
+	From           time.Time        `json:"-"`
+	To             time.Time        `json:"-"`
+	FromFormated   string           `json:"from,omitempty"`
+	ToFormated     string           `json:"to"`
+	Entries        []StatementEntry `json:"entries"`
+	OpeningBalance float64          `json:"opening_balance"`
+	ClosingBalance float64          `json:"closing_balance"`
+}
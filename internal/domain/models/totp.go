@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// TOTP is the second factor of a user. It is enforced on login only after ConfirmedAt is set.
+type TOTP struct {
+	ConfirmedAt  *time.Time
+	UserLogin    string
+	Secret       string
+	LastUsedStep int64
+}
+
+func (t TOTP) Confirmed() bool {
+	return t.ConfirmedAt != nil
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Transfer directions as seen by the user whose history is listed.
+const (
+	TransferIn  = "in"
+	TransferOut = "out"
+)
+
+// Transfer moves points from one user to another. It is shown in the history of both.
+type Transfer struct {
+	CreatedAt         time.Time `json:"-"`
+	CreatedAtFormated string    `json:"created_at"`
+	From              string    `json:"from"`
+	To                string    `json:"to"`
+	// Direction is set when listing a user's transfers.
+	Direction      string  `json:"direction,omitempty"`
+	IdempotencyKey string  `json:"-"`
+	ID             int64   `json:"id"`
+	Amount         float64 `json:"amount"`
+}
@@ -1,7 +1,15 @@
 package models
 
+type Role string
+
+const (
+	RoleUser  Role = "user"
+	RoleAdmin Role = "admin"
+)
+
 type User struct {
-	Login    string
-	PassHash []byte
-	Balance  int64
+	Login    string  `json:"login"`
+	Role     Role    `json:"role"`
+	PassHash []byte  `json:"-"`
+	Balance  float64 `json:"balance"`
 }
@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// Version identifies the state of a user's resource without reading the resource itself.
+// Counter is the number of rows for lists and the change counter for the balance.
+type Version struct {
+	LastModified time.Time
+	Counter      int64
+}
@@ -0,0 +1,74 @@
+// Package grpcerr builds gRPC statuses that carry the same machine-readable codes as the HTTP problems.
+package grpcerr
+
+import (
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Domain is set on every ErrorInfo detail.
+const Domain = "gophermart"
+
+// New returns a status error with an ErrorInfo detail whose reason is one of the problem codes.
+func New(c codes.Code, reason string, msg string) error {
+	return withDetails(c, msg, &errdetails.ErrorInfo{Reason: reason, Domain: Domain})
+}
+
+// Internal hides the cause from the client, the same way problem.Internal does.
+func Internal() error {
+	return status.Error(codes.Internal, "internal server error")
+}
+
+// RateLimited is codes.ResourceExhausted with a RetryInfo detail, the counterpart of 429 with Retry-After.
+func RateLimited(reason string, msg string, retryAfter time.Duration) error {
+	return withDetails(codes.ResourceExhausted, msg,
+		&errdetails.ErrorInfo{Reason: reason, Domain: Domain},
+		&errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)})
+}
+
+// FieldViolation describes one invalid field of the request.
+type FieldViolation struct {
+	Field   string
+	Message string
+}
+
+// InvalidArgument lists every broken field in a BadRequest detail.
+func InvalidArgument(reason string, msg string, violations []FieldViolation) error {
+	br := &errdetails.BadRequest{}
+	for _, v := range violations {
+		br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       v.Field,
+			Description: v.Message,
+		})
+	}
+	return withDetails(codes.InvalidArgument, msg,
+		&errdetails.ErrorInfo{Reason: reason, Domain: Domain}, br)
+}
+
+// Reason returns the ErrorInfo reason of err, or an empty string.
+func Reason(err error) string {
+	st, ok := status.FromError(err)
+	if !ok {
+		return ""
+	}
+	for _, d := range st.Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok {
+			return info.GetReason()
+		}
+	}
+	return ""
+}
+
+func withDetails(c codes.Code, msg string, details ...protoadapt.MessageV1) error {
+	st := status.New(c, msg)
+	detailed, err := st.WithDetails(details...)
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
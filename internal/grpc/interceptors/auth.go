@@ -0,0 +1,123 @@
+package interceptors
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/grpc/grpcerr"
+	"github.com/VanGoghDev/gophermart/internal/lib/logger/sl"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/VanGoghDev/gophermart/internal/middleware/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// TokenKey is the metadata key of the token, the same one the HTTP API reads.
+	TokenKey = "authorization"
+	// APIKeyKey is the metadata key of a personal API key.
+	APIKeyKey = "x-api-key"
+)
+
+// Auth checks credentials of incoming calls with the same rules as the HTTP auth middleware.
+type Auth struct {
+	log      *slog.Logger
+	keys     auth.APIKeyProvider
+	sessions auth.SessionProvider
+	public   map[string]bool
+	scopes   map[string][]models.Scope
+	secret   string
+}
+
+// NewAuth lets public methods through without credentials. API keys may call only the methods listed in scopes
+// and only if they have every scope listed for the method; tokens may call anything.
+func NewAuth(
+	log *slog.Logger,
+	secret string,
+	keys auth.APIKeyProvider,
+	sessions auth.SessionProvider,
+	public []string,
+	scopes map[string][]models.Scope,
+) *Auth {
+	a := &Auth{
+		log:      log,
+		keys:     keys,
+		sessions: sessions,
+		public:   make(map[string]bool, len(public)),
+		scopes:   scopes,
+		secret:   secret,
+	}
+	for _, m := range public {
+		a.public[m] = true
+	}
+	return a
+}
+
+func (a *Auth) Unary() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		ctx, err := a.authenticate(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func (a *Auth) Stream() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := a.authenticate(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func (a *Auth) authenticate(ctx context.Context, method string) (context.Context, error) {
+	if a.public[method] {
+		return ctx, nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	p, authErr := auth.Authenticate(ctx, a.secret, a.keys, a.sessions, first(md, TokenKey), first(md, APIKeyKey))
+	if authErr != nil {
+		if authErr.Internal() {
+			a.log.ErrorContext(ctx, "failed to authorize", "method", method, sl.Err(authErr))
+			return nil, grpcerr.Internal()
+		}
+		a.log.InfoContext(ctx, "authorization failed", "method", method, sl.Err(authErr))
+		return nil, grpcerr.New(codes.Unauthenticated, authErr.Code, authErr.Detail)
+	}
+
+	if !auth.ScopeAllowed(p.Scopes, p.IsAPIKey, a.scopes[method]...) {
+		a.log.InfoContext(ctx, "api key scope denied", "method", method, "required", a.scopes[method])
+		return nil, grpcerr.New(codes.PermissionDenied, problem.CodeInsufficientScope,
+			"api key lacks the scope required for this method")
+	}
+
+	return auth.WithPrincipal(ctx, p), nil
+}
+
+func first(md metadata.MD, key string) string {
+	if v := md.Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// serverStream replaces the context of the stream with the authenticated one.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
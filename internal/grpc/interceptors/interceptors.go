@@ -0,0 +1,78 @@
+// Package interceptors holds the gRPC counterparts of the HTTP middlewares.
+package interceptors
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/grpc/grpcerr"
+	"github.com/VanGoghDev/gophermart/internal/lib/logger/sl"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryLogger logs every call with its status code and duration.
+func UnaryLogger(log *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		log.InfoContext(ctx, "grpc call",
+			"method", info.FullMethod,
+			"code", status.Code(err).String(),
+			"duration", time.Since(start))
+		return resp, err
+	}
+}
+
+// StreamLogger logs every stream when it ends.
+func StreamLogger(log *slog.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		log.InfoContext(ss.Context(), "grpc stream",
+			"method", info.FullMethod,
+			"code", status.Code(err).String(),
+			"duration", time.Since(start))
+		return err
+	}
+}
+
+// UnaryRecoverer turns a panic in a handler into codes.Internal instead of crashing the server.
+func UnaryRecoverer(log *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (resp any, err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				log.ErrorContext(ctx, "grpc handler panicked",
+					"method", info.FullMethod, sl.Err(fmt.Errorf("%v", p)))
+				err = grpcerr.Internal()
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// StreamRecoverer is UnaryRecoverer for streams.
+func StreamRecoverer(log *slog.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				log.ErrorContext(ss.Context(), "grpc handler panicked",
+					"method", info.FullMethod, sl.Err(fmt.Errorf("%v", p)))
+				err = grpcerr.Internal()
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
@@ -0,0 +1,126 @@
+package interceptors
+
+import (
+	"context"
+	"log/slog"
+	"net"
+
+	"github.com/VanGoghDev/gophermart/internal/grpc/grpcerr"
+	"github.com/VanGoghDev/gophermart/internal/lib/logger/sl"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/VanGoghDev/gophermart/internal/middleware/auth"
+	"github.com/VanGoghDev/gophermart/internal/services/ratelimit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+)
+
+// RateLimit limits call rates with the same buckets as the throttle middleware, so that a client
+// can't get around the HTTP limits by switching to gRPC.
+type RateLimit struct {
+	log    *slog.Logger
+	store  ratelimit.Store
+	public map[string]bool
+	orders map[string]bool
+	limits ratelimit.Limits
+}
+
+// NewRateLimit limits public methods per client address with the auth limit and the rest per user
+// with the API limit. Methods listed in orders also take a token of the orders limit.
+// Must be chained after the auth interceptor.
+func NewRateLimit(
+	log *slog.Logger,
+	store ratelimit.Store,
+	limits ratelimit.Limits,
+	public []string,
+	orders []string,
+) *RateLimit {
+	l := &RateLimit{
+		log:    log,
+		store:  store,
+		public: make(map[string]bool, len(public)),
+		orders: make(map[string]bool, len(orders)),
+		limits: limits,
+	}
+	for _, m := range public {
+		l.public[m] = true
+	}
+	for _, m := range orders {
+		l.orders[m] = true
+	}
+	return l
+}
+
+func (l *RateLimit) Unary() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		if err := l.take(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func (l *RateLimit) Stream() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := l.take(ss.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func (l *RateLimit) take(ctx context.Context, method string) error {
+	if l.public[method] {
+		return l.takeFrom(ctx, method, "auth", l.limits.Auth, byIP(ctx))
+	}
+	key := byLogin(ctx)
+	if err := l.takeFrom(ctx, method, "api", l.limits.API, key); err != nil {
+		return err
+	}
+	if l.orders[method] {
+		return l.takeFrom(ctx, method, "orders", l.limits.Orders, key)
+	}
+	return nil
+}
+
+// takeFrom takes a token from the bucket of the group. Store failures let the call through, as in throttle.
+func (l *RateLimit) takeFrom(ctx context.Context, method string, group string, limit ratelimit.Limit, key string) error {
+	if limit.Disabled() {
+		return nil
+	}
+	res, err := l.store.Take(ctx, group+":"+key, limit)
+	if err != nil {
+		l.log.ErrorContext(ctx, "failed to check rate limit", "method", method, "group", group, sl.Err(err))
+		return nil
+	}
+	if !res.Allowed {
+		l.log.InfoContext(ctx, "rate limit exceeded", "method", method, "group", group, "key", key)
+		return grpcerr.RateLimited(problem.CodeRateLimited, "too many requests, retry later", res.RetryAfter)
+	}
+	return nil
+}
+
+// byIP and byLogin build the same keys as throttle.ByIP and throttle.ByLogin.
+func byIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return "ip:unknown"
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return "ip:" + p.Addr.String()
+	}
+	return "ip:" + host
+}
+
+func byLogin(ctx context.Context) string {
+	login, err := auth.LoginFromContext(ctx)
+	if err != nil {
+		return byIP(ctx)
+	}
+	return "user:" + login
+}
@@ -0,0 +1,170 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/grpc/grpcerr"
+	pb "github.com/VanGoghDev/gophermart/internal/grpc/pb/gophermart/v1"
+	hauth "github.com/VanGoghDev/gophermart/internal/handlers/auth"
+	"github.com/VanGoghDev/gophermart/internal/lib/logger/sl"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/VanGoghDev/gophermart/internal/services/auth"
+	"github.com/VanGoghDev/gophermart/internal/storage"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func (s *Server) Register(ctx context.Context, req *pb.RegisterRequest) (*pb.RegisterResponse, error) {
+	if violations := s.policy.Validate(req.GetLogin(), req.GetPassword()); len(violations) > 0 {
+		fields := make([]grpcerr.FieldViolation, 0, len(violations))
+		for _, v := range violations {
+			fields = append(fields, grpcerr.FieldViolation{Field: v.Field, Message: v.Message})
+		}
+		return nil, grpcerr.InvalidArgument(problem.CodeCredentialPolicy,
+			"credentials do not satisfy the policy", fields)
+	}
+
+	passHash, err := s.hasher.Hash(req.GetPassword())
+	if err != nil {
+		s.log.ErrorContext(ctx, "failed to hash password", sl.Err(err))
+		return nil, grpcerr.Internal()
+	}
+
+	login, err := s.storage.RegisterUser(ctx, req.GetLogin(), passHash)
+	if err != nil {
+		if errors.Is(err, storage.ErrAlreadyExists) {
+			return nil, grpcerr.New(codes.AlreadyExists, problem.CodeLoginTaken, "login is already taken")
+		}
+		s.log.ErrorContext(ctx, "failed to register user", sl.Err(err))
+		return nil, grpcerr.Internal()
+	}
+
+	token, err := s.issueToken(ctx, login, models.RoleUser, req.GetDevice())
+	if err != nil {
+		s.log.ErrorContext(ctx, "failed to grant token", sl.Err(err))
+		return nil, grpcerr.Internal()
+	}
+	return &pb.RegisterResponse{Token: token}, nil
+}
+
+func (s *Server) Login(ctx context.Context, req *pb.LoginRequest) (*pb.LoginResponse, error) {
+	if req.GetLogin() == "" || req.GetPassword() == "" {
+		return nil, grpcerr.New(codes.InvalidArgument, problem.CodeValidation, "login and password are required")
+	}
+
+	// Неизвестный логин и неверный пароль не различаются.
+	user, err := s.storage.GetUser(ctx, req.GetLogin())
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, invalidCredentials()
+		}
+		s.log.ErrorContext(ctx, "failed to get user", sl.Err(err))
+		return nil, grpcerr.Internal()
+	}
+
+	ok, err := s.hasher.Verify(req.GetPassword(), string(user.PassHash))
+	if err != nil || !ok {
+		s.log.InfoContext(ctx, "invalid credentials", "login", user.Login)
+		return nil, invalidCredentials()
+	}
+
+	if s.hasher.NeedsRehash(string(user.PassHash)) {
+		hauth.RehashPassword(ctx, s.log, s.storage, s.hasher, user.Login, req.GetPassword())
+	}
+
+	t, err := s.storage.GetTOTP(ctx, user.Login)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		s.log.ErrorContext(ctx, "failed to get totp", sl.Err(err))
+		return nil, grpcerr.Internal()
+	}
+	if err == nil && t.Confirmed() {
+		challenge, err := auth.GrantChallengeToken(user.Login, s.secret, auth.ChallengeTTL)
+		if err != nil {
+			s.log.ErrorContext(ctx, "failed to generate challenge token", sl.Err(err))
+			return nil, grpcerr.Internal()
+		}
+		return &pb.LoginResponse{Result: &pb.LoginResponse_Challenge{Challenge: &pb.Challenge{
+			ChallengeToken: challenge,
+			ExpiresIn:      int32(auth.ChallengeTTL.Seconds()),
+		}}}, nil
+	}
+
+	token, err := s.issueToken(ctx, user.Login, user.Role, req.GetDevice())
+	if err != nil {
+		s.log.ErrorContext(ctx, "failed to generate auth token", sl.Err(err))
+		return nil, grpcerr.Internal()
+	}
+	return &pb.LoginResponse{Result: &pb.LoginResponse_Token{Token: token}}, nil
+}
+
+func (s *Server) LoginTOTP(ctx context.Context, req *pb.LoginTOTPRequest) (*pb.LoginTOTPResponse, error) {
+	if req.GetChallengeToken() == "" || (req.GetCode() == "" && req.GetRecoveryCode() == "") {
+		return nil, grpcerr.New(codes.InvalidArgument, problem.CodeValidation,
+			"challenge_token and either code or recovery_code are required")
+	}
+
+	challenge, err := auth.ParseChallengeToken(req.GetChallengeToken(), s.secret)
+	if err != nil {
+		s.log.InfoContext(ctx, "invalid challenge token", sl.Err(err))
+		return nil, grpcerr.New(codes.Unauthenticated, problem.CodeInvalidChallenge,
+			"challenge token is invalid or expired")
+	}
+
+	login := challenge.Login
+	ok, err := hauth.PassChallenge(ctx, s.storage, challenge, req.GetCode(), req.GetRecoveryCode())
+	if errors.Is(err, hauth.ErrChallengeUsedUp) {
+		s.log.InfoContext(ctx, "challenge token is used up", "login", login)
+		return nil, grpcerr.New(codes.Unauthenticated, problem.CodeInvalidChallenge,
+			"challenge token is used up, sign in again")
+	}
+	if err != nil {
+		s.log.ErrorContext(ctx, "failed to verify second factor", sl.Err(err))
+		return nil, grpcerr.Internal()
+	}
+	if !ok {
+		s.log.InfoContext(ctx, "invalid second factor", "login", login)
+		return nil, grpcerr.New(codes.Unauthenticated, problem.CodeInvalidSecondFactor,
+			"code is invalid or was already used")
+	}
+
+	user, err := s.storage.GetUser(ctx, login)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, grpcerr.New(codes.Unauthenticated, problem.CodeInvalidCredentials, "user no longer exists")
+		}
+		s.log.ErrorContext(ctx, "failed to get user", sl.Err(err))
+		return nil, grpcerr.Internal()
+	}
+
+	token, err := s.issueToken(ctx, user.Login, user.Role, req.GetDevice())
+	if err != nil {
+		s.log.ErrorContext(ctx, "failed to generate auth token", sl.Err(err))
+		return nil, grpcerr.Internal()
+	}
+	return &pb.LoginTOTPResponse{Token: token}, nil
+}
+
+// issueToken starts a session for the peer the call came from.
+func (s *Server) issueToken(ctx context.Context, login string, role models.Role, device string) (string, error) {
+	session := models.Session{UserLogin: login, Device: device}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ua := md.Get("user-agent"); len(ua) > 0 {
+			session.UserAgent = ua[0]
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		session.IP = p.Addr.String()
+		if host, _, err := net.SplitHostPort(session.IP); err == nil {
+			session.IP = host
+		}
+	}
+	return hauth.IssueToken(ctx, s.storage, session, role, s.secret, s.tokenExpires) //nolint:wrapcheck // already wrapped
+}
+
+func invalidCredentials() error {
+	return grpcerr.New(codes.Unauthenticated, problem.CodeInvalidCredentials, "invalid login or password")
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"errors"
+
+	"github.com/VanGoghDev/gophermart/internal/grpc/grpcerr"
+	pb "github.com/VanGoghDev/gophermart/internal/grpc/pb/gophermart/v1"
+	"github.com/VanGoghDev/gophermart/internal/lib/logger/sl"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/VanGoghDev/gophermart/internal/storage"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (s *Server) GetBalance(ctx context.Context, _ *pb.GetBalanceRequest) (*pb.GetBalanceResponse, error) {
+	userLogin, err := s.login(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	balance, err := s.storage.GetBalance(ctx, userLogin)
+	if err != nil {
+		// У пользователя ещё нет баланса.
+		if errors.Is(err, storage.ErrNotFound) {
+			return &pb.GetBalanceResponse{Balance: &pb.Balance{}}, nil
+		}
+		s.log.ErrorContext(ctx, "failed to get balance from storage", sl.Err(err))
+		return nil, grpcerr.Internal()
+	}
+	return &pb.GetBalanceResponse{Balance: &pb.Balance{
+		Current:   balance.Current,
+		Withdrawn: balance.Withdrawn,
+	}}, nil
+}
+
+func (s *Server) Withdraw(ctx context.Context, req *pb.WithdrawRequest) (*pb.WithdrawResponse, error) {
+	userLogin, err := s.login(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var violations []grpcerr.FieldViolation
+	if req.GetOrder() == "" {
+		violations = append(violations, grpcerr.FieldViolation{Field: "order", Message: "order is required"})
+	}
+	if req.GetSum() <= 0 {
+		violations = append(violations, grpcerr.FieldViolation{Field: "sum", Message: "sum must be greater than 0"})
+	}
+	if len(violations) > 0 {
+		return nil, grpcerr.InvalidArgument(problem.CodeValidation, "request validation failed", violations)
+	}
+
+	err = s.storage.SaveWithdrawal(ctx, userLogin, req.GetOrder(), req.GetSum())
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return &pb.WithdrawResponse{}, nil
+		}
+		if errors.Is(err, storage.ErrNotEnoughFunds) {
+			return nil, grpcerr.New(codes.FailedPrecondition, problem.CodeNotEnoughFunds, "not enough funds")
+		}
+		s.log.ErrorContext(ctx, "failed to save withdrawal", sl.Err(err))
+		return nil, grpcerr.Internal()
+	}
+	return &pb.WithdrawResponse{}, nil
+}
+
+func (s *Server) ListWithdrawals(
+	ctx context.Context,
+	_ *pb.ListWithdrawalsRequest,
+) (*pb.ListWithdrawalsResponse, error) {
+	userLogin, err := s.login(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	withdrawals, err := s.storage.GetWithdrawals(ctx, userLogin)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return &pb.ListWithdrawalsResponse{}, nil
+		}
+		s.log.ErrorContext(ctx, "failed to fetch withdrawals", sl.Err(err))
+		return nil, grpcerr.Internal()
+	}
+
+	resp := &pb.ListWithdrawalsResponse{Withdrawals: make([]*pb.Withdrawal, 0, len(withdrawals))}
+	for _, w := range withdrawals {
+		withdrawal := &pb.Withdrawal{Order: w.OrderNumber, Sum: w.Sum}
+		if !w.ProcessedAt.IsZero() {
+			withdrawal.ProcessedAt = timestamppb.New(w.ProcessedAt)
+		}
+		resp.Withdrawals = append(resp.Withdrawals, withdrawal)
+	}
+	return resp, nil
+}
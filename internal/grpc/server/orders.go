@@ -0,0 +1,150 @@
+package server
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ShiraazMoollatjie/goluhn"
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/grpc/grpcerr"
+	pb "github.com/VanGoghDev/gophermart/internal/grpc/pb/gophermart/v1"
+	"github.com/VanGoghDev/gophermart/internal/lib/logger/sl"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/VanGoghDev/gophermart/internal/middleware/auth"
+	"github.com/VanGoghDev/gophermart/internal/services/events"
+	"github.com/VanGoghDev/gophermart/internal/storage"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (s *Server) UploadOrder(ctx context.Context, req *pb.UploadOrderRequest) (*pb.UploadOrderResponse, error) {
+	userLogin, err := s.login(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := goluhn.Validate(req.GetNumber()); err != nil {
+		return nil, grpcerr.New(codes.InvalidArgument, problem.CodeInvalidOrderNumber,
+			"order number failed the Luhn check")
+	}
+
+	err = s.storage.SaveOrder(ctx, req.GetNumber(), userLogin, models.New)
+	if err != nil {
+		if errors.Is(err, storage.ErrGoodConflict) || errors.Is(err, storage.ErrAlreadyExists) {
+			return &pb.UploadOrderResponse{Accepted: false}, nil
+		}
+		if errors.Is(err, storage.ErrConflict) {
+			return nil, grpcerr.New(codes.AlreadyExists, problem.CodeOrderConflict,
+				"order was already uploaded by another user")
+		}
+		s.log.ErrorContext(ctx, "failed to save order", sl.Err(err))
+		return nil, grpcerr.Internal()
+	}
+	return &pb.UploadOrderResponse{Accepted: true}, nil
+}
+
+func (s *Server) ListOrders(ctx context.Context, _ *pb.ListOrdersRequest) (*pb.ListOrdersResponse, error) {
+	userLogin, err := s.login(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	orders, err := s.storage.GetOrders(ctx, userLogin)
+	if err != nil {
+		// HTTP отвечает 204, здесь просто пустой список.
+		if errors.Is(err, storage.ErrNotFound) {
+			return &pb.ListOrdersResponse{}, nil
+		}
+		s.log.ErrorContext(ctx, "failed to get orders from storage", sl.Err(err))
+		return nil, grpcerr.Internal()
+	}
+
+	resp := &pb.ListOrdersResponse{Orders: make([]*pb.Order, 0, len(orders))}
+	for _, o := range orders {
+		resp.Orders = append(resp.Orders, toOrder(o))
+	}
+	return resp, nil
+}
+
+func (s *Server) GetOrder(ctx context.Context, req *pb.GetOrderRequest) (*pb.GetOrderResponse, error) {
+	userLogin, err := s.login(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := s.storage.GetOrder(ctx, req.GetNumber())
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, grpcerr.New(codes.NotFound, problem.CodeOrderNotFound, "order not found")
+		}
+		s.log.ErrorContext(ctx, "failed to get order from storage", sl.Err(err))
+		return nil, grpcerr.Internal()
+	}
+	if order.UserLogin != userLogin {
+		return nil, grpcerr.New(codes.PermissionDenied, problem.CodeOrderForbidden,
+			"order was uploaded by another user")
+	}
+	return &pb.GetOrderResponse{Order: toOrder(order)}, nil
+}
+
+// WatchOrders sends every status change of the user's orders until the client goes away.
+// When the stream falls behind or the server shuts down it ends with Unavailable, and the client
+// is expected to call ListOrders and watch again.
+func (s *Server) WatchOrders(_ *pb.WatchOrdersRequest, stream pb.GophermartService_WatchOrdersServer) error {
+	ctx := stream.Context()
+	userLogin, err := s.login(ctx)
+	if err != nil {
+		return err
+	}
+
+	sub := s.sub.Subscribe(userLogin, 0)
+	defer sub.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case e, ok := <-sub.C:
+			if !ok {
+				return grpcerr.New(codes.Unavailable, problem.CodeInternal, "stream closed, watch again")
+			}
+			order, ok := e.Data.(models.Order)
+			if e.Type != events.TypeOrder || !ok {
+				continue
+			}
+			if err := stream.Send(&pb.WatchOrdersResponse{Order: toOrder(order)}); err != nil {
+				return err //nolint:wrapcheck // status errors must reach the client as is
+			}
+		}
+	}
+}
+
+// login returns the user the interceptor authenticated.
+func (s *Server) login(ctx context.Context) (string, error) {
+	userLogin, err := auth.LoginFromContext(ctx)
+	if err != nil {
+		s.log.ErrorContext(ctx, "failed to fetch user login from context")
+		return "", grpcerr.Internal()
+	}
+	return userLogin, nil
+}
+
+var orderStatuses = map[models.OrderStatus]pb.OrderStatus{
+	models.New:        pb.OrderStatus_ORDER_STATUS_NEW,
+	models.Registered: pb.OrderStatus_ORDER_STATUS_REGISTERED,
+	models.Invalid:    pb.OrderStatus_ORDER_STATUS_INVALID,
+	models.Processing: pb.OrderStatus_ORDER_STATUS_PROCESSING,
+	models.Processed:  pb.OrderStatus_ORDER_STATUS_PROCESSED,
+}
+
+func toOrder(o models.Order) *pb.Order {
+	order := &pb.Order{
+		Number:  o.Number,
+		Status:  orderStatuses[o.Status],
+		Accrual: o.Accrual,
+	}
+	if !o.UploadedAt.IsZero() {
+		order.UploadedAt = timestamppb.New(o.UploadedAt)
+	}
+	return order
+}
@@ -0,0 +1,161 @@
+// Package server implements the gRPC API. It mirrors the /api/user HTTP endpoints
+// on top of the same storage and auth rules.
+package server
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/grpc/interceptors"
+	pb "github.com/VanGoghDev/gophermart/internal/grpc/pb/gophermart/v1"
+	"github.com/VanGoghDev/gophermart/internal/services/auth/hasher"
+	"github.com/VanGoghDev/gophermart/internal/services/auth/policy"
+	"github.com/VanGoghDev/gophermart/internal/services/events"
+	"github.com/VanGoghDev/gophermart/internal/services/ratelimit"
+	"google.golang.org/grpc"
+)
+
+type Storage interface {
+	RegisterUser(ctx context.Context, login string, passHash string) (string, error)
+	GetUser(ctx context.Context, userLogin string) (models.User, error)
+	UpdatePassHash(ctx context.Context, login string, passHash string) error
+
+	UseAPIKey(ctx context.Context, keyHash string) (models.APIKey, error)
+
+	GetTOTP(ctx context.Context, login string) (models.TOTP, error)
+	UseTOTPStep(ctx context.Context, login string, step int64) error
+	UseRecoveryCode(ctx context.Context, login string, codeHash string) error
+	UseChallengeAttempt(ctx context.Context, jti string, login string, expiresAt time.Time, maxAttempts int) error
+	CompleteChallenge(ctx context.Context, jti string) error
+
+	CreateSession(ctx context.Context, session models.Session) (models.Session, error)
+	TouchSession(ctx context.Context, id int64) (models.Session, error)
+
+	GetOrder(ctx context.Context, number string) (models.Order, error)
+	GetOrders(ctx context.Context, userLogin string) ([]models.Order, error)
+	SaveOrder(ctx context.Context, number string, userLogin string, status models.OrderStatus) error
+
+	GetBalance(ctx context.Context, userLogin string) (models.Balance, error)
+	GetWithdrawals(ctx context.Context, userLogin string) ([]models.Withdrawal, error)
+	SaveWithdrawal(ctx context.Context, userLogin string, orderNum string, sum float64) error
+}
+
+type Subscriber interface {
+	Subscribe(login string, lastEventID uint64) *events.Subscription
+}
+
+// Option overrides server defaults.
+type Option func(o *options)
+
+type options struct {
+	credentialPolicy *policy.Policy
+	hasher           *hasher.Hasher
+	rateLimitStore   ratelimit.Store
+	rateLimits       ratelimit.Limits
+}
+
+// WithCredentialPolicy sets the policy applied to credentials on registration.
+func WithCredentialPolicy(p *policy.Policy) Option {
+	return func(o *options) {
+		o.credentialPolicy = p
+	}
+}
+
+// WithPasswordHasher sets the hasher used to store and verify passwords.
+func WithPasswordHasher(h *hasher.Hasher) Option {
+	return func(o *options) {
+		o.hasher = h
+	}
+}
+
+// WithRateLimits limits call rates with the limits of the HTTP route groups, sharing their buckets in the store.
+// Without it nothing is limited.
+func WithRateLimits(store ratelimit.Store, limits ratelimit.Limits) Option {
+	return func(o *options) {
+		o.rateLimitStore = store
+		o.rateLimits = limits
+	}
+}
+
+// publicMethods are called without credentials.
+var publicMethods = []string{
+	pb.GophermartService_Register_FullMethodName,
+	pb.GophermartService_Login_FullMethodName,
+	pb.GophermartService_LoginTOTP_FullMethodName,
+}
+
+// methodScopes mirrors RequireScope of the HTTP routes. Methods that are not listed are token-only.
+var methodScopes = map[string][]models.Scope{
+	pb.GophermartService_UploadOrder_FullMethodName:     {models.ScopeOrdersWrite},
+	pb.GophermartService_GetBalance_FullMethodName:      {models.ScopeBalanceRead},
+	pb.GophermartService_Withdraw_FullMethodName:        {models.ScopeWithdraw},
+	pb.GophermartService_ListWithdrawals_FullMethodName: {models.ScopeBalanceRead},
+}
+
+// orderMethods take a token of the orders limit on top of the API one, as order uploads over HTTP do.
+var orderMethods = []string{
+	pb.GophermartService_UploadOrder_FullMethodName,
+}
+
+type Server struct {
+	pb.UnimplementedGophermartServiceServer
+
+	log          *slog.Logger
+	storage      Storage
+	sub          Subscriber
+	policy       *policy.Policy
+	hasher       *hasher.Hasher
+	secret       string
+	tokenExpires time.Duration
+}
+
+// New returns a gRPC server with the Gophermart service and the auth, logging and recovery interceptors.
+func New(
+	log *slog.Logger,
+	storage Storage,
+	sub Subscriber,
+	tokenSecret string,
+	tokenExpires time.Duration,
+	opts ...Option,
+) *grpc.Server {
+	o := &options{
+		credentialPolicy: policy.Default(),
+		hasher:           hasher.Default(),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	a := interceptors.NewAuth(log, tokenSecret, storage, storage, publicMethods, methodScopes)
+	unary := []grpc.UnaryServerInterceptor{
+		interceptors.UnaryRecoverer(log),
+		interceptors.UnaryLogger(log),
+		a.Unary(),
+	}
+	stream := []grpc.StreamServerInterceptor{
+		interceptors.StreamRecoverer(log),
+		interceptors.StreamLogger(log),
+		a.Stream(),
+	}
+	if o.rateLimitStore != nil {
+		l := interceptors.NewRateLimit(log, o.rateLimitStore, o.rateLimits, publicMethods, orderMethods)
+		unary = append(unary, l.Unary())
+		stream = append(stream, l.Stream())
+	}
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+	)
+	pb.RegisterGophermartServiceServer(srv, &Server{
+		log:          log,
+		storage:      storage,
+		sub:          sub,
+		policy:       o.credentialPolicy,
+		hasher:       o.hasher,
+		secret:       tokenSecret,
+		tokenExpires: tokenExpires,
+	})
+	return srv
+}
@@ -0,0 +1,571 @@
+package server_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/grpc/grpcerr"
+	pb "github.com/VanGoghDev/gophermart/internal/grpc/pb/gophermart/v1"
+	"github.com/VanGoghDev/gophermart/internal/grpc/server"
+	hauth "github.com/VanGoghDev/gophermart/internal/handlers/auth"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/mocks"
+	"github.com/VanGoghDev/gophermart/internal/services/auth"
+	"github.com/VanGoghDev/gophermart/internal/services/auth/hasher"
+	"github.com/VanGoghDev/gophermart/internal/services/auth/totp"
+	"github.com/VanGoghDev/gophermart/internal/services/events"
+	"github.com/VanGoghDev/gophermart/internal/services/ratelimit"
+	"github.com/VanGoghDev/gophermart/internal/storage"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const secret = "secret"
+
+// newClient starts the server on an in-memory listener.
+func newClient(t *testing.T, m *mocks.MockStorage, b *events.Broker, opts ...server.Option) pb.GophermartServiceClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := server.New(logger.New("dev"), m, b, secret, time.Hour, opts...)
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return pb.NewGophermartServiceClient(conn)
+}
+
+func withToken(t *testing.T, login string) context.Context {
+	t.Helper()
+	token, err := auth.GenerateToken(login, secret, time.Hour)
+	require.NoError(t, err)
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", token)
+}
+
+func withSession(t *testing.T) context.Context {
+	t.Helper()
+	token, err := auth.GrantSessionToken("test", models.RoleUser, 1, secret, time.Hour)
+	require.NoError(t, err)
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", token)
+}
+
+func assertStatus(t *testing.T, err error, wantCode codes.Code, wantReason string) {
+	t.Helper()
+	assert.Equal(t, wantCode, status.Code(err))
+	if wantReason != "" {
+		assert.Equal(t, wantReason, grpcerr.Reason(err))
+	}
+}
+
+func TestRegister(t *testing.T) {
+	tests := []struct {
+		name       string
+		login      string
+		password   string
+		storageErr error
+		wantCode   codes.Code
+		wantReason string
+	}{
+		{
+			name:     "registers user",
+			login:    "test",
+			password: "gopher-mart-42",
+			wantCode: codes.OK,
+		},
+		{
+			name:       "rejects weak password",
+			login:      "test",
+			password:   "1",
+			wantCode:   codes.InvalidArgument,
+			wantReason: problem.CodeCredentialPolicy,
+		},
+		{
+			name:       "rejects taken login",
+			login:      "test",
+			password:   "gopher-mart-42",
+			storageErr: storage.ErrAlreadyExists,
+			wantCode:   codes.AlreadyExists,
+			wantReason: problem.CodeLoginTaken,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			m := mocks.NewMockStorage(ctrl)
+			m.EXPECT().RegisterUser(gomock.Any(), tt.login, gomock.Any()).
+				Return(tt.login, tt.storageErr).MaxTimes(1)
+			m.EXPECT().CreateSession(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, s models.Session) (models.Session, error) {
+					assert.Equal(t, "phone", s.Device)
+					s.ID = 1
+					return s, nil
+				}).MaxTimes(1)
+
+			client := newClient(t, m, events.New(events.DefaultHistorySize, events.DefaultHistoryTTL))
+			resp, err := client.Register(context.Background(), &pb.RegisterRequest{
+				Login:    tt.login,
+				Password: tt.password,
+				Device:   "phone",
+			})
+
+			assertStatus(t, err, tt.wantCode, tt.wantReason)
+			if tt.wantCode == codes.OK {
+				assert.NotEmpty(t, resp.GetToken())
+			}
+		})
+	}
+}
+
+func TestLogin(t *testing.T) {
+	passHash, err := hasher.Default().Hash("gopher-mart-42")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		password      string
+		totp          models.TOTP
+		totpErr       error
+		wantCode      codes.Code
+		wantChallenge bool
+	}{
+		{
+			name:     "returns token",
+			password: "gopher-mart-42",
+			totpErr:  storage.ErrNotFound,
+			wantCode: codes.OK,
+		},
+		{
+			name:          "returns challenge with totp enabled",
+			password:      "gopher-mart-42",
+			totp:          models.TOTP{ConfirmedAt: func() *time.Time { now := time.Now(); return &now }()},
+			wantCode:      codes.OK,
+			wantChallenge: true,
+		},
+		{
+			name:     "rejects wrong password",
+			password: "wrong",
+			wantCode: codes.Unauthenticated,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			m := mocks.NewMockStorage(ctrl)
+			m.EXPECT().GetUser(gomock.Any(), "test").
+				Return(models.User{Login: "test", PassHash: []byte(passHash), Role: models.RoleUser}, nil)
+			m.EXPECT().GetTOTP(gomock.Any(), "test").Return(tt.totp, tt.totpErr).MaxTimes(1)
+			m.EXPECT().CreateSession(gomock.Any(), gomock.Any()).
+				Return(models.Session{ID: 1, UserLogin: "test"}, nil).MaxTimes(1)
+
+			client := newClient(t, m, events.New(events.DefaultHistorySize, events.DefaultHistoryTTL))
+			resp, err := client.Login(context.Background(), &pb.LoginRequest{Login: "test", Password: tt.password})
+
+			assertStatus(t, err, tt.wantCode, "")
+			if tt.wantCode != codes.OK {
+				return
+			}
+			if tt.wantChallenge {
+				assert.NotEmpty(t, resp.GetChallenge().GetChallengeToken())
+				assert.Empty(t, resp.GetToken())
+			} else {
+				assert.NotEmpty(t, resp.GetToken())
+			}
+		})
+	}
+}
+
+func TestLoginTOTP(t *testing.T) {
+	secret2FA, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	confirmedAt := time.Now()
+	challenge, err := auth.GrantChallengeToken("test", secret, auth.ChallengeTTL)
+	require.NoError(t, err)
+
+	ctrl := gomock.NewController(t)
+	m := mocks.NewMockStorage(ctrl)
+	m.EXPECT().GetTOTP(gomock.Any(), "test").
+		Return(models.TOTP{UserLogin: "test", Secret: secret2FA, ConfirmedAt: &confirmedAt}, nil).AnyTimes()
+	// Хранилище считает попытки по идентификатору токена.
+	attempts := make(map[string]int)
+	m.EXPECT().UseChallengeAttempt(gomock.Any(), gomock.Any(), "test", gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, jti string, _ string, _ time.Time, maxAttempts int) error {
+			if attempts[jti] >= maxAttempts {
+				return storage.ErrLimitExceeded
+			}
+			attempts[jti]++
+			return nil
+		}).AnyTimes()
+
+	client := newClient(t, m, events.New(events.DefaultHistorySize, events.DefaultHistoryTTL))
+	req := &pb.LoginTOTPRequest{ChallengeToken: challenge, Factor: &pb.LoginTOTPRequest_Code{Code: "000000"}}
+	for i := 0; i < hauth.MaxChallengeAttempts; i++ {
+		_, err := client.LoginTOTP(context.Background(), req)
+		assertStatus(t, err, codes.Unauthenticated, problem.CodeInvalidSecondFactor)
+	}
+	_, err = client.LoginTOTP(context.Background(), req)
+	assertStatus(t, err, codes.Unauthenticated, problem.CodeInvalidChallenge)
+}
+
+func TestUploadOrder(t *testing.T) {
+	tests := []struct {
+		name         string
+		number       string
+		storageErr   error
+		wantCode     codes.Code
+		wantReason   string
+		wantAccepted bool
+	}{
+		{
+			name:         "accepts new order",
+			number:       "12345678903",
+			wantCode:     codes.OK,
+			wantAccepted: true,
+		},
+		{
+			name:       "already uploaded by the user",
+			number:     "12345678903",
+			storageErr: storage.ErrAlreadyExists,
+			wantCode:   codes.OK,
+		},
+		{
+			name:       "uploaded by another user",
+			number:     "12345678903",
+			storageErr: storage.ErrConflict,
+			wantCode:   codes.AlreadyExists,
+			wantReason: problem.CodeOrderConflict,
+		},
+		{
+			name:       "fails the Luhn check",
+			number:     "12345678902",
+			wantCode:   codes.InvalidArgument,
+			wantReason: problem.CodeInvalidOrderNumber,
+		},
+		{
+			name:       "storage error",
+			number:     "12345678903",
+			storageErr: errors.New("storage error"),
+			wantCode:   codes.Internal,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			m := mocks.NewMockStorage(ctrl)
+			m.EXPECT().SaveOrder(gomock.Any(), tt.number, "test", models.New).Return(tt.storageErr).MaxTimes(1)
+
+			client := newClient(t, m, events.New(events.DefaultHistorySize, events.DefaultHistoryTTL))
+			resp, err := client.UploadOrder(withToken(t, "test"), &pb.UploadOrderRequest{Number: tt.number})
+
+			assertStatus(t, err, tt.wantCode, tt.wantReason)
+			assert.Equal(t, tt.wantAccepted, resp.GetAccepted())
+		})
+	}
+}
+
+func TestGetOrder(t *testing.T) {
+	tests := []struct {
+		name       string
+		order      models.Order
+		storageErr error
+		wantCode   codes.Code
+		wantReason string
+	}{
+		{
+			name:     "returns order",
+			order:    models.Order{Number: "12345678903", UserLogin: "test", Status: models.Processed, Accrual: 500},
+			wantCode: codes.OK,
+		},
+		{
+			name:       "order of another user",
+			order:      models.Order{Number: "12345678903", UserLogin: "other"},
+			wantCode:   codes.PermissionDenied,
+			wantReason: problem.CodeOrderForbidden,
+		},
+		{
+			name:       "unknown order",
+			storageErr: storage.ErrNotFound,
+			wantCode:   codes.NotFound,
+			wantReason: problem.CodeOrderNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			m := mocks.NewMockStorage(ctrl)
+			m.EXPECT().GetOrder(gomock.Any(), "12345678903").Return(tt.order, tt.storageErr)
+
+			client := newClient(t, m, events.New(events.DefaultHistorySize, events.DefaultHistoryTTL))
+			resp, err := client.GetOrder(withToken(t, "test"), &pb.GetOrderRequest{Number: "12345678903"})
+
+			assertStatus(t, err, tt.wantCode, tt.wantReason)
+			if tt.wantCode == codes.OK {
+				assert.Equal(t, pb.OrderStatus_ORDER_STATUS_PROCESSED, resp.GetOrder().GetStatus())
+				assert.Equal(t, float64(500), resp.GetOrder().GetAccrual())
+			}
+		})
+	}
+}
+
+func TestAuth(t *testing.T) {
+	tests := []struct {
+		name       string
+		ctx        func(t *testing.T) context.Context
+		wantCode   codes.Code
+		wantReason string
+	}{
+		{
+			name:       "no credentials",
+			ctx:        func(*testing.T) context.Context { return context.Background() },
+			wantCode:   codes.Unauthenticated,
+			wantReason: problem.CodeUnauthorized,
+		},
+		{
+			name: "invalid token",
+			ctx: func(*testing.T) context.Context {
+				return metadata.AppendToOutgoingContext(context.Background(), "authorization", "invalid")
+			},
+			wantCode:   codes.Unauthenticated,
+			wantReason: problem.CodeInvalidToken,
+		},
+		{
+			name: "api key with the scope",
+			ctx: func(*testing.T) context.Context {
+				return metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "balance-key")
+			},
+			wantCode: codes.OK,
+		},
+		{
+			name: "api key without the scope",
+			ctx: func(*testing.T) context.Context {
+				return metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "orders-key")
+			},
+			wantCode:   codes.PermissionDenied,
+			wantReason: problem.CodeInsufficientScope,
+		},
+		{
+			name: "unknown api key",
+			ctx: func(*testing.T) context.Context {
+				return metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "unknown-key")
+			},
+			wantCode:   codes.Unauthenticated,
+			wantReason: problem.CodeInvalidAPIKey,
+		},
+		{
+			name: "api key storage failure",
+			ctx: func(*testing.T) context.Context {
+				return metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "balance-key")
+			},
+			wantCode: codes.Internal,
+		},
+		{
+			name:       "revoked session",
+			ctx:        withSession,
+			wantCode:   codes.Unauthenticated,
+			wantReason: problem.CodeSessionRevoked,
+		},
+		{
+			name:     "session storage failure",
+			ctx:      withSession,
+			wantCode: codes.Internal,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			m := mocks.NewMockStorage(ctrl)
+			m.EXPECT().UseAPIKey(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, _ string) (models.APIKey, error) {
+					switch tt.name {
+					case "api key with the scope":
+						return models.APIKey{UserLogin: "test", Scopes: []models.Scope{models.ScopeBalanceRead}}, nil
+					case "unknown api key":
+						return models.APIKey{}, storage.ErrNotFound
+					case "api key storage failure":
+						return models.APIKey{}, errors.New("connection refused")
+					}
+					return models.APIKey{UserLogin: "test", Scopes: []models.Scope{models.ScopeOrdersWrite}}, nil
+				}).MaxTimes(1)
+			m.EXPECT().TouchSession(gomock.Any(), int64(1)).
+				DoAndReturn(func(_ context.Context, _ int64) (models.Session, error) {
+					if tt.name == "revoked session" {
+						return models.Session{}, storage.ErrNotFound
+					}
+					return models.Session{}, errors.New("connection refused")
+				}).MaxTimes(1)
+			m.EXPECT().GetBalance(gomock.Any(), "test").Return(models.Balance{Current: 100}, nil).MaxTimes(1)
+
+			client := newClient(t, m, events.New(events.DefaultHistorySize, events.DefaultHistoryTTL))
+			resp, err := client.GetBalance(tt.ctx(t), &pb.GetBalanceRequest{})
+
+			assertStatus(t, err, tt.wantCode, tt.wantReason)
+			if tt.wantCode == codes.OK {
+				assert.Equal(t, float64(100), resp.GetBalance().GetCurrent())
+			}
+		})
+	}
+}
+
+func TestWithdraw(t *testing.T) {
+	tests := []struct {
+		name       string
+		sum        float64
+		storageErr error
+		wantCode   codes.Code
+		wantReason string
+	}{
+		{
+			name:     "withdraws",
+			sum:      100,
+			wantCode: codes.OK,
+		},
+		{
+			name:       "not enough funds",
+			sum:        100,
+			storageErr: storage.ErrNotEnoughFunds,
+			wantCode:   codes.FailedPrecondition,
+			wantReason: problem.CodeNotEnoughFunds,
+		},
+		{
+			name:       "non-positive sum",
+			sum:        0,
+			wantCode:   codes.InvalidArgument,
+			wantReason: problem.CodeValidation,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			m := mocks.NewMockStorage(ctrl)
+			m.EXPECT().SaveWithdrawal(gomock.Any(), "test", "2377225624", tt.sum).Return(tt.storageErr).MaxTimes(1)
+
+			client := newClient(t, m, events.New(events.DefaultHistorySize, events.DefaultHistoryTTL))
+			_, err := client.Withdraw(withToken(t, "test"), &pb.WithdrawRequest{Order: "2377225624", Sum: tt.sum})
+
+			assertStatus(t, err, tt.wantCode, tt.wantReason)
+		})
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	once := ratelimit.Limit{Requests: 1, Period: time.Hour}
+	tests := []struct {
+		name   string
+		limits ratelimit.Limits
+		call   func(client pb.GophermartServiceClient, login string) error
+		// otherLogin, if set, has a bucket of its own and must still get through.
+		otherLogin string
+	}{
+		{
+			name:   "public methods are limited by client address",
+			limits: ratelimit.Limits{Auth: once},
+			call: func(client pb.GophermartServiceClient, login string) error {
+				_, err := client.Login(context.Background(), &pb.LoginRequest{Login: login, Password: "gopher-mart-42"})
+				return err
+			},
+		},
+		{
+			name:   "authenticated methods are limited by user",
+			limits: ratelimit.Limits{API: once},
+			call: func(client pb.GophermartServiceClient, login string) error {
+				_, err := client.GetBalance(withToken(t, login), &pb.GetBalanceRequest{})
+				return err
+			},
+			otherLogin: "other",
+		},
+		{
+			name:   "order uploads take the orders limit",
+			limits: ratelimit.Limits{API: ratelimit.Limit{Requests: 10, Period: time.Hour}, Orders: once},
+			call: func(client pb.GophermartServiceClient, login string) error {
+				_, err := client.UploadOrder(withToken(t, login), &pb.UploadOrderRequest{Number: "12345678903"})
+				return err
+			},
+			otherLogin: "other",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			m := mocks.NewMockStorage(ctrl)
+			m.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(models.User{}, storage.ErrNotFound).AnyTimes()
+			m.EXPECT().GetBalance(gomock.Any(), gomock.Any()).Return(models.Balance{}, nil).AnyTimes()
+			m.EXPECT().GetOrder(gomock.Any(), gomock.Any()).Return(models.Order{}, storage.ErrNotFound).AnyTimes()
+			m.EXPECT().SaveOrder(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+			client := newClient(t, m, events.New(events.DefaultHistorySize, events.DefaultHistoryTTL),
+				server.WithRateLimits(ratelimit.NewMemory(), tt.limits))
+
+			require.NotEqual(t, codes.ResourceExhausted, status.Code(tt.call(client, "test")))
+			err := tt.call(client, "test")
+			assertStatus(t, err, codes.ResourceExhausted, problem.CodeRateLimited)
+			if tt.otherLogin != "" {
+				assert.NotEqual(t, codes.ResourceExhausted, status.Code(tt.call(client, tt.otherLogin)))
+			}
+		})
+	}
+}
+
+func TestWatchOrders(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	m := mocks.NewMockStorage(ctrl)
+	b := events.New(events.DefaultHistorySize, events.DefaultHistoryTTL)
+	client := newClient(t, m, b)
+
+	ctx, cancel := context.WithCancel(withToken(t, "test"))
+	defer cancel()
+	stream, err := client.WatchOrders(ctx, &pb.WatchOrdersRequest{})
+	require.NoError(t, err)
+
+	// Подписка появляется только после того, как сервер принял поток; публикуем, пока событие не дойдёт.
+	got := make(chan *pb.WatchOrdersResponse, 1)
+	go func() {
+		resp, err := stream.Recv()
+		if err == nil {
+			got <- resp
+		}
+		close(got)
+	}()
+
+	var resp *pb.WatchOrdersResponse
+	for resp == nil {
+		b.Publish("other", events.TypeOrder, models.Order{Number: "2377225624", Status: models.Processed})
+		b.Publish("test", events.TypeBalance, models.Balance{Current: 100})
+		b.Publish("test", events.TypeOrder, models.Order{Number: "12345678903", Status: models.Processed, Accrual: 100})
+		select {
+		case resp = <-got:
+			require.NotNil(t, resp)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	assert.Equal(t, "12345678903", resp.GetOrder().GetNumber())
+	assert.Equal(t, pb.OrderStatus_ORDER_STATUS_PROCESSED, resp.GetOrder().GetStatus())
+
+	// После закрытия брокера поток завершается с Unavailable, клиент должен переподписаться.
+	b.Close()
+	for {
+		_, err = stream.Recv()
+		if err != nil {
+			break
+		}
+	}
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
@@ -0,0 +1,42 @@
+package getadjustments
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/lib/logger/sl"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/go-chi/chi"
+)
+
+type AdjustmentsProvider interface {
+	GetBalanceAdjustments(ctx context.Context, userLogin string) ([]models.BalanceAdjustment, error)
+}
+
+func New(log *slog.Logger, s AdjustmentsProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context(), log)
+		adjustments, err := s.GetBalanceAdjustments(r.Context(), chi.URLParam(r, "login"))
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to fetch balance adjustments", sl.Err(err))
+			problem.Internal(w, r)
+			return
+		}
+		if len(adjustments) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		err = enc.Encode(adjustments)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to encode balance adjustments json", sl.Err(err))
+			return
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package getadjustments_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/mocks"
+	"github.com/VanGoghDev/gophermart/internal/router"
+	"github.com/VanGoghDev/gophermart/internal/services/auth"
+	"github.com/go-resty/resty/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name           string
+		adjustments    []models.BalanceAdjustment
+		storageErr     error
+		wantStatusCode int
+	}{
+		{
+			name: "must return 200 status",
+			adjustments: []models.BalanceAdjustment{
+				{ID: 1, UserLogin: "john", AdminLogin: "admin", Amount: 100, Reason: "compensation"},
+			},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "must return 204 status",
+			adjustments:    []models.BalanceAdjustment{},
+			wantStatusCode: http.StatusNoContent,
+		},
+		{
+			name:           "must return 500 status",
+			storageErr:     errors.New("storage error"),
+			wantStatusCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := logger.New("dev")
+			secret := "secret"
+
+			token, err := auth.GenerateRoleToken("admin", models.RoleAdmin, secret, time.Hour)
+			assert.Empty(t, err)
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			m := mocks.NewMockStorage(ctrl)
+
+			m.EXPECT().GetBalanceAdjustments(gomock.Any(), "john").
+				Return(tt.adjustments, tt.storageErr).AnyTimes()
+
+			r := router.New(log, m, secret, time.Hour)
+			srv := httptest.NewServer(r)
+			defer srv.Close()
+
+			client := resty.New()
+
+			resp, err := client.R().
+				SetHeader("Authorization", token).
+				Get(fmt.Sprintf("%s/%s", srv.URL, "api/admin/users/john/balance/adjustments"))
+
+			assert.Empty(t, err)
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode())
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package getusers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/lib/logger/sl"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+)
+
+const (
+	defaultLimit = 50
+	maxLimit     = 500
+)
+
+type UsersProvider interface {
+	ListUsers(ctx context.Context, search string, limit int, offset int) ([]models.User, error)
+}
+
+func New(log *slog.Logger, s UsersProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context(), log)
+		query := r.URL.Query()
+
+		limit, err := intParam(query.Get("limit"), defaultLimit)
+		if err != nil || limit <= 0 || limit > maxLimit {
+			problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidParameter,
+				fmt.Sprintf("limit must be in 1..%d", maxLimit))
+			return
+		}
+		offset, err := intParam(query.Get("offset"), 0)
+		if err != nil || offset < 0 {
+			problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidParameter, "offset must not be negative")
+			return
+		}
+
+		users, err := s.ListUsers(r.Context(), query.Get("search"), limit, offset)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to list users", sl.Err(err))
+			problem.Internal(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		err = enc.Encode(users)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to encode users json", sl.Err(err))
+			return
+		}
+	}
+}
+
+func intParam(v string, def int) (int, error) {
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse int param: %w", err)
+	}
+	return n, nil
+}
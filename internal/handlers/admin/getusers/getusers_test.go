@@ -0,0 +1,100 @@
+package getusers_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/mocks"
+	"github.com/VanGoghDev/gophermart/internal/router"
+	"github.com/VanGoghDev/gophermart/internal/services/auth"
+	"github.com/go-resty/resty/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	type args struct {
+		role       models.Role
+		query      string
+		users      []models.User
+		storageErr error
+	}
+	tests := []struct {
+		name           string
+		args           args
+		wantStatusCode int
+		wantBody       string
+	}{
+		{
+			name: "must return 200 status",
+			args: args{
+				role:  models.RoleAdmin,
+				query: "?search=jo&limit=10",
+				users: []models.User{{Login: "john", Role: models.RoleUser, Balance: 10, PassHash: []byte("hash")}},
+			},
+			wantStatusCode: http.StatusOK,
+			wantBody:       `[{"login":"john","role":"user","balance":10}]`,
+		},
+		{
+			name: "must return 400 status (invalid limit)",
+			args: args{
+				role:  models.RoleAdmin,
+				query: "?limit=100000",
+			},
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "must return 403 status",
+			args: args{
+				role: models.RoleUser,
+			},
+			wantStatusCode: http.StatusForbidden,
+		},
+		{
+			name: "must return 500 status",
+			args: args{
+				role:       models.RoleAdmin,
+				storageErr: errors.New("storage error"),
+			},
+			wantStatusCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := logger.New("dev")
+			secret := "secret"
+
+			token, err := auth.GenerateRoleToken("admin", tt.args.role, secret, time.Hour)
+			assert.Empty(t, err)
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			m := mocks.NewMockStorage(ctrl)
+
+			m.EXPECT().ListUsers(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+				Return(tt.args.users, tt.args.storageErr).AnyTimes()
+
+			r := router.New(log, m, secret, time.Hour)
+			srv := httptest.NewServer(r)
+			defer srv.Close()
+
+			client := resty.New()
+
+			resp, err := client.R().
+				SetHeader("Authorization", token).
+				Get(fmt.Sprintf("%s/%s%s", srv.URL, "api/admin/users", tt.args.query))
+
+			assert.Empty(t, err)
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode())
+			if tt.wantBody != "" {
+				assert.JSONEq(t, tt.wantBody, string(resp.Body()))
+			}
+		})
+	}
+}
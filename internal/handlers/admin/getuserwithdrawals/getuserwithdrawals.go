@@ -0,0 +1,61 @@
+package getuserwithdrawals
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/lib/logger/sl"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/storage"
+	"github.com/go-chi/chi"
+)
+
+type UserProvider interface {
+	GetUser(ctx context.Context, login string) (models.User, error)
+}
+
+type WithdrawalsProvider interface {
+	GetWithdrawals(ctx context.Context, userLogin string) ([]models.Withdrawal, error)
+}
+
+func New(log *slog.Logger, su UserProvider, s WithdrawalsProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context(), log)
+		userLogin := chi.URLParam(r, "login")
+
+		_, err := su.GetUser(r.Context(), userLogin)
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				problem.Write(w, r, http.StatusNotFound, problem.CodeUserNotFound, "")
+				return
+			}
+			log.ErrorContext(r.Context(), "failed to get user", sl.Err(err))
+			problem.Internal(w, r)
+			return
+		}
+
+		withdrawals, err := s.GetWithdrawals(r.Context(), userLogin)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to fetch withdrawals", sl.Err(err))
+			problem.Internal(w, r)
+			return
+		}
+		if len(withdrawals) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		err = enc.Encode(withdrawals)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to encode withdrawals json", sl.Err(err))
+			return
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package getuserwithdrawals_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/mocks"
+	"github.com/VanGoghDev/gophermart/internal/router"
+	"github.com/VanGoghDev/gophermart/internal/services/auth"
+	"github.com/VanGoghDev/gophermart/internal/storage"
+	"github.com/go-resty/resty/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	type args struct {
+		userErr        error
+		withdrawals    []models.Withdrawal
+		withdrawalsErr error
+	}
+	tests := []struct {
+		name           string
+		args           args
+		wantStatusCode int
+	}{
+		{
+			name: "must return 200 status",
+			args: args{
+				withdrawals: []models.Withdrawal{{OrderNumber: "2377225624", Sum: 500}},
+			},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "must return 204 status",
+			args:           args{withdrawals: []models.Withdrawal{}},
+			wantStatusCode: http.StatusNoContent,
+		},
+		{
+			name:           "must return 404 status",
+			args:           args{userErr: storage.ErrNotFound},
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name:           "must return 500 status",
+			args:           args{withdrawalsErr: errors.New("storage error")},
+			wantStatusCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := logger.New("dev")
+			secret := "secret"
+
+			token, err := auth.GenerateRoleToken("admin", models.RoleAdmin, secret, time.Hour)
+			assert.Empty(t, err)
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			m := mocks.NewMockStorage(ctrl)
+
+			m.EXPECT().GetUser(gomock.Any(), "john").
+				Return(models.User{Login: "john"}, tt.args.userErr).AnyTimes()
+			m.EXPECT().GetWithdrawals(gomock.Any(), "john").
+				Return(tt.args.withdrawals, tt.args.withdrawalsErr).AnyTimes()
+
+			r := router.New(log, m, secret, time.Hour)
+			srv := httptest.NewServer(r)
+			defer srv.Close()
+
+			client := resty.New()
+
+			resp, err := client.R().
+				SetHeader("Authorization", token).
+				Get(fmt.Sprintf("%s/%s", srv.URL, "api/admin/users/john/withdrawals"))
+
+			assert.Empty(t, err)
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode())
+		})
+	}
+}
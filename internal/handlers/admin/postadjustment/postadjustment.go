@@ -0,0 +1,83 @@
+package postadjustment
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/lib/decode"
+	"github.com/VanGoghDev/gophermart/internal/lib/logger/sl"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/middleware/auth"
+	"github.com/VanGoghDev/gophermart/internal/storage"
+	"github.com/go-chi/chi"
+)
+
+type BalanceAdjuster interface {
+	AdjustBalance(ctx context.Context, adj models.BalanceAdjustment) (models.BalanceAdjustment, error)
+}
+
+type Request struct {
+	// Причина обязательна: без неё запись в журнале аудита бесполезна.
+	Reason string  `json:"reason" validate:"notblank"`
+	Amount float64 `json:"amount" validate:"ne=0"`
+}
+
+func New(log *slog.Logger, s BalanceAdjuster) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context(), log)
+		adminLogin, err := auth.GetLogin(r)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to fetch user login from context")
+			problem.Internal(w, r)
+			return
+		}
+
+		req := &Request{}
+		if prob := decode.JSON(w, r, req); prob != nil {
+			problem.Render(w, r, *prob)
+			return
+		}
+
+		adj, err := s.AdjustBalance(r.Context(), models.BalanceAdjustment{
+			UserLogin:  chi.URLParam(r, "login"),
+			AdminLogin: adminLogin,
+			Amount:     req.Amount,
+			Reason:     req.Reason,
+		})
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				problem.Write(w, r, http.StatusNotFound, problem.CodeUserNotFound, "")
+				return
+			}
+			if errors.Is(err, storage.ErrNotEnoughFunds) {
+				problem.Write(w, r, http.StatusPaymentRequired, problem.CodeNotEnoughFunds,
+					"balance would become negative")
+				return
+			}
+			log.ErrorContext(r.Context(), "failed to adjust balance", sl.Err(err))
+			problem.Internal(w, r)
+			return
+		}
+
+		log.InfoContext(r.Context(), "balance adjusted",
+			"admin", adj.AdminLogin,
+			"user", adj.UserLogin,
+			"amount", adj.Amount,
+			"adjustmentID", adj.ID,
+		)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		enc := json.NewEncoder(w)
+		err = enc.Encode(adj)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to encode adjustment json", sl.Err(err))
+			return
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package postadjustment_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/mocks"
+	"github.com/VanGoghDev/gophermart/internal/router"
+	"github.com/VanGoghDev/gophermart/internal/services/auth"
+	"github.com/VanGoghDev/gophermart/internal/storage"
+	"github.com/go-resty/resty/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	type args struct {
+		contentType string
+		body        string
+		storageErr  error
+	}
+	tests := []struct {
+		name           string
+		args           args
+		wantStatusCode int
+	}{
+		{
+			name: "must return 201 status",
+			args: args{
+				contentType: "application/json",
+				body:        `{"amount": 100, "reason": "compensation for ticket #42"}`,
+			},
+			wantStatusCode: http.StatusCreated,
+		},
+		{
+			name: "must return 400 status (missing reason)",
+			args: args{
+				contentType: "application/json",
+				body:        `{"amount": 100}`,
+			},
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "must return 400 status (zero amount)",
+			args: args{
+				contentType: "application/json",
+				body:        `{"amount": 0, "reason": "nothing"}`,
+			},
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "must return 402 status",
+			args: args{
+				contentType: "application/json",
+				body:        `{"amount": -100, "reason": "chargeback"}`,
+				storageErr:  storage.ErrNotEnoughFunds,
+			},
+			wantStatusCode: http.StatusPaymentRequired,
+		},
+		{
+			name: "must return 404 status",
+			args: args{
+				contentType: "application/json",
+				body:        `{"amount": 100, "reason": "compensation"}`,
+				storageErr:  storage.ErrNotFound,
+			},
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name: "must return 500 status",
+			args: args{
+				contentType: "application/json",
+				body:        `{"amount": 100, "reason": "compensation"}`,
+				storageErr:  errors.New("storage error"),
+			},
+			wantStatusCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := logger.New("dev")
+			secret := "secret"
+
+			token, err := auth.GenerateRoleToken("admin", models.RoleAdmin, secret, time.Hour)
+			assert.Empty(t, err)
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			m := mocks.NewMockStorage(ctrl)
+
+			m.EXPECT().AdjustBalance(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ any, adj models.BalanceAdjustment) (models.BalanceAdjustment, error) {
+					assert.Equal(t, "john", adj.UserLogin)
+					assert.Equal(t, "admin", adj.AdminLogin)
+					assert.NotEmpty(t, adj.Reason)
+					return adj, tt.args.storageErr
+				}).AnyTimes()
+
+			r := router.New(log, m, secret, time.Hour)
+			srv := httptest.NewServer(r)
+			defer srv.Close()
+
+			client := resty.New()
+
+			resp, err := client.R().
+				SetHeader("Content-Type", tt.args.contentType).
+				SetHeader("Authorization", token).
+				SetBody(tt.args.body).
+				Post(fmt.Sprintf("%s/%s", srv.URL, "api/admin/users/john/balance/adjustments"))
+
+			assert.Empty(t, err)
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode())
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package deleteapikey
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/VanGoghDev/gophermart/internal/lib/logger/sl"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/middleware/auth"
+	"github.com/VanGoghDev/gophermart/internal/storage"
+	"github.com/go-chi/chi"
+)
+
+type APIKeyRevoker interface {
+	RevokeAPIKey(ctx context.Context, userLogin string, id int64) error
+}
+
+func New(log *slog.Logger, s APIKeyRevoker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context(), log)
+		userLogin, err := auth.GetLogin(r)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to fetch user login from context")
+			problem.Internal(w, r)
+			return
+		}
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidParameter, "id must be an integer")
+			return
+		}
+
+		err = s.RevokeAPIKey(r.Context(), userLogin, id)
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				problem.Write(w, r, http.StatusNotFound, problem.CodeAPIKeyNotFound, "")
+				return
+			}
+			log.ErrorContext(r.Context(), "failed to revoke api key", sl.Err(err))
+			problem.Internal(w, r)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
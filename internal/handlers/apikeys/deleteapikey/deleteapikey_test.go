@@ -0,0 +1,80 @@
+package deleteapikey_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/mocks"
+	"github.com/VanGoghDev/gophermart/internal/openapi/openapitest"
+	"github.com/VanGoghDev/gophermart/internal/router"
+	"github.com/VanGoghDev/gophermart/internal/services/auth"
+	"github.com/VanGoghDev/gophermart/internal/storage"
+	"github.com/go-resty/resty/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name           string
+		id             string
+		storageErr     error
+		wantStatusCode int
+	}{
+		{
+			name:           "must return 204 status",
+			id:             "1",
+			wantStatusCode: http.StatusNoContent,
+		},
+		{
+			name:           "must return 400 status",
+			id:             "abc",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "must return 404 status",
+			id:             "2",
+			storageErr:     storage.ErrNotFound,
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name:           "must return 500 status",
+			id:             "3",
+			storageErr:     errors.New("storage error"),
+			wantStatusCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := logger.New("dev")
+			secret := "secret"
+
+			token, err := auth.GenerateToken("test", secret, time.Hour)
+			assert.Empty(t, err)
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			m := mocks.NewMockStorage(ctrl)
+
+			m.EXPECT().RevokeAPIKey(gomock.Any(), "test", gomock.Any()).Return(tt.storageErr).AnyTimes()
+
+			r := router.New(log, m, secret, time.Hour)
+			srv := httptest.NewServer(openapitest.Handler(t, r))
+			defer srv.Close()
+
+			client := resty.New()
+
+			resp, err := client.R().
+				SetHeader("Authorization", token).
+				Delete(fmt.Sprintf("%s/%s/%s", srv.URL, "api/user/apikeys", tt.id))
+
+			assert.Empty(t, err)
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode())
+		})
+	}
+}
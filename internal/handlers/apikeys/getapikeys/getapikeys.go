@@ -0,0 +1,49 @@
+package getapikeys
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/lib/logger/sl"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/middleware/auth"
+)
+
+type APIKeysProvider interface {
+	GetAPIKeys(ctx context.Context, userLogin string) ([]models.APIKey, error)
+}
+
+func New(log *slog.Logger, s APIKeysProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context(), log)
+		userLogin, err := auth.GetLogin(r)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to fetch user login from context")
+			problem.Internal(w, r)
+			return
+		}
+
+		keys, err := s.GetAPIKeys(r.Context(), userLogin)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to fetch api keys", sl.Err(err))
+			problem.Internal(w, r)
+			return
+		}
+		if len(keys) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		err = enc.Encode(keys)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to encode api keys json", sl.Err(err))
+			return
+		}
+	}
+}
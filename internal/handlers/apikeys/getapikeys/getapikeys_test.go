@@ -0,0 +1,79 @@
+package getapikeys_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/mocks"
+	"github.com/VanGoghDev/gophermart/internal/openapi/openapitest"
+	"github.com/VanGoghDev/gophermart/internal/router"
+	"github.com/VanGoghDev/gophermart/internal/services/auth"
+	"github.com/go-resty/resty/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name           string
+		keys           []models.APIKey
+		storageErr     error
+		wantStatusCode int
+	}{
+		{
+			name: "must return 200 status",
+			keys: []models.APIKey{
+				{
+					ID: 1, Name: "shop", Prefix: "gm_0a1b2c3d", KeyHash: "hash", Scopes: []models.Scope{models.ScopeWithdraw},
+					CreatedAtFormated: "2024-05-01T10:00:00+03:00",
+				},
+			},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "must return 204 status",
+			keys:           []models.APIKey{},
+			wantStatusCode: http.StatusNoContent,
+		},
+		{
+			name:           "must return 500 status",
+			storageErr:     errors.New("storage error"),
+			wantStatusCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := logger.New("dev")
+			secret := "secret"
+
+			token, err := auth.GenerateToken("test", secret, time.Hour)
+			assert.Empty(t, err)
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			m := mocks.NewMockStorage(ctrl)
+
+			m.EXPECT().GetAPIKeys(gomock.Any(), "test").Return(tt.keys, tt.storageErr).AnyTimes()
+
+			r := router.New(log, m, secret, time.Hour)
+			srv := httptest.NewServer(openapitest.Handler(t, r))
+			defer srv.Close()
+
+			client := resty.New()
+
+			resp, err := client.R().
+				SetHeader("Authorization", token).
+				Get(fmt.Sprintf("%s/%s", srv.URL, "api/user/apikeys"))
+
+			assert.Empty(t, err)
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode())
+			assert.NotContains(t, string(resp.Body()), "hash")
+		})
+	}
+}
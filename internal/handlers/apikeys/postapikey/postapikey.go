@@ -0,0 +1,90 @@
+package postapikey
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/lib/decode"
+	"github.com/VanGoghDev/gophermart/internal/lib/logger/sl"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/middleware/auth"
+	"github.com/VanGoghDev/gophermart/internal/services/auth/apikey"
+)
+
+type APIKeySaver interface {
+	SaveAPIKey(ctx context.Context, key models.APIKey) (models.APIKey, error)
+}
+
+type Request struct {
+	Name   string         `json:"name" validate:"notblank,max=100"`
+	Scopes []models.Scope `json:"scopes" validate:"required,min=1"`
+}
+
+// Response is the only place where the plain key is ever shown.
+type Response struct {
+	Key string `json:"key"`
+	models.APIKey
+}
+
+func New(log *slog.Logger, s APIKeySaver) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context(), log)
+		userLogin, err := auth.GetLogin(r)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to fetch user login from context")
+			problem.Internal(w, r)
+			return
+		}
+
+		req := &Request{}
+		if prob := decode.JSON(w, r, req); prob != nil {
+			problem.Render(w, r, *prob)
+			return
+		}
+
+		req.Name = strings.TrimSpace(req.Name)
+		for _, sc := range req.Scopes {
+			if !apikey.ValidScope(sc) {
+				problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidBody,
+					fmt.Sprintf("unknown scope %q", sc))
+				return
+			}
+		}
+
+		key, prefix, err := apikey.Generate()
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to generate api key", sl.Err(err))
+			problem.Internal(w, r)
+			return
+		}
+
+		saved, err := s.SaveAPIKey(r.Context(), models.APIKey{
+			UserLogin: userLogin,
+			Name:      req.Name,
+			Prefix:    prefix,
+			KeyHash:   apikey.Hash(key),
+			Scopes:    req.Scopes,
+		})
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to save api key", sl.Err(err))
+			problem.Internal(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusCreated)
+		enc := json.NewEncoder(w)
+		err = enc.Encode(Response{Key: key, APIKey: saved})
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to encode api key json", sl.Err(err))
+			return
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package postapikey_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/handlers/apikeys/postapikey"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/mocks"
+	"github.com/VanGoghDev/gophermart/internal/openapi/openapitest"
+	"github.com/VanGoghDev/gophermart/internal/router"
+	"github.com/VanGoghDev/gophermart/internal/services/auth"
+	"github.com/VanGoghDev/gophermart/internal/services/auth/apikey"
+	"github.com/go-resty/resty/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	type args struct {
+		contentType string
+		body        string
+		storageErr  error
+	}
+	tests := []struct {
+		name           string
+		args           args
+		wantStatusCode int
+	}{
+		{
+			name: "must return 201 status",
+			args: args{
+				contentType: "application/json",
+				body:        `{"name": "shop import", "scopes": ["orders:write", "balance:read"]}`,
+			},
+			wantStatusCode: http.StatusCreated,
+		},
+		{
+			name: "must return 400 status (unknown scope)",
+			args: args{
+				contentType: "application/json",
+				body:        `{"name": "shop import", "scopes": ["admin"]}`,
+			},
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "must return 400 status (no scopes)",
+			args: args{
+				contentType: "application/json",
+				body:        `{"name": "shop import", "scopes": []}`,
+			},
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "must return 400 status (no name)",
+			args: args{
+				contentType: "application/json",
+				body:        `{"scopes": ["withdraw"]}`,
+			},
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "must return 500 status",
+			args: args{
+				contentType: "application/json",
+				body:        `{"name": "shop import", "scopes": ["withdraw"]}`,
+				storageErr:  errors.New("storage error"),
+			},
+			wantStatusCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := logger.New("dev")
+			secret := "secret"
+
+			token, err := auth.GenerateToken("test", secret, time.Hour)
+			assert.Empty(t, err)
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			m := mocks.NewMockStorage(ctrl)
+
+			var savedHash string
+			m.EXPECT().SaveAPIKey(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ any, key models.APIKey) (models.APIKey, error) {
+					assert.Equal(t, "test", key.UserLogin)
+					savedHash = key.KeyHash
+					key.ID = 1
+					key.CreatedAtFormated = time.Now().Format(time.RFC3339)
+					return key, tt.args.storageErr
+				}).AnyTimes()
+
+			r := router.New(log, m, secret, time.Hour)
+			srv := httptest.NewServer(openapitest.Handler(t, r))
+			defer srv.Close()
+
+			client := resty.New()
+
+			resp, err := client.R().
+				SetHeader("Content-Type", tt.args.contentType).
+				SetHeader("Authorization", token).
+				SetBody(tt.args.body).
+				Post(fmt.Sprintf("%s/%s", srv.URL, "api/user/apikeys"))
+
+			assert.Empty(t, err)
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode())
+
+			if resp.StatusCode() == http.StatusCreated {
+				var created postapikey.Response
+				assert.Empty(t, json.Unmarshal(resp.Body(), &created))
+				assert.NotEmpty(t, created.Key)
+				// Хранится только хэш ключа.
+				assert.Equal(t, apikey.Hash(created.Key), savedHash)
+				assert.NotContains(t, string(resp.Body()), savedHash)
+			}
+		})
+	}
+}
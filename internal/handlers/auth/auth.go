@@ -2,36 +2,239 @@ package auth
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
 
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/lib/decode"
 	"github.com/VanGoghDev/gophermart/internal/lib/logger/sl"
-	"gopkg.in/go-playground/validator.v9"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/VanGoghDev/gophermart/internal/services/auth"
+	"github.com/VanGoghDev/gophermart/internal/services/auth/hasher"
+	"github.com/VanGoghDev/gophermart/internal/services/auth/policy"
+	"github.com/VanGoghDev/gophermart/internal/services/auth/totp"
+	"github.com/VanGoghDev/gophermart/internal/storage"
 )
 
+// DeviceHeader lets clients name the device a session is created on.
+const DeviceHeader = "X-Device-Name"
+
+const (
+	maxDeviceLength    = 200
+	maxUserAgentLength = 500
+)
+
+type SessionCreator interface {
+	CreateSession(ctx context.Context, session models.Session) (models.Session, error)
+}
+
+type PassHashUpdater interface {
+	UpdatePassHash(ctx context.Context, login string, passHash string) error
+}
+
+// SecondFactorVerifier is the storage a TOTP code or a recovery code is checked against.
+type SecondFactorVerifier interface {
+	GetTOTP(ctx context.Context, login string) (models.TOTP, error)
+	UseTOTPStep(ctx context.Context, login string, step int64) error
+	UseRecoveryCode(ctx context.Context, login string, codeHash string) error
+}
+
 type Request struct {
 	Login    string `json:"login" validate:"required"`
 	Password string `json:"password" validate:"required"`
 }
 
-func ValidateUserRequest(ctx context.Context, log *slog.Logger, r *http.Request) (statusCode int, req *Request) {
-	contentType := r.Header.Get("Content-Type")
-	if contentType != "application/json" {
-		return http.StatusBadRequest, req
+// ValidateUserRequest decodes credentials from the request body. If p is not nil, credentials
+// are checked against the policy and every broken rule is listed in the problem errors.
+func ValidateUserRequest(w http.ResponseWriter, r *http.Request, p *policy.Policy) (*Request, *problem.Problem) {
+	req := &Request{}
+	if prob := decode.JSON(w, r, req); prob != nil {
+		return nil, prob
+	}
+
+	if p != nil {
+		if violations := p.Validate(req.Login, req.Password); len(violations) > 0 {
+			pr := problem.New(http.StatusBadRequest, problem.CodeCredentialPolicy,
+				"credentials do not satisfy the policy")
+			pr.Errors = violations
+			return nil, &pr
+		}
+	}
+
+	return req, nil
+}
+
+// IssueSessionToken starts a session for the device the request came from and grants a token bound to it.
+func IssueSessionToken(
+	r *http.Request,
+	s SessionCreator,
+	login string,
+	role models.Role,
+	secret string,
+	tokenExpires time.Duration,
+) (string, error) {
+	return IssueToken(r.Context(), s, models.Session{
+		UserLogin: login,
+		Device:    r.Header.Get(DeviceHeader),
+		UserAgent: r.UserAgent(),
+		IP:        clientIP(r),
+	}, role, secret, tokenExpires)
+}
+
+// IssueToken starts the session and grants a token bound to it.
+// It is used by transports that describe the device without an *http.Request.
+func IssueToken(
+	ctx context.Context,
+	s SessionCreator,
+	session models.Session,
+	role models.Role,
+	secret string,
+	tokenExpires time.Duration,
+) (string, error) {
+	session.Device = truncate(session.Device, maxDeviceLength)
+	session.UserAgent = truncate(session.UserAgent, maxUserAgentLength)
+	created, err := s.CreateSession(ctx, session)
+	if err != nil {
+		return "", fmt.Errorf("failed to create session: %w", err)
 	}
-	req = &Request{}
-	dec := json.NewDecoder(r.Body)
 
-	err := dec.Decode(req)
+	token, err := auth.GrantSessionToken(session.UserLogin, role, created.ID, secret, tokenExpires)
 	if err != nil {
-		log.ErrorContext(ctx, "%w: failed to unmarshal json", sl.Err(err))
-		return http.StatusBadRequest, req
+		return "", fmt.Errorf("failed to grant session token: %w", err)
 	}
+	return token, nil
+}
 
-	if err := validator.New().Struct(req); err != nil {
-		return http.StatusBadRequest, req
+// RehashPassword stores the password hashed with the current algorithm. Failures are only logged:
+// the user has already proved the password and the old hash keeps working.
+func RehashPassword(
+	ctx context.Context,
+	log *slog.Logger,
+	s PassHashUpdater,
+	h *hasher.Hasher,
+	login string,
+	password string,
+) {
+	passHash, err := h.Hash(password)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to rehash password", sl.Err(err))
+		return
 	}
+	if err := s.UpdatePassHash(ctx, login, passHash); err != nil {
+		log.ErrorContext(ctx, "failed to save rehashed password", sl.Err(err))
+	}
+}
+
+// MaxChallengeAttempts is how many second factors can be tried with one challenge token.
+const MaxChallengeAttempts = 5
+
+// ErrChallengeUsedUp means the challenge token had too many attempts or was already used to sign in.
+var ErrChallengeUsedUp = errors.New("challenge token is used up")
+
+// ChallengeVerifier counts attempts to pass a challenge besides checking the second factor.
+type ChallengeVerifier interface {
+	SecondFactorVerifier
+	UseChallengeAttempt(ctx context.Context, jti string, login string, expiresAt time.Time, maxAttempts int) error
+	CompleteChallenge(ctx context.Context, jti string) error
+}
 
-	return http.StatusOK, req
+// PassChallenge checks the second factor of the challenge. Every call is an attempt: after MaxChallengeAttempts
+// of them, or once the challenge is passed, it fails with ErrChallengeUsedUp, so codes can't be guessed
+// for the whole lifetime of the token.
+func PassChallenge(
+	ctx context.Context,
+	s ChallengeVerifier,
+	c auth.Challenge,
+	code string,
+	recoveryCode string,
+) (ok bool, err error) {
+	err = s.UseChallengeAttempt(ctx, c.ID, c.Login, c.ExpiresAt, MaxChallengeAttempts)
+	if err != nil {
+		if errors.Is(err, storage.ErrLimitExceeded) {
+			return false, ErrChallengeUsedUp
+		}
+		return false, err //nolint:wrapcheck // storage errors are already wrapped
+	}
+
+	ok, err = VerifySecondFactor(ctx, s, c.Login, code, recoveryCode)
+	if err != nil || !ok {
+		return false, err
+	}
+	if err = s.CompleteChallenge(ctx, c.ID); err != nil {
+		return false, err //nolint:wrapcheck // storage errors are already wrapped
+	}
+	return true, nil
+}
+
+// VerifySecondFactor checks the recovery code if it is set, otherwise the TOTP code.
+// Used codes are burned, so ok is false when the same code is presented twice.
+func VerifySecondFactor(
+	ctx context.Context,
+	s SecondFactorVerifier,
+	login string,
+	code string,
+	recoveryCode string,
+) (ok bool, err error) {
+	if recoveryCode != "" {
+		err := s.UseRecoveryCode(ctx, login, totp.HashRecoveryCode(recoveryCode))
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				return false, nil
+			}
+			return false, err //nolint:wrapcheck // storage errors are already wrapped
+		}
+		return true, nil
+	}
+
+	t, err := s.GetTOTP(ctx, login)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return false, nil
+		}
+		return false, err //nolint:wrapcheck // storage errors are already wrapped
+	}
+	if !t.Confirmed() {
+		return false, nil
+	}
+
+	step, ok, err := totp.Validate(t.Secret, code, time.Now())
+	if err != nil || !ok {
+		return false, err //nolint:wrapcheck // totp errors are already wrapped
+	}
+
+	// Повторное использование того же кода отклоняется.
+	err = s.UseTOTPStep(ctx, login, step)
+	if err != nil {
+		if errors.Is(err, storage.ErrConflict) {
+			return false, nil
+		}
+		return false, err //nolint:wrapcheck // storage errors are already wrapped
+	}
+	return true, nil
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// truncate cuts s to at most n bytes without splitting a rune: Postgres rejects invalid UTF-8.
+func truncate(s string, n int) string {
+	s = strings.ToValidUTF8(s, "\uFFFD")
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
 }
@@ -2,6 +2,7 @@ package login
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"log/slog"
 	"net/http"
@@ -10,45 +11,83 @@ import (
 	"github.com/VanGoghDev/gophermart/internal/domain/models"
 	hauth "github.com/VanGoghDev/gophermart/internal/handlers/auth"
 	"github.com/VanGoghDev/gophermart/internal/lib/logger/sl"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/VanGoghDev/gophermart/internal/logger"
 	"github.com/VanGoghDev/gophermart/internal/services/auth"
+	"github.com/VanGoghDev/gophermart/internal/services/auth/hasher"
 	"github.com/VanGoghDev/gophermart/internal/storage"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type UserProvider interface {
 	GetUser(ctx context.Context, login string) (models.User, error)
+	CreateSession(ctx context.Context, session models.Session) (models.Session, error)
+	UpdatePassHash(ctx context.Context, login string, passHash string) error
+	GetTOTP(ctx context.Context, login string) (models.TOTP, error)
 }
 
-func New(log *slog.Logger, s UserProvider, secret string, tokenExpires time.Duration) http.HandlerFunc {
+// ChallengeResponse is returned instead of a token when the user has two-factor auth enabled.
+type ChallengeResponse struct {
+	ChallengeToken string `json:"challenge_token"`
+	ExpiresIn      int    `json:"expires_in"`
+}
+
+func New(
+	log *slog.Logger,
+	s UserProvider,
+	h *hasher.Hasher,
+	secret string,
+	tokenExpires time.Duration,
+) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		code, req := hauth.ValidateUserRequest(r.Context(), log, r)
-		if code >= http.StatusBadRequest {
-			w.WriteHeader(code)
+		log := logger.FromContext(r.Context(), log)
+		// Политику не проверяем: пользователи, зарегистрированные до её введения, должны иметь возможность войти.
+		req, prob := hauth.ValidateUserRequest(w, r, nil)
+		if prob != nil {
+			problem.Render(w, r, *prob)
+			return
 		}
 
 		// 401 неверная пара логин/пароль.
 		user, err := s.GetUser(r.Context(), req.Login)
 		if err != nil {
 			if errors.Is(err, storage.ErrNotFound) {
-				w.WriteHeader(http.StatusUnauthorized)
+				writeInvalidCredentials(w, r)
 				return
 			}
 			log.ErrorContext(r.Context(), "failed to get user", sl.Err(err))
-			w.WriteHeader(http.StatusInternalServerError)
+			problem.Internal(w, r)
 			return
 		}
 
-		if err := bcrypt.CompareHashAndPassword(user.PassHash, []byte(req.Password)); err != nil {
-			log.InfoContext(r.Context(), "invalid credentials", sl.Err(err))
-			w.WriteHeader(http.StatusUnauthorized)
+		ok, err := h.Verify(req.Password, string(user.PassHash))
+		if err != nil || !ok {
+			log.InfoContext(r.Context(), "invalid credentials", "login", user.Login)
+			writeInvalidCredentials(w, r)
+			return
+		}
+
+		// Пароль верный, значит можно прозрачно перехешировать его актуальным алгоритмом.
+		if h.NeedsRehash(string(user.PassHash)) {
+			hauth.RehashPassword(r.Context(), log, s, h, user.Login, req.Password)
+		}
+
+		// Если включена двухфакторная аутентификация, токен выдаётся только после проверки кода.
+		t, err := s.GetTOTP(r.Context(), user.Login)
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			log.ErrorContext(r.Context(), "failed to get totp", sl.Err(err))
+			problem.Internal(w, r)
+			return
+		}
+		if err == nil && t.Confirmed() {
+			writeChallenge(log, w, r, user.Login, secret)
 			return
 		}
 
 		// выписать токен
-		token, err := auth.GrantToken(user.Login, secret, tokenExpires)
+		token, err := hauth.IssueSessionToken(r, s, user.Login, user.Role, secret, tokenExpires)
 		if err != nil {
 			log.ErrorContext(r.Context(), "failed to generate auth token", sl.Err(err))
-			w.WriteHeader(http.StatusInternalServerError)
+			problem.Internal(w, r)
 			return
 		}
 
@@ -56,3 +95,29 @@ func New(log *slog.Logger, s UserProvider, secret string, tokenExpires time.Dura
 		w.Header().Set("Authorization", token)
 	}
 }
+
+// writeInvalidCredentials does not tell an unknown login from a wrong password.
+func writeInvalidCredentials(w http.ResponseWriter, r *http.Request) {
+	problem.Write(w, r, http.StatusUnauthorized, problem.CodeInvalidCredentials, "invalid login or password")
+}
+
+func writeChallenge(log *slog.Logger, w http.ResponseWriter, r *http.Request, login string, secret string) {
+	ctx := r.Context()
+	challenge, err := auth.GrantChallengeToken(login, secret, auth.ChallengeTTL)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to generate challenge token", sl.Err(err))
+		problem.Internal(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	enc := json.NewEncoder(w)
+	err = enc.Encode(ChallengeResponse{
+		ChallengeToken: challenge,
+		ExpiresIn:      int(auth.ChallengeTTL.Seconds()),
+	})
+	if err != nil {
+		log.ErrorContext(ctx, "failed to encode challenge json", sl.Err(err))
+	}
+}
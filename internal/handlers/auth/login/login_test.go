@@ -1,18 +1,25 @@
 package login_test
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
+	"unicode/utf8"
 
-	"github.com/VanGoghDev/gophermart/internal/config"
 	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	hauth "github.com/VanGoghDev/gophermart/internal/handlers/auth"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
 	"github.com/VanGoghDev/gophermart/internal/logger"
 	"github.com/VanGoghDev/gophermart/internal/mocks"
+	"github.com/VanGoghDev/gophermart/internal/openapi/openapitest"
 	"github.com/VanGoghDev/gophermart/internal/router"
 	"github.com/VanGoghDev/gophermart/internal/services/auth"
+	"github.com/VanGoghDev/gophermart/internal/services/auth/hasher"
 	"github.com/VanGoghDev/gophermart/internal/storage"
 	"github.com/go-resty/resty/v2"
 	"github.com/golang/mock/gomock"
@@ -51,7 +58,7 @@ func TestNew(t *testing.T) {
 				storageErr: nil,
 			},
 			want: want{
-				http.StatusOK,
+				statusCode: http.StatusOK,
 			},
 		},
 		{
@@ -62,7 +69,7 @@ func TestNew(t *testing.T) {
 				storageErr:  errors.New("storage error"),
 			},
 			want: want{
-				http.StatusBadRequest,
+				statusCode: http.StatusBadRequest,
 			},
 		},
 		{
@@ -73,7 +80,7 @@ func TestNew(t *testing.T) {
 				storageErr:  errors.New("storage error"),
 			},
 			want: want{
-				http.StatusBadRequest,
+				statusCode: http.StatusBadRequest,
 			},
 		},
 		{
@@ -84,7 +91,7 @@ func TestNew(t *testing.T) {
 				body:        "{\"login\": \"\", \"password\":\"\"}",
 			},
 			want: want{
-				http.StatusBadRequest,
+				statusCode: http.StatusBadRequest,
 			},
 		},
 		{
@@ -95,7 +102,7 @@ func TestNew(t *testing.T) {
 				body:        "{\"login\": \"\", \"password\":\"123\"}",
 			},
 			want: want{
-				http.StatusBadRequest,
+				statusCode: http.StatusBadRequest,
 			},
 		},
 		{
@@ -106,7 +113,7 @@ func TestNew(t *testing.T) {
 				body:        "{\"login\": \"test\", \"password\":\"\"}",
 			},
 			want: want{
-				http.StatusBadRequest,
+				statusCode: http.StatusBadRequest,
 			},
 		},
 		{
@@ -122,7 +129,7 @@ func TestNew(t *testing.T) {
 				},
 			},
 			want: want{
-				http.StatusUnauthorized,
+				statusCode: http.StatusUnauthorized,
 			},
 		},
 		{
@@ -134,17 +141,16 @@ func TestNew(t *testing.T) {
 				storageErr:  storage.ErrNotFound,
 			},
 			want: want{
-				http.StatusUnauthorized,
+				statusCode: http.StatusUnauthorized,
 			},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			log := logger.New("dev")
-			cfg, err := config.New()
-			assert.Empty(t, err)
+			secret := "secret"
 
-			token, err := auth.GenerateToken(tt.args.login, cfg.Secret, cfg.TokenExpires)
+			token, err := auth.GenerateToken(tt.args.login, secret, time.Hour)
 			assert.Empty(t, err)
 
 			ctrl := gomock.NewController(t)
@@ -159,8 +165,16 @@ func TestNew(t *testing.T) {
 			m.EXPECT().GetUser(gomock.Any(), gomock.Any()).
 				Return(tt.args.storageUser, tt.args.storageErr).AnyTimes()
 
-			r := router.New(log, m, cfg.Secret, cfg.TokenExpires)
-			srv := httptest.NewServer(r)
+			m.EXPECT().UpdatePassHash(gomock.Any(), tt.args.login, gomock.Any()).
+				Return(nil).AnyTimes()
+
+			m.EXPECT().GetTOTP(gomock.Any(), gomock.Any()).
+				Return(models.TOTP{}, storage.ErrNotFound).AnyTimes()
+			m.EXPECT().CreateSession(gomock.Any(), gomock.Any()).
+				Return(models.Session{ID: 1}, nil).AnyTimes()
+
+			r := router.New(log, m, secret, time.Hour)
+			srv := httptest.NewServer(openapitest.Handler(t, r))
 			defer srv.Close()
 
 			client := resty.New()
@@ -176,6 +190,169 @@ func TestNew(t *testing.T) {
 			if resp.StatusCode() == http.StatusOK {
 				assert.NotEmpty(t, resp.Header().Get("Authorization"))
 			}
+			if resp.StatusCode() >= http.StatusBadRequest {
+				var p problem.Problem
+				assert.Equal(t, problem.ContentType, resp.Header().Get("Content-Type"))
+				assert.Empty(t, json.Unmarshal(resp.Body(), &p))
+				assert.NotEmpty(t, p.Code)
+			}
 		})
 	}
 }
+
+func TestRehash(t *testing.T) {
+	legacyHash, err := bcrypt.GenerateFromPassword([]byte("123"), bcrypt.DefaultCost)
+	assert.Empty(t, err)
+	currentHash, err := hasher.Default().Hash("123")
+	assert.Empty(t, err)
+
+	tests := []struct {
+		name           string
+		passHash       []byte
+		password       string
+		wantStatusCode int
+		wantRehash     bool
+	}{
+		{
+			name:           "must rehash legacy hash",
+			passHash:       legacyHash,
+			password:       "123",
+			wantStatusCode: http.StatusOK,
+			wantRehash:     true,
+		},
+		{
+			name:           "must keep up to date hash",
+			passHash:       []byte(currentHash),
+			password:       "123",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "must not rehash on wrong password",
+			passHash:       legacyHash,
+			password:       "1234",
+			wantStatusCode: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := logger.New("dev")
+			secret := "secret"
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			m := mocks.NewMockStorage(ctrl)
+
+			m.EXPECT().GetUser(gomock.Any(), "test").
+				Return(models.User{Login: "test", PassHash: tt.passHash}, nil)
+			rehashCalls := 0
+			if tt.wantRehash {
+				rehashCalls = 1
+			}
+			m.EXPECT().UpdatePassHash(gomock.Any(), "test", gomock.Any()).
+				DoAndReturn(func(_ any, _ string, passHash string) error {
+					ok, err := hasher.Default().Verify("123", passHash)
+					assert.Empty(t, err)
+					assert.True(t, ok, "the new hash must match the password")
+					return nil
+				}).Times(rehashCalls)
+			m.EXPECT().GetTOTP(gomock.Any(), "test").Return(models.TOTP{}, storage.ErrNotFound).AnyTimes()
+			m.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Return(models.Session{ID: 1}, nil).AnyTimes()
+
+			r := router.New(log, m, secret, time.Hour)
+			srv := httptest.NewServer(openapitest.Handler(t, r))
+			defer srv.Close()
+
+			resp, err := resty.New().R().
+				SetHeader("Content-Type", "application/json").
+				SetBody(fmt.Sprintf(`{"login": "test", "password": %q}`, tt.password)).
+				Post(fmt.Sprintf("%s/%s", srv.URL, "api/user/login"))
+
+			assert.Empty(t, err)
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode())
+		})
+	}
+}
+
+func TestTOTPChallenge(t *testing.T) {
+	log := logger.New("dev")
+	secret := "secret"
+	confirmedAt := time.Now()
+
+	passHash, err := hasher.Default().Hash("123")
+	assert.Empty(t, err)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := mocks.NewMockStorage(ctrl)
+	m.EXPECT().GetUser(gomock.Any(), "test").
+		Return(models.User{Login: "test", PassHash: []byte(passHash)}, nil)
+	m.EXPECT().GetTOTP(gomock.Any(), "test").
+		Return(models.TOTP{UserLogin: "test", ConfirmedAt: &confirmedAt}, nil)
+
+	r := router.New(log, m, secret, time.Hour)
+	srv := httptest.NewServer(openapitest.Handler(t, r))
+	defer srv.Close()
+
+	resp, err := resty.New().R().
+		SetHeader("Content-Type", "application/json").
+		SetBody(`{"login": "test", "password": "123"}`).
+		Post(fmt.Sprintf("%s/%s", srv.URL, "api/user/login"))
+
+	assert.Empty(t, err)
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode())
+	assert.Empty(t, resp.Header().Get("Authorization"), "no session before the second factor")
+
+	var body struct {
+		ChallengeToken string `json:"challenge_token"`
+	}
+	assert.Empty(t, json.Unmarshal(resp.Body(), &body))
+	c, err := auth.ParseChallengeToken(body.ChallengeToken, secret)
+	assert.Empty(t, err)
+	assert.Equal(t, "test", c.Login)
+}
+
+func TestSessionClientInfo(t *testing.T) {
+	log := logger.New("dev")
+	secret := "secret"
+	// Граница обрезки приходится на середину двухбайтовой руны, а невалидный байт заменяется.
+	userAgent := "a" + strings.Repeat("я", 300)
+	device := "\xff" + strings.Repeat("ж", 150)
+
+	passHash, err := hasher.Default().Hash("123")
+	assert.Empty(t, err)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := mocks.NewMockStorage(ctrl)
+	m.EXPECT().GetUser(gomock.Any(), "test").
+		Return(models.User{Login: "test", PassHash: []byte(passHash)}, nil)
+	m.EXPECT().GetTOTP(gomock.Any(), "test").
+		Return(models.TOTP{}, storage.ErrNotFound)
+	m.EXPECT().CreateSession(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ any, session models.Session) (models.Session, error) {
+			assert.True(t, utf8.ValidString(session.UserAgent))
+			assert.LessOrEqual(t, len(session.UserAgent), 500)
+			assert.Equal(t, "a"+strings.Repeat("я", 249), session.UserAgent)
+
+			assert.True(t, utf8.ValidString(session.Device))
+			assert.LessOrEqual(t, len(session.Device), 200)
+			assert.True(t, strings.HasPrefix(session.Device, "\uFFFDж"))
+			session.ID = 1
+			return session, nil
+		})
+
+	r := router.New(log, m, secret, time.Hour)
+	srv := httptest.NewServer(openapitest.Handler(t, r))
+	defer srv.Close()
+
+	resp, err := resty.New().R().
+		SetHeader("Content-Type", "application/json").
+		SetHeader("User-Agent", userAgent).
+		SetHeader(hauth.DeviceHeader, device).
+		SetBody(`{"login": "test", "password": "123"}`).
+		Post(fmt.Sprintf("%s/%s", srv.URL, "api/user/login"))
+
+	assert.Empty(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	assert.NotEmpty(t, resp.Header().Get("Authorization"))
+}
@@ -0,0 +1,101 @@
+package logintotp
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	hauth "github.com/VanGoghDev/gophermart/internal/handlers/auth"
+	"github.com/VanGoghDev/gophermart/internal/lib/decode"
+	"github.com/VanGoghDev/gophermart/internal/lib/logger/sl"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/services/auth"
+	"github.com/VanGoghDev/gophermart/internal/storage"
+)
+
+type UserProvider interface {
+	GetUser(ctx context.Context, login string) (models.User, error)
+	CreateSession(ctx context.Context, session models.Session) (models.Session, error)
+	GetTOTP(ctx context.Context, login string) (models.TOTP, error)
+	UseTOTPStep(ctx context.Context, login string, step int64) error
+	UseRecoveryCode(ctx context.Context, login string, codeHash string) error
+	UseChallengeAttempt(ctx context.Context, jti string, login string, expiresAt time.Time, maxAttempts int) error
+	CompleteChallenge(ctx context.Context, jti string) error
+}
+
+// Request must contain either a TOTP code or one of the recovery codes.
+type Request struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	Code           string `json:"code,omitempty" validate:"required_without=RecoveryCode"`
+	RecoveryCode   string `json:"recovery_code,omitempty" validate:"required_without=Code"`
+}
+
+// New is the second login step: it exchanges the challenge token and a second factor for the auth token.
+func New(log *slog.Logger, s UserProvider, secret string, tokenExpires time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context(), log)
+		req := &Request{}
+		if prob := decode.JSON(w, r, req); prob != nil {
+			problem.Render(w, r, *prob)
+			return
+		}
+		if req.Code != "" && req.RecoveryCode != "" {
+			problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidBody,
+				"only one of code or recovery_code is allowed")
+			return
+		}
+
+		challenge, err := auth.ParseChallengeToken(req.ChallengeToken, secret)
+		if err != nil {
+			log.InfoContext(r.Context(), "invalid challenge token", sl.Err(err))
+			problem.Write(w, r, http.StatusUnauthorized, problem.CodeInvalidChallenge,
+				"challenge token is invalid or expired")
+			return
+		}
+
+		login := challenge.Login
+		ok, err := hauth.PassChallenge(r.Context(), s, challenge, req.Code, req.RecoveryCode)
+		if errors.Is(err, hauth.ErrChallengeUsedUp) {
+			log.InfoContext(r.Context(), "challenge token is used up", "login", login)
+			problem.Write(w, r, http.StatusUnauthorized, problem.CodeInvalidChallenge,
+				"challenge token is used up, sign in again")
+			return
+		}
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to verify second factor", sl.Err(err))
+			problem.Internal(w, r)
+			return
+		}
+		if !ok {
+			log.InfoContext(r.Context(), "invalid second factor", "login", login)
+			problem.Write(w, r, http.StatusUnauthorized, problem.CodeInvalidSecondFactor,
+				"code is invalid or was already used")
+			return
+		}
+
+		user, err := s.GetUser(r.Context(), login)
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				problem.Write(w, r, http.StatusUnauthorized, problem.CodeInvalidCredentials, "user no longer exists")
+				return
+			}
+			log.ErrorContext(r.Context(), "failed to get user", sl.Err(err))
+			problem.Internal(w, r)
+			return
+		}
+
+		token, err := hauth.IssueSessionToken(r, s, user.Login, user.Role, secret, tokenExpires)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to generate auth token", sl.Err(err))
+			problem.Internal(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Authorization", token)
+	}
+}
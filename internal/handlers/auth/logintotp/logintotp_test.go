@@ -0,0 +1,249 @@
+package logintotp_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	hauth "github.com/VanGoghDev/gophermart/internal/handlers/auth"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/mocks"
+	"github.com/VanGoghDev/gophermart/internal/openapi/openapitest"
+	"github.com/VanGoghDev/gophermart/internal/router"
+	"github.com/VanGoghDev/gophermart/internal/services/auth"
+	"github.com/VanGoghDev/gophermart/internal/services/auth/totp"
+	"github.com/VanGoghDev/gophermart/internal/storage"
+	"github.com/go-resty/resty/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	tokenSecret := "secret"
+	secret, err := totp.GenerateSecret()
+	assert.Empty(t, err)
+	validCode, err := totp.Code(secret, totp.Step(time.Now()))
+	assert.Empty(t, err)
+	challenge, err := auth.GrantChallengeToken("test", tokenSecret, auth.ChallengeTTL)
+	assert.Empty(t, err)
+	authToken, err := auth.GenerateToken("test", tokenSecret, time.Hour)
+	assert.Empty(t, err)
+	confirmedAt := time.Now()
+
+	type args struct {
+		body        string
+		stepErr     error
+		recoveryErr error
+	}
+	tests := []struct {
+		name           string
+		args           args
+		wantStatusCode int
+	}{
+		{
+			name: "must return 200 status (totp code)",
+			args: args{
+				body: fmt.Sprintf(`{"challenge_token": %q, "code": %q}`, challenge, validCode),
+			},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name: "must return 200 status (recovery code)",
+			args: args{
+				body: fmt.Sprintf(`{"challenge_token": %q, "recovery_code": "abcd-efgh"}`, challenge),
+			},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name: "must return 400 status (both factors)",
+			args: args{
+				body: fmt.Sprintf(`{"challenge_token": %q, "code": %q, "recovery_code": "abcd-efgh"}`,
+					challenge, validCode),
+			},
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "must return 401 status (auth token instead of challenge)",
+			args: args{
+				body: fmt.Sprintf(`{"challenge_token": %q, "code": %q}`, authToken, validCode),
+			},
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name: "must return 401 status (wrong code)",
+			args: args{
+				body: fmt.Sprintf(`{"challenge_token": %q, "code": "abcdef"}`, challenge),
+			},
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name: "must return 401 status (replayed code)",
+			args: args{
+				body:    fmt.Sprintf(`{"challenge_token": %q, "code": %q}`, challenge, validCode),
+				stepErr: storage.ErrConflict,
+			},
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name: "must return 401 status (used recovery code)",
+			args: args{
+				body:        fmt.Sprintf(`{"challenge_token": %q, "recovery_code": "abcd-efgh"}`, challenge),
+				recoveryErr: storage.ErrNotFound,
+			},
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name: "must return 500 status",
+			args: args{
+				body:    fmt.Sprintf(`{"challenge_token": %q, "code": %q}`, challenge, validCode),
+				stepErr: errors.New("storage error"),
+			},
+			wantStatusCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := logger.New("dev")
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			m := mocks.NewMockStorage(ctrl)
+
+			m.EXPECT().GetUser(gomock.Any(), "test").
+				Return(models.User{Login: "test", Role: models.RoleUser}, nil).AnyTimes()
+			m.EXPECT().GetTOTP(gomock.Any(), "test").
+				Return(models.TOTP{UserLogin: "test", Secret: secret, ConfirmedAt: &confirmedAt}, nil).AnyTimes()
+			m.EXPECT().UseTOTPStep(gomock.Any(), "test", gomock.Any()).
+				Return(tt.args.stepErr).AnyTimes()
+			m.EXPECT().UseRecoveryCode(gomock.Any(), "test", totp.HashRecoveryCode("abcd-efgh")).
+				Return(tt.args.recoveryErr).AnyTimes()
+			m.EXPECT().CreateSession(gomock.Any(), gomock.Any()).
+				Return(models.Session{ID: 1}, nil).AnyTimes()
+			expectChallenges(m, newChallengeCounter())
+
+			r := router.New(log, m, tokenSecret, time.Hour)
+			srv := httptest.NewServer(openapitest.Handler(t, r))
+			defer srv.Close()
+
+			client := resty.New()
+
+			resp, err := client.R().
+				SetHeader("Content-Type", "application/json").
+				SetBody(tt.args.body).
+				Post(fmt.Sprintf("%s/%s", srv.URL, "api/user/login/totp"))
+
+			assert.Empty(t, err)
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode())
+			if resp.StatusCode() == http.StatusOK {
+				login, err := auth.ExtractLoginFromToken(resp.Header().Get("Authorization"), tokenSecret)
+				assert.Empty(t, err)
+				assert.Equal(t, "test", login)
+			}
+		})
+	}
+}
+
+func TestChallengeAttempts(t *testing.T) {
+	tokenSecret := "secret"
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	validCode, err := totp.Code(secret, totp.Step(time.Now()))
+	require.NoError(t, err)
+	confirmedAt := time.Now()
+
+	log := logger.New("dev")
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := mocks.NewMockStorage(ctrl)
+	m.EXPECT().GetUser(gomock.Any(), "test").
+		Return(models.User{Login: "test", Role: models.RoleUser}, nil).AnyTimes()
+	m.EXPECT().GetTOTP(gomock.Any(), "test").
+		Return(models.TOTP{UserLogin: "test", Secret: secret, ConfirmedAt: &confirmedAt}, nil).AnyTimes()
+	m.EXPECT().UseTOTPStep(gomock.Any(), "test", gomock.Any()).Return(nil).AnyTimes()
+	m.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Return(models.Session{ID: 1}, nil).AnyTimes()
+	expectChallenges(m, newChallengeCounter())
+
+	r := router.New(log, m, tokenSecret, time.Hour)
+	srv := httptest.NewServer(openapitest.Handler(t, r))
+	defer srv.Close()
+
+	send := func(challenge string, code string) (int, string) {
+		resp, err := resty.New().R().
+			SetHeader("Content-Type", "application/json").
+			SetBody(fmt.Sprintf(`{"challenge_token": %q, "code": %q}`, challenge, code)).
+			Post(srv.URL + "/api/user/login/totp")
+		require.NoError(t, err)
+		var p problem.Problem
+		_ = json.Unmarshal(resp.Body(), &p)
+		return resp.StatusCode(), p.Code
+	}
+
+	t.Run("must reject the challenge after too many wrong codes", func(t *testing.T) {
+		challenge, err := auth.GrantChallengeToken("test", tokenSecret, auth.ChallengeTTL)
+		require.NoError(t, err)
+		for i := 0; i < hauth.MaxChallengeAttempts; i++ {
+			status, code := send(challenge, "000000")
+			assert.Equal(t, http.StatusUnauthorized, status)
+			assert.Equal(t, problem.CodeInvalidSecondFactor, code, "attempt %d", i+1)
+		}
+
+		status, code := send(challenge, "000000")
+		assert.Equal(t, http.StatusUnauthorized, status)
+		assert.Equal(t, problem.CodeInvalidChallenge, code, "the token has not expired yet")
+
+		status, code = send(challenge, validCode)
+		assert.Equal(t, http.StatusUnauthorized, status)
+		assert.Equal(t, problem.CodeInvalidChallenge, code, "a valid code must not help either")
+	})
+
+	t.Run("must not accept a passed challenge again", func(t *testing.T) {
+		challenge, err := auth.GrantChallengeToken("test", tokenSecret, auth.ChallengeTTL)
+		require.NoError(t, err)
+		status, _ := send(challenge, validCode)
+		assert.Equal(t, http.StatusOK, status)
+
+		status, code := send(challenge, validCode)
+		assert.Equal(t, http.StatusUnauthorized, status)
+		assert.Equal(t, problem.CodeInvalidChallenge, code)
+	})
+}
+
+// challengeCounter counts attempts the way storage does.
+type challengeCounter struct {
+	attempts  map[string]int
+	completed map[string]bool
+	mu        sync.Mutex
+}
+
+func newChallengeCounter() *challengeCounter {
+	return &challengeCounter{attempts: make(map[string]int), completed: make(map[string]bool)}
+}
+
+func expectChallenges(m *mocks.MockStorage, c *challengeCounter) {
+	m.EXPECT().UseChallengeAttempt(gomock.Any(), gomock.Any(), "test", gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, jti string, _ string, _ time.Time, maxAttempts int) error {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			if c.completed[jti] || c.attempts[jti] >= maxAttempts {
+				return storage.ErrLimitExceeded
+			}
+			c.attempts[jti]++
+			return nil
+		}).AnyTimes()
+	m.EXPECT().CompleteChallenge(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, jti string) error {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			c.completed[jti] = true
+			return nil
+		}).AnyTimes()
+}
@@ -0,0 +1,47 @@
+package authorize
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/VanGoghDev/gophermart/internal/lib/logger/sl"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/services/auth/oidc"
+)
+
+type AuthURLProvider interface {
+	AuthCodeURL(f oidc.Flow) string
+}
+
+// New redirects the user to the identity provider. State, PKCE verifier and nonce are kept
+// in a signed cookie, so the callback can be served by any instance.
+func New(log *slog.Logger, p AuthURLProvider, secret string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context(), log)
+		f, err := oidc.NewFlow()
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to start oidc flow", sl.Err(err))
+			problem.Internal(w, r)
+			return
+		}
+
+		encoded, err := oidc.EncodeFlow(f, secret)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to encode oidc flow", sl.Err(err))
+			problem.Internal(w, r)
+			return
+		}
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     oidc.FlowCookie,
+			Value:    encoded,
+			Path:     "/api/user/oidc",
+			MaxAge:   int(oidc.FlowTTL.Seconds()),
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+			SameSite: http.SameSiteLaxMode,
+		})
+		http.Redirect(w, r, p.AuthCodeURL(f), http.StatusFound)
+	}
+}
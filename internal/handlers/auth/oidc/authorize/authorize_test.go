@@ -0,0 +1,105 @@
+package authorize_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/mocks"
+	"github.com/VanGoghDev/gophermart/internal/openapi/openapitest"
+	"github.com/VanGoghDev/gophermart/internal/router"
+	"github.com/VanGoghDev/gophermart/internal/services/auth/oidc"
+	"github.com/VanGoghDev/gophermart/internal/services/auth/oidc/oidctest"
+	"github.com/go-resty/resty/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	idp := oidctest.New("gophermart", "client-secret")
+	defer idp.Close()
+
+	tests := []struct {
+		name           string
+		enabled        bool
+		secret         string
+		wantStatusCode int
+	}{
+		{
+			name:           "must return 302 status",
+			enabled:        true,
+			secret:         "secret",
+			wantStatusCode: http.StatusFound,
+		},
+		{
+			name:           "must return 500 status (flow can't be signed)",
+			enabled:        true,
+			wantStatusCode: http.StatusInternalServerError,
+		},
+		{
+			name:           "must return 404 status (oidc disabled)",
+			secret:         "secret",
+			wantStatusCode: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := logger.New("dev")
+			secret := tt.secret
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			m := mocks.NewMockStorage(ctrl)
+
+			opts := make([]router.Option, 0)
+			if tt.enabled {
+				p, err := oidc.New(context.Background(), idp.URL, idp.ClientID, idp.ClientSecret,
+					"http://localhost/api/user/oidc/callback", nil)
+				assert.Empty(t, err)
+				opts = append(opts, router.WithOIDC(p))
+			}
+
+			r := router.New(log, m, secret, time.Hour, opts...)
+			srv := httptest.NewServer(openapitest.Handler(t, r))
+			defer srv.Close()
+
+			client := resty.New().SetRedirectPolicy(resty.NoRedirectPolicy())
+
+			resp, _ := client.R().Get(fmt.Sprintf("%s/%s", srv.URL, "api/user/oidc/authorize"))
+
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode())
+			if resp.StatusCode() == http.StatusInternalServerError {
+				assert.Equal(t, problem.ContentType, resp.Header().Get("Content-Type"))
+			}
+			if resp.StatusCode() != http.StatusFound {
+				return
+			}
+
+			loc, err := url.Parse(resp.Header().Get("Location"))
+			assert.Empty(t, err)
+			assert.True(t, strings.HasPrefix(loc.String(), idp.URL+"/authorize"), loc.String())
+			assert.Equal(t, "S256", loc.Query().Get("code_challenge_method"))
+			assert.NotEmpty(t, loc.Query().Get("code_challenge"))
+			assert.NotEmpty(t, loc.Query().Get("nonce"))
+
+			var flow *http.Cookie
+			for _, c := range resp.Cookies() {
+				if c.Name == oidc.FlowCookie {
+					flow = c
+				}
+			}
+			if assert.NotNil(t, flow) {
+				assert.True(t, flow.HttpOnly)
+				_, err = oidc.DecodeFlow(flow.Value, loc.Query().Get("state"), secret)
+				assert.Empty(t, err)
+			}
+		})
+	}
+}
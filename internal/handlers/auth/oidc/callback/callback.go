@@ -0,0 +1,126 @@
+package callback
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	hauth "github.com/VanGoghDev/gophermart/internal/handlers/auth"
+	"github.com/VanGoghDev/gophermart/internal/lib/logger/sl"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/services/auth/oidc"
+	"github.com/VanGoghDev/gophermart/internal/storage"
+)
+
+const maxLoginLength = 64
+
+var forbiddenLoginChars = regexp.MustCompile(`[^a-zA-Z0-9._@-]`)
+
+type Exchanger interface {
+	Exchange(ctx context.Context, code string, f oidc.Flow) (oidc.Identity, error)
+}
+
+type UserProvider interface {
+	GetOrCreateOIDCUser(ctx context.Context, issuer string, subject string, candidates []string) (models.User, error)
+	CreateSession(ctx context.Context, session models.Session) (models.Session, error)
+}
+
+// New finishes the sign in: it verifies the identity with the provider, maps it to a user
+// and issues the usual token. Second factor is left to the identity provider.
+func New(
+	log *slog.Logger,
+	p Exchanger,
+	s UserProvider,
+	secret string,
+	tokenExpires time.Duration,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context(), log)
+		q := r.URL.Query()
+		if idpErr := q.Get("error"); idpErr != "" {
+			log.InfoContext(r.Context(), "identity provider denied sign in", slog.String("error", idpErr))
+			problem.Write(w, r, http.StatusUnauthorized, problem.CodeOIDCDenied, "identity provider denied sign in")
+			return
+		}
+
+		cookie, err := r.Cookie(oidc.FlowCookie)
+		if err != nil || q.Get("code") == "" {
+			problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidOIDCFlow, "sign in was not started or expired")
+			return
+		}
+		f, err := oidc.DecodeFlow(cookie.Value, q.Get("state"), secret)
+		if err != nil {
+			log.InfoContext(r.Context(), "invalid oidc flow", sl.Err(err))
+			problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidOIDCFlow, "state does not match")
+			return
+		}
+
+		// Cookie одноразовая.
+		http.SetCookie(w, &http.Cookie{Name: oidc.FlowCookie, Path: "/api/user/oidc", MaxAge: -1})
+
+		id, err := p.Exchange(r.Context(), q.Get("code"), f)
+		if err != nil {
+			log.InfoContext(r.Context(), "oidc exchange failed", sl.Err(err))
+			problem.Write(w, r, http.StatusUnauthorized, problem.CodeOIDCDenied, "identity could not be verified")
+			return
+		}
+
+		user, err := s.GetOrCreateOIDCUser(r.Context(), id.Issuer, id.Subject, loginCandidates(id))
+		if err != nil {
+			if errors.Is(err, storage.ErrAlreadyExists) || errors.Is(err, storage.ErrConflict) {
+				log.InfoContext(r.Context(), "failed to map oidc subject", sl.Err(err))
+				problem.Write(w, r, http.StatusConflict, problem.CodeOIDCLoginTaken, "no free login for the identity")
+				return
+			}
+			log.ErrorContext(r.Context(), "failed to get oidc user", sl.Err(err))
+			problem.Internal(w, r)
+			return
+		}
+
+		token, err := hauth.IssueSessionToken(r, s, user.Login, user.Role, secret, tokenExpires)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to generate auth token", sl.Err(err))
+			problem.Internal(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Authorization", token)
+	}
+}
+
+// loginCandidates lists logins for a new user, most readable first. The last one is derived
+// from the subject and is practically always free.
+func loginCandidates(id oidc.Identity) []string {
+	sum := sha256.Sum256([]byte(id.Issuer + "\n" + id.Subject))
+	suffix := hex.EncodeToString(sum[:4])
+
+	candidates := make([]string, 0)
+	for _, name := range []string{id.PreferredUsername, id.Email} {
+		name = sanitizeLogin(name)
+		if name != "" {
+			candidates = append(candidates, name, truncate(name, maxLoginLength-len(suffix)-1)+"-"+suffix)
+		}
+	}
+	return append(candidates, "oidc-"+suffix+hex.EncodeToString(sum[4:8]))
+}
+
+func sanitizeLogin(name string) string {
+	name = forbiddenLoginChars.ReplaceAllString(strings.TrimSpace(name), "-")
+	return truncate(name, maxLoginLength)
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
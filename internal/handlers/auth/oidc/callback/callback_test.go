@@ -0,0 +1,123 @@
+package callback_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/mocks"
+	"github.com/VanGoghDev/gophermart/internal/openapi/openapitest"
+	"github.com/VanGoghDev/gophermart/internal/router"
+	"github.com/VanGoghDev/gophermart/internal/services/auth"
+	"github.com/VanGoghDev/gophermart/internal/services/auth/oidc"
+	"github.com/VanGoghDev/gophermart/internal/services/auth/oidc/oidctest"
+	"github.com/VanGoghDev/gophermart/internal/storage"
+	"github.com/go-resty/resty/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	idp := oidctest.New("gophermart", "client-secret")
+	defer idp.Close()
+	idp.SignIn(oidctest.User{Subject: "42", Email: "john@corp.example", PreferredUsername: "john"})
+
+	type args struct {
+		path        string
+		storageUser models.User
+		storageErr  error
+	}
+	tests := []struct {
+		name           string
+		args           args
+		wantStatusCode int
+	}{
+		{
+			name: "must return 200 status",
+			args: args{
+				path:        "api/user/oidc/authorize",
+				storageUser: models.User{Login: "john", Role: models.RoleUser},
+			},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name: "must return 400 status (no flow cookie)",
+			args: args{
+				path: "api/user/oidc/callback?code=abc&state=xyz",
+			},
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "must return 401 status (provider error)",
+			args: args{
+				path: "api/user/oidc/callback?error=access_denied",
+			},
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name: "must return 409 status (no free login)",
+			args: args{
+				path:       "api/user/oidc/authorize",
+				storageErr: storage.ErrAlreadyExists,
+			},
+			wantStatusCode: http.StatusConflict,
+		},
+		{
+			name: "must return 500 status",
+			args: args{
+				path:       "api/user/oidc/authorize",
+				storageErr: errors.New("storage error"),
+			},
+			wantStatusCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := logger.New("dev")
+			secret := "secret"
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			m := mocks.NewMockStorage(ctrl)
+
+			m.EXPECT().GetOrCreateOIDCUser(gomock.Any(), idp.URL, "42", gomock.Any()).
+				DoAndReturn(func(_ any, _ string, _ string, candidates []string) (models.User, error) {
+					assert.Equal(t, "john", candidates[0])
+					return tt.args.storageUser, tt.args.storageErr
+				}).AnyTimes()
+			m.EXPECT().CreateSession(gomock.Any(), gomock.Any()).
+				Return(models.Session{ID: 1}, nil).AnyTimes()
+
+			// Адрес callback известен только после запуска сервера.
+			var h http.Handler
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				h.ServeHTTP(w, r)
+			}))
+			defer srv.Close()
+
+			p, err := oidc.New(context.Background(), idp.URL, idp.ClientID, idp.ClientSecret,
+				srv.URL+"/api/user/oidc/callback", nil)
+			assert.Empty(t, err)
+			h = openapitest.Handler(t, router.New(log, m, secret, time.Hour, router.WithOIDC(p)))
+
+			// Клиент проходит редиректы к IdP и обратно, сохраняя cookie.
+			client := resty.New()
+
+			resp, err := client.R().Get(fmt.Sprintf("%s/%s", srv.URL, tt.args.path))
+
+			assert.Empty(t, err)
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode())
+			if resp.StatusCode() == http.StatusOK {
+				login, err := auth.ExtractLoginFromToken(resp.Header().Get("Authorization"), secret)
+				assert.Empty(t, err)
+				assert.Equal(t, "john", login)
+			}
+		})
+	}
+}
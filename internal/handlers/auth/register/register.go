@@ -7,41 +7,63 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
 	hauth "github.com/VanGoghDev/gophermart/internal/handlers/auth"
 	"github.com/VanGoghDev/gophermart/internal/lib/logger/sl"
-	"github.com/VanGoghDev/gophermart/internal/services/auth"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/services/auth/hasher"
+	"github.com/VanGoghDev/gophermart/internal/services/auth/policy"
 	"github.com/VanGoghDev/gophermart/internal/storage"
 )
 
 type Register interface {
-	RegisterUser(ctx context.Context, login string, password string) (lgn string, err error)
+	RegisterUser(ctx context.Context, login string, passHash string) (lgn string, err error)
+	CreateSession(ctx context.Context, session models.Session) (models.Session, error)
 }
 
-func New(log *slog.Logger, s Register, secret string, tokenExpires time.Duration) http.HandlerFunc {
+func New(
+	log *slog.Logger,
+	s Register,
+	p *policy.Policy,
+	h *hasher.Hasher,
+	secret string,
+	tokenExpires time.Duration,
+) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		code, req := hauth.ValidateUserRequest(r.Context(), log, r)
-		if code >= http.StatusBadRequest {
-			w.WriteHeader(code)
+		log := logger.FromContext(r.Context(), log)
+		// 400 логин или пароль не соответствуют политике.
+		req, prob := hauth.ValidateUserRequest(w, r, p)
+		if prob != nil {
+			problem.Render(w, r, *prob)
+			return
 		}
-		login, err := s.RegisterUser(r.Context(), req.Login, req.Password)
+		passHash, err := h.Hash(req.Password)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to hash password", sl.Err(err))
+			problem.Internal(w, r)
+			return
+		}
+
+		login, err := s.RegisterUser(r.Context(), req.Login, passHash)
 		if err != nil {
 			// 409 логин уже занят.
 			if errors.Is(err, storage.ErrAlreadyExists) {
 				log.ErrorContext(r.Context(), "login already exists", sl.Err(err))
-				w.WriteHeader(http.StatusConflict)
+				problem.Write(w, r, http.StatusConflict, problem.CodeLoginTaken, "login is already taken")
 				return
 			}
 
 			// 500 внутренняя ошибка сервера.
 			log.ErrorContext(r.Context(), "failed to register user", sl.Err(err))
-			w.WriteHeader(http.StatusInternalServerError)
+			problem.Internal(w, r)
 			return
 		}
 
-		token, err := auth.GrantToken(login, secret, tokenExpires)
+		token, err := hauth.IssueSessionToken(r, s, login, models.RoleUser, secret, tokenExpires)
 		if err != nil {
 			log.ErrorContext(r.Context(), "failed to grant token", sl.Err(err))
-			w.WriteHeader(http.StatusInternalServerError)
+			problem.Internal(w, r)
 			return
 		}
 
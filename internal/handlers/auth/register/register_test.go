@@ -1,15 +1,20 @@
 package register_test
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
-	"github.com/VanGoghDev/gophermart/internal/config"
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	hauth "github.com/VanGoghDev/gophermart/internal/handlers/auth"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
 	"github.com/VanGoghDev/gophermart/internal/logger"
 	"github.com/VanGoghDev/gophermart/internal/mocks"
+	"github.com/VanGoghDev/gophermart/internal/openapi/openapitest"
 	"github.com/VanGoghDev/gophermart/internal/router"
 	"github.com/VanGoghDev/gophermart/internal/storage"
 	"github.com/go-resty/resty/v2"
@@ -37,7 +42,7 @@ func TestNew(t *testing.T) {
 			args: args{
 				login:       "test",
 				contentType: "application/json",
-				body:        "{\"login\": \"123\", \"password\":\"123\"}",
+				body:        "{\"login\": \"123\", \"password\":\"Sup3rSecret\"}",
 			},
 			want: want{
 				http.StatusOK,
@@ -103,7 +108,7 @@ func TestNew(t *testing.T) {
 			args: args{
 				login:       "test",
 				contentType: "application/json",
-				body:        "{\"login\": \"test\", \"password\":\"123\"}",
+				body:        "{\"login\": \"test\", \"password\":\"Sup3rSecret\"}",
 				storageErr:  storage.ErrAlreadyExists,
 			},
 			want: want{
@@ -115,7 +120,7 @@ func TestNew(t *testing.T) {
 			args: args{
 				login:       "test",
 				contentType: "application/json",
-				body:        "{\"login\": \"test\", \"password\":\"123\"}",
+				body:        "{\"login\": \"test\", \"password\":\"Sup3rSecret\"}",
 				storageErr:  errors.New("storage error"),
 			},
 			want: want{
@@ -126,8 +131,7 @@ func TestNew(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			log := logger.New("dev")
-			cfg, err := config.New()
-			assert.Empty(t, err)
+			secret := "secret"
 
 			ctrl := gomock.NewController(t)
 			defer ctrl.Finish()
@@ -135,15 +139,24 @@ func TestNew(t *testing.T) {
 
 			m.EXPECT().RegisterUser(gomock.Any(), gomock.Any(), gomock.Any()).
 				Return(tt.args.login, tt.args.storageErr).AnyTimes()
+			m.EXPECT().CreateSession(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ any, session models.Session) (models.Session, error) {
+					assert.Equal(t, tt.args.login, session.UserLogin)
+					assert.Equal(t, "test phone", session.Device)
+					assert.NotEmpty(t, session.IP)
+					session.ID = 1
+					return session, nil
+				}).AnyTimes()
 
-			r := router.New(log, m, cfg.Secret, cfg.TokenExpires)
-			srv := httptest.NewServer(r)
+			r := router.New(log, m, secret, time.Hour)
+			srv := httptest.NewServer(openapitest.Handler(t, r))
 			defer srv.Close()
 
 			client := resty.New()
 
 			resp, err := client.R().
 				SetHeader("Content-Type", tt.args.contentType).
+				SetHeader(hauth.DeviceHeader, "test phone").
 				SetBody(tt.args.body).
 				Post(fmt.Sprintf("%s/%s", srv.URL, "api/user/register"))
 
@@ -152,6 +165,56 @@ func TestNew(t *testing.T) {
 			if resp.StatusCode() == http.StatusOK {
 				assert.NotEmpty(t, resp.Header().Get("Authorization"))
 			}
+			if resp.StatusCode() >= http.StatusBadRequest {
+				var p problem.Problem
+				assert.Equal(t, problem.ContentType, resp.Header().Get("Content-Type"))
+				assert.Empty(t, json.Unmarshal(resp.Body(), &p))
+				assert.NotEmpty(t, p.Code)
+			}
+		})
+	}
+}
+
+func TestCredentialPolicy(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{
+			name: "must reject weak password",
+			body: "{\"login\": \"test\", \"password\":\"123\"}",
+		},
+		{
+			name: "must reject login outside the charset",
+			body: "{\"login\": \"te st!\", \"password\":\"Sup3rSecret\"}",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := logger.New("dev")
+			secret := "secret"
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			// Политика проверяется до обращения к хранилищу.
+			m := mocks.NewMockStorage(ctrl)
+
+			r := router.New(log, m, secret, time.Hour)
+			srv := httptest.NewServer(openapitest.Handler(t, r))
+			defer srv.Close()
+
+			resp, err := resty.New().R().
+				SetHeader("Content-Type", "application/json").
+				SetBody(tt.body).
+				Post(fmt.Sprintf("%s/%s", srv.URL, "api/user/register"))
+
+			assert.Empty(t, err)
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode())
+
+			var p problem.Problem
+			assert.Equal(t, problem.ContentType, resp.Header().Get("Content-Type"))
+			assert.Empty(t, json.Unmarshal(resp.Body(), &p))
+			assert.Equal(t, problem.CodeCredentialPolicy, p.Code)
 		})
 	}
 }
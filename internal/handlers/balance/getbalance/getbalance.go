@@ -8,21 +8,40 @@ import (
 	"net/http"
 
 	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/lib/conditional"
 	"github.com/VanGoghDev/gophermart/internal/lib/logger/sl"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/VanGoghDev/gophermart/internal/logger"
 	"github.com/VanGoghDev/gophermart/internal/middleware/auth"
 	"github.com/VanGoghDev/gophermart/internal/storage"
 )
 
 type BalanceProvider interface {
 	GetBalance(ctx context.Context, userLogin string) (models.Balance, error)
+	GetBalanceVersion(ctx context.Context, userLogin string) (models.Version, error)
 }
 
 func New(log *slog.Logger, s BalanceProvider) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context(), log)
 		userLogin, err := auth.GetLogin(r)
 		if err != nil {
 			log.ErrorContext(r.Context(), "failed to fetch user login from context")
-			w.WriteHeader(http.StatusInternalServerError)
+			problem.Internal(w, r)
+			return
+		}
+
+		version, err := s.GetBalanceVersion(r.Context(), userLogin)
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			log.ErrorContext(r.Context(), "failed to get balance version", sl.Err(err))
+			problem.Internal(w, r)
+			return
+		}
+		if conditional.NotModified(w, r, conditional.ETag("balance", version), version.LastModified) {
 			return
 		}
 
@@ -33,7 +52,7 @@ func New(log *slog.Logger, s BalanceProvider) http.HandlerFunc {
 				return
 			}
 			log.ErrorContext(r.Context(), "failed to get balance from storage: %w", sl.Err(err))
-			w.WriteHeader(http.StatusInternalServerError)
+			problem.Internal(w, r)
 			return
 		}
 
@@ -43,7 +62,6 @@ func New(log *slog.Logger, s BalanceProvider) http.HandlerFunc {
 		err = enc.Encode(balance)
 		if err != nil {
 			log.ErrorContext(r.Context(), "failed to encode response on getbalance: %w", sl.Err(err))
-			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
 	}
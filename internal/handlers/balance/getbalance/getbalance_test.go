@@ -6,13 +6,17 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
-	"github.com/VanGoghDev/gophermart/internal/config"
 	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/lib/conditional"
 	"github.com/VanGoghDev/gophermart/internal/logger"
 	"github.com/VanGoghDev/gophermart/internal/mocks"
+	"github.com/VanGoghDev/gophermart/internal/openapi/openapitest"
 	"github.com/VanGoghDev/gophermart/internal/router"
 	"github.com/VanGoghDev/gophermart/internal/services/auth"
+	"github.com/VanGoghDev/gophermart/internal/services/ratelimit"
+	"github.com/VanGoghDev/gophermart/internal/storage"
 	"github.com/go-resty/resty/v2"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
@@ -57,21 +61,22 @@ func TestNew(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			log := logger.New("dev")
-			cfg, err := config.New()
-			assert.Empty(t, err)
+			secret := "secret"
 
-			token, err := auth.GenerateToken(tt.args.login, cfg.Secret, cfg.TokenExpires)
+			token, err := auth.GenerateToken(tt.args.login, secret, time.Hour)
 			assert.Empty(t, err)
 
 			ctrl := gomock.NewController(t)
 			defer ctrl.Finish()
 			m := mocks.NewMockStorage(ctrl)
 
+			m.EXPECT().GetBalanceVersion(gomock.Any(), gomock.Any()).
+				Return(models.Version{Counter: 1}, nil).AnyTimes()
 			m.EXPECT().GetBalance(gomock.Any(), gomock.Any()).
 				Return(models.Balance{}, tt.args.storageErr).AnyTimes()
 
-			r := router.New(log, m, cfg.Secret, cfg.TokenExpires)
-			srv := httptest.NewServer(r)
+			r := router.New(log, m, secret, time.Hour)
+			srv := httptest.NewServer(openapitest.Handler(t, r))
 			defer srv.Close()
 
 			client := resty.New()
@@ -86,3 +91,99 @@ func TestNew(t *testing.T) {
 		})
 	}
 }
+
+func TestConditional(t *testing.T) {
+	version := models.Version{Counter: 1, LastModified: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)}
+	etag := conditional.ETag("balance", version)
+
+	tests := []struct {
+		name           string
+		ifNoneMatch    string
+		versionErr     error
+		wantStatusCode int
+	}{
+		{
+			name:           "must return 200 status (stale etag)",
+			ifNoneMatch:    `"stale"`,
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "must return 204 status",
+			versionErr:     storage.ErrNotFound,
+			wantStatusCode: http.StatusNoContent,
+		},
+		{
+			name:           "must return 304 status",
+			ifNoneMatch:    etag,
+			wantStatusCode: http.StatusNotModified,
+		},
+		{
+			name:           "must return 500 status (version)",
+			versionErr:     errors.New("storage error"),
+			wantStatusCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := logger.New("dev")
+			secret := "secret"
+
+			token, err := auth.GenerateToken("test", secret, time.Hour)
+			assert.Empty(t, err)
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			m := mocks.NewMockStorage(ctrl)
+			m.EXPECT().GetBalanceVersion(gomock.Any(), "test").Return(version, tt.versionErr)
+			m.EXPECT().GetBalance(gomock.Any(), "test").Return(models.Balance{Current: 100}, nil).MaxTimes(1)
+
+			r := router.New(log, m, secret, time.Hour)
+			srv := httptest.NewServer(openapitest.Handler(t, r))
+			defer srv.Close()
+
+			resp, err := resty.New().R().
+				SetHeader("Authorization", token).
+				SetHeader("If-None-Match", tt.ifNoneMatch).
+				Get(fmt.Sprintf("%s/%s", srv.URL, "api/user/balance"))
+
+			assert.Empty(t, err)
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode())
+			if tt.wantStatusCode == http.StatusOK || tt.wantStatusCode == http.StatusNotModified {
+				assert.Equal(t, etag, resp.Header().Get("ETag"))
+			}
+		})
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	log := logger.New("dev")
+	secret := "secret"
+
+	token, err := auth.GenerateToken("test", secret, time.Hour)
+	assert.Empty(t, err)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := mocks.NewMockStorage(ctrl)
+	m.EXPECT().GetBalanceVersion(gomock.Any(), "test").Return(models.Version{Counter: 1}, nil)
+	m.EXPECT().GetBalance(gomock.Any(), "test").Return(models.Balance{Current: 100}, nil)
+
+	r := router.New(log, m, secret, time.Hour, router.WithRateLimits(ratelimit.NewMemory(), ratelimit.Limits{
+		API: ratelimit.Limit{Requests: 1, Period: time.Minute},
+	}))
+	srv := httptest.NewServer(openapitest.Handler(t, r))
+	defer srv.Close()
+
+	client := resty.New()
+	url := fmt.Sprintf("%s/%s", srv.URL, "api/user/balance")
+
+	resp, err := client.R().SetHeader("Authorization", token).Get(url)
+	assert.Empty(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	assert.Equal(t, "0", resp.Header().Get("RateLimit-Remaining"))
+
+	resp, err = client.R().SetHeader("Authorization", token).Get(url)
+	assert.Empty(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode())
+	assert.Equal(t, "60", resp.Header().Get("Retry-After"))
+}
@@ -0,0 +1,84 @@
+package getstatement
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/lib/conditional"
+	"github.com/VanGoghDev/gophermart/internal/lib/logger/sl"
+	"github.com/VanGoghDev/gophermart/internal/lib/period"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/middleware/auth"
+	"github.com/VanGoghDev/gophermart/internal/storage"
+)
+
+type StatementProvider interface {
+	GetStatement(ctx context.Context, userLogin string, from time.Time, to time.Time) (models.Statement, error)
+	GetBalanceVersion(ctx context.Context, userLogin string) (models.Version, error)
+}
+
+// New returns the statement for the range given by the from and to query parameters, see period.Parse.
+// Without from the statement starts with the first entry. Conditional requests are answered only
+// for a range with to, because without it the statement ends at the moment of the request.
+func New(log *slog.Logger, s StatementProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context(), log)
+		userLogin, err := auth.GetLogin(r)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to fetch user login from context")
+			problem.Internal(w, r)
+			return
+		}
+
+		rng, err := period.Parse(r.URL.Query(), time.Now())
+		if err != nil {
+			problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidParameter, err.Error())
+			return
+		}
+
+		// Любое изменение баланса увеличивает его версию, поэтому выписка меняется только вместе с ней.
+		version, err := s.GetBalanceVersion(r.Context(), userLogin)
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			log.ErrorContext(r.Context(), "failed to get balance version", sl.Err(err))
+			problem.Internal(w, r)
+			return
+		}
+		// Без to выписка заканчивается моментом запроса и при той же версии отличается полем to,
+		// поэтому проверять её актуальность можно только для явно заданного диапазона.
+		if r.URL.Query().Get("to") != "" {
+			etag := conditional.ETag("statement:"+rng.From.Format(time.RFC3339)+":"+rng.To.Format(time.RFC3339), version)
+			if conditional.NotModified(w, r, etag, version.LastModified) {
+				return
+			}
+		}
+
+		statement, err := s.GetStatement(r.Context(), userLogin, rng.From, rng.To)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get statement", sl.Err(err))
+			problem.Internal(w, r)
+			return
+		}
+		if !rng.From.IsZero() {
+			statement.FromFormated = rng.From.Format(time.RFC3339)
+		}
+		statement.ToFormated = rng.To.Format(time.RFC3339)
+
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		err = enc.Encode(statement)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to encode statement json", sl.Err(err))
+			return
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package getstatement_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/lib/conditional"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/mocks"
+	"github.com/VanGoghDev/gophermart/internal/openapi/openapitest"
+	"github.com/VanGoghDev/gophermart/internal/router"
+	"github.com/VanGoghDev/gophermart/internal/services/auth"
+	"github.com/VanGoghDev/gophermart/internal/storage"
+	"github.com/go-resty/resty/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	version := models.Version{Counter: 3, LastModified: time.Date(2024, 5, 20, 10, 0, 0, 0, time.UTC)}
+	etag := conditional.ETag("statement:2024-05-01T00:00:00Z:2024-06-01T00:00:00Z", version)
+	at := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC).Format(time.RFC3339)
+	statement := models.Statement{
+		OpeningBalance: 100,
+		ClosingBalance: 650,
+		Entries: []models.StatementEntry{
+			{AtFormated: at, Type: models.EntryAccrual, Reference: "12345678903", Amount: 700, Balance: 800},
+			{AtFormated: at, Type: models.EntryWithdrawal, Reference: "2377225624", Amount: -150, Balance: 650},
+		},
+	}
+
+	type args struct {
+		query       string
+		ifNoneMatch string
+		versionErr  error
+		storageErr  error
+	}
+	tests := []struct {
+		name           string
+		args           args
+		wantFrom       time.Time
+		wantTo         time.Time
+		wantStatusCode int
+	}{
+		{
+			name:           "must return 200 status",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "must return 200 status (dates)",
+			args:           args{query: "from=2024-05-01&to=2024-05-31"},
+			wantFrom:       time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+			wantTo:         time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "must return 200 status (timestamps)",
+			args:           args{query: "from=2024-05-01T03:00:00%2B03:00&to=2024-05-02T00:00:00Z"},
+			wantFrom:       time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+			wantTo:         time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC),
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "must return 204 status",
+			args:           args{versionErr: storage.ErrNotFound},
+			wantStatusCode: http.StatusNoContent,
+		},
+		{
+			name:           "must return 304 status",
+			args:           args{query: "from=2024-05-01&to=2024-05-31", ifNoneMatch: etag},
+			wantStatusCode: http.StatusNotModified,
+		},
+		{
+			name:           "must return 200 status (other range)",
+			args:           args{query: "from=2024-04-01&to=2024-05-31", ifNoneMatch: etag},
+			wantFrom:       time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC),
+			wantTo:         time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "must return 200 status (no to)",
+			args:           args{query: "from=2024-05-01", ifNoneMatch: etag},
+			wantFrom:       time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "must return 400 status (bad date)",
+			args:           args{query: "from=01.05.2024"},
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "must return 400 status (empty range)",
+			args:           args{query: "from=2024-05-31&to=2024-05-01"},
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "must return 500 status",
+			args:           args{storageErr: errors.New("storage error")},
+			wantStatusCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := logger.New("dev")
+			secret := "secret"
+
+			token, err := auth.GenerateToken("test", secret, time.Hour)
+			assert.Empty(t, err)
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			m := mocks.NewMockStorage(ctrl)
+
+			m.EXPECT().GetBalanceVersion(gomock.Any(), "test").Return(version, tt.args.versionErr).AnyTimes()
+			m.EXPECT().GetStatement(gomock.Any(), "test", gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ any, _ string, from, to time.Time) (models.Statement, error) {
+					assert.True(t, tt.wantFrom.Equal(from), "from %v", from)
+					if tt.wantTo.IsZero() {
+						assert.WithinDuration(t, time.Now(), to, time.Minute)
+					} else {
+						assert.True(t, tt.wantTo.Equal(to), "to %v", to)
+					}
+					return statement, tt.args.storageErr
+				}).AnyTimes()
+
+			r := router.New(log, m, secret, time.Hour)
+			srv := httptest.NewServer(openapitest.Handler(t, r))
+			defer srv.Close()
+
+			resp, err := resty.New().R().
+				SetHeader("Authorization", token).
+				SetHeader("If-None-Match", tt.args.ifNoneMatch).
+				Get(fmt.Sprintf("%s/%s?%s", srv.URL, "api/user/balance/statement", tt.args.query))
+
+			assert.Empty(t, err)
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode())
+			if tt.wantStatusCode == http.StatusOK {
+				var got models.Statement
+				require.NoError(t, json.Unmarshal(resp.Body(), &got))
+				assert.Len(t, got.Entries, 2)
+				assert.Equal(t, 650.0, got.ClosingBalance)
+				assert.NotEmpty(t, got.ToFormated)
+				assert.Equal(t, tt.wantFrom.IsZero(), got.FromFormated == "")
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package gettransfers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/lib/logger/sl"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/middleware/auth"
+)
+
+type TransfersProvider interface {
+	GetTransfers(ctx context.Context, userLogin string) ([]models.Transfer, error)
+}
+
+// New lists the transfers the user sent and received.
+func New(log *slog.Logger, s TransfersProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context(), log)
+		userLogin, err := auth.GetLogin(r)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to fetch user login from context")
+			problem.Internal(w, r)
+			return
+		}
+
+		transfers, err := s.GetTransfers(r.Context(), userLogin)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to fetch transfers", sl.Err(err))
+			problem.Internal(w, r)
+			return
+		}
+		if len(transfers) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		err = enc.Encode(transfers)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to encode transfers json", sl.Err(err))
+			return
+		}
+	}
+}
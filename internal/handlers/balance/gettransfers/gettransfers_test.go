@@ -0,0 +1,85 @@
+package gettransfers_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/mocks"
+	"github.com/VanGoghDev/gophermart/internal/openapi/openapitest"
+	"github.com/VanGoghDev/gophermart/internal/router"
+	"github.com/VanGoghDev/gophermart/internal/services/auth"
+	"github.com/go-resty/resty/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	now := time.Now().Format(time.RFC3339)
+	transfers := []models.Transfer{
+		{ID: 1, From: "test", To: "mom", Direction: models.TransferOut, Amount: 100, CreatedAtFormated: now},
+		{ID: 2, From: "dad", To: "test", Direction: models.TransferIn, Amount: 50, CreatedAtFormated: now},
+	}
+
+	tests := []struct {
+		name           string
+		transfers      []models.Transfer
+		storageErr     error
+		wantStatusCode int
+	}{
+		{
+			name:           "must return 200 status",
+			transfers:      transfers,
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "must return 204 status",
+			transfers:      []models.Transfer{},
+			wantStatusCode: http.StatusNoContent,
+		},
+		{
+			name:           "must return 500 status",
+			storageErr:     errors.New("storage error"),
+			wantStatusCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := logger.New("dev")
+			secret := "secret"
+
+			token, err := auth.GenerateToken("test", secret, time.Hour)
+			assert.Empty(t, err)
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			m := mocks.NewMockStorage(ctrl)
+			m.EXPECT().GetTransfers(gomock.Any(), "test").Return(tt.transfers, tt.storageErr)
+
+			r := router.New(log, m, secret, time.Hour)
+			srv := httptest.NewServer(openapitest.Handler(t, r))
+			defer srv.Close()
+
+			resp, err := resty.New().R().
+				SetHeader("Authorization", token).
+				Get(fmt.Sprintf("%s/%s", srv.URL, "api/user/balance/transfers"))
+
+			assert.Empty(t, err)
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode())
+			if tt.wantStatusCode == http.StatusOK {
+				var got []models.Transfer
+				require.NoError(t, json.Unmarshal(resp.Body(), &got))
+				require.Len(t, got, 2)
+				assert.Equal(t, models.TransferOut, got[0].Direction)
+				assert.Equal(t, models.TransferIn, got[1].Direction)
+			}
+		})
+	}
+}
@@ -8,21 +8,36 @@ import (
 	"net/http"
 
 	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/lib/conditional"
 	"github.com/VanGoghDev/gophermart/internal/lib/logger/sl"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/VanGoghDev/gophermart/internal/logger"
 	"github.com/VanGoghDev/gophermart/internal/middleware/auth"
 	"github.com/VanGoghDev/gophermart/internal/storage"
 )
 
 type WithdrawalsProvider interface {
 	GetWithdrawals(ctx context.Context, userLogin string) ([]models.Withdrawal, error)
+	GetWithdrawalsVersion(ctx context.Context, userLogin string) (models.Version, error)
 }
 
 func New(log *slog.Logger, s WithdrawalsProvider) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context(), log)
 		userLogin, err := auth.GetLogin(r)
 		if err != nil {
 			log.ErrorContext(r.Context(), "failed to fetch user login from context")
-			w.WriteHeader(http.StatusInternalServerError)
+			problem.Internal(w, r)
+			return
+		}
+
+		version, err := s.GetWithdrawalsVersion(r.Context(), userLogin)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get withdrawals version", sl.Err(err))
+			problem.Internal(w, r)
+			return
+		}
+		if conditional.NotModified(w, r, conditional.ETag("withdrawals", version), version.LastModified) {
 			return
 		}
 
@@ -33,7 +48,7 @@ func New(log *slog.Logger, s WithdrawalsProvider) http.HandlerFunc {
 				return
 			}
 			log.ErrorContext(r.Context(), "failed to fetch withdrawals", sl.Err(err))
-			w.WriteHeader(http.StatusInternalServerError)
+			problem.Internal(w, r)
 			return
 		}
 
@@ -42,7 +57,6 @@ func New(log *slog.Logger, s WithdrawalsProvider) http.HandlerFunc {
 		err = enc.Encode(withdrawals)
 		if err != nil {
 			log.ErrorContext(r.Context(), "failed to encode withdrawals json", sl.Err(err))
-			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
 	}
@@ -8,10 +8,11 @@ import (
 	"testing"
 	"time"
 
-	"github.com/VanGoghDev/gophermart/internal/config"
 	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/lib/conditional"
 	"github.com/VanGoghDev/gophermart/internal/logger"
 	"github.com/VanGoghDev/gophermart/internal/mocks"
+	"github.com/VanGoghDev/gophermart/internal/openapi/openapitest"
 	"github.com/VanGoghDev/gophermart/internal/router"
 	"github.com/VanGoghDev/gophermart/internal/services/auth"
 	"github.com/VanGoghDev/gophermart/internal/storage"
@@ -54,10 +55,9 @@ func TestNew(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			log := logger.New("dev")
-			cfg, err := config.New()
-			assert.Empty(t, err)
+			secret := "secret"
 
-			token, err := auth.GenerateToken("test", cfg.Secret, cfg.TokenExpires)
+			token, err := auth.GenerateToken("test", secret, time.Hour)
 			assert.Empty(t, err)
 
 			ctrl := gomock.NewController(t)
@@ -69,12 +69,16 @@ func TestNew(t *testing.T) {
 				OrderNumber: "12345",
 				Sum:         500,
 				ProcessedAt: time.Now(),
+				// Форматированную дату заполняет хранилище.
+				ProcessedAtFormated: time.Now().Format(time.RFC3339),
 			}
+			m.EXPECT().GetWithdrawalsVersion(gomock.Any(), gomock.Any()).
+				Return(models.Version{Counter: 1}, nil).AnyTimes()
 			m.EXPECT().GetWithdrawals(gomock.Any(), gomock.Any()).
 				Return(sWithdrawals, tt.args.storageErr).AnyTimes()
 
-			r := router.New(log, m, cfg.Secret, cfg.TokenExpires)
-			srv := httptest.NewServer(r)
+			r := router.New(log, m, secret, time.Hour)
+			srv := httptest.NewServer(openapitest.Handler(t, r))
 			defer srv.Close()
 
 			client := resty.New()
@@ -91,3 +95,72 @@ func TestNew(t *testing.T) {
 		})
 	}
 }
+
+func TestConditional(t *testing.T) {
+	version := models.Version{Counter: 1, LastModified: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)}
+	etag := conditional.ETag("withdrawals", version)
+
+	tests := []struct {
+		name            string
+		ifModifiedSince string
+		versionErr      error
+		wantStatusCode  int
+	}{
+		{
+			name:            "must return 200 status (modified since)",
+			ifModifiedSince: version.LastModified.Add(-time.Second).Format(http.TimeFormat),
+			wantStatusCode:  http.StatusOK,
+		},
+		{
+			name:            "must return 304 status",
+			ifModifiedSince: version.LastModified.Format(http.TimeFormat),
+			wantStatusCode:  http.StatusNotModified,
+		},
+		{
+			name:           "must return 500 status (version)",
+			versionErr:     errors.New("storage error"),
+			wantStatusCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := logger.New("dev")
+			secret := "secret"
+
+			token, err := auth.GenerateToken("test", secret, time.Hour)
+			assert.Empty(t, err)
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			m := mocks.NewMockStorage(ctrl)
+
+			m.EXPECT().GetWithdrawalsVersion(gomock.Any(), "test").Return(version, tt.versionErr)
+			m.EXPECT().GetWithdrawals(gomock.Any(), "test").Return([]models.Withdrawal{{
+				OrderNumber:         "2377225624",
+				Sum:                 500,
+				ProcessedAtFormated: version.LastModified.Format(time.RFC3339),
+			}}, nil).MaxTimes(1)
+
+			r := router.New(log, m, secret, time.Hour)
+			srv := httptest.NewServer(openapitest.Handler(t, r))
+			defer srv.Close()
+
+			resp, err := resty.New().R().
+				SetHeader("Authorization", token).
+				SetHeader("If-Modified-Since", tt.ifModifiedSince).
+				Get(fmt.Sprintf("%s/%s", srv.URL, "api/user/withdrawals"))
+
+			assert.Empty(t, err)
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode())
+			if tt.wantStatusCode == http.StatusInternalServerError {
+				return
+			}
+			assert.Equal(t, etag, resp.Header().Get("ETag"))
+			if tt.wantStatusCode == http.StatusNotModified {
+				assert.Empty(t, resp.Body())
+			} else {
+				assert.NotEmpty(t, resp.Body())
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package posttransfer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/lib/decode"
+	"github.com/VanGoghDev/gophermart/internal/lib/logger/sl"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/middleware/auth"
+	"github.com/VanGoghDev/gophermart/internal/storage"
+)
+
+const (
+	// HeaderIdempotencyKey identifies a transfer across retries of the request.
+	HeaderIdempotencyKey = "Idempotency-Key"
+	// HeaderReplayed marks the response to a retry: the transfer was made by an earlier request.
+	HeaderReplayed = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+)
+
+// Limits cap the points a user can transfer. Zero disables a cap.
+type Limits struct {
+	// PerTransfer caps a single transfer.
+	PerTransfer float64
+	// Daily caps the sum transferred within 24 hours.
+	Daily float64
+}
+
+type TransferSaver interface {
+	SaveTransfer(ctx context.Context, t models.Transfer, dailyLimit float64) (models.Transfer, error)
+}
+
+type Request struct {
+	To     string  `json:"to" validate:"notblank"`
+	Amount float64 `json:"amount" validate:"gt=0"`
+}
+
+func New(log *slog.Logger, s TransferSaver, limits Limits) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context(), log)
+		userLogin, err := auth.GetLogin(r)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to fetch user login from context")
+			problem.Internal(w, r)
+			return
+		}
+
+		// Без ключа повтор после обрыва соединения перевёл бы баллы второй раз.
+		key := r.Header.Get(HeaderIdempotencyKey)
+		if !validKey(key) {
+			problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidIdempotencyKey,
+				fmt.Sprintf("%s header of 1 to %d printable characters is required",
+					HeaderIdempotencyKey, maxIdempotencyKeyLength))
+			return
+		}
+
+		req := &Request{}
+		if prob := decode.JSON(w, r, req); prob != nil {
+			problem.Render(w, r, *prob)
+			return
+		}
+
+		if req.To == userLogin {
+			problem.Write(w, r, http.StatusUnprocessableEntity, problem.CodeTransferToSelf, "")
+			return
+		}
+		if limits.PerTransfer > 0 && req.Amount > limits.PerTransfer {
+			problem.Write(w, r, http.StatusUnprocessableEntity, problem.CodeTransferLimitExceeded,
+				fmt.Sprintf("a transfer must not exceed %v", limits.PerTransfer))
+			return
+		}
+
+		t, err := s.SaveTransfer(r.Context(), models.Transfer{
+			From:           userLogin,
+			To:             req.To,
+			Amount:         req.Amount,
+			IdempotencyKey: key,
+		}, limits.Daily)
+		status := http.StatusCreated
+		if err != nil {
+			switch {
+			case errors.Is(err, storage.ErrGoodConflict):
+				w.Header().Set(HeaderReplayed, "true")
+				status = http.StatusOK
+			case errors.Is(err, storage.ErrConflict):
+				problem.Write(w, r, http.StatusUnprocessableEntity, problem.CodeIdempotencyKeyReused,
+					"the key was used for a transfer with another recipient or amount")
+				return
+			case errors.Is(err, storage.ErrNotFound):
+				problem.Write(w, r, http.StatusUnprocessableEntity, problem.CodeUserNotFound,
+					"recipient not found")
+				return
+			case errors.Is(err, storage.ErrNotEnoughFunds):
+				problem.Write(w, r, http.StatusPaymentRequired, problem.CodeNotEnoughFunds, "")
+				return
+			case errors.Is(err, storage.ErrLimitExceeded):
+				problem.Write(w, r, http.StatusUnprocessableEntity, problem.CodeTransferLimitExceeded,
+					fmt.Sprintf("transfers must not exceed %v within 24 hours", limits.Daily))
+				return
+			default:
+				log.ErrorContext(r.Context(), "failed to save transfer", sl.Err(err))
+				problem.Internal(w, r)
+				return
+			}
+		} else {
+			log.InfoContext(r.Context(), "points transferred", "to", t.To, "amount", t.Amount, "transferID", t.ID)
+		}
+		t.Direction = models.TransferOut
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		enc := json.NewEncoder(w)
+		err = enc.Encode(t)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to encode transfer json", sl.Err(err))
+			return
+		}
+	}
+}
+
+func validKey(key string) bool {
+	if key == "" || len(key) > maxIdempotencyKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
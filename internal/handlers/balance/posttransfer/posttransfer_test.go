@@ -0,0 +1,198 @@
+package posttransfer_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/handlers/balance/posttransfer"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/mocks"
+	"github.com/VanGoghDev/gophermart/internal/openapi/openapitest"
+	"github.com/VanGoghDev/gophermart/internal/router"
+	"github.com/VanGoghDev/gophermart/internal/services/auth"
+	"github.com/VanGoghDev/gophermart/internal/storage"
+	"github.com/go-resty/resty/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	type args struct {
+		body           string
+		idempotencyKey string
+		storageErr     error
+	}
+	tests := []struct {
+		name           string
+		args           args
+		wantStorage    bool
+		wantStatusCode int
+		wantReplayed   bool
+	}{
+		{
+			name: "must return 201 status",
+			args: args{
+				body:           `{"to": "mom", "amount": 100}`,
+				idempotencyKey: "key-1",
+			},
+			wantStorage:    true,
+			wantStatusCode: http.StatusCreated,
+		},
+		{
+			name: "must return 200 status on retry",
+			args: args{
+				body:           `{"to": "mom", "amount": 100}`,
+				idempotencyKey: "key-1",
+				storageErr:     storage.ErrGoodConflict,
+			},
+			wantStorage:    true,
+			wantStatusCode: http.StatusOK,
+			wantReplayed:   true,
+		},
+		{
+			name: "must return 400 status (no idempotency key)",
+			args: args{
+				body: `{"to": "mom", "amount": 100}`,
+			},
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "must return 400 status (long idempotency key)",
+			args: args{
+				body:           `{"to": "mom", "amount": 100}`,
+				idempotencyKey: strings.Repeat("k", 256),
+			},
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "must return 400 status (zero amount)",
+			args: args{
+				body:           `{"to": "mom", "amount": 0}`,
+				idempotencyKey: "key-1",
+			},
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "must return 402 status",
+			args: args{
+				body:           `{"to": "mom", "amount": 100}`,
+				idempotencyKey: "key-1",
+				storageErr:     storage.ErrNotEnoughFunds,
+			},
+			wantStorage:    true,
+			wantStatusCode: http.StatusPaymentRequired,
+		},
+		{
+			name: "must return 422 status (self transfer)",
+			args: args{
+				body:           `{"to": "test", "amount": 100}`,
+				idempotencyKey: "key-1",
+			},
+			wantStatusCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "must return 422 status (per transfer cap)",
+			args: args{
+				body:           `{"to": "mom", "amount": 1000.5}`,
+				idempotencyKey: "key-1",
+			},
+			wantStatusCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "must return 422 status (daily cap)",
+			args: args{
+				body:           `{"to": "mom", "amount": 100}`,
+				idempotencyKey: "key-1",
+				storageErr:     storage.ErrLimitExceeded,
+			},
+			wantStorage:    true,
+			wantStatusCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "must return 422 status (unknown recipient)",
+			args: args{
+				body:           `{"to": "nobody", "amount": 100}`,
+				idempotencyKey: "key-1",
+				storageErr:     storage.ErrNotFound,
+			},
+			wantStorage:    true,
+			wantStatusCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "must return 422 status (reused idempotency key)",
+			args: args{
+				body:           `{"to": "mom", "amount": 50}`,
+				idempotencyKey: "key-1",
+				storageErr:     storage.ErrConflict,
+			},
+			wantStorage:    true,
+			wantStatusCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "must return 500 status",
+			args: args{
+				body:           `{"to": "mom", "amount": 100}`,
+				idempotencyKey: "key-1",
+				storageErr:     errors.New("storage error"),
+			},
+			wantStorage:    true,
+			wantStatusCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := logger.New("dev")
+			secret := "secret"
+
+			token, err := auth.GenerateToken("test", secret, time.Hour)
+			assert.Empty(t, err)
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			m := mocks.NewMockStorage(ctrl)
+
+			times := 0
+			if tt.wantStorage {
+				times = 1
+			}
+			m.EXPECT().SaveTransfer(gomock.Any(), gomock.Any(), float64(5000)).
+				DoAndReturn(func(_ any, tr models.Transfer, _ float64) (models.Transfer, error) {
+					assert.Equal(t, "test", tr.From)
+					assert.Equal(t, tt.args.idempotencyKey, tr.IdempotencyKey)
+					tr.ID = 1
+					tr.CreatedAtFormated = time.Now().Format(time.RFC3339)
+					return tr, tt.args.storageErr
+				}).Times(times)
+
+			r := router.New(log, m, secret, time.Hour,
+				router.WithTransferLimits(posttransfer.Limits{PerTransfer: 1000, Daily: 5000}))
+			srv := httptest.NewServer(openapitest.Handler(t, r))
+			defer srv.Close()
+
+			client := resty.New()
+
+			req := client.R().
+				SetHeader("Content-Type", "application/json").
+				SetHeader("Authorization", token).
+				SetBody(tt.args.body)
+			if tt.args.idempotencyKey != "" {
+				req.SetHeader(posttransfer.HeaderIdempotencyKey, tt.args.idempotencyKey)
+			}
+			resp, err := req.Post(fmt.Sprintf("%s/%s", srv.URL, "api/user/balance/transfer"))
+
+			assert.Empty(t, err)
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode())
+			if tt.wantReplayed {
+				assert.Equal(t, "true", resp.Header().Get(posttransfer.HeaderReplayed))
+			} else {
+				assert.Empty(t, resp.Header().Get(posttransfer.HeaderReplayed))
+			}
+		})
+	}
+}
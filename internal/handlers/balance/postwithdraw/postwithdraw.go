@@ -2,13 +2,15 @@ package postwithdraw
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"log/slog"
 	"net/http"
 
 	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/lib/decode"
 	"github.com/VanGoghDev/gophermart/internal/lib/logger/sl"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/VanGoghDev/gophermart/internal/logger"
 	"github.com/VanGoghDev/gophermart/internal/middleware/auth"
 	"github.com/VanGoghDev/gophermart/internal/storage"
 )
@@ -26,31 +28,23 @@ type WithdrawalSaver interface {
 }
 
 type Request struct {
-	OrderNum string  `json:"order"`
-	Sum      float64 `json:"sum"`
+	OrderNum string  `json:"order" validate:"required"`
+	Sum      float64 `json:"sum" validate:"gt=0"`
 }
 
 func New(log *slog.Logger, s WithdrawalSaver, su UserProvider, so OrderProvider) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		contentType := r.Header.Get("Content-Type")
-		if contentType != "application/json" {
-			w.WriteHeader(http.StatusBadRequest)
-			return
-		}
-
+		log := logger.FromContext(r.Context(), log)
 		userLogin, err := auth.GetLogin(r)
 		if err != nil {
 			log.ErrorContext(r.Context(), "failed to fetch user login from context")
-			w.WriteHeader(http.StatusInternalServerError)
+			problem.Internal(w, r)
 			return
 		}
 
 		req := &Request{}
-		dec := json.NewDecoder(r.Body)
-		err = dec.Decode(req)
-		if err != nil {
-			log.ErrorContext(r.Context(), "failed to decode withrawal json: %w")
-			w.WriteHeader(http.StatusInternalServerError)
+		if prob := decode.JSON(w, r, req); prob != nil {
+			problem.Render(w, r, *prob)
 			return
 		}
 
@@ -61,11 +55,11 @@ func New(log *slog.Logger, s WithdrawalSaver, su UserProvider, so OrderProvider)
 				return
 			}
 			if errors.Is(err, storage.ErrNotEnoughFunds) {
-				w.WriteHeader(http.StatusPaymentRequired)
+				problem.Write(w, r, http.StatusPaymentRequired, problem.CodeNotEnoughFunds, "")
 				return
 			}
 			log.ErrorContext(r.Context(), "failed to save withdrawal", sl.Err(err))
-			w.WriteHeader(http.StatusInternalServerError)
+			problem.Internal(w, r)
 			return
 		}
 	}
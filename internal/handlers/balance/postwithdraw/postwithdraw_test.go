@@ -6,11 +6,12 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
-	"github.com/VanGoghDev/gophermart/internal/config"
 	"github.com/VanGoghDev/gophermart/internal/domain/models"
 	"github.com/VanGoghDev/gophermart/internal/logger"
 	"github.com/VanGoghDev/gophermart/internal/mocks"
+	"github.com/VanGoghDev/gophermart/internal/openapi/openapitest"
 	"github.com/VanGoghDev/gophermart/internal/router"
 	"github.com/VanGoghDev/gophermart/internal/services/auth"
 	"github.com/VanGoghDev/gophermart/internal/storage"
@@ -68,7 +69,7 @@ func TestNew(t *testing.T) {
 			wantStatusCode: http.StatusPaymentRequired,
 		},
 		{
-			name: "must return 422 status",
+			name: "must return 200 status (unknown order)",
 			args: args{
 				contentType: "application/json",
 				body:        "{\"order\": \"123456\", \"sum\": 256}",
@@ -77,7 +78,7 @@ func TestNew(t *testing.T) {
 				},
 				storageSaveWithdrawalErr: storage.ErrNotFound,
 			},
-			wantStatusCode: http.StatusUnprocessableEntity,
+			wantStatusCode: http.StatusOK,
 		},
 		{
 			name: "must return 500 status (save error)",
@@ -95,10 +96,9 @@ func TestNew(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			log := logger.New("dev")
-			cfg, err := config.New()
-			assert.Empty(t, err)
+			secret := "secret"
 
-			token, err := auth.GenerateToken("login", cfg.Secret, cfg.TokenExpires)
+			token, err := auth.GenerateToken("login", secret, time.Hour)
 			assert.Empty(t, err)
 
 			ctrl := gomock.NewController(t)
@@ -108,8 +108,8 @@ func TestNew(t *testing.T) {
 			m.EXPECT().SaveWithdrawal(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
 				Return(tt.args.storageSaveWithdrawalErr).AnyTimes()
 
-			r := router.New(log, m, cfg.Secret, cfg.TokenExpires)
-			srv := httptest.NewServer(r)
+			r := router.New(log, m, secret, time.Hour)
+			srv := httptest.NewServer(openapitest.Handler(t, r))
 			defer srv.Close()
 
 			client := resty.New()
@@ -0,0 +1,121 @@
+package getevents
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/lib/logger/sl"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/middleware/auth"
+	"github.com/VanGoghDev/gophermart/internal/services/events"
+)
+
+// DefaultKeepAlive is how often a comment is sent so that proxies do not close an idle stream.
+const DefaultKeepAlive = 15 * time.Second
+
+// retryMillis tells EventSource how long to wait before reconnecting.
+const retryMillis = 3000
+
+type Subscriber interface {
+	Subscribe(login string, lastEventID uint64) *events.Subscription
+}
+
+// New streams order status and balance changes of the user as Server-Sent Events.
+// A client that reconnects with Last-Event-ID first receives the events it missed.
+func New(log *slog.Logger, b Subscriber, keepAlive time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context(), log)
+		userLogin, err := auth.GetLogin(r)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to fetch user login from context")
+			problem.Internal(w, r)
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			log.ErrorContext(r.Context(), "response writer does not support flushing")
+			problem.Internal(w, r)
+			return
+		}
+
+		var lastEventID uint64
+		if v := r.Header.Get("Last-Event-ID"); v != "" {
+			lastEventID, err = strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidParameter,
+					"Last-Event-ID must be an unsigned integer")
+				return
+			}
+		}
+
+		sub := b.Subscribe(userLogin, lastEventID)
+		defer sub.Close()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		// Отключаем буферизацию в nginx, иначе события будут приходить пачками.
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		_, err = fmt.Fprintf(w, "retry: %d\n\n", retryMillis)
+		if err == nil && sub.Resync {
+			err = write(w, events.Event{Type: events.TypeResync, Data: struct{}{}})
+		}
+		for _, e := range sub.Replay {
+			if err != nil {
+				break
+			}
+			err = write(w, e)
+		}
+		if err != nil {
+			log.InfoContext(r.Context(), "failed to write events", sl.Err(err))
+			return
+		}
+		flusher.Flush()
+
+		ticker := time.NewTicker(keepAlive)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case e, ok := <-sub.C:
+				if !ok {
+					// Клиент не успевал читать или сервер останавливается: он переподключится с Last-Event-ID.
+					return
+				}
+				err = write(w, e)
+			case <-ticker.C:
+				_, err = io.WriteString(w, ": keepalive\n\n")
+			}
+			if err != nil {
+				log.InfoContext(r.Context(), "failed to write events", sl.Err(err))
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func write(w io.Writer, e events.Event) error {
+	data, err := json.Marshal(e.Data)
+	if err != nil {
+		return fmt.Errorf("failed to encode event data: %w", err)
+	}
+	if e.ID > 0 {
+		if _, err = fmt.Fprintf(w, "id: %d\n", e.ID); err != nil {
+			return fmt.Errorf("failed to write event id: %w", err)
+		}
+	}
+	if _, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data); err != nil {
+		return fmt.Errorf("failed to write event: %w", err)
+	}
+	return nil
+}
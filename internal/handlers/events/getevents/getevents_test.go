@@ -0,0 +1,154 @@
+package getevents_test
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/mocks"
+	"github.com/VanGoghDev/gophermart/internal/openapi/openapitest"
+	"github.com/VanGoghDev/gophermart/internal/router"
+	"github.com/VanGoghDev/gophermart/internal/services/auth"
+	"github.com/VanGoghDev/gophermart/internal/services/events"
+	"github.com/go-resty/resty/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readEvent reads the stream up to the next event, skipping comments and the retry field.
+func readEvent(t *testing.T, r *bufio.Reader) map[string]string {
+	t.Helper()
+	fields := make(map[string]string)
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			if _, ok := fields["event"]; ok {
+				return fields
+			}
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			fields["comment"] = line
+			continue
+		}
+		name, value, _ := strings.Cut(line, ": ")
+		fields[name] = value
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name           string
+		login          string
+		lastEventID    string
+		wantStatusCode int
+		wantReplay     []string
+	}{
+		{
+			name:           "must return 200 status",
+			login:          "test",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "must return 200 status (resume)",
+			login:          "test",
+			lastEventID:    "1",
+			wantStatusCode: http.StatusOK,
+			wantReplay:     []string{"3"},
+		},
+		{
+			name:           "must return 200 status (resync)",
+			login:          "test",
+			lastEventID:    "42",
+			wantStatusCode: http.StatusOK,
+			wantReplay:     []string{"resync"},
+		},
+		{
+			name:           "must return 400 status",
+			login:          "test",
+			lastEventID:    "abc",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "must return 401 status",
+			login:          "",
+			wantStatusCode: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := logger.New("dev")
+			secret := "secret"
+
+			token, err := auth.GenerateToken(tt.login, secret, time.Hour)
+			assert.Empty(t, err)
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			m := mocks.NewMockStorage(ctrl)
+
+			b := events.New(events.DefaultHistorySize, events.DefaultHistoryTTL)
+			b.Publish("test", events.TypeOrder, models.Order{Number: "1", Status: models.Processing})
+			b.Publish("other", events.TypeOrder, models.Order{Number: "2", Status: models.Processing})
+			b.Publish("test", events.TypeBalance, models.Balance{Current: 100})
+
+			r := router.New(log, m, secret, time.Hour,
+				router.WithEvents(b), router.WithEventsKeepAlive(10*time.Millisecond))
+			srv := httptest.NewServer(openapitest.Handler(t, r))
+			defer srv.Close()
+
+			client := resty.New()
+
+			req := client.R().
+				SetDoNotParseResponse(true).
+				SetHeader("Authorization", token)
+			if tt.lastEventID != "" {
+				req.SetHeader("Last-Event-ID", tt.lastEventID)
+			}
+			resp, err := req.Get(fmt.Sprintf("%s/%s", srv.URL, "api/user/events"))
+			require.NoError(t, err)
+			body := resp.RawBody()
+			defer body.Close()
+
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode())
+			if resp.StatusCode() != http.StatusOK {
+				return
+			}
+			assert.Equal(t, "text/event-stream", resp.Header().Get("Content-Type"))
+
+			stream := bufio.NewReader(body)
+			for _, want := range tt.wantReplay {
+				e := readEvent(t, stream)
+				if want == "resync" {
+					assert.Equal(t, string(events.TypeResync), e["event"])
+					continue
+				}
+				assert.Equal(t, want, e["id"])
+			}
+
+			// После повтора пропущенного поток продолжает получать новые события.
+			b.Publish("test", events.TypeOrder, models.Order{Number: "1", Status: models.Processed, Accrual: 100})
+			e := readEvent(t, stream)
+			assert.Equal(t, "4", e["id"])
+			assert.Equal(t, string(events.TypeOrder), e["event"])
+			assert.JSONEq(t, `{"uploaded_at":"","number":"1","status":"PROCESSED","accrual":100}`, e["data"])
+
+			// Пока событий нет, приходят комментарии keepalive.
+			b.Publish("other", events.TypeOrder, models.Order{Number: "2"})
+			time.Sleep(30 * time.Millisecond)
+			b.Publish("test", events.TypeBalance, models.Balance{Current: 200})
+			e = readEvent(t, stream)
+			assert.Equal(t, "6", e["id"])
+			assert.Equal(t, ": keepalive", e["comment"])
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package exportorders
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/lib/export"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/middleware/auth"
+)
+
+type OrdersExporter interface {
+	EachOrder(ctx context.Context, userLogin string, from time.Time, to time.Time, fn func(models.Order) error) error
+}
+
+var columns = []export.Column{
+	{Name: "number", Width: 24},
+	{Name: "status", Width: 10},
+	{Name: "accrual", Width: 14, Right: true},
+	{Name: "uploaded_at", Width: 20},
+}
+
+// New streams the orders uploaded in the period as CSV or PDF, see export.Request.
+func New(log *slog.Logger, s OrdersExporter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context(), log)
+		userLogin, err := auth.GetLogin(r)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to fetch user login from context")
+			problem.Internal(w, r)
+			return
+		}
+
+		format, rng, prob := export.Request(r, time.Now())
+		if prob != nil {
+			problem.Render(w, r, *prob)
+			return
+		}
+
+		t := export.NewTable(w, format, "orders", export.Title("orders", userLogin, rng), columns...)
+		err = s.EachOrder(r.Context(), userLogin, rng.From, rng.To, func(o models.Order) error {
+			return t.Row(o.Number, string(o.Status), t.Amount(o.Accrual), t.Time(o.UploadedAt))
+		})
+		if err == nil {
+			err = t.Close()
+		}
+		if err != nil {
+			t.Fail(w, r, log, err)
+		}
+	}
+}
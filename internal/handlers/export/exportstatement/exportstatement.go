@@ -0,0 +1,71 @@
+package exportstatement
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/lib/export"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/middleware/auth"
+)
+
+type StatementExporter interface {
+	EachStatementEntry(
+		ctx context.Context,
+		userLogin string,
+		from time.Time,
+		to time.Time,
+		fn func(models.StatementEntry) error,
+	) error
+}
+
+var columns = []export.Column{
+	{Name: "at", Width: 20},
+	{Name: "type", Width: 16},
+	{Name: "reference", Width: 24},
+	{Name: "amount", Width: 14, Right: true},
+	{Name: "balance", Width: 14, Right: true},
+}
+
+// New streams the balance movements in the period as CSV or PDF, see export.Request.
+// The first row carries the opening balance and the last one the closing balance.
+func New(log *slog.Logger, s StatementExporter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context(), log)
+		userLogin, err := auth.GetLogin(r)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to fetch user login from context")
+			problem.Internal(w, r)
+			return
+		}
+
+		format, rng, prob := export.Request(r, time.Now())
+		if prob != nil {
+			problem.Render(w, r, *prob)
+			return
+		}
+
+		t := export.NewTable(w, format, "statement", export.Title("statement", userLogin, rng), columns...)
+		err = s.EachStatementEntry(r.Context(), userLogin, rng.From, rng.To, func(e models.StatementEntry) error {
+			// У входящего остатка за всю историю нет даты начала.
+			var at, amount string
+			if !e.At.IsZero() {
+				at = t.Time(e.At)
+			}
+			if e.Type != models.EntryOpening && e.Type != models.EntryClosing {
+				amount = t.Amount(e.Amount)
+			}
+			return t.Row(at, e.Type, e.Reference, amount, t.Amount(e.Balance))
+		})
+		if err == nil {
+			err = t.Close()
+		}
+		if err != nil {
+			t.Fail(w, r, log, err)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package exportwithdrawals
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/lib/export"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/middleware/auth"
+)
+
+type WithdrawalsExporter interface {
+	EachWithdrawal(
+		ctx context.Context,
+		userLogin string,
+		from time.Time,
+		to time.Time,
+		fn func(models.Withdrawal) error,
+	) error
+}
+
+var columns = []export.Column{
+	{Name: "order", Width: 24},
+	{Name: "sum", Width: 14, Right: true},
+	{Name: "processed_at", Width: 20},
+}
+
+// New streams the withdrawals processed in the period as CSV or PDF, see export.Request.
+func New(log *slog.Logger, s WithdrawalsExporter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context(), log)
+		userLogin, err := auth.GetLogin(r)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to fetch user login from context")
+			problem.Internal(w, r)
+			return
+		}
+
+		format, rng, prob := export.Request(r, time.Now())
+		if prob != nil {
+			problem.Render(w, r, *prob)
+			return
+		}
+
+		t := export.NewTable(w, format, "withdrawals", export.Title("withdrawals", userLogin, rng), columns...)
+		err = s.EachWithdrawal(r.Context(), userLogin, rng.From, rng.To, func(wd models.Withdrawal) error {
+			return t.Row(wd.OrderNumber, t.Amount(wd.Sum), t.Time(wd.ProcessedAt))
+		})
+		if err == nil {
+			err = t.Close()
+		}
+		if err != nil {
+			t.Fail(w, r, log, err)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package exportwithdrawals_test
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/mocks"
+	"github.com/VanGoghDev/gophermart/internal/openapi/openapitest"
+	"github.com/VanGoghDev/gophermart/internal/router"
+	"github.com/VanGoghDev/gophermart/internal/services/auth"
+	"github.com/go-resty/resty/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	processedAt := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	withdrawals := []models.Withdrawal{
+		{OrderNumber: "2377225624", Sum: 500, ProcessedAt: processedAt},
+		{OrderNumber: "2377225632", Sum: 12.5, ProcessedAt: processedAt.Add(time.Hour)},
+	}
+
+	type args struct {
+		query      string
+		accept     string
+		storageErr error
+	}
+	tests := []struct {
+		name            string
+		args            args
+		wantFrom        time.Time
+		wantTo          time.Time
+		wantContentType string
+		wantStatusCode  int
+	}{
+		{
+			name:            "must return csv",
+			wantContentType: "text/csv; charset=utf-8",
+			wantStatusCode:  http.StatusOK,
+		},
+		{
+			name:            "must return pdf (accept)",
+			args:            args{accept: "application/pdf"},
+			wantContentType: "application/pdf",
+			wantStatusCode:  http.StatusOK,
+		},
+		{
+			name:            "must return pdf (format)",
+			args:            args{query: "format=pdf&from=2024-05-01&to=2024-05-31", accept: "text/csv"},
+			wantFrom:        time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+			wantTo:          time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+			wantContentType: "application/pdf",
+			wantStatusCode:  http.StatusOK,
+		},
+		{
+			name:           "must return 400 status (bad format)",
+			args:           args{query: "format=xlsx"},
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "must return 400 status (bad date)",
+			args:           args{query: "to=31.05.2024"},
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "must return 406 status",
+			args:           args{accept: "application/json"},
+			wantStatusCode: http.StatusNotAcceptable,
+		},
+		{
+			name:           "must return 500 status",
+			args:           args{storageErr: errors.New("storage error")},
+			wantStatusCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := logger.New("dev")
+			secret := "secret"
+
+			token, err := auth.GenerateToken("test", secret, time.Hour)
+			assert.Empty(t, err)
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			m := mocks.NewMockStorage(ctrl)
+
+			m.EXPECT().EachWithdrawal(gomock.Any(), "test", gomock.Any(), gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ any, _ string, from, to time.Time, fn func(models.Withdrawal) error) error {
+					assert.True(t, tt.wantFrom.Equal(from), "from %v", from)
+					if !tt.wantTo.IsZero() {
+						assert.True(t, tt.wantTo.Equal(to), "to %v", to)
+					}
+					if tt.args.storageErr != nil {
+						return tt.args.storageErr
+					}
+					for _, wd := range withdrawals {
+						if err := fn(wd); err != nil {
+							return err
+						}
+					}
+					return nil
+				}).AnyTimes()
+
+			r := router.New(log, m, secret, time.Hour)
+			srv := httptest.NewServer(openapitest.Handler(t, r))
+			defer srv.Close()
+
+			resp, err := resty.New().R().
+				SetHeader("Authorization", token).
+				SetHeader("Accept", tt.args.accept).
+				Get(fmt.Sprintf("%s/%s?%s", srv.URL, "api/user/export/withdrawals", tt.args.query))
+
+			assert.Empty(t, err)
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode())
+			if tt.wantStatusCode != http.StatusOK {
+				return
+			}
+			assert.Equal(t, tt.wantContentType, resp.Header().Get("Content-Type"))
+			assert.Contains(t, resp.Header().Get("Content-Disposition"), "attachment")
+			if tt.wantContentType == "application/pdf" {
+				assert.True(t, strings.HasPrefix(resp.String(), "%PDF-"))
+				return
+			}
+			records, err := csv.NewReader(strings.NewReader(resp.String())).ReadAll()
+			require.NoError(t, err)
+			assert.Equal(t, [][]string{
+				{"order", "sum", "processed_at"},
+				{"2377225624", "500", "2024-05-10T12:00:00Z"},
+				{"2377225632", "12.5", "2024-05-10T13:00:00Z"},
+			}, records)
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package gethealthz
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/VanGoghDev/gophermart/internal/lib/logger/sl"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/services/health"
+)
+
+// New reports that the process is up. It checks no dependencies, so the orchestrator
+// restarts the process only if it stops answering at all.
+func New(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		err := json.NewEncoder(w).Encode(map[string]string{"status": health.StatusOK})
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to encode health json", sl.Err(err))
+			return
+		}
+	}
+}
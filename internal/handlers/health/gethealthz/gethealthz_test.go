@@ -0,0 +1,61 @@
+package gethealthz_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/mocks"
+	"github.com/VanGoghDev/gophermart/internal/openapi/openapitest"
+	"github.com/VanGoghDev/gophermart/internal/router"
+	"github.com/VanGoghDev/gophermart/internal/services/health"
+	"github.com/go-resty/resty/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name           string
+		drain          bool
+		wantStatusCode int
+	}{
+		{
+			name:           "must return 200 status",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			// Живость не зависит от остановки: процесс ещё дослуживает запросы.
+			name:           "must return 200 status (draining)",
+			drain:          true,
+			wantStatusCode: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := logger.New("dev")
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			m := mocks.NewMockStorage(ctrl)
+
+			checker := health.New(time.Second)
+			if tt.drain {
+				checker.Drain()
+			}
+
+			r := router.New(log, m, "secret", time.Hour, router.WithHealth(checker))
+			srv := httptest.NewServer(openapitest.Handler(t, r))
+			defer srv.Close()
+
+			resp, err := resty.New().R().Get(fmt.Sprintf("%s/%s", srv.URL, "healthz"))
+
+			assert.Empty(t, err)
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode())
+			assert.JSONEq(t, `{"status":"ok"}`, resp.String())
+		})
+	}
+}
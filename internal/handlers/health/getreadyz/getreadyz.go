@@ -0,0 +1,58 @@
+package getreadyz
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/VanGoghDev/gophermart/internal/lib/logger/sl"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/services/health"
+)
+
+type ReadinessChecker interface {
+	Check(ctx context.Context) health.Report
+}
+
+type response struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+const (
+	checkOK     = "ok"
+	checkFailed = "failed"
+)
+
+// New reports whether the server can take traffic. It answers 503 while a dependency is down
+// and from the moment shutdown begins. Failure reasons are only logged, the body names the checks.
+func New(log *slog.Logger, c ReadinessChecker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context(), log)
+
+		report := c.Check(r.Context())
+		resp := response{Status: report.Status, Checks: make(map[string]string, len(report.Results))}
+		for _, res := range report.Results {
+			if res.Err != nil {
+				resp.Checks[res.Name] = checkFailed
+				log.WarnContext(r.Context(), "readiness check failed", "check", res.Name, sl.Err(res.Err))
+				continue
+			}
+			resp.Checks[res.Name] = checkOK
+		}
+
+		status := http.StatusOK
+		if !report.Ready() {
+			status = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(status)
+		err := json.NewEncoder(w).Encode(resp)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to encode readiness json", sl.Err(err))
+			return
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package getreadyz_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/mocks"
+	"github.com/VanGoghDev/gophermart/internal/openapi/openapitest"
+	"github.com/VanGoghDev/gophermart/internal/router"
+	"github.com/VanGoghDev/gophermart/internal/services/health"
+	"github.com/go-resty/resty/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	type args struct {
+		checkErr error
+		drain    bool
+	}
+	tests := []struct {
+		name           string
+		args           args
+		wantStatusCode int
+		wantBody       string
+	}{
+		{
+			name:           "must return 200 status",
+			wantStatusCode: http.StatusOK,
+			wantBody:       `{"status":"ok","checks":{"database":"ok"}}`,
+		},
+		{
+			name: "must return 503 status",
+			args: args{
+				checkErr: errors.New("connection refused"),
+			},
+			wantStatusCode: http.StatusServiceUnavailable,
+			wantBody:       `{"status":"unavailable","checks":{"database":"failed"}}`,
+		},
+		{
+			name: "must return 503 status (draining)",
+			args: args{
+				drain: true,
+			},
+			wantStatusCode: http.StatusServiceUnavailable,
+			wantBody:       `{"status":"draining","checks":{"shutdown":"failed"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := logger.New("dev")
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			m := mocks.NewMockStorage(ctrl)
+
+			checker := health.New(time.Second)
+			checker.Add("database", func(context.Context) error { return tt.args.checkErr })
+			if tt.args.drain {
+				checker.Drain()
+			}
+
+			r := router.New(log, m, "secret", time.Hour, router.WithHealth(checker))
+			srv := httptest.NewServer(openapitest.Handler(t, r))
+			defer srv.Close()
+
+			resp, err := resty.New().R().Get(fmt.Sprintf("%s/%s", srv.URL, "readyz"))
+
+			assert.Empty(t, err)
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode())
+			assert.JSONEq(t, tt.wantBody, resp.String())
+			assert.Equal(t, "no-store", resp.Header().Get("Cache-Control"))
+		})
+	}
+}
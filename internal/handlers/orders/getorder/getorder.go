@@ -0,0 +1,58 @@
+package getorder
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/lib/logger/sl"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/middleware/auth"
+	"github.com/VanGoghDev/gophermart/internal/storage"
+	"github.com/go-chi/chi"
+)
+
+type OrderProvider interface {
+	GetOrder(ctx context.Context, number string) (models.Order, error)
+}
+
+// New returns a single order of the user so that clients can poll its status.
+func New(log *slog.Logger, s OrderProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context(), log)
+		userLogin, err := auth.GetLogin(r)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to fetch user login from context")
+			problem.Internal(w, r)
+			return
+		}
+
+		order, err := s.GetOrder(r.Context(), chi.URLParam(r, "number"))
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				problem.Write(w, r, http.StatusNotFound, problem.CodeOrderNotFound, "")
+				return
+			}
+			log.ErrorContext(r.Context(), "failed to get order from storage", sl.Err(err))
+			problem.Internal(w, r)
+			return
+		}
+		if order.UserLogin != userLogin {
+			problem.Write(w, r, http.StatusForbidden, problem.CodeOrderForbidden,
+				"order was uploaded by another user")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		err = enc.Encode(order)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to encode order json", sl.Err(err))
+			return
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package getorder_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/mocks"
+	"github.com/VanGoghDev/gophermart/internal/openapi/openapitest"
+	"github.com/VanGoghDev/gophermart/internal/router"
+	"github.com/VanGoghDev/gophermart/internal/services/auth"
+	"github.com/VanGoghDev/gophermart/internal/storage"
+	"github.com/go-resty/resty/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	order := models.Order{
+		Number:             "12345678903",
+		UserLogin:          "test",
+		Status:             models.Processed,
+		Accrual:            500,
+		UploadedAtFormated: "2024-05-01T10:00:00+03:00",
+	}
+
+	tests := []struct {
+		name           string
+		login          string
+		storageOrder   models.Order
+		storageErr     error
+		wantStatusCode int
+	}{
+		{
+			name:           "must return 200 status",
+			login:          "test",
+			storageOrder:   order,
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "must return 403 status",
+			login:          "another",
+			storageOrder:   order,
+			wantStatusCode: http.StatusForbidden,
+		},
+		{
+			name:           "must return 404 status",
+			login:          "test",
+			storageErr:     storage.ErrNotFound,
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name:           "must return 401 status",
+			login:          "",
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:           "must return 500 status",
+			login:          "test",
+			storageErr:     errors.New("storage error"),
+			wantStatusCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := logger.New("dev")
+			secret := "secret"
+
+			token, err := auth.GenerateToken(tt.login, secret, time.Hour)
+			assert.Empty(t, err)
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			m := mocks.NewMockStorage(ctrl)
+
+			m.EXPECT().GetOrder(gomock.Any(), order.Number).
+				Return(tt.storageOrder, tt.storageErr).AnyTimes()
+
+			r := router.New(log, m, secret, time.Hour)
+			srv := httptest.NewServer(openapitest.Handler(t, r))
+			defer srv.Close()
+
+			client := resty.New()
+
+			resp, err := client.R().
+				SetHeader("Authorization", token).
+				Get(fmt.Sprintf("%s/%s/%s", srv.URL, "api/user/orders", order.Number))
+
+			assert.Empty(t, err)
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode())
+			if resp.StatusCode() != http.StatusOK {
+				return
+			}
+
+			var got map[string]any
+			err = json.Unmarshal(resp.Body(), &got)
+			assert.Empty(t, err)
+			assert.Equal(t, order.Number, got["number"])
+			assert.Equal(t, string(models.Processed), got["status"])
+			assert.Equal(t, order.Accrual, got["accrual"])
+			assert.Equal(t, order.UploadedAtFormated, got["uploaded_at"])
+			assert.NotContains(t, got, "user_login")
+		})
+	}
+}
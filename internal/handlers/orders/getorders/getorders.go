@@ -8,21 +8,42 @@ import (
 	"net/http"
 
 	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/lib/conditional"
 	"github.com/VanGoghDev/gophermart/internal/lib/logger/sl"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/VanGoghDev/gophermart/internal/logger"
 	"github.com/VanGoghDev/gophermart/internal/middleware/auth"
 	"github.com/VanGoghDev/gophermart/internal/storage"
 )
 
 type OrderProvider interface {
 	GetOrders(ctx context.Context, userLogin string) ([]models.Order, error)
+	GetOrdersVersion(ctx context.Context, userLogin string) (models.Version, error)
 }
 
 func New(log *slog.Logger, s OrderProvider) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context(), log)
 		userLogin, err := auth.GetLogin(r)
 		if err != nil {
 			log.ErrorContext(r.Context(), "failed to get userLogin from context: %w")
-			w.WriteHeader(http.StatusInternalServerError)
+			problem.Internal(w, r)
+			return
+		}
+
+		// Версию читаем до списка: если заказ изменится между запросами, ETag окажется устаревшим,
+		// и клиент просто получит список заново.
+		version, err := s.GetOrdersVersion(r.Context(), userLogin)
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			log.ErrorContext(r.Context(), "failed to get orders version", sl.Err(err))
+			problem.Internal(w, r)
+			return
+		}
+		if conditional.NotModified(w, r, conditional.ETag("orders", version), version.LastModified) {
 			return
 		}
 
@@ -33,7 +54,7 @@ func New(log *slog.Logger, s OrderProvider) http.HandlerFunc {
 				return
 			}
 			log.ErrorContext(r.Context(), "failed to get orders from storage: %w", sl.Err(err))
-			w.WriteHeader(http.StatusInternalServerError)
+			problem.Internal(w, r)
 			return
 		}
 
@@ -43,7 +64,6 @@ func New(log *slog.Logger, s OrderProvider) http.HandlerFunc {
 		err = enc.Encode(orders)
 		if err != nil {
 			log.ErrorContext(r.Context(), "failed to encode orders json: %w")
-			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
 	}
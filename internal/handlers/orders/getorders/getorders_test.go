@@ -6,11 +6,13 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
-	"github.com/VanGoghDev/gophermart/internal/config"
 	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/lib/conditional"
 	"github.com/VanGoghDev/gophermart/internal/logger"
 	"github.com/VanGoghDev/gophermart/internal/mocks"
+	"github.com/VanGoghDev/gophermart/internal/openapi/openapitest"
 	"github.com/VanGoghDev/gophermart/internal/router"
 	"github.com/VanGoghDev/gophermart/internal/services/auth"
 	"github.com/VanGoghDev/gophermart/internal/storage"
@@ -86,21 +88,22 @@ func TestNew(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			log := logger.New("dev")
-			cfg, err := config.New()
-			assert.Empty(t, err)
+			secret := "secret"
 
-			token, err := auth.GenerateToken(tt.args.login, cfg.Secret, cfg.TokenExpires)
+			token, err := auth.GenerateToken(tt.args.login, secret, time.Hour)
 			assert.Empty(t, err)
 
 			ctrl := gomock.NewController(t)
 			defer ctrl.Finish()
 			m := mocks.NewMockStorage(ctrl)
 
+			m.EXPECT().GetOrdersVersion(gomock.Any(), gomock.Any()).
+				Return(models.Version{Counter: 1}, nil).AnyTimes()
 			m.EXPECT().GetOrders(gomock.Any(), gomock.Any()).
 				Return(tt.args.storageGetOrder, tt.args.storageGetErr).AnyTimes()
 
-			r := router.New(log, m, cfg.Secret, cfg.TokenExpires)
-			srv := httptest.NewServer(r)
+			r := router.New(log, m, secret, time.Hour)
+			srv := httptest.NewServer(openapitest.Handler(t, r))
 			defer srv.Close()
 
 			client := resty.New()
@@ -115,3 +118,79 @@ func TestNew(t *testing.T) {
 		})
 	}
 }
+
+func TestConditional(t *testing.T) {
+	version := models.Version{Counter: 1, LastModified: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)}
+	etag := conditional.ETag("orders", version)
+	orders := []models.Order{{
+		Number:             "12345678903",
+		Status:             models.Processed,
+		Accrual:            500,
+		UploadedAtFormated: version.LastModified.Format(time.RFC3339),
+	}}
+
+	tests := []struct {
+		name            string
+		ifNoneMatch     string
+		ifModifiedSince string
+		versionErr      error
+		wantStatusCode  int
+	}{
+		{
+			name:           "must return 200 status (stale etag)",
+			ifNoneMatch:    `"stale"`,
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "must return 204 status (no user)",
+			versionErr:     storage.ErrNotFound,
+			wantStatusCode: http.StatusNoContent,
+		},
+		{
+			name:           "must return 304 status (etag)",
+			ifNoneMatch:    etag,
+			wantStatusCode: http.StatusNotModified,
+		},
+		{
+			name:            "must return 304 status (date)",
+			ifModifiedSince: version.LastModified.Format(http.TimeFormat),
+			wantStatusCode:  http.StatusNotModified,
+		},
+		{
+			name:           "must return 500 status (version)",
+			versionErr:     errors.New("storage error"),
+			wantStatusCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := logger.New("dev")
+			secret := "secret"
+
+			token, err := auth.GenerateToken("test", secret, time.Hour)
+			assert.Empty(t, err)
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			m := mocks.NewMockStorage(ctrl)
+			m.EXPECT().GetOrdersVersion(gomock.Any(), "test").Return(version, tt.versionErr)
+			m.EXPECT().GetOrders(gomock.Any(), "test").Return(orders, nil).MaxTimes(1)
+
+			r := router.New(log, m, secret, time.Hour)
+			srv := httptest.NewServer(openapitest.Handler(t, r))
+			defer srv.Close()
+
+			resp, err := resty.New().R().
+				SetHeader("Authorization", token).
+				SetHeader("If-None-Match", tt.ifNoneMatch).
+				SetHeader("If-Modified-Since", tt.ifModifiedSince).
+				Get(fmt.Sprintf("%s/%s", srv.URL, "api/user/orders"))
+
+			assert.Empty(t, err)
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode())
+			if tt.wantStatusCode == http.StatusOK || tt.wantStatusCode == http.StatusNotModified {
+				assert.Equal(t, etag, resp.Header().Get("ETag"))
+			}
+		})
+	}
+}
@@ -3,13 +3,17 @@ package postorders
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 
 	"github.com/ShiraazMoollatjie/goluhn"
 	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/lib/decode"
 	"github.com/VanGoghDev/gophermart/internal/lib/logger/sl"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/VanGoghDev/gophermart/internal/logger"
 	"github.com/VanGoghDev/gophermart/internal/middleware/auth"
 	"github.com/VanGoghDev/gophermart/internal/storage"
 )
@@ -24,29 +28,38 @@ type OrdersSaver interface {
 
 func New(log *slog.Logger, s OrdersSaver, sp OrderProvider) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context(), log)
 		contentType := r.Header.Get("Content-Type")
 		if contentType != "text/plain" {
-			w.WriteHeader(http.StatusBadRequest)
+			problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidContentType,
+				"Content-Type must be text/plain")
 			return
 		}
 
 		userLogin, err := auth.GetLogin(r)
 		if err != nil {
 			log.ErrorContext(r.Context(), "failed to fetch user login from context")
-			w.WriteHeader(http.StatusInternalServerError)
+			problem.Internal(w, r)
 			return
 		}
 
-		bNum, err := io.ReadAll(r.Body)
+		bNum, err := io.ReadAll(http.MaxBytesReader(w, r.Body, decode.DefaultMaxBodySize))
 		if err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				problem.Write(w, r, http.StatusRequestEntityTooLarge, problem.CodeBodyTooLarge,
+					fmt.Sprintf("request body must not exceed %d bytes", maxErr.Limit))
+				return
+			}
 			log.ErrorContext(r.Context(), "failed to read body", sl.Err(err))
-			w.WriteHeader(http.StatusInternalServerError)
+			problem.Internal(w, r)
 			return
 		}
 
 		err = goluhn.Validate(string(bNum))
 		if err != nil {
-			w.WriteHeader(http.StatusUnprocessableEntity)
+			problem.Write(w, r, http.StatusUnprocessableEntity, problem.CodeInvalidOrderNumber,
+				"order number failed the Luhn check")
 			return
 		}
 
@@ -57,7 +70,8 @@ func New(log *slog.Logger, s OrdersSaver, sp OrderProvider) http.HandlerFunc {
 				return
 			}
 			if errors.Is(err, storage.ErrConflict) {
-				w.WriteHeader(http.StatusConflict)
+				problem.Write(w, r, http.StatusConflict, problem.CodeOrderConflict,
+					"order was already uploaded by another user")
 				return
 			}
 			if errors.Is(err, storage.ErrAlreadyExists) {
@@ -66,7 +80,7 @@ func New(log *slog.Logger, s OrdersSaver, sp OrderProvider) http.HandlerFunc {
 				return
 			}
 			log.ErrorContext(r.Context(), "failed to save order", sl.Err(err))
-			w.WriteHeader(http.StatusInternalServerError)
+			problem.Internal(w, r)
 			return
 		}
 
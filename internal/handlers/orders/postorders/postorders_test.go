@@ -1,16 +1,20 @@
 package postorders_test
 
 import (
+	"bytes"
+	"compress/gzip"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
-	"github.com/VanGoghDev/gophermart/internal/config"
 	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/lib/decode"
 	"github.com/VanGoghDev/gophermart/internal/logger"
 	"github.com/VanGoghDev/gophermart/internal/mocks"
+	"github.com/VanGoghDev/gophermart/internal/openapi/openapitest"
 	"github.com/VanGoghDev/gophermart/internal/router"
 	"github.com/VanGoghDev/gophermart/internal/services/auth"
 	"github.com/VanGoghDev/gophermart/internal/storage"
@@ -41,7 +45,7 @@ func TestNew(t *testing.T) {
 			args: args{
 				login:          "test",
 				contentType:    "text/plain",
-				body:           "123456",
+				body:           "12345678903",
 				storagePostErr: storage.ErrAlreadyExists,
 			},
 			want: want{
@@ -53,7 +57,7 @@ func TestNew(t *testing.T) {
 			args: args{
 				login:       "test",
 				contentType: "text/plain",
-				body:        "123456",
+				body:        "12345678903",
 			},
 			want: want{
 				http.StatusAccepted,
@@ -64,7 +68,7 @@ func TestNew(t *testing.T) {
 			args: args{
 				login:       "testLogin",
 				contentType: "application/json",
-				body:        "123456",
+				body:        "12345678903",
 			},
 			want: want{
 				http.StatusBadRequest,
@@ -75,7 +79,7 @@ func TestNew(t *testing.T) {
 			args: args{
 				login:           "testLogin",
 				contentType:     "text/plain",
-				body:            "123456",
+				body:            "12345678903",
 				storagePostErr:  storage.ErrConflict,
 				storageGetOrder: models.Order{UserLogin: "testLogin2"},
 			},
@@ -99,7 +103,7 @@ func TestNew(t *testing.T) {
 			args: args{
 				login:          "testLogin",
 				contentType:    "text/plain",
-				body:           "123456",
+				body:           "12345678903",
 				storagePostErr: errors.New("storage error"),
 			},
 			want: want{
@@ -111,10 +115,9 @@ func TestNew(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			log := logger.New("dev")
-			cfg, err := config.New()
-			assert.Empty(t, err)
+			secret := "secret"
 
-			token, err := auth.GenerateToken(tt.args.login, cfg.Secret, cfg.TokenExpires)
+			token, err := auth.GenerateToken(tt.args.login, secret, time.Hour)
 			assert.Empty(t, err)
 
 			ctrl := gomock.NewController(t)
@@ -127,8 +130,8 @@ func TestNew(t *testing.T) {
 			m.EXPECT().SaveOrder(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
 				Return(tt.args.storagePostErr).AnyTimes()
 
-			r := router.New(log, m, cfg.Secret, cfg.TokenExpires)
-			srv := httptest.NewServer(r)
+			r := router.New(log, m, secret, time.Hour)
+			srv := httptest.NewServer(openapitest.Handler(t, r))
 			defer srv.Close()
 
 			client := resty.New()
@@ -144,3 +147,40 @@ func TestNew(t *testing.T) {
 		})
 	}
 }
+
+func TestBodyLimit(t *testing.T) {
+	log := logger.New("dev")
+	secret := "secret"
+
+	token, err := auth.GenerateToken("test", secret, time.Hour)
+	assert.Empty(t, err)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := mocks.NewMockStorage(ctrl)
+
+	r := router.New(log, m, secret, time.Hour)
+	srv := httptest.NewServer(openapitest.Handler(t, r))
+	defer srv.Close()
+
+	resp, err := resty.New().R().
+		SetHeader("Content-Type", "text/plain").
+		SetHeader("Content-Encoding", "gzip").
+		SetHeader("Authorization", token).
+		SetBody(gzipBomb(t)).
+		Post(fmt.Sprintf("%s/%s", srv.URL, "api/user/orders"))
+
+	assert.Empty(t, err)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode())
+}
+
+// gzipBomb returns a few kilobytes that decompress into zeros past the body size limit.
+func gzipBomb(t *testing.T) string {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := zw.Write(make([]byte, 2*decode.DefaultMaxBodySize))
+	assert.Empty(t, err)
+	assert.Empty(t, zw.Close())
+	return buf.String()
+}
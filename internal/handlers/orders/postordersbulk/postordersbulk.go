@@ -0,0 +1,272 @@
+package postordersbulk
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/ShiraazMoollatjie/goluhn"
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/lib/decode"
+	"github.com/VanGoghDev/gophermart/internal/lib/logger/sl"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/middleware/auth"
+)
+
+// DefaultLimit is the number of orders accepted in one request if the router is not told otherwise.
+const DefaultLimit = 1000
+
+const (
+	contentTypeJSON   = "application/json"
+	contentTypeNDJSON = "application/x-ndjson"
+	contentTypeText   = "text/plain"
+	contentTypeCSV    = "text/csv"
+)
+
+var errInvalidBody = errors.New("invalid body")
+
+type OrdersSaver interface {
+	SaveOrders(
+		ctx context.Context,
+		numbers []string,
+		userLogin string,
+		status models.OrderStatus,
+	) (map[string]models.UploadResult, error)
+}
+
+// New uploads up to limit order numbers at once. The body is a JSON array, newline delimited
+// numbers (text/plain or application/x-ndjson) or CSV with the number in the first column.
+func New(log *slog.Logger, s OrdersSaver, limit int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context(), log)
+		userLogin, err := auth.GetLogin(r)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to fetch user login from context")
+			problem.Internal(w, r)
+			return
+		}
+
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil {
+			mediaType = ""
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, decode.DefaultMaxBodySize))
+		if err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				problem.Write(w, r, http.StatusRequestEntityTooLarge, problem.CodeBodyTooLarge,
+					fmt.Sprintf("request body must not exceed %d bytes", maxErr.Limit))
+				return
+			}
+			log.ErrorContext(r.Context(), "failed to read body", sl.Err(err))
+			problem.Internal(w, r)
+			return
+		}
+
+		numbers, err := parse(mediaType, body)
+		if err != nil {
+			if errors.Is(err, errInvalidBody) {
+				problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidBody, err.Error())
+				return
+			}
+			problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidContentType,
+				"Content-Type must be application/json, application/x-ndjson, text/plain or text/csv")
+			return
+		}
+		numbers = unique(numbers)
+		if len(numbers) == 0 {
+			problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidBody, "no order numbers in request body")
+			return
+		}
+		if len(numbers) > limit {
+			problem.Write(w, r, http.StatusRequestEntityTooLarge, problem.CodeTooManyOrders,
+				fmt.Sprintf("at most %d orders can be uploaded at once", limit))
+			return
+		}
+
+		valid := make([]string, 0, len(numbers))
+		for _, number := range numbers {
+			if goluhn.Validate(number) == nil {
+				valid = append(valid, number)
+			}
+		}
+
+		saved := make(map[string]models.UploadResult)
+		if len(valid) > 0 {
+			saved, err = s.SaveOrders(r.Context(), valid, userLogin, models.New)
+			if err != nil {
+				log.ErrorContext(r.Context(), "failed to save orders", sl.Err(err))
+				problem.Internal(w, r)
+				return
+			}
+		}
+
+		results := make([]models.OrderUpload, 0, len(numbers))
+		for _, number := range numbers {
+			result, ok := saved[number]
+			if !ok {
+				result = models.UploadInvalid
+			}
+			results = append(results, models.OrderUpload{Number: number, Result: result})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		err = enc.Encode(results)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to encode upload results json", sl.Err(err))
+			return
+		}
+	}
+}
+
+func parse(mediaType string, body []byte) ([]string, error) {
+	switch mediaType {
+	case contentTypeJSON:
+		return parseJSON(body)
+	case contentTypeNDJSON:
+		return parseNDJSON(body)
+	case contentTypeText:
+		return parseLines(body)
+	case contentTypeCSV:
+		return parseCSV(body)
+	default:
+		return nil, fmt.Errorf("unsupported content type %q", mediaType)
+	}
+}
+
+// parseJSON accepts an array of numbers written as strings or as JSON numbers.
+func parseJSON(body []byte) ([]string, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	var values []any
+	if err := dec.Decode(&values); err != nil {
+		return nil, fmt.Errorf("%w: request body must be a JSON array of order numbers", errInvalidBody)
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("%w: request body must contain a single JSON value", errInvalidBody)
+	}
+
+	numbers := make([]string, 0, len(values))
+	for i, v := range values {
+		number, err := jsonNumber(v)
+		if err != nil {
+			return nil, fmt.Errorf("%w: element %d: %w", errInvalidBody, i, err)
+		}
+		numbers = append(numbers, number)
+	}
+	return numbers, nil
+}
+
+func parseNDJSON(body []byte) ([]string, error) {
+	numbers := make([]string, 0)
+	sc := bufio.NewScanner(bytes.NewReader(body))
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" {
+			continue
+		}
+
+		dec := json.NewDecoder(strings.NewReader(text))
+		dec.UseNumber()
+		var v any
+		if err := dec.Decode(&v); err != nil {
+			return nil, fmt.Errorf("%w: line %d is not valid JSON", errInvalidBody, line)
+		}
+		number, err := jsonNumber(v)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %w", errInvalidBody, line, err)
+		}
+		numbers = append(numbers, number)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %w", errInvalidBody, err)
+	}
+	return numbers, nil
+}
+
+func parseLines(body []byte) ([]string, error) {
+	numbers := make([]string, 0)
+	sc := bufio.NewScanner(bytes.NewReader(body))
+	for sc.Scan() {
+		if text := strings.TrimSpace(sc.Text()); text != "" {
+			numbers = append(numbers, text)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %w", errInvalidBody, err)
+	}
+	return numbers, nil
+}
+
+// parseCSV takes the first column. A first row that is not a number is treated as a header.
+func parseCSV(body []byte) ([]string, error) {
+	cr := csv.NewReader(bytes.NewReader(body))
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%w: request body is not valid CSV", errInvalidBody)
+	}
+
+	numbers := make([]string, 0, len(records))
+	for i, rec := range records {
+		number := strings.TrimSpace(rec[0])
+		if i == 0 && !isDigits(number) {
+			continue
+		}
+		if number != "" {
+			numbers = append(numbers, number)
+		}
+	}
+	return numbers, nil
+}
+
+func jsonNumber(v any) (string, error) {
+	switch n := v.(type) {
+	case string:
+		return strings.TrimSpace(n), nil
+	case json.Number:
+		return n.String(), nil
+	default:
+		return "", errors.New("order number must be a string or a number")
+	}
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// unique drops repeated numbers, keeping the order of the first occurrence.
+func unique(numbers []string) []string {
+	seen := make(map[string]struct{}, len(numbers))
+	out := make([]string, 0, len(numbers))
+	for _, number := range numbers {
+		if _, ok := seen[number]; ok {
+			continue
+		}
+		seen[number] = struct{}{}
+		out = append(out, number)
+	}
+	return out
+}
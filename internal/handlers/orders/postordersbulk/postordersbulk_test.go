@@ -0,0 +1,175 @@
+package postordersbulk_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/mocks"
+	"github.com/VanGoghDev/gophermart/internal/openapi/openapitest"
+	"github.com/VanGoghDev/gophermart/internal/router"
+	"github.com/VanGoghDev/gophermart/internal/services/auth"
+	"github.com/go-resty/resty/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	// Хранилище знает о трёх номерах: один загружен этим пользователем, другой чужой.
+	stored := map[string]models.UploadResult{
+		"12345678903": models.UploadAccepted,
+		"2377225624":  models.UploadAlreadyYours,
+		"79927398713": models.UploadConflict,
+	}
+
+	tests := []struct {
+		name           string
+		contentType    string
+		body           string
+		limit          int
+		storageErr     error
+		wantStatusCode int
+		want           []models.OrderUpload
+	}{
+		{
+			name:           "must return 200 status (json)",
+			contentType:    "application/json",
+			body:           `["12345678903", 2377225624, "79927398713", "12345678902", "12345678903"]`,
+			wantStatusCode: http.StatusOK,
+			want: []models.OrderUpload{
+				{Number: "12345678903", Result: models.UploadAccepted},
+				{Number: "2377225624", Result: models.UploadAlreadyYours},
+				{Number: "79927398713", Result: models.UploadConflict},
+				{Number: "12345678902", Result: models.UploadInvalid},
+			},
+		},
+		{
+			name:           "must return 200 status (ndjson)",
+			contentType:    "application/x-ndjson",
+			body:           "\"12345678903\"\n\n2377225624\n",
+			wantStatusCode: http.StatusOK,
+			want: []models.OrderUpload{
+				{Number: "12345678903", Result: models.UploadAccepted},
+				{Number: "2377225624", Result: models.UploadAlreadyYours},
+			},
+		},
+		{
+			name:           "must return 200 status (text)",
+			contentType:    "text/plain; charset=utf-8",
+			body:           "12345678903\r\n 79927398713 \n",
+			wantStatusCode: http.StatusOK,
+			want: []models.OrderUpload{
+				{Number: "12345678903", Result: models.UploadAccepted},
+				{Number: "79927398713", Result: models.UploadConflict},
+			},
+		},
+		{
+			name:           "must return 200 status (csv)",
+			contentType:    "text/csv",
+			body:           "number,comment\n12345678903,first\n12345678902,typo\n",
+			wantStatusCode: http.StatusOK,
+			want: []models.OrderUpload{
+				{Number: "12345678903", Result: models.UploadAccepted},
+				{Number: "12345678902", Result: models.UploadInvalid},
+			},
+		},
+		{
+			name:           "must return 200 status (all invalid)",
+			contentType:    "application/json",
+			body:           `["12345678902"]`,
+			wantStatusCode: http.StatusOK,
+			want: []models.OrderUpload{
+				{Number: "12345678902", Result: models.UploadInvalid},
+			},
+		},
+		{
+			name:           "must return 400 status (empty)",
+			contentType:    "application/json",
+			body:           `[]`,
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "must return 400 status (malformed json)",
+			contentType:    "application/json",
+			body:           `["12345678903", {}]`,
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "must return 400 status (content type)",
+			contentType:    "application/xml",
+			body:           `<orders/>`,
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "must return 413 status",
+			contentType:    "text/plain",
+			body:           "12345678903\n2377225624\n79927398713\n",
+			limit:          2,
+			wantStatusCode: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:           "must return 500 status",
+			contentType:    "text/plain",
+			body:           "12345678903\n",
+			storageErr:     errors.New("storage error"),
+			wantStatusCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := logger.New("dev")
+			secret := "secret"
+
+			token, err := auth.GenerateToken("test", secret, time.Hour)
+			assert.Empty(t, err)
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			m := mocks.NewMockStorage(ctrl)
+
+			m.EXPECT().SaveOrders(gomock.Any(), gomock.Any(), "test", models.New).
+				DoAndReturn(func(_ any, numbers []string, _ string, _ models.OrderStatus) (
+					map[string]models.UploadResult, error,
+				) {
+					results := make(map[string]models.UploadResult, len(numbers))
+					for _, number := range numbers {
+						results[number] = stored[number]
+					}
+					return results, tt.storageErr
+				}).MaxTimes(1)
+
+			opts := make([]router.Option, 0)
+			if tt.limit > 0 {
+				opts = append(opts, router.WithBulkOrdersLimit(tt.limit))
+			}
+			r := router.New(log, m, secret, time.Hour, opts...)
+			srv := httptest.NewServer(openapitest.Handler(t, r))
+			defer srv.Close()
+
+			client := resty.New()
+
+			resp, err := client.R().
+				SetHeader("Content-Type", tt.contentType).
+				SetHeader("Authorization", token).
+				SetBody(tt.body).
+				Post(fmt.Sprintf("%s/%s", srv.URL, "api/user/orders/bulk"))
+
+			assert.Empty(t, err)
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode())
+			if tt.want == nil {
+				return
+			}
+
+			var got []models.OrderUpload
+			err = json.Unmarshal(resp.Body(), &got)
+			assert.Empty(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package getsessions
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/lib/logger/sl"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/middleware/auth"
+)
+
+type SessionsProvider interface {
+	GetSessions(ctx context.Context, userLogin string) ([]models.Session, error)
+}
+
+func New(log *slog.Logger, s SessionsProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context(), log)
+		userLogin, err := auth.GetLogin(r)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to fetch user login from context")
+			problem.Internal(w, r)
+			return
+		}
+
+		sessions, err := s.GetSessions(r.Context(), userLogin)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to fetch sessions", sl.Err(err))
+			problem.Internal(w, r)
+			return
+		}
+		if len(sessions) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		// Помечаем сессию, с которой пришёл запрос.
+		if current, ok := auth.GetSessionID(r); ok {
+			for i := range sessions {
+				sessions[i].Current = sessions[i].ID == current
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		err = enc.Encode(sessions)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to encode sessions json", sl.Err(err))
+			return
+		}
+	}
+}
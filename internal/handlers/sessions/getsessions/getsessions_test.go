@@ -0,0 +1,93 @@
+package getsessions_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/mocks"
+	"github.com/VanGoghDev/gophermart/internal/openapi/openapitest"
+	"github.com/VanGoghDev/gophermart/internal/router"
+	"github.com/VanGoghDev/gophermart/internal/services/auth"
+	"github.com/go-resty/resty/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name           string
+		sessions       []models.Session
+		storageErr     error
+		wantStatusCode int
+	}{
+		{
+			name: "must return 200 status",
+			sessions: []models.Session{
+				{
+					ID: 1, UserLogin: "test", Device: "laptop", UserAgent: "curl/8.0", IP: "127.0.0.1",
+					CreatedAtFormated: "2024-05-01T10:00:00+03:00", LastUsedAtFormated: "2024-05-02T10:00:00+03:00",
+				},
+				{
+					ID: 2, UserLogin: "test", Device: "phone",
+					CreatedAtFormated: "2024-05-01T11:00:00+03:00", LastUsedAtFormated: "2024-05-01T11:00:00+03:00",
+				},
+			},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "must return 204 status",
+			sessions:       []models.Session{},
+			wantStatusCode: http.StatusNoContent,
+		},
+		{
+			name:           "must return 500 status",
+			storageErr:     errors.New("storage error"),
+			wantStatusCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := logger.New("dev")
+			secret := "secret"
+
+			token, err := auth.GrantSessionToken("test", models.RoleUser, 1, secret, time.Hour)
+			assert.Empty(t, err)
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			m := mocks.NewMockStorage(ctrl)
+
+			m.EXPECT().TouchSession(gomock.Any(), int64(1)).
+				Return(models.Session{ID: 1, UserLogin: "test"}, nil)
+			m.EXPECT().GetSessions(gomock.Any(), "test").Return(tt.sessions, tt.storageErr)
+
+			r := router.New(log, m, secret, time.Hour)
+			srv := httptest.NewServer(openapitest.Handler(t, r))
+			defer srv.Close()
+
+			client := resty.New()
+
+			resp, err := client.R().
+				SetHeader("Authorization", token).
+				Get(fmt.Sprintf("%s/%s", srv.URL, "api/user/sessions"))
+
+			assert.Empty(t, err)
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode())
+
+			if resp.StatusCode() == http.StatusOK {
+				var sessions []models.Session
+				assert.Empty(t, json.Unmarshal(resp.Body(), &sessions))
+				assert.Len(t, sessions, len(tt.sessions))
+				assert.True(t, sessions[0].Current)
+				assert.False(t, sessions[1].Current)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package postconfirm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/lib/decode"
+	"github.com/VanGoghDev/gophermart/internal/lib/logger/sl"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/middleware/auth"
+	"github.com/VanGoghDev/gophermart/internal/services/auth/totp"
+	"github.com/VanGoghDev/gophermart/internal/storage"
+)
+
+const recoveryCodesCount = 10
+
+type TOTPConfirmer interface {
+	GetTOTP(ctx context.Context, login string) (models.TOTP, error)
+	ConfirmTOTP(ctx context.Context, login string, step int64, recoveryHashes []string) error
+}
+
+type Request struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// Response holds recovery codes. They are shown only once.
+type Response struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+func New(log *slog.Logger, s TOTPConfirmer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context(), log)
+		userLogin, err := auth.GetLogin(r)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to fetch user login from context")
+			problem.Internal(w, r)
+			return
+		}
+
+		req := &Request{}
+		if prob := decode.JSON(w, r, req); prob != nil {
+			problem.Render(w, r, *prob)
+			return
+		}
+
+		t, err := s.GetTOTP(r.Context(), userLogin)
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				problem.Write(w, r, http.StatusNotFound, problem.CodeTOTPNotEnrolled,
+					"two-factor enrollment was not started")
+				return
+			}
+			log.ErrorContext(r.Context(), "failed to get totp", sl.Err(err))
+			problem.Internal(w, r)
+			return
+		}
+		if t.Confirmed() {
+			problem.Write(w, r, http.StatusConflict, problem.CodeTOTPAlreadyEnabled,
+				"two-factor authentication is already enabled")
+			return
+		}
+
+		step, ok, err := totp.Validate(t.Secret, req.Code, time.Now())
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to validate totp code", sl.Err(err))
+			problem.Internal(w, r)
+			return
+		}
+		if !ok {
+			problem.Write(w, r, http.StatusUnprocessableEntity, problem.CodeInvalidTOTPCode, "code is invalid")
+			return
+		}
+
+		codes, err := totp.GenerateRecoveryCodes(recoveryCodesCount)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to generate recovery codes", sl.Err(err))
+			problem.Internal(w, r)
+			return
+		}
+		hashes := make([]string, 0, len(codes))
+		for _, c := range codes {
+			hashes = append(hashes, totp.HashRecoveryCode(c))
+		}
+
+		err = s.ConfirmTOTP(r.Context(), userLogin, step, hashes)
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				problem.Write(w, r, http.StatusConflict, problem.CodeTOTPAlreadyEnabled,
+					"two-factor authentication is already enabled")
+				return
+			}
+			log.ErrorContext(r.Context(), "failed to confirm totp", sl.Err(err))
+			problem.Internal(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		enc := json.NewEncoder(w)
+		err = enc.Encode(Response{RecoveryCodes: codes})
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to encode recovery codes json", sl.Err(err))
+			return
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package postconfirm_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/handlers/totp/postconfirm"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/mocks"
+	"github.com/VanGoghDev/gophermart/internal/openapi/openapitest"
+	"github.com/VanGoghDev/gophermart/internal/router"
+	"github.com/VanGoghDev/gophermart/internal/services/auth"
+	"github.com/VanGoghDev/gophermart/internal/services/auth/totp"
+	"github.com/VanGoghDev/gophermart/internal/storage"
+	"github.com/go-resty/resty/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	secret, err := totp.GenerateSecret()
+	assert.Empty(t, err)
+	validCode, err := totp.Code(secret, totp.Step(time.Now()))
+	assert.Empty(t, err)
+	confirmedAt := time.Now()
+
+	type args struct {
+		contentType string
+		body        string
+		totp        models.TOTP
+		getErr      error
+		confirmErr  error
+	}
+	tests := []struct {
+		name           string
+		args           args
+		wantStatusCode int
+		wantConfirm    bool
+	}{
+		{
+			name: "must return 200 status",
+			args: args{
+				contentType: "application/json",
+				body:        fmt.Sprintf(`{"code": %q}`, validCode),
+				totp:        models.TOTP{UserLogin: "test", Secret: secret},
+			},
+			wantStatusCode: http.StatusOK,
+			wantConfirm:    true,
+		},
+		{
+			name: "must return 400 status (no code)",
+			args: args{
+				contentType: "application/json",
+				body:        `{}`,
+			},
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "must return 404 status (not enrolled)",
+			args: args{
+				contentType: "application/json",
+				body:        fmt.Sprintf(`{"code": %q}`, validCode),
+				getErr:      storage.ErrNotFound,
+			},
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name: "must return 409 status (already confirmed)",
+			args: args{
+				contentType: "application/json",
+				body:        fmt.Sprintf(`{"code": %q}`, validCode),
+				totp:        models.TOTP{UserLogin: "test", Secret: secret, ConfirmedAt: &confirmedAt},
+			},
+			wantStatusCode: http.StatusConflict,
+		},
+		{
+			name: "must return 422 status (wrong code)",
+			args: args{
+				contentType: "application/json",
+				body:        `{"code": "abcdef"}`,
+				totp:        models.TOTP{UserLogin: "test", Secret: secret},
+			},
+			wantStatusCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "must return 500 status",
+			args: args{
+				contentType: "application/json",
+				body:        fmt.Sprintf(`{"code": %q}`, validCode),
+				totp:        models.TOTP{UserLogin: "test", Secret: secret},
+				confirmErr:  errors.New("storage error"),
+			},
+			wantStatusCode: http.StatusInternalServerError,
+			wantConfirm:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := logger.New("dev")
+			tokenSecret := "secret"
+
+			token, err := auth.GenerateToken("test", tokenSecret, time.Hour)
+			assert.Empty(t, err)
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			m := mocks.NewMockStorage(ctrl)
+
+			m.EXPECT().GetTOTP(gomock.Any(), "test").
+				Return(tt.args.totp, tt.args.getErr).AnyTimes()
+
+			var savedHashes []string
+			confirmCalls := 0
+			if tt.wantConfirm {
+				confirmCalls = 1
+			}
+			m.EXPECT().ConfirmTOTP(gomock.Any(), "test", gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ any, _ string, _ int64, hashes []string) error {
+					savedHashes = hashes
+					return tt.args.confirmErr
+				}).Times(confirmCalls)
+
+			r := router.New(log, m, tokenSecret, time.Hour)
+			srv := httptest.NewServer(openapitest.Handler(t, r))
+			defer srv.Close()
+
+			client := resty.New()
+
+			resp, err := client.R().
+				SetHeader("Content-Type", tt.args.contentType).
+				SetHeader("Authorization", token).
+				SetBody(tt.args.body).
+				Post(fmt.Sprintf("%s/%s", srv.URL, "api/user/totp/confirm"))
+
+			assert.Empty(t, err)
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode())
+
+			if resp.StatusCode() == http.StatusOK {
+				var confirmed postconfirm.Response
+				assert.Empty(t, json.Unmarshal(resp.Body(), &confirmed))
+				assert.Len(t, confirmed.RecoveryCodes, len(savedHashes))
+				// Коды хранятся только в виде хэшей.
+				for i, c := range confirmed.RecoveryCodes {
+					assert.Equal(t, totp.HashRecoveryCode(c), savedHashes[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package posttotp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/VanGoghDev/gophermart/internal/lib/logger/sl"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/middleware/auth"
+	"github.com/VanGoghDev/gophermart/internal/services/auth/totp"
+	"github.com/VanGoghDev/gophermart/internal/storage"
+)
+
+type TOTPSaver interface {
+	SaveTOTPSecret(ctx context.Context, login string, secret string) error
+}
+
+type Response struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+// New starts TOTP enrollment. The secret is not enforced until it is confirmed with a valid code.
+func New(log *slog.Logger, s TOTPSaver, issuer string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context(), log)
+		userLogin, err := auth.GetLogin(r)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to fetch user login from context")
+			problem.Internal(w, r)
+			return
+		}
+
+		secret, err := totp.GenerateSecret()
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to generate totp secret", sl.Err(err))
+			problem.Internal(w, r)
+			return
+		}
+
+		err = s.SaveTOTPSecret(r.Context(), userLogin, secret)
+		if err != nil {
+			// 409 двухфакторная аутентификация уже включена.
+			if errors.Is(err, storage.ErrAlreadyExists) {
+				problem.Write(w, r, http.StatusConflict, problem.CodeTOTPAlreadyEnabled,
+					"two-factor authentication is already enabled")
+				return
+			}
+			log.ErrorContext(r.Context(), "failed to save totp secret", sl.Err(err))
+			problem.Internal(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		enc := json.NewEncoder(w)
+		err = enc.Encode(Response{
+			Secret:     secret,
+			OTPAuthURI: totp.URI(issuer, userLogin, secret),
+		})
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to encode totp json", sl.Err(err))
+			return
+		}
+	}
+}
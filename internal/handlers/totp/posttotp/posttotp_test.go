@@ -0,0 +1,86 @@
+package posttotp_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/handlers/totp/posttotp"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/mocks"
+	"github.com/VanGoghDev/gophermart/internal/openapi/openapitest"
+	"github.com/VanGoghDev/gophermart/internal/router"
+	"github.com/VanGoghDev/gophermart/internal/services/auth"
+	"github.com/VanGoghDev/gophermart/internal/storage"
+	"github.com/go-resty/resty/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name           string
+		storageErr     error
+		wantStatusCode int
+	}{
+		{
+			name:           "must return 200 status",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "must return 409 status (already enabled)",
+			storageErr:     storage.ErrAlreadyExists,
+			wantStatusCode: http.StatusConflict,
+		},
+		{
+			name:           "must return 500 status",
+			storageErr:     errors.New("storage error"),
+			wantStatusCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := logger.New("dev")
+			secret := "secret"
+
+			token, err := auth.GenerateToken("test", secret, time.Hour)
+			assert.Empty(t, err)
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			m := mocks.NewMockStorage(ctrl)
+
+			var savedSecret string
+			m.EXPECT().SaveTOTPSecret(gomock.Any(), "test", gomock.Any()).
+				DoAndReturn(func(_ any, _ string, s string) error {
+					savedSecret = s
+					return tt.storageErr
+				})
+
+			r := router.New(log, m, secret, time.Hour, router.WithTOTPIssuer("Shop"))
+			srv := httptest.NewServer(openapitest.Handler(t, r))
+			defer srv.Close()
+
+			client := resty.New()
+
+			resp, err := client.R().
+				SetHeader("Authorization", token).
+				Post(fmt.Sprintf("%s/%s", srv.URL, "api/user/totp"))
+
+			assert.Empty(t, err)
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode())
+
+			if resp.StatusCode() == http.StatusOK {
+				var enrolled posttotp.Response
+				assert.Empty(t, json.Unmarshal(resp.Body(), &enrolled))
+				assert.Equal(t, savedSecret, enrolled.Secret)
+				assert.True(t, strings.HasPrefix(enrolled.OTPAuthURI, "otpauth://totp/Shop:test?"), enrolled.OTPAuthURI)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+// Package conditional answers conditional GET requests (RFC 9110, section 13)
+// from a cheap version of the resource, before the resource itself is read and serialized.
+package conditional
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+)
+
+// ETag returns a strong entity tag for the version of the named resource.
+func ETag(resource string, v models.Version) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s:%d:%d", resource, v.Counter, v.LastModified.UnixMicro())))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// NotModified sets the validators on the response and writes 304 if the client's copy is still fresh.
+// When it returns true the handler must not write anything else.
+func NotModified(w http.ResponseWriter, r *http.Request, etag string, lastModified time.Time) bool {
+	h := w.Header()
+	// Ответы зависят от пользователя, поэтому только частный кэш, и каждый раз с перепроверкой.
+	h.Set("Cache-Control", "private, no-cache")
+	h.Set("ETag", etag)
+	if !lastModified.IsZero() {
+		h.Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if !fresh(r, etag, lastModified) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+func fresh(r *http.Request, etag string, lastModified time.Time) bool {
+	// If-None-Match важнее If-Modified-Since, если клиент прислал оба.
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return matches(inm, etag)
+	}
+
+	ims := r.Header.Get("If-Modified-Since")
+	if ims == "" || lastModified.IsZero() {
+		return false
+	}
+	t, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	// Last-Modified передаётся с точностью до секунды.
+	return !lastModified.Truncate(time.Second).After(t)
+}
+
+// matches uses the weak comparison, as required for If-None-Match.
+func matches(header string, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
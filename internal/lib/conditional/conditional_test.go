@@ -0,0 +1,95 @@
+package conditional_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/lib/conditional"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotModified(t *testing.T) {
+	modified := time.Date(2024, 5, 1, 10, 0, 0, 500, time.UTC)
+	etag := conditional.ETag("orders", models.Version{Counter: 2, LastModified: modified})
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    bool
+	}{
+		{
+			name: "no validators",
+		},
+		{
+			name:    "matching etag",
+			headers: map[string]string{"If-None-Match": etag},
+			want:    true,
+		},
+		{
+			name:    "matching weak etag in a list",
+			headers: map[string]string{"If-None-Match": `"other", W/` + etag},
+			want:    true,
+		},
+		{
+			name:    "any etag",
+			headers: map[string]string{"If-None-Match": "*"},
+			want:    true,
+		},
+		{
+			name:    "stale etag",
+			headers: map[string]string{"If-None-Match": `"other"`},
+		},
+		{
+			name:    "not modified since",
+			headers: map[string]string{"If-Modified-Since": modified.Format(http.TimeFormat)},
+			want:    true,
+		},
+		{
+			name:    "modified since",
+			headers: map[string]string{"If-Modified-Since": modified.Add(-time.Second).Format(http.TimeFormat)},
+		},
+		{
+			name: "etag wins over date",
+			headers: map[string]string{
+				"If-None-Match":     `"other"`,
+				"If-Modified-Since": modified.Format(http.TimeFormat),
+			},
+		},
+		{
+			name:    "invalid date",
+			headers: map[string]string{"If-Modified-Since": "yesterday"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+
+			got := conditional.NotModified(w, r, etag, modified)
+
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, etag, w.Header().Get("ETag"))
+			assert.Equal(t, modified.Format(http.TimeFormat), w.Header().Get("Last-Modified"))
+			if tt.want {
+				assert.Equal(t, http.StatusNotModified, w.Code)
+			}
+		})
+	}
+}
+
+func TestETag(t *testing.T) {
+	v := models.Version{Counter: 1, LastModified: time.Unix(100, 0)}
+
+	assert.Equal(t, conditional.ETag("orders", v), conditional.ETag("orders", v))
+	assert.NotEqual(t, conditional.ETag("orders", v), conditional.ETag("withdrawals", v))
+	assert.NotEqual(t, conditional.ETag("orders", v),
+		conditional.ETag("orders", models.Version{Counter: 2, LastModified: v.LastModified}))
+	assert.NotEqual(t, conditional.ETag("orders", v),
+		conditional.ETag("orders", models.Version{Counter: 1, LastModified: v.LastModified.Add(time.Microsecond)}))
+}
@@ -0,0 +1,175 @@
+// Package decode reads JSON request bodies the same way in every handler.
+package decode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"gopkg.in/go-playground/validator.v9"
+)
+
+// DefaultMaxBodySize is enough for any JSON request of the API.
+const DefaultMaxBodySize = 1 << 20
+
+// FieldError describes a field that broke a validation rule.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+var validate = newValidator()
+
+// JSON decodes the body into dst and validates it by the `validate` struct tags.
+// On failure the returned problem is ready to be rendered.
+func JSON(w http.ResponseWriter, r *http.Request, dst any) *problem.Problem {
+	return JSONLimit(w, r, dst, DefaultMaxBodySize)
+}
+
+// JSONLimit is JSON with a custom body size limit in bytes.
+func JSONLimit(w http.ResponseWriter, r *http.Request, dst any, limit int64) *problem.Problem {
+	if !IsJSON(r.Header.Get("Content-Type")) {
+		return newProblem(http.StatusBadRequest, problem.CodeInvalidContentType,
+			"Content-Type must be application/json")
+	}
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, limit))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(dst); err != nil {
+		return decodeProblem(err)
+	}
+	// Тело должно содержать ровно один JSON документ.
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return decodeProblem(err)
+		}
+		return newProblem(http.StatusBadRequest, problem.CodeInvalidBody,
+			"request body must contain a single JSON value")
+	}
+
+	return Validate(dst)
+}
+
+// Validate checks dst by its `validate` struct tags.
+func Validate(dst any) *problem.Problem {
+	err := validate.Struct(dst)
+	if err == nil {
+		return nil
+	}
+
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return newProblem(http.StatusBadRequest, problem.CodeInvalidBody, err.Error())
+	}
+
+	fields := make([]FieldError, 0, len(verrs))
+	for _, fe := range verrs {
+		fields = append(fields, FieldError{
+			Field:   fieldPath(fe),
+			Rule:    fe.Tag(),
+			Message: message(fe),
+		})
+	}
+	p := problem.New(http.StatusBadRequest, problem.CodeValidation, "request body failed validation")
+	p.Errors = fields
+	return &p
+}
+
+// IsJSON reports whether the content type is application/json, optionally with utf-8 charset.
+func IsJSON(contentType string) bool {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != "application/json" {
+		return false
+	}
+	charset, ok := params["charset"]
+	return !ok || strings.EqualFold(charset, "utf-8")
+}
+
+func decodeProblem(err error) *problem.Problem {
+	var (
+		maxErr    *http.MaxBytesError
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+	switch {
+	case errors.As(err, &maxErr):
+		return newProblem(http.StatusRequestEntityTooLarge, problem.CodeBodyTooLarge,
+			fmt.Sprintf("request body must not exceed %d bytes", maxErr.Limit))
+	case errors.Is(err, io.EOF):
+		return newProblem(http.StatusBadRequest, problem.CodeInvalidBody, "request body is empty")
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return newProblem(http.StatusBadRequest, problem.CodeInvalidBody, "request body is not valid JSON")
+	case errors.As(err, &typeErr):
+		return newProblem(http.StatusBadRequest, problem.CodeInvalidBody,
+			fmt.Sprintf("field %q must be %s", typeErr.Field, typeErr.Type))
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json не экспортирует тип этой ошибки.
+		return newProblem(http.StatusBadRequest, problem.CodeInvalidBody,
+			strings.TrimPrefix(err.Error(), "json: "))
+	default:
+		return newProblem(http.StatusBadRequest, problem.CodeInvalidBody, "request body could not be decoded")
+	}
+}
+
+func newProblem(status int, code string, detail string) *problem.Problem {
+	p := problem.New(status, code, detail)
+	return &p
+}
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	// В ошибках используем имена полей из JSON.
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	// notblank is like required but does not accept whitespace only strings.
+	_ = v.RegisterValidation("notblank", func(fl validator.FieldLevel) bool {
+		return strings.TrimSpace(fl.Field().String()) != ""
+	})
+	return v
+}
+
+// fieldPath drops the root struct name: "Request.order" becomes "order".
+func fieldPath(fe validator.FieldError) string {
+	_, path, found := strings.Cut(fe.Namespace(), ".")
+	if !found {
+		return fe.Field()
+	}
+	return path
+}
+
+func message(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required", "notblank":
+		return "is required"
+	case "required_without":
+		return "is required unless " + fe.Param() + " is set"
+	case "max":
+		return "must be at most " + fe.Param()
+	case "min":
+		return "must be at least " + fe.Param()
+	case "gt":
+		return "must be greater than " + fe.Param()
+	case "gte":
+		return "must be greater than or equal to " + fe.Param()
+	case "ne":
+		return "must not be " + fe.Param()
+	case "oneof":
+		return "must be one of " + fe.Param()
+	default:
+		return fmt.Sprintf("failed the %q rule", fe.Tag())
+	}
+}
@@ -0,0 +1,123 @@
+package decode_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/VanGoghDev/gophermart/internal/lib/decode"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type request struct {
+	Order string  `json:"order" validate:"required"`
+	Name  string  `json:"name,omitempty" validate:"omitempty,notblank,max=5"`
+	Sum   float64 `json:"sum" validate:"gt=0"`
+}
+
+func TestJSON(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		limit       int64
+		status      int
+		code        string
+		fields      []decode.FieldError
+	}{
+		{
+			name:        "valid request",
+			contentType: "application/json",
+			body:        `{"order": "123", "sum": 10}`,
+		},
+		{
+			name:        "utf-8 charset is accepted",
+			contentType: "application/json; charset=UTF-8",
+			body:        `{"order": "123", "sum": 10}`,
+		},
+		{
+			name:        "other charset is rejected",
+			contentType: "application/json; charset=latin1",
+			body:        `{"order": "123", "sum": 10}`,
+			status:      http.StatusBadRequest,
+			code:        problem.CodeInvalidContentType,
+		},
+		{
+			name:        "wrong content type",
+			contentType: "text/plain",
+			body:        `{"order": "123", "sum": 10}`,
+			status:      http.StatusBadRequest,
+			code:        problem.CodeInvalidContentType,
+		},
+		{
+			name:        "unknown field",
+			contentType: "application/json",
+			body:        `{"order": "123", "sum": 10, "extra": true}`,
+			status:      http.StatusBadRequest,
+			code:        problem.CodeInvalidBody,
+		},
+		{
+			name:        "malformed json",
+			contentType: "application/json",
+			body:        `{"order": `,
+			status:      http.StatusBadRequest,
+			code:        problem.CodeInvalidBody,
+		},
+		{
+			name:        "trailing data",
+			contentType: "application/json",
+			body:        `{"order": "123", "sum": 10} {}`,
+			status:      http.StatusBadRequest,
+			code:        problem.CodeInvalidBody,
+		},
+		{
+			name:        "body too large",
+			contentType: "application/json",
+			body:        `{"order": "` + strings.Repeat("1", 64) + `", "sum": 10}`,
+			limit:       32,
+			status:      http.StatusRequestEntityTooLarge,
+			code:        problem.CodeBodyTooLarge,
+		},
+		{
+			name:        "validation errors use json names",
+			contentType: "application/json",
+			body:        `{"name": "   ", "sum": 0}`,
+			status:      http.StatusBadRequest,
+			code:        problem.CodeValidation,
+			fields: []decode.FieldError{
+				{Field: "order", Rule: "required", Message: "is required"},
+				{Field: "name", Rule: "notblank", Message: "is required"},
+				{Field: "sum", Rule: "gt", Message: "must be greater than 0"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+
+			limit := tt.limit
+			if limit == 0 {
+				limit = decode.DefaultMaxBodySize
+			}
+			req := &request{}
+			prob := decode.JSONLimit(w, r, req, limit)
+
+			if tt.status == 0 {
+				require.Nil(t, prob)
+				assert.Equal(t, "123", req.Order)
+				return
+			}
+			require.NotNil(t, prob)
+			assert.Equal(t, tt.status, prob.Status)
+			assert.Equal(t, tt.code, prob.Code)
+			if tt.fields != nil {
+				assert.Equal(t, tt.fields, prob.Errors)
+			}
+		})
+	}
+}
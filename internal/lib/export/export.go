@@ -0,0 +1,331 @@
+// Package export streams tables as CSV or PDF downloads.
+package export
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/lib/logger/sl"
+	"github.com/VanGoghDev/gophermart/internal/lib/pdf"
+	"github.com/VanGoghDev/gophermart/internal/lib/period"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+)
+
+// Format is a file format of an export.
+type Format string
+
+const (
+	CSV Format = "csv"
+	PDF Format = "pdf"
+)
+
+// formats are listed in the order of preference when the client accepts several equally.
+var formats = []Format{CSV, PDF}
+
+var mediaTypes = map[Format]string{
+	CSV: "text/csv",
+	PDF: "application/pdf",
+}
+
+const (
+	// flushEvery is how many rows are written between flushes of the response.
+	flushEvery = 100
+	// writeTimeout is how long a client may take to receive the rows written between flushes. While the table
+	// is written a storage connection is held, so a client that stops reading must not keep it for long.
+	writeTimeout = 30 * time.Second
+	// DefaultConcurrency is how many exports may run at once. Each one holds a storage connection until it ends.
+	DefaultConcurrency = 2
+)
+
+var (
+	ErrUnknownFormat = errors.New("unknown export format")
+	ErrNotAcceptable = errors.New("none of the accepted media types can be exported")
+)
+
+// Negotiate picks the format. The format query parameter wins over the Accept header;
+// without both, or with an empty Accept, the format is CSV.
+func Negotiate(r *http.Request) (Format, error) {
+	if v := r.URL.Query().Get("format"); v != "" {
+		f := Format(strings.ToLower(v))
+		if _, ok := mediaTypes[f]; !ok {
+			return "", fmt.Errorf("%w: %q", ErrUnknownFormat, v)
+		}
+		return f, nil
+	}
+
+	accept := r.Header.Values("Accept")
+	if strings.TrimSpace(strings.Join(accept, "")) == "" {
+		return CSV, nil
+	}
+	var best Format
+	bestQ := 0.0
+	for _, f := range formats {
+		if q := quality(accept, mediaTypes[f]); q > bestQ {
+			best, bestQ = f, q
+		}
+	}
+	if best == "" {
+		return "", ErrNotAcceptable
+	}
+	return best, nil
+}
+
+// quality returns the q-value the Accept header gives to the media type. The most specific matching range wins,
+// as RFC 9110 requires: "text/csv;q=0" excludes CSV even with "*/*" in the header.
+func quality(accept []string, mediaType string) float64 {
+	typ, _, _ := strings.Cut(mediaType, "/")
+	q, specificity := 0.0, -1
+	for _, header := range accept {
+		for _, part := range strings.Split(header, ",") {
+			rng, params, err := mime.ParseMediaType(part)
+			if err != nil {
+				continue
+			}
+			s := -1
+			switch rng {
+			case mediaType:
+				s = 2
+			case typ + "/*":
+				s = 1
+			case "*/*":
+				s = 0
+			}
+			if s <= specificity {
+				continue
+			}
+			v := 1.0
+			if raw, ok := params["q"]; ok {
+				v, err = strconv.ParseFloat(raw, 64)
+				if err != nil || v < 0 || v > 1 {
+					continue
+				}
+			}
+			q, specificity = v, s
+		}
+	}
+	return q
+}
+
+// Request reads the format and the period of an export request, see Negotiate and period.Parse.
+// On failure the returned problem is ready to be rendered.
+func Request(r *http.Request, now time.Time) (Format, period.Range, *problem.Problem) {
+	format, err := Negotiate(r)
+	if err != nil {
+		p := problem.New(http.StatusBadRequest, problem.CodeInvalidParameter, err.Error())
+		if errors.Is(err, ErrNotAcceptable) {
+			p = problem.New(http.StatusNotAcceptable, problem.CodeNotAcceptable, "text/csv or application/pdf")
+		}
+		return "", period.Range{}, &p
+	}
+	rng, err := period.Parse(r.URL.Query(), now)
+	if err != nil {
+		p := problem.New(http.StatusBadRequest, problem.CodeInvalidParameter, err.Error())
+		return "", period.Range{}, &p
+	}
+	return format, rng, nil
+}
+
+// Title returns the title lines of the user's table for the period.
+func Title(name string, userLogin string, rng period.Range) []string {
+	const layout = "2006-01-02 15:04 MST"
+	span := "up to " + rng.To.UTC().Format(layout)
+	if !rng.From.IsZero() {
+		span = rng.From.UTC().Format(layout) + " - " + rng.To.UTC().Format(layout)
+	}
+	return []string{"Gophermart: " + name, "Account: " + userLogin, "Period: " + span}
+}
+
+// Column describes a column of a table.
+type Column struct {
+	Name string
+	// Width is the width of the column in characters in PDF. Longer values are cut.
+	Width int
+	// Right aligns the column to the right in PDF, for amounts.
+	Right bool
+}
+
+// Table writes rows to the response as they come. Nothing is sent before the first row,
+// so an error that happens earlier can still be answered with a problem.
+type Table struct {
+	w        http.ResponseWriter
+	rc       *http.ResponseController
+	format   Format
+	filename string
+	title    []string
+	columns  []Column
+	csv      *csv.Writer
+	pdf      *pdf.Writer
+	rows     int
+	started  bool
+}
+
+// NewTable returns a table downloaded as filename with the extension of the format.
+// The title lines head every page of PDF, the first of them also titles the document.
+// CSV has no title: its first row names the columns.
+func NewTable(w http.ResponseWriter, format Format, filename string, title []string, columns ...Column) *Table {
+	return &Table{
+		w:        w,
+		rc:       http.NewResponseController(w),
+		format:   format,
+		filename: filename + "." + string(format),
+		title:    title,
+		columns:  columns,
+	}
+}
+
+// Row writes a row, one cell per column.
+func (t *Table) Row(cells ...string) error {
+	if err := t.start(); err != nil {
+		return err
+	}
+	var err error
+	if t.format == PDF {
+		err = t.pdf.Line(t.line(cells))
+	} else {
+		err = t.csv.Write(cells)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write row: %w", err)
+	}
+
+	t.rows++
+	if t.rows%flushEvery == 0 {
+		return t.flush()
+	}
+	return nil
+}
+
+// Close finishes the file. A table without rows is still a valid file with the column names.
+func (t *Table) Close() error {
+	if err := t.start(); err != nil {
+		return err
+	}
+	if t.format == PDF {
+		if err := t.pdf.Close(); err != nil {
+			return fmt.Errorf("failed to close pdf: %w", err)
+		}
+	}
+	return t.flush()
+}
+
+// Fail handles an error of filling the table. Before the first row it responds with 500. After it
+// a part of the file may already be sent, so the connection is aborted: a cut file must not look like a complete one.
+func (t *Table) Fail(w http.ResponseWriter, r *http.Request, log *slog.Logger, err error) {
+	log.ErrorContext(r.Context(), "failed to export "+t.filename, sl.Err(err))
+	if !t.started {
+		problem.Internal(w, r)
+		return
+	}
+	panic(http.ErrAbortHandler)
+}
+
+// Amount formats an amount of points for the format: exact in CSV and with two decimals in PDF.
+func (t *Table) Amount(v float64) string {
+	if t.format == PDF {
+		return strconv.FormatFloat(v, 'f', 2, 64)
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// Time formats a time for the format: RFC 3339 in CSV and to the minute in PDF.
+func (t *Table) Time(v time.Time) string {
+	if t.format == PDF {
+		return v.UTC().Format("2006-01-02 15:04")
+	}
+	return v.UTC().Format(time.RFC3339)
+}
+
+func (t *Table) start() error {
+	if t.started {
+		return nil
+	}
+	t.started = true
+	if err := t.extendDeadline(); err != nil {
+		return err
+	}
+
+	contentType := mediaTypes[t.format]
+	if t.format == CSV {
+		contentType += "; charset=utf-8"
+	}
+	h := t.w.Header()
+	h.Set("Content-Type", contentType)
+	h.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": t.filename}))
+	h.Set("X-Content-Type-Options", "nosniff")
+
+	names := make([]string, len(t.columns))
+	for i, c := range t.columns {
+		names[i] = c.Name
+	}
+	if t.format == PDF {
+		var title string
+		if len(t.title) > 0 {
+			title = t.title[0]
+		}
+		t.pdf = pdf.New(t.w, title)
+		header := slices.Clone(t.title)
+		line := t.line(names)
+		header = append(header, "", line, strings.Repeat("-", len(line)))
+		t.pdf.SetHeader(header...)
+		return nil
+	}
+	t.csv = csv.NewWriter(t.w)
+	if err := t.csv.Write(names); err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
+	return nil
+}
+
+// line lays the cells out in the fixed-width columns of PDF.
+func (t *Table) line(cells []string) string {
+	var b strings.Builder
+	for i, c := range t.columns {
+		if i > 0 {
+			b.WriteString("  ")
+		}
+		var cell string
+		if i < len(cells) {
+			cell = cells[i]
+		}
+		if r := []rune(cell); len(r) > c.Width {
+			cell = string(r[:c.Width])
+		}
+		format := "%-*s"
+		if c.Right {
+			format = "%*s"
+		}
+		fmt.Fprintf(&b, format, c.Width, cell)
+	}
+	return strings.TrimRight(b.String(), " ")
+}
+
+func (t *Table) flush() error {
+	if t.csv != nil {
+		t.csv.Flush()
+		if err := t.csv.Error(); err != nil {
+			return fmt.Errorf("failed to write csv: %w", err)
+		}
+	}
+	// Промежуточные сбросы нужны только для потоковой отдачи, писатель без Flush отдаст всё в конце.
+	if err := t.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return fmt.Errorf("failed to flush response: %w", err)
+	}
+	return t.extendDeadline()
+}
+
+// extendDeadline gives the client writeTimeout to receive the next rows.
+func (t *Table) extendDeadline() error {
+	err := t.rc.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return fmt.Errorf("failed to set write deadline: %w", err)
+	}
+	return nil
+}
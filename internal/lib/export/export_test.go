@@ -0,0 +1,163 @@
+package export_test
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/lib/export"
+	"github.com/VanGoghDev/gophermart/internal/lib/period"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		accept  []string
+		want    export.Format
+		wantErr error
+	}{
+		{name: "must default to csv", want: export.CSV},
+		{name: "must default to csv on empty header", accept: []string{""}, want: export.CSV},
+		{name: "must pick csv", accept: []string{"text/csv"}, want: export.CSV},
+		{name: "must pick pdf", accept: []string{"application/pdf"}, want: export.PDF},
+		{name: "must prefer csv on any type", accept: []string{"*/*"}, want: export.CSV},
+		{name: "must follow q-values", accept: []string{"text/csv;q=0.5, application/pdf"}, want: export.PDF},
+		{name: "must match subtype wildcards", accept: []string{"application/*"}, want: export.PDF},
+		{name: "must read every header", accept: []string{"text/html", "application/pdf"}, want: export.PDF},
+		{
+			name:   "must let the most specific range win",
+			accept: []string{"*/*, text/csv;q=0"},
+			want:   export.PDF,
+		},
+		{name: "must let the parameter win", query: "format=PDF", accept: []string{"text/csv"}, want: export.PDF},
+		{name: "must reject unknown formats", query: "format=xlsx", wantErr: export.ErrUnknownFormat},
+		{name: "must reject unacceptable types", accept: []string{"application/json"}, wantErr: export.ErrNotAcceptable},
+		{name: "must reject excluded types", accept: []string{"text/csv;q=0"}, wantErr: export.ErrNotAcceptable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/export?"+tt.query, http.NoBody)
+			for _, v := range tt.accept {
+				r.Header.Add("Accept", v)
+			}
+
+			got, err := export.Negotiate(r)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestTable(t *testing.T) {
+	columns := []export.Column{{Name: "number", Width: 12}, {Name: "sum", Width: 10, Right: true}}
+	title := export.Title("orders", "test", period.Range{To: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)})
+
+	t.Run("must stream csv", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		tbl := export.NewTable(w, export.CSV, "orders", title, columns...)
+		for i := 0; i < 250; i++ {
+			require.NoError(t, tbl.Row(strconv.Itoa(i), tbl.Amount(float64(i)+0.5)))
+		}
+		assert.True(t, w.Flushed, "rows must be flushed before the table is closed")
+		require.NoError(t, tbl.Close())
+
+		assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Equal(t, "attachment; filename=orders.csv", w.Header().Get("Content-Disposition"))
+		records, err := csv.NewReader(w.Body).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 251)
+		assert.Equal(t, []string{"number", "sum"}, records[0])
+		assert.Equal(t, []string{"249", "249.5"}, records[250])
+	})
+
+	t.Run("must write pdf", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		tbl := export.NewTable(w, export.PDF, "orders", title, columns...)
+		require.NoError(t, tbl.Row("12345678903", tbl.Amount(700)))
+		require.NoError(t, tbl.Close())
+
+		assert.Equal(t, "application/pdf", w.Header().Get("Content-Type"))
+		assert.Equal(t, "attachment; filename=orders.pdf", w.Header().Get("Content-Disposition"))
+		body := w.Body.String()
+		assert.True(t, strings.HasPrefix(body, "%PDF-"))
+		assert.Contains(t, body, "/Title (Gophermart: orders)")
+	})
+
+	t.Run("must write headers of an empty table", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		tbl := export.NewTable(w, export.CSV, "orders", title, columns...)
+		require.NoError(t, tbl.Close())
+		assert.Equal(t, "number,sum\n", w.Body.String())
+	})
+
+	t.Run("must set a write deadline", func(t *testing.T) {
+		w := &deadlineRecorder{ResponseRecorder: httptest.NewRecorder()}
+		tbl := export.NewTable(w, export.CSV, "orders", title, columns...)
+		assert.Empty(t, w.deadlines, "nothing is sent before the first row")
+		for i := 0; i < 150; i++ {
+			require.NoError(t, tbl.Row(strconv.Itoa(i), "1"))
+		}
+		require.NoError(t, tbl.Close())
+		// При старте, после сброса сотой строки и после закрытия.
+		require.Len(t, w.deadlines, 3)
+		for _, d := range w.deadlines {
+			assert.WithinDuration(t, time.Now().Add(30*time.Second), d, 5*time.Second)
+		}
+	})
+
+	t.Run("must answer with a problem before the first row", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/export", http.NoBody)
+		tbl := export.NewTable(w, export.CSV, "orders", title, columns...)
+		tbl.Fail(w, r, discardLogger(), errors.New("storage error"))
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Empty(t, w.Header().Get("Content-Disposition"))
+	})
+
+	t.Run("must abort after the first row", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/export", http.NoBody)
+		tbl := export.NewTable(w, export.CSV, "orders", title, columns...)
+		require.NoError(t, tbl.Row("1", "1"))
+		assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+			tbl.Fail(w, r, discardLogger(), errors.New("storage error"))
+		})
+	})
+}
+
+func TestTitle(t *testing.T) {
+	to := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, []string{"Gophermart: orders", "Account: test", "Period: up to 2024-06-01 00:00 UTC"},
+		export.Title("orders", "test", period.Range{To: to}))
+	assert.Equal(t, "Period: 2024-05-01 00:00 UTC - 2024-06-01 00:00 UTC",
+		export.Title("orders", "test", period.Range{From: to.AddDate(0, -1, 0), To: to})[2])
+}
+
+// deadlineRecorder records the write deadlines set through http.ResponseController.
+type deadlineRecorder struct {
+	*httptest.ResponseRecorder
+	deadlines []time.Time
+}
+
+func (d *deadlineRecorder) SetWriteDeadline(t time.Time) error {
+	d.deadlines = append(d.deadlines, t)
+	return nil
+}
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
@@ -0,0 +1,196 @@
+// Package pdf writes simple text-only PDF documents in a monospaced font.
+// Pages are written as soon as they are full, so a long document is never held in memory.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// A4 portrait in points.
+const (
+	pageWidth  = 595
+	pageHeight = 842
+	margin     = 40
+	fontSize   = 8
+	leading    = 11
+
+	// LineWidth is how many characters of the font fit between the margins.
+	LineWidth = (pageWidth - 2*margin) * 10 / (fontSize * 6)
+)
+
+// linesPerPage leaves a line for the page number at the bottom.
+const linesPerPage = (pageHeight-2*margin)/leading - 2
+
+// Fixed objects; pages get their IDs as they are written.
+const (
+	catalogID = iota + 1
+	pagesID
+	fontID
+	infoID
+	firstPageObjectID
+)
+
+var ErrClosed = errors.New("pdf: document is closed")
+
+// Writer writes a document line by line. Header lines are repeated at the top of every page.
+type Writer struct {
+	w       *countingWriter
+	title   string
+	header  []string
+	lines   []string
+	offsets map[int]int64
+	pages   []int
+	nextID  int
+	closed  bool
+	err     error
+}
+
+// New writes the file header and returns a writer of a document with the title.
+func New(w io.Writer, title string) *Writer {
+	p := &Writer{
+		w:       &countingWriter{w: w},
+		title:   title,
+		offsets: make(map[int]int64),
+		nextID:  firstPageObjectID,
+	}
+	// Двоичный комментарий во второй строке подсказывает программам передачи, что файл не текстовый.
+	p.printf("%%PDF-1.4\n%%\xe2\xe3\xcf\xd3\n")
+	return p
+}
+
+// SetHeader sets the lines printed at the top of every following page.
+func (p *Writer) SetHeader(lines ...string) {
+	p.header = lines
+}
+
+// Line adds a line, starting a new page when the current one is full. Lines longer than LineWidth are cut.
+func (p *Writer) Line(s string) error {
+	if p.closed {
+		return ErrClosed
+	}
+	if len(p.lines) == 0 {
+		p.lines = append(p.lines, p.header...)
+	}
+	p.lines = append(p.lines, s)
+	if len(p.lines) >= linesPerPage {
+		p.writePage()
+	}
+	return p.err
+}
+
+// Close writes the last page and the document trailer. It does not close the underlying writer.
+func (p *Writer) Close() error {
+	if p.closed {
+		return ErrClosed
+	}
+	if len(p.lines) > 0 || len(p.pages) == 0 {
+		if len(p.lines) == 0 {
+			p.lines = append(p.lines, p.header...)
+		}
+		p.writePage()
+	}
+	p.closed = true
+
+	p.object(fontID, "<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>")
+	kids := make([]string, len(p.pages))
+	for i, id := range p.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", id)
+	}
+	p.object(pagesID, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(p.pages)))
+	p.object(catalogID, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesID))
+	p.object(infoID, fmt.Sprintf("<< /Title %s /Producer (Gophermart) >>", literal(p.title)))
+
+	xref := p.w.n
+	size := p.nextID
+	p.printf("xref\n0 %d\n0000000000 65535 f \n", size)
+	for id := 1; id < size; id++ {
+		p.printf("%010d 00000 n \n", p.offsets[id])
+	}
+	p.printf("trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n",
+		size, catalogID, infoID, xref)
+	return p.err
+}
+
+func (p *Writer) writePage() {
+	var content bytes.Buffer
+	fmt.Fprintf(&content, "BT\n/F1 %d Tf\n%d TL\n%d %d Td\n", fontSize, leading, margin, pageHeight-margin-fontSize)
+	for _, line := range p.lines {
+		fmt.Fprintf(&content, "%s Tj T*\n", literal(cut(line, LineWidth)))
+	}
+	fmt.Fprintf(&content, "ET\nBT\n/F1 %d Tf\n%d %d Td\n%s Tj\nET\n",
+		fontSize, pageWidth-margin-fontSize*3, margin/2, literal(fmt.Sprint(len(p.pages)+1)))
+	p.lines = p.lines[:0]
+
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	_, _ = zw.Write(content.Bytes())
+	_ = zw.Close()
+
+	contentID, pageID := p.nextID, p.nextID+1
+	p.nextID += 2
+	p.object(contentID, fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream",
+		compressed.Len(), compressed.Bytes()))
+	p.object(pageID, fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %d %d] "+
+		"/Resources << /Font << /F1 %d 0 R >> >> /Contents %d 0 R >>",
+		pagesID, pageWidth, pageHeight, fontID, contentID))
+	p.pages = append(p.pages, pageID)
+}
+
+func (p *Writer) object(id int, body string) {
+	p.offsets[id] = p.w.n
+	p.printf("%d 0 obj\n%s\nendobj\n", id, body)
+}
+
+func (p *Writer) printf(format string, args ...any) {
+	if p.err != nil {
+		return
+	}
+	_, err := fmt.Fprintf(p.w, format, args...)
+	if err != nil {
+		p.err = fmt.Errorf("pdf: failed to write: %w", err)
+	}
+}
+
+// literal encodes s as a PDF string. Characters outside of Latin-1 can't be shown by the standard font
+// and are replaced with "?".
+func literal(s string) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r == 0x7f || r > 0xff:
+			b.WriteByte('?')
+		default:
+			b.WriteByte(byte(r))
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+func cut(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n])
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err //nolint:wrapcheck // wrapped by Writer.printf
+}
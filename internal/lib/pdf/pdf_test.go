@@ -0,0 +1,105 @@
+package pdf_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/VanGoghDev/gophermart/internal/lib/pdf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriter(t *testing.T) {
+	tests := []struct {
+		name      string
+		lines     int
+		wantPages int
+	}{
+		{name: "must write a page for an empty document", lines: 0, wantPages: 1},
+		{name: "must write one page", lines: 10, wantPages: 1},
+		{name: "must split lines into pages", lines: 200, wantPages: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			p := pdf.New(&buf, "Orders (May)")
+			p.SetHeader("Number  Status", "")
+			for i := 0; i < tt.lines; i++ {
+				require.NoError(t, p.Line(fmt.Sprintf("%d  PROCESSED", i)))
+			}
+			require.NoError(t, p.Close())
+			assert.ErrorIs(t, p.Line("late"), pdf.ErrClosed)
+
+			doc := buf.Bytes()
+			assert.True(t, bytes.HasPrefix(doc, []byte("%PDF-1.4\n")))
+			assert.True(t, bytes.HasSuffix(doc, []byte("%%EOF\n")))
+			assert.Contains(t, string(doc), fmt.Sprintf("/Count %d", tt.wantPages))
+			assert.Contains(t, string(doc), `/Title (Orders \(May\))`)
+			checkXref(t, doc)
+
+			text := pageText(t, doc)
+			assert.Len(t, text, tt.wantPages)
+			for _, page := range text {
+				assert.Contains(t, page, "(Number  Status) Tj", "header must be repeated on every page")
+			}
+			if tt.lines > 0 {
+				last := text[len(text)-1]
+				assert.Contains(t, last, fmt.Sprintf("(%d  PROCESSED) Tj", tt.lines-1))
+			}
+		})
+	}
+}
+
+func TestLineEscaping(t *testing.T) {
+	var buf bytes.Buffer
+	p := pdf.New(&buf, "")
+	require.NoError(t, p.Line(`a\b (c) логин `+strings.Repeat("x", pdf.LineWidth)))
+	require.NoError(t, p.Close())
+
+	text := pageText(t, buf.Bytes())
+	require.Len(t, text, 1)
+	want := `a\\b \(c\) ????? ` + strings.Repeat("x", pdf.LineWidth-utf8.RuneCountInString(`a\b (c) логин `))
+	assert.Contains(t, text[0], "("+want+") Tj")
+}
+
+// checkXref verifies that every offset of the cross-reference table points at its object.
+func checkXref(t *testing.T, doc []byte) {
+	t.Helper()
+	m := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(doc)
+	require.NotNil(t, m)
+	start, err := strconv.Atoi(string(m[1]))
+	require.NoError(t, err)
+	require.True(t, bytes.HasPrefix(doc[start:], []byte("xref\n")))
+
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(doc[start:], -1)
+	require.NotEmpty(t, entries)
+	for i, e := range entries {
+		off, err := strconv.Atoi(string(e[1]))
+		require.NoError(t, err)
+		assert.True(t, bytes.HasPrefix(doc[off:], []byte(fmt.Sprintf("%d 0 obj\n", i+1))), "object %d", i+1)
+	}
+}
+
+// pageText returns the decompressed content streams of the pages.
+func pageText(t *testing.T, doc []byte) []string {
+	t.Helper()
+	re := regexp.MustCompile(`(?s)<< /Length (\d+) /Filter /FlateDecode >>\nstream\n`)
+	var pages []string
+	for _, loc := range re.FindAllSubmatchIndex(doc, -1) {
+		n, err := strconv.Atoi(string(doc[loc[2]:loc[3]]))
+		require.NoError(t, err)
+		zr, err := zlib.NewReader(bytes.NewReader(doc[loc[1] : loc[1]+n]))
+		require.NoError(t, err)
+		content, err := io.ReadAll(zr)
+		require.NoError(t, err)
+		pages = append(pages, string(content))
+	}
+	return pages
+}
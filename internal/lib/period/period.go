@@ -0,0 +1,54 @@
+// Package period parses the from and to query parameters that select a date range.
+package period
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Range is the half-open range [From, To). A zero From means "since the beginning".
+type Range struct {
+	From time.Time
+	To   time.Time
+}
+
+// Parse reads the from and to query parameters. Both take a date or an RFC 3339 timestamp;
+// a date in to includes the whole day. Dates are taken in UTC. Without to the range ends at now.
+func Parse(query url.Values, now time.Time) (Range, error) {
+	rng := Range{To: now.UTC()}
+	var err error
+	if v := query.Get("from"); v != "" {
+		rng.From, err = parseTime(v, false)
+		if err != nil {
+			return Range{}, fmt.Errorf("from: %w", err)
+		}
+	}
+	if v := query.Get("to"); v != "" {
+		rng.To, err = parseTime(v, true)
+		if err != nil {
+			return Range{}, fmt.Errorf("to: %w", err)
+		}
+	}
+	if !rng.From.IsZero() && !rng.From.Before(rng.To) {
+		return Range{}, errors.New("from must be before to")
+	}
+	return rng, nil
+}
+
+// parseTime parses a date or an RFC 3339 timestamp. With endOfDay a date means the start of the next day,
+// so that the range includes the whole date.
+func parseTime(v string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, v); err == nil {
+		if endOfDay {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("must be a date (YYYY-MM-DD) or an RFC 3339 timestamp, got %q", v)
+	}
+	return t.UTC(), nil
+}
@@ -0,0 +1,121 @@
+// Package problem renders errors as RFC 7807 application/problem+json documents.
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/middleware"
+)
+
+const ContentType = "application/problem+json"
+
+// TypePrefix is prepended to the code to build the problem type URI.
+const TypePrefix = "/problems/"
+
+// Stable error codes. Clients may rely on them, so existing codes must never change meaning.
+const (
+	CodeInternal           = "internal_error"
+	CodeNotFound           = "not_found"
+	CodeMethodNotAllowed   = "method_not_allowed"
+	CodeInvalidContentType = "invalid_content_type"
+	CodeInvalidBody        = "invalid_body"
+	CodeInvalidParameter   = "invalid_parameter"
+	CodeInvalidEncoding    = "invalid_encoding"
+	CodeBodyTooLarge       = "body_too_large"
+	CodeValidation         = "validation_failed"
+	CodeRateLimited        = "rate_limited"
+	CodeNotAcceptable      = "not_acceptable"
+	CodeOverloaded         = "overloaded"
+
+	CodeUnauthorized       = "unauthorized"
+	CodeInvalidToken       = "invalid_token"
+	CodeInvalidAPIKey      = "invalid_api_key"
+	CodeSessionRevoked     = "session_revoked"
+	CodeInvalidCredentials = "invalid_credentials"
+	CodeForbidden          = "forbidden"
+	CodeInsufficientScope  = "insufficient_scope"
+
+	CodeCredentialPolicy = "credential_policy_violation"
+	CodeLoginTaken       = "login_taken"
+
+	CodeTOTPAlreadyEnabled  = "totp_already_enabled"
+	CodeTOTPNotEnrolled     = "totp_not_enrolled"
+	CodeInvalidTOTPCode     = "invalid_totp_code"
+	CodeInvalidSecondFactor = "invalid_second_factor"
+	CodeInvalidChallenge    = "invalid_challenge_token"
+
+	CodeOIDCDenied      = "oidc_denied"
+	CodeInvalidOIDCFlow = "invalid_oidc_flow"
+	CodeOIDCLoginTaken  = "oidc_login_unavailable"
+
+	CodeUserNotFound    = "user_not_found"
+	CodeAPIKeyNotFound  = "api_key_not_found"
+	CodeSessionNotFound = "session_not_found"
+	CodeOrderNotFound   = "order_not_found"
+
+	CodeInvalidOrderNumber = "invalid_order_number"
+	CodeOrderConflict      = "order_owned_by_another_user"
+	CodeNotEnoughFunds     = "not_enough_funds"
+	CodeOrderForbidden     = "order_of_another_user"
+	CodeTooManyOrders      = "too_many_orders"
+
+	CodeTransferToSelf        = "transfer_to_self"
+	CodeTransferLimitExceeded = "transfer_limit_exceeded"
+	CodeInvalidIdempotencyKey = "invalid_idempotency_key"
+	CodeIdempotencyKeyReused  = "idempotency_key_reused"
+)
+
+// Problem is an RFC 7807 problem details document extended with a stable code and the request ID.
+type Problem struct {
+	Errors    any    `json:"errors,omitempty"`
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      string `json:"code"`
+	RequestID string `json:"request_id,omitempty"`
+	Status    int    `json:"status"`
+}
+
+func New(status int, code string, detail string) Problem {
+	return Problem{
+		Type:   TypePrefix + code,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// Write responds with a problem of the given status and code.
+func Write(w http.ResponseWriter, r *http.Request, status int, code string, detail string) {
+	Render(w, r, New(status, code, detail))
+}
+
+// Internal responds with 500. Details of internal errors are only logged, never sent to clients.
+func Internal(w http.ResponseWriter, r *http.Request) {
+	Write(w, r, http.StatusInternalServerError, CodeInternal, "")
+}
+
+// Render fills request specific fields and writes the problem.
+func Render(w http.ResponseWriter, r *http.Request, p Problem) {
+	p.Instance = r.URL.Path
+	p.RequestID = middleware.GetReqID(r.Context())
+
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	// Статус уже отправлен, поэтому ошибку записи тела обработать нельзя.
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// NotFound is used for unknown routes.
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	Write(w, r, http.StatusNotFound, CodeNotFound, "")
+}
+
+// MethodNotAllowed is used for known routes requested with an unsupported method.
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	Write(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "")
+}
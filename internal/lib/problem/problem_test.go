@@ -0,0 +1,64 @@
+package problem_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/go-chi/chi/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		name   string
+		write  func(w http.ResponseWriter, r *http.Request)
+		status int
+		code   string
+		detail string
+	}{
+		{
+			name: "client error",
+			write: func(w http.ResponseWriter, r *http.Request) {
+				problem.Write(w, r, http.StatusUnprocessableEntity, problem.CodeInvalidOrderNumber, "luhn")
+			},
+			status: http.StatusUnprocessableEntity,
+			code:   problem.CodeInvalidOrderNumber,
+			detail: "luhn",
+		},
+		{
+			name:   "internal error",
+			write:  problem.Internal,
+			status: http.StatusInternalServerError,
+			code:   problem.CodeInternal,
+		},
+		{
+			name:   "not found",
+			write:  problem.NotFound,
+			status: http.StatusNotFound,
+			code:   problem.CodeNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := middleware.RequestID(http.HandlerFunc(tt.write))
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/user/orders", nil))
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"))
+
+			var p problem.Problem
+			assert.Empty(t, json.Unmarshal(w.Body.Bytes(), &p))
+			assert.Equal(t, problem.TypePrefix+tt.code, p.Type)
+			assert.Equal(t, http.StatusText(tt.status), p.Title)
+			assert.Equal(t, tt.status, p.Status)
+			assert.Equal(t, tt.code, p.Code)
+			assert.Equal(t, tt.detail, p.Detail)
+			assert.Equal(t, "/api/user/orders", p.Instance)
+			assert.NotEmpty(t, p.RequestID)
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+type contextKey struct{}
+
+// scope holds the request logger. It is shared by pointer so that attributes added deeper in the
+// middleware chain (for example the login after authentication) are visible to the access log too.
+type scope struct {
+	mu  sync.RWMutex
+	log *slog.Logger
+}
+
+// WithContext stores a request-scoped logger in the context.
+func WithContext(ctx context.Context, log *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, &scope{log: log})
+}
+
+// FromContext returns the request-scoped logger or fallback if the context has none.
+func FromContext(ctx context.Context, fallback *slog.Logger) *slog.Logger {
+	s, ok := ctx.Value(contextKey{}).(*scope)
+	if !ok {
+		return fallback
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.log
+}
+
+// With adds attributes to the request-scoped logger. Without one in the context it does nothing.
+func With(ctx context.Context, args ...any) {
+	s, ok := ctx.Value(contextKey{}).(*scope)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.log = s.log.With(args...)
+}
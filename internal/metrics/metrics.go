@@ -0,0 +1,165 @@
+// Package metrics collects the service metrics and serves them in the Prometheus text format.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "gophermart"
+
+// Outcomes of accrual requests. Other statuses are folded into OutcomeOther so the label stays bounded.
+const (
+	OutcomeOK              = "200"
+	OutcomeNoContent       = "204"
+	OutcomeTooManyRequests = "429"
+	OutcomeServerError     = "5xx"
+	OutcomeOther           = "other"
+	OutcomeError           = "error"
+)
+
+// Metrics holds the collectors and the registry they are exposed from.
+// A nil *Metrics is valid and records nothing, so components work without metrics in tests.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+
+	accrualRequests *prometheus.CounterVec
+	accrualDuration *prometheus.HistogramVec
+
+	busyWorkers prometheus.Gauge
+	queueDepth  atomic.Pointer[func() int]
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "HTTP requests by route, method and status.",
+		}, []string{"route", "method", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "HTTP request latency by route, method and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		accrualRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "accrual",
+			Name:      "requests_total",
+			Help:      "Requests to the accrual system by outcome.",
+		}, []string{"outcome"}),
+		accrualDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "accrual",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of requests to the accrual system by outcome.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"outcome"}),
+		busyWorkers: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "dispatcher",
+			Name:      "busy_workers",
+			Help:      "Dispatcher workers currently processing an order.",
+		}),
+	}
+
+	queueDepth := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "dispatcher",
+		Name:      "queue_depth",
+		Help:      "Orders waiting in the dispatcher queue.",
+	}, func() float64 {
+		fn := m.queueDepth.Load()
+		if fn == nil {
+			return 0
+		}
+		return float64((*fn)())
+	})
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.accrualRequests,
+		m.accrualDuration,
+		m.busyWorkers,
+		queueDepth,
+	)
+	return m
+}
+
+// MustRegister adds collectors, for example the storage ones, to the registry.
+func (m *Metrics) MustRegister(cs ...prometheus.Collector) {
+	m.registry.MustRegister(cs...)
+}
+
+// Handler serves the registry in the Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ObserveHTTPRequest records a served HTTP request.
+func (m *Metrics) ObserveHTTPRequest(route string, method string, status int, d time.Duration) {
+	if m == nil {
+		return
+	}
+	code := strconv.Itoa(status)
+	m.httpRequests.WithLabelValues(route, method, code).Inc()
+	m.httpDuration.WithLabelValues(route, method, code).Observe(d.Seconds())
+}
+
+// ObserveAccrualRequest records a request to the accrual system.
+func (m *Metrics) ObserveAccrualRequest(outcome string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.accrualRequests.WithLabelValues(outcome).Inc()
+	m.accrualDuration.WithLabelValues(outcome).Observe(d.Seconds())
+}
+
+// AccrualOutcome maps a response status of the accrual system to an outcome label.
+func AccrualOutcome(status int) string {
+	switch {
+	case status == http.StatusOK:
+		return OutcomeOK
+	case status == http.StatusNoContent:
+		return OutcomeNoContent
+	case status == http.StatusTooManyRequests:
+		return OutcomeTooManyRequests
+	case status >= http.StatusInternalServerError:
+		return OutcomeServerError
+	default:
+		return OutcomeOther
+	}
+}
+
+// WorkerBusy marks a dispatcher worker as busy; the returned func marks it idle again.
+func (m *Metrics) WorkerBusy() (done func()) {
+	if m == nil {
+		return func() {}
+	}
+	m.busyWorkers.Inc()
+	return m.busyWorkers.Dec
+}
+
+// ObserveQueue sets the function reporting the dispatcher queue depth at scrape time.
+func (m *Metrics) ObserveQueue(depth func() int) {
+	if m == nil {
+		return
+	}
+	m.queueDepth.Store(&depth)
+}
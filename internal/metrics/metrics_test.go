@@ -0,0 +1,98 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type ordersCounter struct {
+	counts map[models.OrderStatus]int64
+	err    error
+}
+
+func (c ordersCounter) CountOrdersByStatus(context.Context) (map[models.OrderStatus]int64, error) {
+	return c.counts, c.err
+}
+
+func scrape(t *testing.T, m *metrics.Metrics) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	return rec.Body.String()
+}
+
+func TestMetrics(t *testing.T) {
+	m := metrics.New()
+	m.ObserveHTTPRequest("/api/user/orders", http.MethodGet, http.StatusOK, 10*time.Millisecond)
+	m.ObserveAccrualRequest(metrics.AccrualOutcome(http.StatusTooManyRequests), time.Millisecond)
+	done := m.WorkerBusy()
+	m.ObserveQueue(func() int { return 3 })
+
+	body := scrape(t, m)
+	assert.Contains(t, body,
+		`gophermart_http_requests_total{method="GET",route="/api/user/orders",status="200"} 1`)
+	assert.Contains(t, body,
+		`gophermart_http_request_duration_seconds_count{method="GET",route="/api/user/orders",status="200"} 1`)
+	assert.Contains(t, body, `gophermart_accrual_requests_total{outcome="429"} 1`)
+	assert.Contains(t, body, `gophermart_accrual_request_duration_seconds_count{outcome="429"} 1`)
+	assert.Contains(t, body, "gophermart_dispatcher_busy_workers 1")
+	assert.Contains(t, body, "gophermart_dispatcher_queue_depth 3")
+
+	done()
+	assert.Contains(t, scrape(t, m), "gophermart_dispatcher_busy_workers 0")
+}
+
+func TestAccrualOutcome(t *testing.T) {
+	tests := []struct {
+		status int
+		want   string
+	}{
+		{http.StatusOK, metrics.OutcomeOK},
+		{http.StatusNoContent, metrics.OutcomeNoContent},
+		{http.StatusTooManyRequests, metrics.OutcomeTooManyRequests},
+		{http.StatusBadGateway, metrics.OutcomeServerError},
+		{http.StatusNotFound, metrics.OutcomeOther},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, metrics.AccrualOutcome(tt.status), tt.status)
+	}
+}
+
+func TestOrdersCollector(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	m := metrics.New()
+	m.MustRegister(metrics.NewOrdersCollector(log, ordersCounter{counts: map[models.OrderStatus]int64{
+		models.Processed: 5,
+		models.New:       2,
+	}}))
+	body := scrape(t, m)
+	assert.Contains(t, body, `gophermart_orders_total{status="PROCESSED"} 5`)
+	assert.Contains(t, body, `gophermart_orders_total{status="NEW"} 2`)
+	// Статусы без заказов экспортируются нулём.
+	assert.Contains(t, body, `gophermart_orders_total{status="INVALID"} 0`)
+
+	m = metrics.New()
+	m.MustRegister(metrics.NewOrdersCollector(log, ordersCounter{err: errors.New("storage error")}))
+	assert.NotContains(t, scrape(t, m), "gophermart_orders_total")
+}
+
+func TestNilMetrics(t *testing.T) {
+	var m *metrics.Metrics
+	m.ObserveHTTPRequest("/", http.MethodGet, http.StatusOK, time.Millisecond)
+	m.ObserveAccrualRequest(metrics.OutcomeOK, time.Millisecond)
+	m.ObserveQueue(func() int { return 1 })
+	m.WorkerBusy()()
+}
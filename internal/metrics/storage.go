@@ -0,0 +1,126 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/lib/logger/sl"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// orderStatuses are always exported, even with zero orders, so that series don't disappear.
+var orderStatuses = []models.OrderStatus{
+	models.New, models.Registered, models.Processing, models.Processed, models.Invalid,
+}
+
+const ordersScrapeTimeout = 5 * time.Second
+
+type OrdersCounter interface {
+	CountOrdersByStatus(ctx context.Context) (map[models.OrderStatus]int64, error)
+}
+
+// OrdersCollector queries the number of orders by status on every scrape.
+type OrdersCollector struct {
+	log     *slog.Logger
+	counter OrdersCounter
+	desc    *prometheus.Desc
+}
+
+func NewOrdersCollector(log *slog.Logger, counter OrdersCounter) *OrdersCollector {
+	return &OrdersCollector{
+		log:     log,
+		counter: counter,
+		desc: prometheus.NewDesc(prometheus.BuildFQName(namespace, "orders", "total"),
+			"Orders by status.", []string{"status"}, nil),
+	}
+}
+
+func (c *OrdersCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *OrdersCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), ordersScrapeTimeout)
+	defer cancel()
+
+	counts, err := c.counter.CountOrdersByStatus(ctx)
+	if err != nil {
+		// Без метрики скрейп не падает целиком, а пропуск виден в логах и как отсутствие серии.
+		c.log.ErrorContext(ctx, "failed to count orders by status", sl.Err(err))
+		return
+	}
+	for _, status := range orderStatuses {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(counts[status]), string(status))
+	}
+}
+
+type PoolStater interface {
+	PoolStat() *pgxpool.Stat
+}
+
+// PoolCollector exports the pgxpool statistics.
+type PoolCollector struct {
+	pool PoolStater
+
+	acquireCount         *prometheus.Desc
+	acquireDuration      *prometheus.Desc
+	acquiredConns        *prometheus.Desc
+	canceledAcquireCount *prometheus.Desc
+	constructingConns    *prometheus.Desc
+	emptyAcquireCount    *prometheus.Desc
+	idleConns            *prometheus.Desc
+	maxConns             *prometheus.Desc
+	totalConns           *prometheus.Desc
+}
+
+func NewPoolCollector(pool PoolStater) *PoolCollector {
+	desc := func(name string, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "pgxpool", name), help, nil, nil)
+	}
+	return &PoolCollector{
+		pool:                 pool,
+		acquireCount:         desc("acquire_count_total", "Successful connection acquires from the pool."),
+		acquireDuration:      desc("acquire_duration_seconds_total", "Total time spent acquiring connections."),
+		acquiredConns:        desc("acquired_conns", "Connections currently acquired from the pool."),
+		canceledAcquireCount: desc("canceled_acquire_count_total", "Acquires canceled by their context."),
+		constructingConns:    desc("constructing_conns", "Connections currently being established."),
+		emptyAcquireCount:    desc("empty_acquire_count_total", "Acquires that had to wait for a connection."),
+		idleConns:            desc("idle_conns", "Idle connections in the pool."),
+		maxConns:             desc("max_conns", "Maximum size of the pool."),
+		totalConns:           desc("total_conns", "Total connections in the pool."),
+	}
+}
+
+func (c *PoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.acquireCount
+	ch <- c.acquireDuration
+	ch <- c.acquiredConns
+	ch <- c.canceledAcquireCount
+	ch <- c.constructingConns
+	ch <- c.emptyAcquireCount
+	ch <- c.idleConns
+	ch <- c.maxConns
+	ch <- c.totalConns
+}
+
+func (c *PoolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.pool.PoolStat()
+	counter := func(d *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.CounterValue, v)
+	}
+	gauge := func(d *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, v)
+	}
+	counter(c.acquireCount, float64(s.AcquireCount()))
+	counter(c.acquireDuration, s.AcquireDuration().Seconds())
+	gauge(c.acquiredConns, float64(s.AcquiredConns()))
+	counter(c.canceledAcquireCount, float64(s.CanceledAcquireCount()))
+	gauge(c.constructingConns, float64(s.ConstructingConns()))
+	counter(c.emptyAcquireCount, float64(s.EmptyAcquireCount()))
+	gauge(c.idleConns, float64(s.IdleConns()))
+	gauge(c.maxConns, float64(s.MaxConns()))
+	gauge(c.totalConns, float64(s.TotalConns()))
+}
@@ -3,65 +3,279 @@ package auth
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"slices"
 
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
 	"github.com/VanGoghDev/gophermart/internal/lib/logger/sl"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/VanGoghDev/gophermart/internal/logger"
 	"github.com/VanGoghDev/gophermart/internal/services/auth"
+	"github.com/VanGoghDev/gophermart/internal/services/auth/apikey"
+	"github.com/VanGoghDev/gophermart/internal/storage"
 	"github.com/go-chi/chi/middleware"
 )
 
+// APIKeyHeader carries a personal API key as an alternative to the Authorization token.
+const APIKeyHeader = "X-API-Key"
+
+// APIKeyProvider returns storage.ErrNotFound for unknown and revoked keys.
+type APIKeyProvider interface {
+	UseAPIKey(ctx context.Context, keyHash string) (models.APIKey, error)
+}
+
+// SessionProvider marks the session as used. It returns storage.ErrNotFound if the session was revoked.
+type SessionProvider interface {
+	TouchSession(ctx context.Context, id int64) (models.Session, error)
+}
+
 type contextKey int
 
 const (
 	KeyUserLogin contextKey = iota
+	KeyUserRole
+	KeyScopes
+	KeySessionID
 )
 
 func GetLogin(r *http.Request) (login string, err error) {
-	userLogin, ok := r.Context().Value(KeyUserLogin).(string)
+	return LoginFromContext(r.Context())
+}
+
+// LoginFromContext is GetLogin for callers that have no *http.Request, such as gRPC handlers.
+func LoginFromContext(ctx context.Context) (login string, err error) {
+	userLogin, ok := ctx.Value(KeyUserLogin).(string)
 	if !ok {
 		return "", errors.New("unable to cast given context value to string")
 	}
 	return userLogin, nil
 }
 
-func New(log *slog.Logger, secret string) func(next http.Handler) http.Handler {
+// GetRole returns the role of the authenticated user.
+func GetRole(r *http.Request) (role models.Role, err error) {
+	userRole, ok := r.Context().Value(KeyUserRole).(models.Role)
+	if !ok {
+		return "", errors.New("unable to cast given context value to role")
+	}
+	return userRole, nil
+}
+
+// GetSessionID returns the session the token is bound to. ok is false for API keys and legacy tokens.
+func GetSessionID(r *http.Request) (id int64, ok bool) {
+	id, ok = r.Context().Value(KeySessionID).(int64)
+	return id, ok
+}
+
+// GetScopes returns scopes of the API key the request was authenticated with.
+// ok is false when the request was authenticated with a token, which grants every scope.
+func GetScopes(r *http.Request) (scopes []models.Scope, ok bool) {
+	scopes, ok = r.Context().Value(KeyScopes).([]models.Scope)
+	return scopes, ok
+}
+
+// RequireScope lets API key requests through only if the key has every given scope.
+// Without scopes the route is not available for API keys at all.
+func RequireScope(log *slog.Logger, required ...models.Scope) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			scopes, isAPIKey := GetScopes(r)
+			if !ScopeAllowed(scopes, isAPIKey, required...) {
+				logger.FromContext(r.Context(), log).InfoContext(r.Context(), "api key scope denied", "required", required)
+				problem.Write(w, r, http.StatusForbidden, problem.CodeInsufficientScope,
+					"api key lacks the scope required for this route")
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// ScopeAllowed reports whether a caller may use a route that requires the scopes.
+// Tokens grant every scope; API keys must have all of them, and routes without scopes are token-only.
+func ScopeAllowed(scopes []models.Scope, isAPIKey bool, required ...models.Scope) bool {
+	if !isAPIKey {
+		return true
+	}
+	if len(required) == 0 {
+		return false
+	}
+	for _, sc := range required {
+		if !slices.Contains(scopes, sc) {
+			return false
+		}
+	}
+	return true
+}
+
+// Principal is who the caller was authenticated as.
+type Principal struct {
+	Login     string
+	Role      models.Role
+	Scopes    []models.Scope
+	SessionID int64
+	IsAPIKey  bool
+}
+
+// Error tells why the credentials were rejected. Code is one of the problem codes.
+type Error struct {
+	Err    error
+	Code   string
+	Detail string
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Detail, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Detail)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Internal reports whether the credentials could not be checked at all, for example because the storage is down.
+// The caller must not be told that its credentials are bad then.
+func (e *Error) Internal() bool {
+	return e.Code == problem.CodeInternal
+}
+
+func invalidToken(err error) *Error {
+	return &Error{Err: err, Code: problem.CodeInvalidToken, Detail: "token is invalid or expired"}
+}
+
+func internalError(err error) *Error {
+	return &Error{Err: err, Code: problem.CodeInternal, Detail: "failed to check credentials"}
+}
+
+// Authenticate checks the token or, if it is empty and keys is not nil, the API key.
+// It does not depend on the transport, so HTTP and gRPC accept exactly the same credentials.
+func Authenticate(
+	ctx context.Context,
+	secret string,
+	keys APIKeyProvider,
+	sessions SessionProvider,
+	token string,
+	key string,
+) (Principal, *Error) {
+	if token != "" {
+		authorized, err := auth.IsAuthorized(token, secret)
+		if err != nil || !authorized {
+			return Principal{}, invalidToken(err)
+		}
+		login, err := auth.ExtractLoginFromToken(token, secret)
+		if err != nil {
+			return Principal{}, invalidToken(fmt.Errorf("failed to get claims from token: %w", err))
+		}
+		if login == "" {
+			return Principal{}, invalidToken(nil)
+		}
+		role, err := auth.ExtractRoleFromToken(token, secret)
+		if err != nil {
+			return Principal{}, invalidToken(fmt.Errorf("failed to get role from token: %w", err))
+		}
+		sessionID, err := auth.ExtractSessionFromToken(token, secret)
+		if err != nil {
+			return Principal{}, invalidToken(fmt.Errorf("failed to get session from token: %w", err))
+		}
+
+		p := Principal{Login: login, Role: role}
+		// Токены, выданные до появления сессий, не привязаны к сессии.
+		if sessionID != 0 && sessions != nil {
+			session, err := sessions.TouchSession(ctx, sessionID)
+			if err != nil && !errors.Is(err, storage.ErrNotFound) {
+				return Principal{}, internalError(fmt.Errorf("failed to check session %d: %w", sessionID, err))
+			}
+			if err != nil {
+				return Principal{}, &Error{
+					Err:    fmt.Errorf("session %d is not active: %w", sessionID, err),
+					Code:   problem.CodeSessionRevoked,
+					Detail: "session was signed out",
+				}
+			}
+			if session.UserLogin != login {
+				return Principal{}, &Error{
+					Err:    fmt.Errorf("session %d belongs to another user", sessionID),
+					Code:   problem.CodeSessionRevoked,
+					Detail: "session was signed out",
+				}
+			}
+			p.SessionID = sessionID
+		}
+		return p, nil
+	}
+
+	if key != "" && keys != nil {
+		apiKey, err := keys.UseAPIKey(ctx, apikey.Hash(key))
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return Principal{}, internalError(fmt.Errorf("failed to check api key %s: %w", apikey.Prefix(key), err))
+		}
+		if err != nil {
+			return Principal{}, &Error{
+				Err:    fmt.Errorf("api key %s rejected: %w", apikey.Prefix(key), err),
+				Code:   problem.CodeInvalidAPIKey,
+				Detail: "api key is invalid or revoked",
+			}
+		}
+		return Principal{
+			Login:    apiKey.UserLogin,
+			Role:     models.RoleUser,
+			Scopes:   apiKey.Scopes,
+			IsAPIKey: true,
+		}, nil
+	}
+
+	return Principal{}, &Error{Code: problem.CodeUnauthorized, Detail: "Authorization header is empty"}
+}
+
+// WithPrincipal stores the principal in the context where GetLogin and the other getters find it.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	ctx = context.WithValue(ctx, KeyUserLogin, p.Login)
+	ctx = context.WithValue(ctx, KeyUserRole, p.Role)
+	if p.SessionID != 0 {
+		ctx = context.WithValue(ctx, KeySessionID, p.SessionID)
+	}
+	if p.IsAPIKey {
+		ctx = context.WithValue(ctx, KeyScopes, p.Scopes)
+	}
+	return ctx
+}
+
+// New authenticates requests by the Authorization token or, if keys is not nil, by the X-API-Key header.
+// If sessions is not nil, tokens bound to a revoked session are rejected.
+func New(
+	log *slog.Logger,
+	secret string,
+	keys APIKeyProvider,
+	sessions SessionProvider,
+) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
-			// взять контекст чтобы потом в него записать инфо о юзере
-			ctx := r.Context()
 			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
 
-			// взять хэдер аутентификации
-			token := r.Header.Get("Authorization")
-			if token != "" {
-				authorized, err := auth.IsAuthorized(token, secret)
-				if err != nil || !authorized {
-					log.ErrorContext(r.Context(), "authorization failed: %w", sl.Err(err))
-					w.WriteHeader(http.StatusUnauthorized)
+			p, authErr := Authenticate(r.Context(), secret, keys, sessions,
+				r.Header.Get("Authorization"), r.Header.Get(APIKeyHeader))
+			if authErr != nil {
+				log := logger.FromContext(r.Context(), log)
+				if authErr.Internal() {
+					log.ErrorContext(r.Context(), "failed to authorize", sl.Err(authErr))
+					problem.Internal(w, r)
 					return
 				}
-				if authorized {
-					// достать claims
-					login, err := auth.ExtractLoginFromToken(token, secret)
-					if err != nil {
-						log.ErrorContext(r.Context(), "failed to get claims from token: %w", sl.Err(err))
-						w.WriteHeader(http.StatusUnauthorized)
-						return
-					}
-					if login == "" {
-						w.WriteHeader(http.StatusUnauthorized)
-						return
-					}
-					ctx = context.WithValue(ctx, KeyUserLogin, login)
-				}
-			} else {
-				http.Error(w, "Authorization header is empty", http.StatusUnauthorized)
+				log.InfoContext(r.Context(), "authorization failed", sl.Err(authErr))
+				problem.Write(w, r, http.StatusUnauthorized, authErr.Code, authErr.Detail)
 				return
 			}
 
+			// Логин попадает во все последующие записи запроса, включая access-лог.
+			logger.With(r.Context(), "login", p.Login)
+
 			// вызываем следующий обработчик
-			next.ServeHTTP(ww, r.WithContext(ctx))
+			next.ServeHTTP(ww, r.WithContext(WithPrincipal(r.Context(), p)))
 		}
 
 		return http.HandlerFunc(fn)
@@ -1,15 +1,21 @@
 package auth_test
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
 	"github.com/VanGoghDev/gophermart/internal/logger"
 	"github.com/VanGoghDev/gophermart/internal/middleware/auth"
 	sauth "github.com/VanGoghDev/gophermart/internal/services/auth"
+	"github.com/VanGoghDev/gophermart/internal/services/auth/apikey"
+	"github.com/VanGoghDev/gophermart/internal/storage"
 	"github.com/go-chi/chi"
 	"github.com/go-resty/resty/v2"
 	"github.com/golang-jwt/jwt/v4"
@@ -101,7 +107,7 @@ func TestNew(t *testing.T) {
 
 	r.Route("/api/user", func(r chi.Router) {
 		r.Group(func(r chi.Router) {
-			r.Use(auth.New(log, serverSecret))
+			r.Use(auth.New(log, serverSecret, nil, nil))
 			r.Get("/orders", func(w http.ResponseWriter, r *http.Request) {
 				w.WriteHeader(http.StatusOK)
 			})
@@ -136,6 +142,209 @@ func TestNew(t *testing.T) {
 				Get(fmt.Sprintf("%s/%s", srv.URL, "api/user/orders"))
 
 			assert.Equal(t, tt.want.statusCode, resp.StatusCode())
+			if resp.StatusCode() == http.StatusUnauthorized {
+				assert.Equal(t, problem.ContentType, resp.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+type fakeKeys map[string]models.APIKey
+
+// brokenKey makes fakeKeys fail as if the storage were down.
+const brokenKey = "gm_broken00_key"
+
+func (f fakeKeys) UseAPIKey(_ context.Context, keyHash string) (models.APIKey, error) {
+	if keyHash == apikey.Hash(brokenKey) {
+		return models.APIKey{}, errors.New("connection refused")
+	}
+	key, ok := f[keyHash]
+	if !ok {
+		return models.APIKey{}, storage.ErrNotFound
+	}
+	return key, nil
+}
+
+func TestNewAPIKey(t *testing.T) {
+	key, prefix, err := apikey.Generate()
+	assert.Empty(t, err)
+
+	keys := fakeKeys{
+		apikey.Hash(key): {
+			UserLogin: "merchant",
+			Prefix:    prefix,
+			Scopes:    []models.Scope{models.ScopeOrdersWrite},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		key        string
+		method     string
+		path       string
+		wantStatus int
+	}{
+		{
+			name:       "key with scope is allowed",
+			key:        key,
+			method:     http.MethodPost,
+			path:       "api/user/orders",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "key without scope is forbidden",
+			key:        key,
+			method:     http.MethodGet,
+			path:       "api/user/balance",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "route without scopes is token only",
+			key:        key,
+			method:     http.MethodGet,
+			path:       "api/user/apikeys",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "unknown key returns 401",
+			key:        "gm_00000000_unknown",
+			method:     http.MethodPost,
+			path:       "api/user/orders",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "storage failure returns 500",
+			key:        brokenKey,
+			method:     http.MethodPost,
+			path:       "api/user/orders",
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	log := logger.New("dev")
+
+	r := chi.NewRouter()
+	r.Route("/api/user", func(r chi.Router) {
+		r.Use(auth.New(log, "secret", keys, nil))
+		ok := func(w http.ResponseWriter, r *http.Request) {
+			login, err := auth.GetLogin(r)
+			assert.Empty(t, err)
+			assert.Equal(t, "merchant", login)
+			w.WriteHeader(http.StatusOK)
+		}
+		r.With(auth.RequireScope(log, models.ScopeOrdersWrite)).Post("/orders", ok)
+		r.With(auth.RequireScope(log, models.ScopeBalanceRead)).Get("/balance", ok)
+		r.With(auth.RequireScope(log)).Get("/apikeys", ok)
+	})
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	client := resty.New()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.R().
+				SetHeader(auth.APIKeyHeader, tt.key).
+				Execute(tt.method, fmt.Sprintf("%s/%s", srv.URL, tt.path))
+
+			assert.Empty(t, err)
+			assert.Equal(t, tt.wantStatus, resp.StatusCode())
+		})
+	}
+}
+
+type fakeSessions map[int64]models.Session
+
+// brokenSession makes fakeSessions fail as if the storage were down.
+const brokenSession = 4
+
+func (f fakeSessions) TouchSession(_ context.Context, id int64) (models.Session, error) {
+	if id == brokenSession {
+		return models.Session{}, errors.New("connection refused")
+	}
+	session, ok := f[id]
+	if !ok {
+		return models.Session{}, storage.ErrNotFound
+	}
+	return session, nil
+}
+
+func TestNewSession(t *testing.T) {
+	secret := "secret"
+	sessions := fakeSessions{
+		1: {ID: 1, UserLogin: "test"},
+		2: {ID: 2, UserLogin: "other"},
+	}
+
+	active, err := sauth.GrantSessionToken("test", models.RoleUser, 1, secret, time.Minute)
+	assert.Empty(t, err)
+	revoked, err := sauth.GrantSessionToken("test", models.RoleUser, 3, secret, time.Minute)
+	assert.Empty(t, err)
+	foreign, err := sauth.GrantSessionToken("test", models.RoleUser, 2, secret, time.Minute)
+	assert.Empty(t, err)
+	legacy, err := sauth.GrantToken("test", secret, time.Minute)
+	assert.Empty(t, err)
+	broken, err := sauth.GrantSessionToken("test", models.RoleUser, brokenSession, secret, time.Minute)
+	assert.Empty(t, err)
+
+	tests := []struct {
+		name        string
+		token       string
+		wantStatus  int
+		wantSession bool
+	}{
+		{
+			name:        "active session is allowed",
+			token:       active,
+			wantStatus:  http.StatusOK,
+			wantSession: true,
+		},
+		{
+			name:       "revoked session returns 401",
+			token:      revoked,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "session of another user returns 401",
+			token:      foreign,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "token without session is allowed",
+			token:      legacy,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "storage failure returns 500",
+			token:      broken,
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	log := logger.New("dev")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := chi.NewRouter()
+			r.With(auth.New(log, secret, nil, sessions)).Get("/", func(w http.ResponseWriter, r *http.Request) {
+				id, ok := auth.GetSessionID(r)
+				assert.Equal(t, tt.wantSession, ok)
+				if ok {
+					assert.Equal(t, int64(1), id)
+				}
+				w.WriteHeader(http.StatusOK)
+			})
+
+			srv := httptest.NewServer(r)
+			defer srv.Close()
+
+			resp, err := resty.New().R().
+				SetHeader("Authorization", tt.token).
+				Get(srv.URL)
+
+			assert.Empty(t, err)
+			assert.Equal(t, tt.wantStatus, resp.StatusCode())
 		})
 	}
 }
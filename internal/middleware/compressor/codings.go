@@ -0,0 +1,173 @@
+package compressor
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Content codings, RFC 9110 section 8.4.1. Note that "deflate" is the zlib format, not raw deflate.
+const (
+	Zstd     = "zstd"
+	Brotli   = "br"
+	Gzip     = "gzip"
+	Deflate  = "deflate"
+	Identity = "identity"
+)
+
+// preference breaks ties between codings the client accepts with the same q-value.
+var preference = []string{Zstd, Brotli, Gzip, Deflate}
+
+// Уровни подобраны для динамических ответов: выигрыш от максимального сжатия не окупает задержку.
+const (
+	gzipLevel   = gzip.DefaultCompression
+	brotliLevel = 5
+)
+
+var ErrUnsupportedEncoding = errors.New("unsupported content encoding")
+
+// encoder is implemented by all the writers of compress/*, brotli and zstd.
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// encoders keeps a pool of writers per coding; creating a writer allocates a lot, especially for zstd and brotli.
+var encoders = map[string]*sync.Pool{
+	Zstd: {New: func() any {
+		// Ошибку NewWriter возвращает только на неверные опции.
+		zw, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1), zstd.WithEncoderLevel(zstd.SpeedDefault))
+		return zw
+	}},
+	Brotli: {New: func() any { return brotli.NewWriterLevel(nil, brotliLevel) }},
+	Gzip: {New: func() any {
+		zw, _ := gzip.NewWriterLevel(nil, gzipLevel)
+		return zw
+	}},
+	Deflate: {New: func() any {
+		zw, _ := zlib.NewWriterLevel(nil, gzipLevel)
+		return zw
+	}},
+}
+
+func getEncoder(coding string, w io.Writer) encoder {
+	enc := encoders[coding].Get().(encoder) //nolint:forcetypeassert // the pools only hold encoders
+	enc.Reset(w)
+	return enc
+}
+
+func putEncoder(coding string, enc encoder) {
+	// Не держим ссылку на ResponseWriter завершённого запроса.
+	enc.Reset(nil)
+	encoders[coding].Put(enc)
+}
+
+// Negotiate picks the coding for a response from the Accept-Encoding header, honouring q-values
+// and "*". It returns "" if the response should not be encoded.
+func Negotiate(acceptEncoding string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+
+	qs := make(map[string]float64)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+		q := 1.0
+		if name, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(name) == "q" {
+			v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil || v < 0 || v > 1 {
+				continue
+			}
+			q = v
+		}
+		if coding == "x-gzip" {
+			coding = Gzip
+		}
+		qs[coding] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, coding := range preference {
+		q, ok := qs[coding]
+		if !ok {
+			q, ok = qs["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = coding, q
+		}
+	}
+	return best
+}
+
+// NewReader decodes r encoded with the coding. Closing the result releases the decoder but not r.
+func NewReader(coding string, r io.Reader) (io.ReadCloser, error) {
+	return newReader(coding, r, 0)
+}
+
+// newReader is NewReader that, if maxSize is positive, also keeps the zstd decoder within maxSize:
+// a frame may ask for a window of gigabytes, which is allocated before a byte is decoded.
+func newReader(coding string, r io.Reader, maxSize int64) (io.ReadCloser, error) {
+	switch strings.ToLower(strings.TrimSpace(coding)) {
+	case Identity:
+		return io.NopCloser(r), nil
+	case Gzip, "x-gzip":
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read gzip header: %w", err)
+		}
+		return zr, nil
+	case Deflate:
+		zr, err := zlib.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read zlib header: %w", err)
+		}
+		return zr, nil
+	case Brotli:
+		return io.NopCloser(brotli.NewReader(r)), nil
+	case Zstd:
+		opts := []zstd.DOption{zstd.WithDecoderConcurrency(1)}
+		if maxSize > 0 {
+			opts = append(opts,
+				zstd.WithDecoderMaxWindow(max(uint64(maxSize), zstd.MinWindowSize)),
+				zstd.WithDecoderMaxMemory(uint64(maxSize)))
+		}
+		zr, err := zstd.NewReader(r, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to init zstd decoder: %w", err)
+		}
+		if maxSize > 0 {
+			return &zstdReader{ReadCloser: zr.IOReadCloser(), maxSize: maxSize}, nil
+		}
+		return zr.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedEncoding, coding)
+	}
+}
+
+// zstdReader reports a frame that needs more memory than maxSize as a body that is too large.
+type zstdReader struct {
+	io.ReadCloser
+	maxSize int64
+}
+
+func (z *zstdReader) Read(p []byte) (int, error) {
+	n, err := z.ReadCloser.Read(p)
+	if errors.Is(err, zstd.ErrWindowSizeExceeded) || errors.Is(err, zstd.ErrDecoderSizeExceeded) {
+		return n, &http.MaxBytesError{Limit: z.maxSize}
+	}
+	return n, err //nolint:wrapcheck // io.EOF must reach the caller as is
+}
@@ -1,131 +1,334 @@
+// Package compressor negotiates response compression and decodes compressed request bodies.
 package compressor
 
 import (
-	"compress/gzip"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"mime"
 	"net/http"
 	"strings"
 
+	"github.com/VanGoghDev/gophermart/internal/lib/decode"
 	"github.com/VanGoghDev/gophermart/internal/lib/logger/sl"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/VanGoghDev/gophermart/internal/logger"
 )
 
-// CompressWriter implements http.ResponseWriter.
-type CompressWriter struct {
-	http.ResponseWriter
-	zw *gzip.Writer
+// DefaultMinSize is the smallest body worth compressing: below it the coding overhead eats the gain.
+const DefaultMinSize = 1024
+
+// DefaultContentTypes lists the compressible media types. A trailing "/*" matches any subtype.
+var DefaultContentTypes = []string{
+	"application/json",
+	"application/problem+json",
+	"application/x-ndjson",
+	"application/xml",
+	"application/javascript",
+	"image/svg+xml",
+	"text/*",
 }
 
-func NewCompressWriter(w http.ResponseWriter) CompressWriter {
-	return CompressWriter{
-		ResponseWriter: w,
-		zw:             gzip.NewWriter(w),
-	}
+type options struct {
+	minSize      int
+	contentTypes []string
+	maxBodySize  int64
 }
 
-func (cw CompressWriter) Write(data []byte) (int, error) {
-	code, err := cw.zw.Write(data)
-	if err != nil {
-		return http.StatusInternalServerError, fmt.Errorf("failed to %w", err)
+type Option func(o *options)
+
+// WithMinSize sets the body size from which responses are compressed.
+func WithMinSize(n int) Option {
+	return func(o *options) {
+		o.minSize = n
 	}
-	return code, nil
 }
 
-// Close закрывает gzip.Writer и досылает все данные из буфера.
-func (cw *CompressWriter) Close() error {
-	err := cw.zw.Close()
-	if err != nil {
-		return fmt.Errorf("failed to close gzip.Writer: %w", err)
+// WithMaxBodySize sets how many bytes a decoded request body may take. Reading past it fails
+// with *http.MaxBytesError, so a small compressed body can't expand into gigabytes.
+func WithMaxBodySize(n int64) Option {
+	return func(o *options) {
+		o.maxBodySize = n
 	}
-	return nil
 }
 
-type CompressReader struct {
-	r  io.ReadCloser
-	zr *gzip.Reader
+// WithContentTypes replaces the list of compressible media types.
+func WithContentTypes(types ...string) Option {
+	return func(o *options) {
+		o.contentTypes = types
+	}
 }
 
-func NewCompressReader(r io.ReadCloser) (*CompressReader, error) {
-	zr, err := gzip.NewReader(r)
+func (o *options) compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
 	if err != nil {
-		return nil, fmt.Errorf("failed to init CompressReader: %w", err)
+		return false
+	}
+	for _, t := range o.contentTypes {
+		if prefix, ok := strings.CutSuffix(t, "/*"); ok {
+			if strings.HasPrefix(mediaType, prefix+"/") {
+				return true
+			}
+			continue
+		}
+		if mediaType == t {
+			return true
+		}
 	}
-	return &CompressReader{
-		r:  r,
-		zr: zr,
-	}, nil
+	return false
 }
 
-// Read implements io.ReadCloser.
-func (c *CompressReader) Read(p []byte) (n int, err error) {
-	code, err := c.zr.Read(p)
-	if err != nil {
-		return http.StatusInternalServerError, fmt.Errorf("failed to read gzip.Reader: %w", err)
+// New compresses responses with the best coding the client accepts and decodes request bodies
+// sent with gzip, deflate, br or zstd Content-Encoding.
+func New(log *slog.Logger, opts ...Option) func(next http.Handler) http.Handler {
+	o := &options{
+		minSize:      DefaultMinSize,
+		maxBodySize:  decode.DefaultMaxBodySize,
+		contentTypes: DefaultContentTypes,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			log := logger.FromContext(ctx, log)
+
+			// Тело запроса декодируем до подмены writer, чтобы ошибка ушла клиенту без сжатия.
+			if err := decodeBody(w, r, o.maxBodySize); err != nil {
+				if errors.Is(err, ErrUnsupportedEncoding) {
+					// RFC 7694: сообщаем клиенту, какие кодировки тела мы принимаем.
+					w.Header().Set("Accept-Encoding", strings.Join(preference, ", "))
+					problem.Write(w, r, http.StatusUnsupportedMediaType, problem.CodeInvalidEncoding, err.Error())
+					return
+				}
+				log.InfoContext(ctx, "failed to decode request body", sl.Err(err))
+				problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidEncoding,
+					"body does not match its Content-Encoding")
+				return
+			}
+
+			// Ответ зависит от Accept-Encoding, даже если именно этот не сжат.
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			coding := Negotiate(r.Header.Get("Accept-Encoding"))
+			if coding == "" || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{ResponseWriter: w, coding: coding, opts: o}
+			defer func() {
+				if err := cw.Close(); err != nil {
+					// Ответ уже отправлен, остаётся только залогировать.
+					log.WarnContext(ctx, "failed to close compress writer", sl.Err(err))
+				}
+			}()
+			next.ServeHTTP(cw, r)
+		}
+
+		return http.HandlerFunc(fn)
 	}
-	return code, nil
 }
 
-func (c *CompressReader) Close() error {
-	err := c.r.Close()
-	if err != nil {
-		return fmt.Errorf("failed to close io.ReadCloser: %w", err)
+// decodeBody replaces the body with its decoded form, cut at maxSize bytes. Codings are listed
+// in the order they were applied, so they are undone from the last one.
+func decodeBody(w http.ResponseWriter, r *http.Request, maxSize int64) error {
+	header := r.Header.Get("Content-Encoding")
+	if header == "" || r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+
+	codings := strings.Split(header, ",")
+	body := &decodedBody{ReadCloser: r.Body}
+	var src io.Reader = r.Body
+	for i := len(codings) - 1; i >= 0; i-- {
+		dec, err := newReader(codings[i], src, maxSize)
+		if err != nil {
+			_ = body.Close()
+			return err
+		}
+		body.decoders = append(body.decoders, dec)
+		src = dec
 	}
+	body.src = src
+
+	r.Body = http.MaxBytesReader(w, body, maxSize)
+	r.Header.Del("Content-Encoding")
+	r.Header.Del("Content-Length")
+	r.ContentLength = -1
 	return nil
 }
 
-func (c *CompressReader) CloseGzRReader() error {
-	err := c.zr.Close()
-	if err != nil {
-		return fmt.Errorf("failed to close gzip.Reader: %w", err)
+// decodedBody reads the decoded body and closes the decoders together with the original body.
+type decodedBody struct {
+	io.ReadCloser
+	src      io.Reader
+	decoders []io.ReadCloser
+}
+
+func (b *decodedBody) Read(p []byte) (int, error) {
+	return b.src.Read(p) //nolint:wrapcheck // io.EOF must reach the caller as is
+}
+
+func (b *decodedBody) Close() error {
+	errs := make([]error, 0, len(b.decoders)+1)
+	for _, d := range b.decoders {
+		errs = append(errs, d.Close())
+	}
+	errs = append(errs, b.ReadCloser.Close())
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("failed to close request body: %w", err)
 	}
 	return nil
 }
 
-func New(log *slog.Logger) func(next http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		fn := func(w http.ResponseWriter, r *http.Request) {
-			ctx := r.Context()
-			ow := w
-
-			// Если клиент поддерживает обработку сжатых ответов, то переопределим responseWriter.
-			if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
-				cw := NewCompressWriter(w)
-				cw.ResponseWriter.Header().Set("Content-Encoding", "gzip")
-
-				ow = cw
-				defer func() {
-					err := cw.Close()
-					if err != nil {
-						w.WriteHeader(http.StatusInternalServerError)
-						return
-					}
-				}()
-			}
+// compressWriter buffers the beginning of the body until it knows whether compression pays off:
+// the body reached minSize and its type is compressible. Until then the status is held back too,
+// because Content-Encoding must be set before the header is sent.
+type compressWriter struct {
+	http.ResponseWriter
+	coding string
+	opts   *options
 
-			// Если данные пришли в сжатом формате, то заменим body после декомпрессии.
-			if strings.Contains(r.Header.Get("Content-Encoding"), "gzip") {
-				log.DebugContext(ctx, "reading compressed body")
+	status      int
+	wroteHeader bool
+	decided     bool
+	enc         encoder
+	buf         []byte
+}
 
-				cr, err := NewCompressReader(r.Body)
-				if err != nil {
-					log.WarnContext(ctx, "Unable to create CompressReader: %v", sl.Err(err))
-					w.WriteHeader(http.StatusInternalServerError)
-					return
-				}
-				r.Body = cr
-				defer func() {
-					err = cr.Close()
-					if err != nil {
-						log.WarnContext(ctx, "failed to close compress reader: %v", sl.Err(err))
-						w.WriteHeader(http.StatusInternalServerError)
-						return
-					}
-				}()
-			}
-			next.ServeHTTP(ow, r)
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.wroteHeader {
+		return
+	}
+	// Информационные ответы уходят сразу и не фиксируют статус.
+	if code >= 100 && code < 200 {
+		cw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	cw.wroteHeader = true
+	cw.status = code
+	if !bodyAllowed(code) {
+		_ = cw.decide(false)
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.decided {
+		return cw.write(p)
+	}
+
+	cw.buf = append(cw.buf, p...)
+	if len(cw.buf) >= cw.opts.minSize {
+		if err := cw.decide(cw.compressible()); err != nil {
+			return 0, err
 		}
+	}
+	return len(p), nil
+}
 
-		return http.HandlerFunc(fn)
+func (cw *compressWriter) write(p []byte) (int, error) {
+	if cw.enc != nil {
+		n, err := cw.enc.Write(p)
+		if err != nil {
+			return n, fmt.Errorf("failed to write %s: %w", cw.coding, err)
+		}
+		return n, nil
+	}
+	return cw.ResponseWriter.Write(p) //nolint:wrapcheck // the plain writer's errors are passed through
+}
+
+// decide sends the header, with Content-Encoding if compress is set, and then the buffered body.
+func (cw *compressWriter) decide(compress bool) error {
+	cw.decided = true
+	h := cw.Header()
+	if compress {
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", cw.coding)
+		// Сжатое представление не совпадает побайтно с исходным, поэтому сильный ETag становится слабым.
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+		cw.enc = getEncoder(cw.coding, cw.ResponseWriter)
+	}
+	if cw.wroteHeader {
+		cw.ResponseWriter.WriteHeader(cw.status)
+	}
+
+	if len(cw.buf) == 0 {
+		return nil
 	}
+	buf := cw.buf
+	cw.buf = nil
+	_, err := cw.write(buf)
+	return err
+}
+
+func (cw *compressWriter) compressible() bool {
+	if !bodyAllowed(cw.status) {
+		return false
+	}
+	h := cw.Header()
+	if h.Get("Content-Encoding") != "" || strings.Contains(h.Get("Cache-Control"), "no-transform") {
+		return false
+	}
+	contentType := h.Get("Content-Type")
+	if contentType == "" {
+		// net/http определил бы тип по уже сжатым байтам, поэтому определяем его сами по исходным.
+		contentType = http.DetectContentType(cw.buf)
+		h.Set("Content-Type", contentType)
+	}
+	return cw.opts.compressible(contentType)
+}
+
+// Flush sends what is buffered. A response flushed before reaching minSize is a stream,
+// so it is compressed regardless of the size.
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		// Flush отправляет заголовок, как и у обычного writer: без статуса это 200.
+		cw.WriteHeader(http.StatusOK)
+		_ = cw.decide(cw.compressible())
+	}
+	if cw.enc != nil {
+		_ = cw.enc.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// Close sends a body that stayed below minSize as is, or finishes the compressed stream.
+func (cw *compressWriter) Close() error {
+	if !cw.decided && cw.wroteHeader {
+		if err := cw.decide(false); err != nil {
+			return err
+		}
+	}
+	if cw.enc == nil {
+		return nil
+	}
+
+	err := cw.enc.Close()
+	putEncoder(cw.coding, cw.enc)
+	cw.enc = nil
+	if err != nil {
+		return fmt.Errorf("failed to close %s writer: %w", cw.coding, err)
+	}
+	return nil
+}
+
+// bodyAllowed reports whether a response with the status may have a body, RFC 9110 section 6.4.1.
+func bodyAllowed(status int) bool {
+	return status != http.StatusNoContent && status != http.StatusNotModified
 }
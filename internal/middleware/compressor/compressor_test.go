@@ -0,0 +1,346 @@
+package compressor_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/middleware/compressor"
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		acceptEncoding string
+		want           string
+	}{
+		{"", ""},
+		{"gzip", compressor.Gzip},
+		{"GZIP", compressor.Gzip},
+		{"x-gzip", compressor.Gzip},
+		{"gzip, deflate, br, zstd", compressor.Zstd},
+		{"gzip;q=0.5, br;q=0.8", compressor.Brotli},
+		{"deflate, gzip;q=0.9", compressor.Deflate},
+		{"*", compressor.Zstd},
+		{"zstd;q=0, *;q=0.1", compressor.Brotli},
+		{"gzip;q=0", ""},
+		{"identity", ""},
+		{"gzip;q=abc", ""},
+		{"gzip;q=2", ""},
+		{"compress, sdch", ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, compressor.Negotiate(tt.acceptEncoding), tt.acceptEncoding)
+	}
+}
+
+func encode(t *testing.T, coding string, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	switch coding {
+	case compressor.Gzip:
+		zw := gzip.NewWriter(&buf)
+		_, err := zw.Write(data)
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+	case compressor.Brotli:
+		zw := brotli.NewWriter(&buf)
+		_, err := zw.Write(data)
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+	case compressor.Zstd:
+		zw, err := zstd.NewWriter(&buf)
+		require.NoError(t, err)
+		_, err = zw.Write(data)
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+	default:
+		t.Fatalf("no test encoder for %s", coding)
+	}
+	return buf.Bytes()
+}
+
+func decode(t *testing.T, coding string, data []byte) string {
+	t.Helper()
+	zr, err := compressor.NewReader(coding, bytes.NewReader(data))
+	require.NoError(t, err)
+	defer func() { _ = zr.Close() }()
+	body, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestResponses(t *testing.T) {
+	large := `{"orders":"` + strings.Repeat("12345678903", 200) + `"}`
+	small := `{"status":"ok"}`
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		status         int
+		contentType    string
+		etag           string
+		body           string
+		wantEncoding   string
+		wantETag       string
+	}{
+		{
+			name:           "must compress with zstd",
+			acceptEncoding: "gzip, deflate, br, zstd",
+			contentType:    "application/json",
+			body:           large,
+			wantEncoding:   compressor.Zstd,
+		},
+		{
+			name:           "must compress with br",
+			acceptEncoding: "br",
+			contentType:    "application/json",
+			body:           large,
+			wantEncoding:   compressor.Brotli,
+		},
+		{
+			name:           "must compress with gzip",
+			acceptEncoding: "gzip",
+			contentType:    "text/csv; charset=utf-8",
+			body:           large,
+			wantEncoding:   compressor.Gzip,
+		},
+		{
+			name:           "must compress with deflate",
+			acceptEncoding: "deflate",
+			contentType:    "application/problem+json",
+			body:           large,
+			wantEncoding:   compressor.Deflate,
+		},
+		{
+			name:           "must weaken etag",
+			acceptEncoding: "gzip",
+			contentType:    "application/json",
+			etag:           `"v1"`,
+			body:           large,
+			wantEncoding:   compressor.Gzip,
+			wantETag:       `W/"v1"`,
+		},
+		{
+			name:           "must not compress small body",
+			acceptEncoding: "gzip",
+			contentType:    "application/json",
+			etag:           `"v1"`,
+			body:           small,
+			wantETag:       `"v1"`,
+		},
+		{
+			name:           "must not compress other types",
+			acceptEncoding: "gzip",
+			contentType:    "application/pdf",
+			body:           large,
+		},
+		{
+			name:        "must not compress without accept encoding",
+			contentType: "application/json",
+			body:        large,
+		},
+		{
+			name:           "must sniff content type",
+			acceptEncoding: "gzip",
+			body:           strings.Repeat("plain text ", 200),
+			wantEncoding:   compressor.Gzip,
+		},
+		{
+			name:           "must not write body on 204",
+			acceptEncoding: "gzip",
+			status:         http.StatusNoContent,
+		},
+		{
+			name:           "must not write body on 304",
+			acceptEncoding: "gzip",
+			status:         http.StatusNotModified,
+			etag:           `"v1"`,
+			wantETag:       `"v1"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := compressor.New(logger.New("dev"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.contentType != "" {
+					w.Header().Set("Content-Type", tt.contentType)
+				}
+				if tt.etag != "" {
+					w.Header().Set("ETag", tt.etag)
+				}
+				if tt.status != 0 {
+					w.WriteHeader(tt.status)
+				}
+				// Пишем частями, чтобы проверить буферизацию до порога.
+				for _, chunk := range chunks(tt.body, 100) {
+					_, _ = w.Write([]byte(chunk))
+				}
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			wantStatus := tt.status
+			if wantStatus == 0 {
+				wantStatus = http.StatusOK
+			}
+			assert.Equal(t, wantStatus, rec.Code)
+			assert.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"))
+			assert.Equal(t, tt.wantEncoding, rec.Header().Get("Content-Encoding"))
+			assert.Equal(t, tt.wantETag, rec.Header().Get("ETag"))
+			if tt.contentType == "" && tt.body != "" {
+				assert.Equal(t, "text/plain; charset=utf-8", rec.Header().Get("Content-Type"))
+			}
+
+			if tt.wantEncoding == "" {
+				assert.Equal(t, tt.body, rec.Body.String())
+				return
+			}
+			assert.Less(t, rec.Body.Len(), len(tt.body))
+			assert.Equal(t, tt.body, decode(t, tt.wantEncoding, rec.Body.Bytes()))
+		})
+	}
+}
+
+// chunks splits s into parts of at most n bytes.
+func chunks(s string, n int) []string {
+	parts := make([]string, 0, len(s)/n+1)
+	for len(s) > 0 {
+		end := min(n, len(s))
+		parts = append(parts, s[:end])
+		s = s[end:]
+	}
+	return parts
+}
+
+func TestFlush(t *testing.T) {
+	h := compressor.New(logger.New("dev"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte("first"))
+		// Поток сжимается несмотря на малый размер, и уже отправленное можно прочитать до конца ответа.
+		w.(http.Flusher).Flush()
+		_, _ = w.Write([]byte(" second"))
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	assert.True(t, rec.Flushed)
+	assert.Equal(t, compressor.Gzip, rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, "first second", decode(t, compressor.Gzip, rec.Body.Bytes()))
+}
+
+func TestRequests(t *testing.T) {
+	const body = "12345678903"
+	// Несколько килобайт сжатых нулей разворачиваются в 2 МиБ, вдвое больше допустимого по умолчанию.
+	bomb := make([]byte, 2<<20)
+
+	tests := []struct {
+		name            string
+		contentEncoding string
+		body            []byte
+		wantStatus      int
+		wantBody        string
+	}{
+		{
+			name:            "must decode gzip",
+			contentEncoding: "gzip",
+			body:            encode(t, compressor.Gzip, []byte(body)),
+			wantStatus:      http.StatusOK,
+			wantBody:        body,
+		},
+		{
+			name:            "must decode br",
+			contentEncoding: "br",
+			body:            encode(t, compressor.Brotli, []byte(body)),
+			wantStatus:      http.StatusOK,
+			wantBody:        body,
+		},
+		{
+			name:            "must decode zstd",
+			contentEncoding: "zstd",
+			body:            encode(t, compressor.Zstd, []byte(body)),
+			wantStatus:      http.StatusOK,
+			wantBody:        body,
+		},
+		{
+			name:            "must decode several codings",
+			contentEncoding: "br, gzip",
+			body:            encode(t, compressor.Gzip, encode(t, compressor.Brotli, []byte(body))),
+			wantStatus:      http.StatusOK,
+			wantBody:        body,
+		},
+		{
+			name:            "must pass identity",
+			contentEncoding: "identity",
+			body:            []byte(body),
+			wantStatus:      http.StatusOK,
+			wantBody:        body,
+		},
+		{
+			name:            "must return 400 status",
+			contentEncoding: "gzip",
+			body:            []byte(body),
+			wantStatus:      http.StatusBadRequest,
+		},
+		{
+			name:            "must return 413 status (gzip bomb)",
+			contentEncoding: "gzip",
+			body:            encode(t, compressor.Gzip, bomb),
+			wantStatus:      http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:            "must return 413 status (zstd bomb)",
+			contentEncoding: "zstd",
+			body:            encode(t, compressor.Zstd, bomb),
+			wantStatus:      http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:            "must return 415 status",
+			contentEncoding: "compress",
+			body:            []byte(body),
+			wantStatus:      http.StatusUnsupportedMediaType,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := compressor.New(logger.New("dev"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Empty(t, r.Header.Get("Content-Encoding"))
+				data, err := io.ReadAll(r.Body)
+				var maxErr *http.MaxBytesError
+				if errors.As(err, &maxErr) {
+					w.WriteHeader(http.StatusRequestEntityTooLarge)
+					return
+				}
+				require.NoError(t, err)
+				_, _ = w.Write(data)
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(tt.body))
+			req.Header.Set("Content-Encoding", tt.contentEncoding)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantStatus == http.StatusOK {
+				assert.Equal(t, tt.wantBody, rec.Body.String())
+			}
+			if tt.wantStatus == http.StatusUnsupportedMediaType {
+				assert.Equal(t, "zstd, br, gzip, deflate", rec.Header().Get("Accept-Encoding"))
+			}
+		})
+	}
+}
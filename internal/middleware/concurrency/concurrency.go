@@ -0,0 +1,43 @@
+// Package concurrency caps how many requests of a route group are served at once.
+package concurrency
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+)
+
+// RetryAfter is what a rejected client is told to wait before retrying.
+const RetryAfter = 5 * time.Second
+
+// New serves up to n requests at once and answers the rest with 503 right away instead of queueing them:
+// a queued request would hold its connection and goroutine just as long. A non-positive n disables the cap.
+func New(log *slog.Logger, group string, n int) func(next http.Handler) http.Handler {
+	sem := make(chan struct{}, max(n, 0))
+	return func(next http.Handler) http.Handler {
+		if n <= 0 {
+			return next
+		}
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case sem <- struct{}{}:
+			default:
+				logger.FromContext(r.Context(), log).WarnContext(r.Context(), "concurrency limit reached",
+					"group", group, "limit", n)
+				w.Header().Set("Retry-After", strconv.Itoa(int(RetryAfter.Seconds())))
+				problem.Write(w, r, http.StatusServiceUnavailable, problem.CodeOverloaded,
+					"too many "+group+" are running, retry later")
+				return
+			}
+			defer func() { <-sem }()
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
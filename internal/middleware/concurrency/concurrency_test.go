@@ -0,0 +1,48 @@
+package concurrency_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/middleware/concurrency"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	h := concurrency.New(logger.New("dev"), "exports", 1)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("block") {
+			close(started)
+			<-release
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	serve := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, http.NoBody))
+		return rec
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		assert.Equal(t, http.StatusOK, serve("/?block").Code)
+	}()
+	<-started
+
+	rec := serve("/")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "5", rec.Header().Get("Retry-After"))
+	assert.Equal(t, problem.ContentType, rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), problem.CodeOverloaded)
+
+	close(release)
+	wg.Wait()
+	assert.Equal(t, http.StatusOK, serve("/").Code, "the slot must be freed when the request ends")
+}
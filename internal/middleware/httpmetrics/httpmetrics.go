@@ -0,0 +1,38 @@
+// Package httpmetrics counts HTTP requests and their latency per route and status.
+package httpmetrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/metrics"
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+)
+
+// unmatchedRoute labels requests that matched no route, so scanners can't blow up the label cardinality.
+const unmatchedRoute = "unmatched"
+
+func New(m *metrics.Metrics) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+			next.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			route := unmatchedRoute
+			// Шаблон маршрута известен только после того, как chi закончил маршрутизацию.
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				route = rctx.RoutePattern()
+			}
+			m.ObserveHTTPRequest(route, r.Method, status, time.Since(start))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,32 @@
+package httpmetrics_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/VanGoghDev/gophermart/internal/metrics"
+	"github.com/VanGoghDev/gophermart/internal/middleware/httpmetrics"
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	m := metrics.New()
+	r := chi.NewRouter()
+	r.Use(httpmetrics.New(m))
+	r.Get("/orders/{number}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	for _, path := range []string{"/orders/1", "/orders/2", "/missing"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	// Запросы к одному маршруту сводятся в одну серию по шаблону, а не по пути.
+	assert.Contains(t, body, `gophermart_http_requests_total{method="GET",route="/orders/{number}",status="202"} 2`)
+	assert.Contains(t, body, `gophermart_http_requests_total{method="GET",route="unmatched",status="404"} 1`)
+}
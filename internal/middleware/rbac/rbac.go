@@ -0,0 +1,38 @@
+package rbac
+
+import (
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/middleware/auth"
+)
+
+// New allows the request only if the authenticated user has one of the given roles.
+// Must be used after the auth middleware.
+func New(log *slog.Logger, roles ...models.Role) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			role, err := auth.GetRole(r)
+			if err != nil {
+				logger.FromContext(r.Context(), log).ErrorContext(r.Context(), "failed to fetch user role from context")
+				problem.Write(w, r, http.StatusUnauthorized, problem.CodeUnauthorized, "")
+				return
+			}
+
+			if !slices.Contains(roles, role) {
+				login, _ := auth.GetLogin(r)
+				logger.FromContext(r.Context(), log).WarnContext(r.Context(), "access denied", "login", login, "role", role)
+				problem.Write(w, r, http.StatusForbidden, problem.CodeForbidden, "role is not allowed")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
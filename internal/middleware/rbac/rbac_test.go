@@ -0,0 +1,80 @@
+package rbac_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/middleware/auth"
+	"github.com/VanGoghDev/gophermart/internal/middleware/rbac"
+	sauth "github.com/VanGoghDev/gophermart/internal/services/auth"
+	"github.com/go-chi/chi"
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name       string
+		role       models.Role
+		legacy     bool
+		statusCode int
+	}{
+		{
+			name:       "admin is allowed",
+			role:       models.RoleAdmin,
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "user is forbidden",
+			role:       models.RoleUser,
+			statusCode: http.StatusForbidden,
+		},
+		{
+			name:       "token without role is forbidden",
+			legacy:     true,
+			statusCode: http.StatusForbidden,
+		},
+	}
+
+	log := logger.New("dev")
+	secret := "secret"
+
+	r := chi.NewRouter()
+	r.Route("/api/admin", func(r chi.Router) {
+		r.Use(auth.New(log, secret, nil, nil))
+		r.Use(rbac.New(log, models.RoleAdmin))
+		r.Get("/users", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+	})
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	client := resty.New()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var token string
+			var err error
+			if tt.legacy {
+				token, err = sauth.GenerateToken("test", secret, time.Second*5)
+			} else {
+				token, err = sauth.GenerateRoleToken("test", tt.role, secret, time.Second*5)
+			}
+			assert.Empty(t, err)
+
+			resp, err := client.R().
+				SetHeader("Authorization", token).
+				Get(fmt.Sprintf("%s/%s", srv.URL, "api/admin/users"))
+
+			assert.Empty(t, err)
+			assert.Equal(t, tt.statusCode, resp.StatusCode())
+		})
+	}
+}
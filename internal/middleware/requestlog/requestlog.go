@@ -0,0 +1,115 @@
+// Package requestlog assigns request IDs, puts a request-scoped logger in the context and writes access logs.
+package requestlog
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+)
+
+// HeaderRequestID carries the request ID in both directions.
+const HeaderRequestID = "X-Request-ID"
+
+const maxRequestIDLength = 128
+
+// New assigns every request an ID, taking it from X-Request-ID if the client sent a sane one,
+// and echoes it in the response. Handlers get a logger enriched with the request ID and route
+// through logger.FromContext. After the request one access-log line is written.
+func New(log *slog.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			id := r.Header.Get(HeaderRequestID)
+			if !validID(id) {
+				id = newID()
+			}
+			w.Header().Set(HeaderRequestID, id)
+
+			// problem.Write берёт идентификатор через middleware.GetReqID, поэтому кладём его по ключу chi.
+			ctx := context.WithValue(r.Context(), middleware.RequestIDKey, id)
+			ctx = logger.WithContext(ctx, slog.New(routeHandler{log.Handler()}).With("request_id", id))
+			r = r.WithContext(ctx)
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			defer func() {
+				status := ww.Status()
+				if status == 0 {
+					status = http.StatusOK
+				}
+				compression := ww.Header().Get("Content-Encoding")
+				if compression == "" {
+					compression = "identity"
+				}
+				level := slog.LevelInfo
+				if status >= http.StatusInternalServerError {
+					level = slog.LevelError
+				}
+				logger.FromContext(ctx, log).Log(ctx, level, "request completed",
+					slog.String("method", r.Method),
+					slog.String("path", r.URL.Path),
+					slog.Int("status", status),
+					slog.Int("bytes", ww.BytesWritten()),
+					slog.Duration("latency", time.Since(start)),
+					slog.String("compression", compression),
+					slog.String("remote_addr", r.RemoteAddr),
+					slog.String("user_agent", r.UserAgent()),
+				)
+			}()
+
+			next.ServeHTTP(ww, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// validID accepts only short printable ASCII IDs so that clients can't inject junk into the logs.
+func validID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	// crypto/rand.Read не возвращает ошибок на поддерживаемых платформах.
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// routeHandler adds the chi route pattern to records. The pattern is only known once routing is done,
+// so it is read from the record context at logging time rather than when the logger is created.
+type routeHandler struct {
+	slog.Handler
+}
+
+func (h routeHandler) Handle(ctx context.Context, rec slog.Record) error {
+	if rctx := chi.RouteContext(ctx); rctx != nil {
+		if pattern := rctx.RoutePattern(); pattern != "" {
+			rec.AddAttrs(slog.String("route", pattern))
+		}
+	}
+	return h.Handler.Handle(ctx, rec) //nolint:wrapcheck // handler errors are passed through
+}
+
+func (h routeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return routeHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h routeHandler) WithGroup(name string) slog.Handler {
+	return routeHandler{h.Handler.WithGroup(name)}
+}
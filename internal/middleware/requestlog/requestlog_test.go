@@ -0,0 +1,105 @@
+package requestlog_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/middleware/requestlog"
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name      string
+		requestID string
+		wantSame  bool
+	}{
+		{
+			name:      "must propagate request id",
+			requestID: "abc-123",
+			wantSame:  true,
+		},
+		{
+			name:      "must generate request id",
+			requestID: "",
+		},
+		{
+			name:      "must replace invalid request id",
+			requestID: "bad id\n",
+		},
+		{
+			name:      "must replace too long request id",
+			requestID: strings.Repeat("a", 129),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			log := slog.New(slog.NewJSONHandler(&buf, nil))
+
+			var ctxID string
+			r := chi.NewRouter()
+			r.Use(requestlog.New(log))
+			r.Get("/orders/{number}", func(w http.ResponseWriter, r *http.Request) {
+				ctxID = middleware.GetReqID(r.Context())
+				logger.With(r.Context(), "login", "test")
+				logger.FromContext(r.Context(), log).InfoContext(r.Context(), "handled")
+				w.WriteHeader(http.StatusCreated)
+				_, _ = w.Write([]byte("hello"))
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/orders/42", nil)
+			req.Header.Set(requestlog.HeaderRequestID, tt.requestID)
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			id := rec.Header().Get(requestlog.HeaderRequestID)
+			assert.NotEmpty(t, id)
+			assert.Equal(t, id, ctxID)
+			if tt.wantSame {
+				assert.Equal(t, tt.requestID, id)
+			} else {
+				assert.NotEqual(t, tt.requestID, id)
+			}
+
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			require.Len(t, lines, 2)
+
+			var handled, access map[string]any
+			require.NoError(t, json.Unmarshal([]byte(lines[0]), &handled))
+			require.NoError(t, json.Unmarshal([]byte(lines[1]), &access))
+
+			assert.Equal(t, "handled", handled["msg"])
+			assert.Equal(t, id, handled["request_id"])
+			assert.Equal(t, "/orders/{number}", handled["route"])
+
+			assert.Equal(t, "request completed", access["msg"])
+			assert.Equal(t, id, access["request_id"])
+			assert.Equal(t, "test", access["login"])
+			assert.Equal(t, "/orders/{number}", access["route"])
+			assert.Equal(t, "/orders/42", access["path"])
+			assert.EqualValues(t, http.StatusCreated, access["status"])
+			assert.EqualValues(t, 5, access["bytes"])
+			assert.Equal(t, "identity", access["compression"])
+			assert.Contains(t, access, "latency")
+		})
+	}
+}
+
+func TestFromContextFallback(t *testing.T) {
+	log := slog.New(slog.NewJSONHandler(&bytes.Buffer{}, nil))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	// Без middleware логгер берётся из аргумента, а With ничего не ломает.
+	logger.With(req.Context(), "login", "test")
+	assert.Same(t, log, logger.FromContext(req.Context(), log))
+}
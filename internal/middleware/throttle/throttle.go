@@ -0,0 +1,90 @@
+// Package throttle limits request rates with token buckets and reports the state in RateLimit-* headers.
+package throttle
+
+import (
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/lib/logger/sl"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/middleware/auth"
+	"github.com/VanGoghDev/gophermart/internal/services/ratelimit"
+)
+
+// KeyFunc returns the bucket the request takes a token from.
+type KeyFunc func(r *http.Request) string
+
+// ByIP keys requests by the client address.
+func ByIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return "ip:" + r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// ByLogin keys requests by the authenticated user and falls back to the client address.
+// Must be used after the auth middleware.
+func ByLogin(r *http.Request) string {
+	login, err := auth.GetLogin(r)
+	if err != nil {
+		return ByIP(r)
+	}
+	return "user:" + login
+}
+
+// New lets a request through only if its bucket in the named group has a token.
+// Store failures are logged and let the request through: an outage of the limiter must not take the API down.
+func New(
+	log *slog.Logger,
+	store ratelimit.Store,
+	group string,
+	limit ratelimit.Limit,
+	key KeyFunc,
+) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limit.Disabled() {
+			return next
+		}
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			res, err := store.Take(r.Context(), group+":"+key(r), limit)
+			if err != nil {
+				logger.FromContext(r.Context(), log).ErrorContext(r.Context(), "failed to check rate limit", "group", group, sl.Err(err))
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			h.Set("RateLimit-Reset", ceilSeconds(res.Reset))
+			h.Set("RateLimit-Policy", policy(limit))
+
+			if !res.Allowed {
+				logger.FromContext(r.Context(), log).InfoContext(r.Context(), "rate limit exceeded", "group", group, "key", key(r))
+				h.Set("Retry-After", ceilSeconds(res.RetryAfter))
+				problem.Write(w, r, http.StatusTooManyRequests, problem.CodeRateLimited,
+					"too many requests, retry later")
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// policy describes the limit as in the IETF RateLimit header fields draft: "60;w=60;burst=60".
+func policy(l ratelimit.Limit) string {
+	return strconv.Itoa(l.Requests) + ";w=" + ceilSeconds(l.Period) + ";burst=" + strconv.Itoa(l.Capacity())
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,123 @@
+package throttle_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/middleware/auth"
+	"github.com/VanGoghDev/gophermart/internal/middleware/throttle"
+	"github.com/VanGoghDev/gophermart/internal/services/ratelimit"
+	"github.com/stretchr/testify/assert"
+)
+
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, ratelimit.Limit) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("store is down")
+}
+
+func TestNew(t *testing.T) {
+	limit := ratelimit.Limit{Requests: 2, Period: time.Minute}
+
+	tests := []struct {
+		name        string
+		store       ratelimit.Store
+		limit       ratelimit.Limit
+		requests    int
+		login       string
+		wantStatus  int
+		wantHeaders map[string]string
+	}{
+		{
+			name:       "within the limit",
+			store:      ratelimit.NewMemory(),
+			limit:      limit,
+			requests:   2,
+			wantStatus: http.StatusOK,
+			wantHeaders: map[string]string{
+				"RateLimit-Limit":     "2",
+				"RateLimit-Remaining": "0",
+				"RateLimit-Reset":     "60",
+				"RateLimit-Policy":    "2;w=60;burst=2",
+			},
+		},
+		{
+			name:       "over the limit",
+			store:      ratelimit.NewMemory(),
+			limit:      limit,
+			requests:   3,
+			wantStatus: http.StatusTooManyRequests,
+			wantHeaders: map[string]string{
+				"Retry-After":         "30",
+				"RateLimit-Remaining": "0",
+				"Content-Type":        problem.ContentType,
+			},
+		},
+		{
+			name:       "users do not share the bucket with the address",
+			store:      ratelimit.NewMemory(),
+			limit:      limit,
+			requests:   3,
+			login:      "test",
+			wantStatus: http.StatusTooManyRequests,
+		},
+		{
+			name:       "disabled limit",
+			store:      ratelimit.NewMemory(),
+			requests:   10,
+			wantStatus: http.StatusOK,
+			wantHeaders: map[string]string{
+				"RateLimit-Limit": "",
+			},
+		},
+		{
+			name:       "store failure lets requests through",
+			store:      failingStore{},
+			limit:      limit,
+			requests:   3,
+			wantStatus: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := logger.New("dev")
+			h := throttle.New(log, tt.store, "test", tt.limit, throttle.ByLogin)(
+				http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+					w.WriteHeader(http.StatusOK)
+				}))
+
+			// Запросы другого пользователя с того же адреса не расходуют бакет.
+			other := httptest.NewRequest(http.MethodGet, "/", nil)
+			other = other.WithContext(auth.WithPrincipal(other.Context(), auth.Principal{Login: "other"}))
+			h.ServeHTTP(httptest.NewRecorder(), other)
+
+			var w *httptest.ResponseRecorder
+			for i := 0; i < tt.requests; i++ {
+				r := httptest.NewRequest(http.MethodGet, "/", nil)
+				if tt.login != "" {
+					r = r.WithContext(auth.WithPrincipal(r.Context(), auth.Principal{Login: tt.login}))
+				}
+				w = httptest.NewRecorder()
+				h.ServeHTTP(w, r)
+			}
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			for k, v := range tt.wantHeaders {
+				assert.Equal(t, v, w.Header().Get(k), k)
+			}
+		})
+	}
+}
+
+func TestByIP(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	assert.Equal(t, "ip:192.0.2.1", throttle.ByIP(r))
+	assert.Equal(t, "ip:192.0.2.1", throttle.ByLogin(r))
+}
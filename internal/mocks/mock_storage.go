@@ -7,6 +7,7 @@ package mocks
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	models "github.com/VanGoghDev/gophermart/internal/domain/models"
 	gomock "github.com/golang/mock/gomock"
@@ -35,6 +36,121 @@ func (m *MockStorage) EXPECT() *MockStorageMockRecorder {
 	return m.recorder
 }
 
+// AdjustBalance mocks base method.
+func (m *MockStorage) AdjustBalance(arg0 context.Context, arg1 models.BalanceAdjustment) (models.BalanceAdjustment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdjustBalance", arg0, arg1)
+	ret0, _ := ret[0].(models.BalanceAdjustment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AdjustBalance indicates an expected call of AdjustBalance.
+func (mr *MockStorageMockRecorder) AdjustBalance(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdjustBalance", reflect.TypeOf((*MockStorage)(nil).AdjustBalance), arg0, arg1)
+}
+
+// CompleteChallenge mocks base method.
+func (m *MockStorage) CompleteChallenge(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompleteChallenge", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CompleteChallenge indicates an expected call of CompleteChallenge.
+func (mr *MockStorageMockRecorder) CompleteChallenge(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompleteChallenge", reflect.TypeOf((*MockStorage)(nil).CompleteChallenge), arg0, arg1)
+}
+
+// ConfirmTOTP mocks base method.
+func (m *MockStorage) ConfirmTOTP(arg0 context.Context, arg1 string, arg2 int64, arg3 []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConfirmTOTP", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ConfirmTOTP indicates an expected call of ConfirmTOTP.
+func (mr *MockStorageMockRecorder) ConfirmTOTP(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfirmTOTP", reflect.TypeOf((*MockStorage)(nil).ConfirmTOTP), arg0, arg1, arg2, arg3)
+}
+
+// CreateSession mocks base method.
+func (m *MockStorage) CreateSession(arg0 context.Context, arg1 models.Session) (models.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSession", arg0, arg1)
+	ret0, _ := ret[0].(models.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateSession indicates an expected call of CreateSession.
+func (mr *MockStorageMockRecorder) CreateSession(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSession", reflect.TypeOf((*MockStorage)(nil).CreateSession), arg0, arg1)
+}
+
+// EachOrder mocks base method.
+func (m *MockStorage) EachOrder(arg0 context.Context, arg1 string, arg2, arg3 time.Time, arg4 func(models.Order) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EachOrder", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// EachOrder indicates an expected call of EachOrder.
+func (mr *MockStorageMockRecorder) EachOrder(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EachOrder", reflect.TypeOf((*MockStorage)(nil).EachOrder), arg0, arg1, arg2, arg3, arg4)
+}
+
+// EachStatementEntry mocks base method.
+func (m *MockStorage) EachStatementEntry(arg0 context.Context, arg1 string, arg2, arg3 time.Time, arg4 func(models.StatementEntry) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EachStatementEntry", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// EachStatementEntry indicates an expected call of EachStatementEntry.
+func (mr *MockStorageMockRecorder) EachStatementEntry(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EachStatementEntry", reflect.TypeOf((*MockStorage)(nil).EachStatementEntry), arg0, arg1, arg2, arg3, arg4)
+}
+
+// EachWithdrawal mocks base method.
+func (m *MockStorage) EachWithdrawal(arg0 context.Context, arg1 string, arg2, arg3 time.Time, arg4 func(models.Withdrawal) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EachWithdrawal", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// EachWithdrawal indicates an expected call of EachWithdrawal.
+func (mr *MockStorageMockRecorder) EachWithdrawal(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EachWithdrawal", reflect.TypeOf((*MockStorage)(nil).EachWithdrawal), arg0, arg1, arg2, arg3, arg4)
+}
+
+// GetAPIKeys mocks base method.
+func (m *MockStorage) GetAPIKeys(arg0 context.Context, arg1 string) ([]models.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAPIKeys", arg0, arg1)
+	ret0, _ := ret[0].([]models.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAPIKeys indicates an expected call of GetAPIKeys.
+func (mr *MockStorageMockRecorder) GetAPIKeys(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAPIKeys", reflect.TypeOf((*MockStorage)(nil).GetAPIKeys), arg0, arg1)
+}
+
 // GetBalance mocks base method.
 func (m *MockStorage) GetBalance(arg0 context.Context, arg1 string) (models.Balance, error) {
 	m.ctrl.T.Helper()
@@ -50,6 +166,51 @@ func (mr *MockStorageMockRecorder) GetBalance(arg0, arg1 interface{}) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBalance", reflect.TypeOf((*MockStorage)(nil).GetBalance), arg0, arg1)
 }
 
+// GetBalanceAdjustments mocks base method.
+func (m *MockStorage) GetBalanceAdjustments(arg0 context.Context, arg1 string) ([]models.BalanceAdjustment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBalanceAdjustments", arg0, arg1)
+	ret0, _ := ret[0].([]models.BalanceAdjustment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBalanceAdjustments indicates an expected call of GetBalanceAdjustments.
+func (mr *MockStorageMockRecorder) GetBalanceAdjustments(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBalanceAdjustments", reflect.TypeOf((*MockStorage)(nil).GetBalanceAdjustments), arg0, arg1)
+}
+
+// GetBalanceVersion mocks base method.
+func (m *MockStorage) GetBalanceVersion(arg0 context.Context, arg1 string) (models.Version, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBalanceVersion", arg0, arg1)
+	ret0, _ := ret[0].(models.Version)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBalanceVersion indicates an expected call of GetBalanceVersion.
+func (mr *MockStorageMockRecorder) GetBalanceVersion(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBalanceVersion", reflect.TypeOf((*MockStorage)(nil).GetBalanceVersion), arg0, arg1)
+}
+
+// GetOrCreateOIDCUser mocks base method.
+func (m *MockStorage) GetOrCreateOIDCUser(arg0 context.Context, arg1, arg2 string, arg3 []string) (models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrCreateOIDCUser", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrCreateOIDCUser indicates an expected call of GetOrCreateOIDCUser.
+func (mr *MockStorageMockRecorder) GetOrCreateOIDCUser(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrCreateOIDCUser", reflect.TypeOf((*MockStorage)(nil).GetOrCreateOIDCUser), arg0, arg1, arg2, arg3)
+}
+
 // GetOrder mocks base method.
 func (m *MockStorage) GetOrder(arg0 context.Context, arg1 string) (models.Order, error) {
 	m.ctrl.T.Helper()
@@ -80,6 +241,81 @@ func (mr *MockStorageMockRecorder) GetOrders(arg0, arg1 interface{}) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrders", reflect.TypeOf((*MockStorage)(nil).GetOrders), arg0, arg1)
 }
 
+// GetOrdersVersion mocks base method.
+func (m *MockStorage) GetOrdersVersion(arg0 context.Context, arg1 string) (models.Version, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrdersVersion", arg0, arg1)
+	ret0, _ := ret[0].(models.Version)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrdersVersion indicates an expected call of GetOrdersVersion.
+func (mr *MockStorageMockRecorder) GetOrdersVersion(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrdersVersion", reflect.TypeOf((*MockStorage)(nil).GetOrdersVersion), arg0, arg1)
+}
+
+// GetSessions mocks base method.
+func (m *MockStorage) GetSessions(arg0 context.Context, arg1 string) ([]models.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSessions", arg0, arg1)
+	ret0, _ := ret[0].([]models.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSessions indicates an expected call of GetSessions.
+func (mr *MockStorageMockRecorder) GetSessions(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSessions", reflect.TypeOf((*MockStorage)(nil).GetSessions), arg0, arg1)
+}
+
+// GetStatement mocks base method.
+func (m *MockStorage) GetStatement(arg0 context.Context, arg1 string, arg2, arg3 time.Time) (models.Statement, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStatement", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(models.Statement)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetStatement indicates an expected call of GetStatement.
+func (mr *MockStorageMockRecorder) GetStatement(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStatement", reflect.TypeOf((*MockStorage)(nil).GetStatement), arg0, arg1, arg2, arg3)
+}
+
+// GetTOTP mocks base method.
+func (m *MockStorage) GetTOTP(arg0 context.Context, arg1 string) (models.TOTP, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTOTP", arg0, arg1)
+	ret0, _ := ret[0].(models.TOTP)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTOTP indicates an expected call of GetTOTP.
+func (mr *MockStorageMockRecorder) GetTOTP(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTOTP", reflect.TypeOf((*MockStorage)(nil).GetTOTP), arg0, arg1)
+}
+
+// GetTransfers mocks base method.
+func (m *MockStorage) GetTransfers(arg0 context.Context, arg1 string) ([]models.Transfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransfers", arg0, arg1)
+	ret0, _ := ret[0].([]models.Transfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransfers indicates an expected call of GetTransfers.
+func (mr *MockStorageMockRecorder) GetTransfers(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransfers", reflect.TypeOf((*MockStorage)(nil).GetTransfers), arg0, arg1)
+}
+
 // GetUser mocks base method.
 func (m *MockStorage) GetUser(arg0 context.Context, arg1 string) (models.User, error) {
 	m.ctrl.T.Helper()
@@ -110,6 +346,36 @@ func (mr *MockStorageMockRecorder) GetWithdrawals(arg0, arg1 interface{}) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWithdrawals", reflect.TypeOf((*MockStorage)(nil).GetWithdrawals), arg0, arg1)
 }
 
+// GetWithdrawalsVersion mocks base method.
+func (m *MockStorage) GetWithdrawalsVersion(arg0 context.Context, arg1 string) (models.Version, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWithdrawalsVersion", arg0, arg1)
+	ret0, _ := ret[0].(models.Version)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWithdrawalsVersion indicates an expected call of GetWithdrawalsVersion.
+func (mr *MockStorageMockRecorder) GetWithdrawalsVersion(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWithdrawalsVersion", reflect.TypeOf((*MockStorage)(nil).GetWithdrawalsVersion), arg0, arg1)
+}
+
+// ListUsers mocks base method.
+func (m *MockStorage) ListUsers(arg0 context.Context, arg1 string, arg2, arg3 int) ([]models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListUsers", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].([]models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListUsers indicates an expected call of ListUsers.
+func (mr *MockStorageMockRecorder) ListUsers(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUsers", reflect.TypeOf((*MockStorage)(nil).ListUsers), arg0, arg1, arg2, arg3)
+}
+
 // RegisterUser mocks base method.
 func (m *MockStorage) RegisterUser(arg0 context.Context, arg1, arg2 string) (string, error) {
 	m.ctrl.T.Helper()
@@ -125,6 +391,49 @@ func (mr *MockStorageMockRecorder) RegisterUser(arg0, arg1, arg2 interface{}) *g
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RegisterUser", reflect.TypeOf((*MockStorage)(nil).RegisterUser), arg0, arg1, arg2)
 }
 
+// RevokeAPIKey mocks base method.
+func (m *MockStorage) RevokeAPIKey(arg0 context.Context, arg1 string, arg2 int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAPIKey", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeAPIKey indicates an expected call of RevokeAPIKey.
+func (mr *MockStorageMockRecorder) RevokeAPIKey(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAPIKey", reflect.TypeOf((*MockStorage)(nil).RevokeAPIKey), arg0, arg1, arg2)
+}
+
+// RevokeSession mocks base method.
+func (m *MockStorage) RevokeSession(arg0 context.Context, arg1 string, arg2 int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeSession", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeSession indicates an expected call of RevokeSession.
+func (mr *MockStorageMockRecorder) RevokeSession(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeSession", reflect.TypeOf((*MockStorage)(nil).RevokeSession), arg0, arg1, arg2)
+}
+
+// SaveAPIKey mocks base method.
+func (m *MockStorage) SaveAPIKey(arg0 context.Context, arg1 models.APIKey) (models.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveAPIKey", arg0, arg1)
+	ret0, _ := ret[0].(models.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SaveAPIKey indicates an expected call of SaveAPIKey.
+func (mr *MockStorageMockRecorder) SaveAPIKey(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveAPIKey", reflect.TypeOf((*MockStorage)(nil).SaveAPIKey), arg0, arg1)
+}
+
 // SaveOrder mocks base method.
 func (m *MockStorage) SaveOrder(arg0 context.Context, arg1, arg2 string, arg3 models.OrderStatus) error {
 	m.ctrl.T.Helper()
@@ -139,6 +448,50 @@ func (mr *MockStorageMockRecorder) SaveOrder(arg0, arg1, arg2, arg3 interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveOrder", reflect.TypeOf((*MockStorage)(nil).SaveOrder), arg0, arg1, arg2, arg3)
 }
 
+// SaveOrders mocks base method.
+func (m *MockStorage) SaveOrders(arg0 context.Context, arg1 []string, arg2 string, arg3 models.OrderStatus) (map[string]models.UploadResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveOrders", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(map[string]models.UploadResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SaveOrders indicates an expected call of SaveOrders.
+func (mr *MockStorageMockRecorder) SaveOrders(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveOrders", reflect.TypeOf((*MockStorage)(nil).SaveOrders), arg0, arg1, arg2, arg3)
+}
+
+// SaveTOTPSecret mocks base method.
+func (m *MockStorage) SaveTOTPSecret(arg0 context.Context, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveTOTPSecret", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveTOTPSecret indicates an expected call of SaveTOTPSecret.
+func (mr *MockStorageMockRecorder) SaveTOTPSecret(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveTOTPSecret", reflect.TypeOf((*MockStorage)(nil).SaveTOTPSecret), arg0, arg1, arg2)
+}
+
+// SaveTransfer mocks base method.
+func (m *MockStorage) SaveTransfer(arg0 context.Context, arg1 models.Transfer, arg2 float64) (models.Transfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveTransfer", arg0, arg1, arg2)
+	ret0, _ := ret[0].(models.Transfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SaveTransfer indicates an expected call of SaveTransfer.
+func (mr *MockStorageMockRecorder) SaveTransfer(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveTransfer", reflect.TypeOf((*MockStorage)(nil).SaveTransfer), arg0, arg1, arg2)
+}
+
 // SaveWithdrawal mocks base method.
 func (m *MockStorage) SaveWithdrawal(arg0 context.Context, arg1, arg2 string, arg3 float64) error {
 	m.ctrl.T.Helper()
@@ -152,3 +505,89 @@ func (mr *MockStorageMockRecorder) SaveWithdrawal(arg0, arg1, arg2, arg3 interfa
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveWithdrawal", reflect.TypeOf((*MockStorage)(nil).SaveWithdrawal), arg0, arg1, arg2, arg3)
 }
+
+// TouchSession mocks base method.
+func (m *MockStorage) TouchSession(arg0 context.Context, arg1 int64) (models.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TouchSession", arg0, arg1)
+	ret0, _ := ret[0].(models.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TouchSession indicates an expected call of TouchSession.
+func (mr *MockStorageMockRecorder) TouchSession(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TouchSession", reflect.TypeOf((*MockStorage)(nil).TouchSession), arg0, arg1)
+}
+
+// UpdatePassHash mocks base method.
+func (m *MockStorage) UpdatePassHash(arg0 context.Context, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePassHash", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdatePassHash indicates an expected call of UpdatePassHash.
+func (mr *MockStorageMockRecorder) UpdatePassHash(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePassHash", reflect.TypeOf((*MockStorage)(nil).UpdatePassHash), arg0, arg1, arg2)
+}
+
+// UseAPIKey mocks base method.
+func (m *MockStorage) UseAPIKey(arg0 context.Context, arg1 string) (models.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseAPIKey", arg0, arg1)
+	ret0, _ := ret[0].(models.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseAPIKey indicates an expected call of UseAPIKey.
+func (mr *MockStorageMockRecorder) UseAPIKey(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseAPIKey", reflect.TypeOf((*MockStorage)(nil).UseAPIKey), arg0, arg1)
+}
+
+// UseChallengeAttempt mocks base method.
+func (m *MockStorage) UseChallengeAttempt(arg0 context.Context, arg1, arg2 string, arg3 time.Time, arg4 int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseChallengeAttempt", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UseChallengeAttempt indicates an expected call of UseChallengeAttempt.
+func (mr *MockStorageMockRecorder) UseChallengeAttempt(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseChallengeAttempt", reflect.TypeOf((*MockStorage)(nil).UseChallengeAttempt), arg0, arg1, arg2, arg3, arg4)
+}
+
+// UseRecoveryCode mocks base method.
+func (m *MockStorage) UseRecoveryCode(arg0 context.Context, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseRecoveryCode", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UseRecoveryCode indicates an expected call of UseRecoveryCode.
+func (mr *MockStorageMockRecorder) UseRecoveryCode(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseRecoveryCode", reflect.TypeOf((*MockStorage)(nil).UseRecoveryCode), arg0, arg1, arg2)
+}
+
+// UseTOTPStep mocks base method.
+func (m *MockStorage) UseTOTPStep(arg0 context.Context, arg1 string, arg2 int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseTOTPStep", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UseTOTPStep indicates an expected call of UseTOTPStep.
+func (mr *MockStorageMockRecorder) UseTOTPStep(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseTOTPStep", reflect.TypeOf((*MockStorage)(nil).UseTOTPStep), arg0, arg1, arg2)
+}
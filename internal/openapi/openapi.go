@@ -0,0 +1,40 @@
+// Package openapi serves the OpenAPI 3 description of the API and a page to browse it.
+package openapi
+
+import (
+	_ "embed"
+	"log/slog"
+	"net/http"
+
+	"github.com/VanGoghDev/gophermart/internal/lib/logger/sl"
+)
+
+// Spec is the OpenAPI document of the /api/user routes.
+//
+//go:embed openapi.json
+var Spec []byte
+
+//go:embed docs.html
+var docs []byte
+
+// SpecHandler serves the OpenAPI document.
+func SpecHandler(log *slog.Logger) http.HandlerFunc {
+	return serve(log, "application/json", Spec)
+}
+
+// DocsHandler serves a page that renders the OpenAPI document.
+func DocsHandler(log *slog.Logger) http.HandlerFunc {
+	return serve(log, "text/html; charset=utf-8", docs)
+}
+
+func serve(log *slog.Logger, contentType string, body []byte) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Cache-Control", "no-cache")
+		_, err := w.Write(body)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to write openapi response", sl.Err(err))
+			return
+		}
+	}
+}
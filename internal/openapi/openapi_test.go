@@ -0,0 +1,101 @@
+package openapi_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/mocks"
+	"github.com/VanGoghDev/gophermart/internal/openapi"
+	"github.com/VanGoghDev/gophermart/internal/openapi/openapitest"
+	"github.com/VanGoghDev/gophermart/internal/router"
+	"github.com/VanGoghDev/gophermart/internal/services/auth/oidc"
+	"github.com/VanGoghDev/gophermart/internal/services/auth/oidc/oidctest"
+	"github.com/VanGoghDev/gophermart/internal/services/health"
+	"github.com/go-chi/chi"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRouter(t *testing.T) chi.Router {
+	t.Helper()
+	idp := oidctest.New("gophermart", "client-secret")
+	t.Cleanup(idp.Close)
+
+	p, err := oidc.New(context.Background(), idp.URL, idp.ClientID, idp.ClientSecret,
+		"http://localhost/api/user/oidc/callback", nil)
+	require.NoError(t, err)
+
+	m := mocks.NewMockStorage(gomock.NewController(t))
+	return router.New(logger.New("dev"), m, "secret", time.Hour,
+		router.WithOIDC(p), router.WithHealth(health.New(health.DefaultTimeout)))
+}
+
+var probes = []string{"/healthz", "/readyz"}
+
+// TestRoutes fails when a route is added to the router but not to the document, or the other way round.
+func TestRoutes(t *testing.T) {
+	doc, _, err := openapitest.Load()
+	require.NoError(t, err)
+
+	documented := make([]string, 0)
+	for path, item := range doc.Paths.Map() {
+		for method := range item.Operations() {
+			documented = append(documented, method+" "+path)
+		}
+	}
+
+	served := make([]string, 0)
+	err = chi.Walk(newRouter(t), func(method string, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		// Пробы оркестратора живут вне /api/user, но тоже документированы.
+		if !strings.HasPrefix(route, openapitest.Prefix) && !slices.Contains(probes, route) {
+			return nil
+		}
+		served = append(served, method+" "+strings.TrimSuffix(route, "/"))
+		return nil
+	})
+	require.NoError(t, err)
+
+	sort.Strings(documented)
+	sort.Strings(served)
+	assert.Equal(t, served, documented)
+}
+
+func TestHandlers(t *testing.T) {
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		wantBody    string
+	}{
+		{
+			name:        "must serve the document",
+			path:        "/api/openapi.json",
+			contentType: "application/json",
+			wantBody:    string(openapi.Spec),
+		},
+		{
+			name:        "must serve the docs page",
+			path:        "/api/docs",
+			contentType: "text/html; charset=utf-8",
+			wantBody:    `fetch("openapi.json")`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			newRouter(t).ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tt.contentType, w.Header().Get("Content-Type"))
+			assert.Contains(t, w.Body.String(), tt.wantBody)
+		})
+	}
+}
@@ -0,0 +1,151 @@
+// Package openapitest checks in tests that the server responds as the OpenAPI document describes.
+package openapitest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/VanGoghDev/gophermart/internal/middleware/compressor"
+	"github.com/VanGoghDev/gophermart/internal/openapi"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+)
+
+// Prefix is the part of the API covered by the document.
+const Prefix = "/api/user"
+
+var (
+	loadOnce sync.Once
+	doc      *openapi3.T
+	router   routers.Router
+	loadErr  error
+)
+
+func init() {
+	// Поток событий и PDF проверяем только по коду ответа и Content-Type.
+	openapi3filter.RegisterBodyDecoder("text/event-stream", openapi3filter.FileBodyDecoder)
+	openapi3filter.RegisterBodyDecoder("application/pdf", openapi3filter.FileBodyDecoder)
+}
+
+// Load parses and validates the embedded document.
+func Load() (*openapi3.T, routers.Router, error) {
+	loadOnce.Do(func() {
+		doc, loadErr = openapi3.NewLoader().LoadFromData(openapi.Spec)
+		if loadErr != nil {
+			loadErr = fmt.Errorf("failed to load openapi document: %w", loadErr)
+			return
+		}
+		if loadErr = doc.Validate(context.Background()); loadErr != nil {
+			loadErr = fmt.Errorf("openapi document is invalid: %w", loadErr)
+			return
+		}
+		// Сервер в документе не указан, поэтому маршруты сопоставляются только по пути.
+		doc.Servers = nil
+		router, loadErr = gorillamux.NewRouter(doc)
+		if loadErr != nil {
+			loadErr = fmt.Errorf("failed to build openapi router: %w", loadErr)
+		}
+	})
+	return doc, router, loadErr
+}
+
+// Handler wraps h and fails t when a response under Prefix is not described by the document:
+// the route, the status code, the content type or the body does not match.
+// Requests are not validated since tests send malformed requests on purpose.
+func Handler(t testing.TB, h http.Handler) http.Handler {
+	t.Helper()
+	_, rt, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &recorder{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(rec, r)
+
+		if !strings.HasPrefix(r.URL.Path, Prefix) {
+			return
+		}
+		if err := check(rt, r, rec); err != nil {
+			t.Errorf("%s %s -> %d: response does not match openapi document: %v",
+				r.Method, r.URL.Path, rec.status, err)
+		}
+	})
+}
+
+func check(rt routers.Router, r *http.Request, rec *recorder) error {
+	route, params, err := rt.FindRoute(r)
+	if err != nil {
+		// Неизвестные маршруты отвечают 404/405 ещё до обработчиков.
+		if rec.status == http.StatusNotFound || rec.status == http.StatusMethodNotAllowed {
+			return nil
+		}
+		return fmt.Errorf("route is not documented: %w", err)
+	}
+
+	body := rec.body.Bytes()
+	if coding := rec.Header().Get("Content-Encoding"); coding != "" && len(body) > 0 {
+		zr, err := compressor.NewReader(coding, bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("failed to read %s body: %w", coding, err)
+		}
+		defer func() { _ = zr.Close() }()
+		body, err = io.ReadAll(zr)
+		if err != nil {
+			return fmt.Errorf("failed to read %s body: %w", coding, err)
+		}
+	}
+
+	opts := &openapi3filter.Options{IncludeResponseStatus: true, MultiError: true}
+	input := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: &openapi3filter.RequestValidationInput{
+			Request:    r,
+			PathParams: params,
+			Route:      route,
+			Options:    opts,
+		},
+		Status:  rec.status,
+		Header:  rec.Header(),
+		Options: opts,
+	}
+	input.SetBodyBytes(body)
+	return openapi3filter.ValidateResponse(r.Context(), input)
+}
+
+type recorder struct {
+	http.ResponseWriter
+	body        bytes.Buffer
+	status      int
+	wroteHeader bool
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	if !rec.wroteHeader {
+		rec.status = status
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *recorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	// Для 204 net/http отбрасывает тело, поэтому записываем только то, что реально ушло клиенту.
+	n, err := rec.ResponseWriter.Write(b)
+	rec.body.Write(b[:n])
+	return n, err //nolint:wrapcheck // transparent wrapper
+}
+
+// Flush lets streaming handlers work through the wrapper.
+func (rec *recorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
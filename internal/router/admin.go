@@ -0,0 +1,28 @@
+package router
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/VanGoghDev/gophermart/internal/handlers/health/gethealthz"
+	"github.com/VanGoghDev/gophermart/internal/handlers/health/getreadyz"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/VanGoghDev/gophermart/internal/metrics"
+	"github.com/VanGoghDev/gophermart/internal/middleware/requestlog"
+	"github.com/VanGoghDev/gophermart/internal/services/health"
+	"github.com/go-chi/chi"
+)
+
+// NewAdmin returns the router of the admin listener. It is served apart from the public API
+// so that metrics are never exposed to users.
+func NewAdmin(log *slog.Logger, m *metrics.Metrics, c *health.Checker) http.Handler {
+	r := chi.NewRouter()
+	r.Use(requestlog.New(log))
+	r.NotFound(problem.NotFound)
+	r.MethodNotAllowed(problem.MethodNotAllowed)
+
+	r.Method(http.MethodGet, "/metrics", m.Handler())
+	r.Get("/healthz", gethealthz.New(log))
+	r.Get("/readyz", getreadyz.New(log, c))
+	return r
+}
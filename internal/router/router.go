@@ -3,54 +3,372 @@ package router
 import (
 	"context"
 	"log/slog"
+	"net/http"
 	"time"
 
 	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/handlers/admin/getadjustments"
+	"github.com/VanGoghDev/gophermart/internal/handlers/admin/getuserorders"
+	"github.com/VanGoghDev/gophermart/internal/handlers/admin/getusers"
+	"github.com/VanGoghDev/gophermart/internal/handlers/admin/getuserwithdrawals"
+	"github.com/VanGoghDev/gophermart/internal/handlers/admin/postadjustment"
+	"github.com/VanGoghDev/gophermart/internal/handlers/apikeys/deleteapikey"
+	"github.com/VanGoghDev/gophermart/internal/handlers/apikeys/getapikeys"
+	"github.com/VanGoghDev/gophermart/internal/handlers/apikeys/postapikey"
 	"github.com/VanGoghDev/gophermart/internal/handlers/auth/login"
+	"github.com/VanGoghDev/gophermart/internal/handlers/auth/logintotp"
+	"github.com/VanGoghDev/gophermart/internal/handlers/auth/oidc/authorize"
+	"github.com/VanGoghDev/gophermart/internal/handlers/auth/oidc/callback"
 	"github.com/VanGoghDev/gophermart/internal/handlers/auth/register"
 	"github.com/VanGoghDev/gophermart/internal/handlers/balance/getbalance"
+	"github.com/VanGoghDev/gophermart/internal/handlers/balance/getstatement"
+	"github.com/VanGoghDev/gophermart/internal/handlers/balance/gettransfers"
 	"github.com/VanGoghDev/gophermart/internal/handlers/balance/getwithdrawals"
+	"github.com/VanGoghDev/gophermart/internal/handlers/balance/posttransfer"
 	"github.com/VanGoghDev/gophermart/internal/handlers/balance/postwithdraw"
+	"github.com/VanGoghDev/gophermart/internal/handlers/events/getevents"
+	"github.com/VanGoghDev/gophermart/internal/handlers/export/exportorders"
+	"github.com/VanGoghDev/gophermart/internal/handlers/export/exportstatement"
+	"github.com/VanGoghDev/gophermart/internal/handlers/export/exportwithdrawals"
+	"github.com/VanGoghDev/gophermart/internal/handlers/health/gethealthz"
+	"github.com/VanGoghDev/gophermart/internal/handlers/health/getreadyz"
+	"github.com/VanGoghDev/gophermart/internal/handlers/orders/getorder"
 	"github.com/VanGoghDev/gophermart/internal/handlers/orders/getorders"
 	"github.com/VanGoghDev/gophermart/internal/handlers/orders/postorders"
+	"github.com/VanGoghDev/gophermart/internal/handlers/orders/postordersbulk"
+	"github.com/VanGoghDev/gophermart/internal/handlers/sessions/deletesession"
+	"github.com/VanGoghDev/gophermart/internal/handlers/sessions/getsessions"
+	"github.com/VanGoghDev/gophermart/internal/handlers/totp/postconfirm"
+	"github.com/VanGoghDev/gophermart/internal/handlers/totp/posttotp"
+	"github.com/VanGoghDev/gophermart/internal/lib/export"
+	"github.com/VanGoghDev/gophermart/internal/lib/problem"
+	"github.com/VanGoghDev/gophermart/internal/metrics"
 	"github.com/VanGoghDev/gophermart/internal/middleware/auth"
 	"github.com/VanGoghDev/gophermart/internal/middleware/compressor"
+	"github.com/VanGoghDev/gophermart/internal/middleware/concurrency"
+	"github.com/VanGoghDev/gophermart/internal/middleware/httpmetrics"
+	"github.com/VanGoghDev/gophermart/internal/middleware/httptracing"
+	"github.com/VanGoghDev/gophermart/internal/middleware/rbac"
+	"github.com/VanGoghDev/gophermart/internal/middleware/requestlog"
+	"github.com/VanGoghDev/gophermart/internal/middleware/throttle"
+	"github.com/VanGoghDev/gophermart/internal/openapi"
+	"github.com/VanGoghDev/gophermart/internal/services/auth/hasher"
+	"github.com/VanGoghDev/gophermart/internal/services/auth/oidc"
+	"github.com/VanGoghDev/gophermart/internal/services/auth/policy"
+	"github.com/VanGoghDev/gophermart/internal/services/events"
+	"github.com/VanGoghDev/gophermart/internal/services/health"
+	"github.com/VanGoghDev/gophermart/internal/services/ratelimit"
 	"github.com/go-chi/chi"
 )
 
 type Storage interface {
-	RegisterUser(ctx context.Context, login string, password string) (string, error)
+	RegisterUser(ctx context.Context, login string, passHash string) (string, error)
 	GetUser(ctx context.Context, userLogin string) (models.User, error)
+	UpdatePassHash(ctx context.Context, login string, passHash string) error
+	ListUsers(ctx context.Context, search string, limit int, offset int) ([]models.User, error)
+	GetOrCreateOIDCUser(ctx context.Context, issuer string, subject string, candidates []string) (models.User, error)
+
+	SaveAPIKey(ctx context.Context, key models.APIKey) (models.APIKey, error)
+	GetAPIKeys(ctx context.Context, userLogin string) ([]models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, userLogin string, id int64) error
+	UseAPIKey(ctx context.Context, keyHash string) (models.APIKey, error)
+
+	GetTOTP(ctx context.Context, login string) (models.TOTP, error)
+	SaveTOTPSecret(ctx context.Context, login string, secret string) error
+	ConfirmTOTP(ctx context.Context, login string, step int64, recoveryHashes []string) error
+	UseTOTPStep(ctx context.Context, login string, step int64) error
+	UseRecoveryCode(ctx context.Context, login string, codeHash string) error
+	UseChallengeAttempt(ctx context.Context, jti string, login string, expiresAt time.Time, maxAttempts int) error
+	CompleteChallenge(ctx context.Context, jti string) error
+
+	CreateSession(ctx context.Context, session models.Session) (models.Session, error)
+	GetSessions(ctx context.Context, userLogin string) ([]models.Session, error)
+	RevokeSession(ctx context.Context, userLogin string, id int64) error
+	TouchSession(ctx context.Context, id int64) (models.Session, error)
 
 	GetOrder(ctx context.Context, number string) (models.Order, error)
 	GetOrders(ctx context.Context, userLogin string) ([]models.Order, error)
+	GetOrdersVersion(ctx context.Context, userLogin string) (models.Version, error)
+	EachOrder(ctx context.Context, userLogin string, from time.Time, to time.Time, fn func(models.Order) error) error
 	SaveOrder(ctx context.Context, number string, userLogin string, status models.OrderStatus) error
+	SaveOrders(
+		ctx context.Context,
+		numbers []string,
+		userLogin string,
+		status models.OrderStatus,
+	) (map[string]models.UploadResult, error)
 
 	GetBalance(ctx context.Context, userLogin string) (models.Balance, error)
+	GetBalanceVersion(ctx context.Context, userLogin string) (models.Version, error)
+	GetStatement(ctx context.Context, userLogin string, from time.Time, to time.Time) (models.Statement, error)
+	EachStatementEntry(
+		ctx context.Context,
+		userLogin string,
+		from time.Time,
+		to time.Time,
+		fn func(models.StatementEntry) error,
+	) error
 
 	GetWithdrawals(ctx context.Context, userLogin string) ([]models.Withdrawal, error)
+	GetWithdrawalsVersion(ctx context.Context, userLogin string) (models.Version, error)
 	SaveWithdrawal(ctx context.Context, userLogin string, orderNum string, sum float64) error
+	EachWithdrawal(
+		ctx context.Context,
+		userLogin string,
+		from time.Time,
+		to time.Time,
+		fn func(models.Withdrawal) error,
+	) error
+
+	SaveTransfer(ctx context.Context, t models.Transfer, dailyLimit float64) (models.Transfer, error)
+	GetTransfers(ctx context.Context, userLogin string) ([]models.Transfer, error)
+
+	AdjustBalance(ctx context.Context, adj models.BalanceAdjustment) (models.BalanceAdjustment, error)
+	GetBalanceAdjustments(ctx context.Context, userLogin string) ([]models.BalanceAdjustment, error)
+}
+
+// Option overrides router defaults.
+type Option func(o *options)
+
+type options struct {
+	credentialPolicy *policy.Policy
+	hasher           *hasher.Hasher
+	totpIssuer       string
+	oidc             *oidc.Provider
+	events           *events.Broker
+	rateLimitStore   ratelimit.Store
+	rateLimits       ratelimit.Limits
+	bulkOrdersLimit  int
+	transferLimits   posttransfer.Limits
+	eventsKeepAlive  time.Duration
+	exportLimit      int
+	metrics          *metrics.Metrics
+	health           *health.Checker
+}
+
+// WithCredentialPolicy sets the policy applied to credentials on registration.
+func WithCredentialPolicy(p *policy.Policy) Option {
+	return func(o *options) {
+		o.credentialPolicy = p
+	}
+}
+
+// WithPasswordHasher sets the hasher used to store and verify passwords.
+func WithPasswordHasher(h *hasher.Hasher) Option {
+	return func(o *options) {
+		o.hasher = h
+	}
+}
+
+// WithTOTPIssuer sets the issuer shown in authenticator apps.
+func WithTOTPIssuer(issuer string) Option {
+	return func(o *options) {
+		o.totpIssuer = issuer
+	}
+}
+
+// WithOIDC enables sign in through the OpenID Connect provider.
+func WithOIDC(p *oidc.Provider) Option {
+	return func(o *options) {
+		o.oidc = p
+	}
+}
+
+// WithBulkOrdersLimit sets how many orders can be uploaded in one bulk request.
+func WithBulkOrdersLimit(n int) Option {
+	return func(o *options) {
+		o.bulkOrdersLimit = n
+	}
+}
+
+// WithTransferLimits caps the points a user can transfer to other users. Without it transfers are not capped.
+func WithTransferLimits(l posttransfer.Limits) Option {
+	return func(o *options) {
+		o.transferLimits = l
+	}
+}
+
+// WithEvents sets the broker that feeds the /api/user/events stream.
+func WithEvents(b *events.Broker) Option {
+	return func(o *options) {
+		o.events = b
+	}
+}
+
+// WithEventsKeepAlive sets how often an idle event stream is pinged.
+func WithEventsKeepAlive(d time.Duration) Option {
+	return func(o *options) {
+		o.eventsKeepAlive = d
+	}
+}
+
+// WithExportLimit sets how many exports may run at once. A non-positive n lifts the cap.
+func WithExportLimit(n int) Option {
+	return func(o *options) {
+		o.exportLimit = n
+	}
 }
 
-func New(log *slog.Logger, storage Storage, tokenSecret string, tokenExpires time.Duration) chi.Router {
+// WithRateLimits limits request rates of the route groups, keeping buckets in the store.
+// Without it nothing is limited.
+func WithRateLimits(store ratelimit.Store, limits ratelimit.Limits) Option {
+	return func(o *options) {
+		o.rateLimitStore = store
+		o.rateLimits = limits
+	}
+}
+
+// WithMetrics counts requests and their latency per route and status.
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(o *options) {
+		o.metrics = m
+	}
+}
+
+// WithHealth serves /healthz and /readyz, so that load balancers of the public listener can probe it.
+func WithHealth(c *health.Checker) Option {
+	return func(o *options) {
+		o.health = c
+	}
+}
+
+func New(
+	log *slog.Logger,
+	storage Storage,
+	tokenSecret string,
+	tokenExpires time.Duration,
+	opts ...Option,
+) chi.Router {
+	o := &options{
+		credentialPolicy: policy.Default(),
+		hasher:           hasher.Default(),
+		totpIssuer:       "Gophermart",
+		bulkOrdersLimit:  postordersbulk.DefaultLimit,
+		eventsKeepAlive:  getevents.DefaultKeepAlive,
+		exportLimit:      export.DefaultConcurrency,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.events == nil {
+		o.events = events.New(events.DefaultHistorySize, events.DefaultHistoryTTL)
+	}
+	if o.rateLimitStore == nil {
+		o.rateLimits = ratelimit.Limits{}
+	}
+	limit := func(group string, l ratelimit.Limit, key throttle.KeyFunc) func(http.Handler) http.Handler {
+		return throttle.New(log, o.rateLimitStore, group, l, key)
+	}
+
 	r := chi.NewRouter()
+	r.Use(requestlog.New(log))
+	r.Use(httptracing.New())
+	if o.metrics != nil {
+		r.Use(httpmetrics.New(o.metrics))
+	}
+	r.NotFound(problem.NotFound)
+	r.MethodNotAllowed(problem.MethodNotAllowed)
+
+	if o.health != nil {
+		r.Get("/healthz", gethealthz.New(log))
+		r.Get("/readyz", getreadyz.New(log, o.health))
+	}
+
+	r.Get("/api/openapi.json", openapi.SpecHandler(log))
+	r.Get("/api/docs", openapi.DocsHandler(log))
 
 	r.Route("/api/user", func(r chi.Router) {
-		r.Post("/register", register.New(log, storage, tokenSecret, tokenExpires))
-		r.Post("/login", login.New(log, storage, tokenSecret, tokenExpires))
+		// Публичные маршруты ограничиваем по IP: перебор паролей и массовая регистрация.
+		r.Group(func(r chi.Router) {
+			r.Use(limit("auth", o.rateLimits.Auth, throttle.ByIP))
+			r.Post("/register", register.New(log, storage, o.credentialPolicy, o.hasher, tokenSecret, tokenExpires))
+			r.Post("/login", login.New(log, storage, o.hasher, tokenSecret, tokenExpires))
+			r.Post("/login/totp", logintotp.New(log, storage, tokenSecret, tokenExpires))
+		})
+		if o.oidc != nil {
+			r.Get("/oidc/authorize", authorize.New(log, o.oidc, tokenSecret))
+			r.Get("/oidc/callback", callback.New(log, o.oidc, storage, tokenSecret, tokenExpires))
+		}
+
+		// Поток событий не сжимаем: события мелкие, а кодировщик пришлось бы сбрасывать после каждого.
+		r.Group(func(r chi.Router) {
+			r.Use(auth.New(log, tokenSecret, storage, storage))
+			r.Use(limit("api", o.rateLimits.API, throttle.ByLogin))
+			r.With(auth.RequireScope(log)).Get("/events", getevents.New(log, o.events, o.eventsKeepAlive))
+		})
 
 		r.Group(func(r chi.Router) {
-			r.Use(auth.New(log, tokenSecret))
+			r.Use(auth.New(log, tokenSecret, storage, storage))
+			r.Use(limit("api", o.rateLimits.API, throttle.ByLogin))
 			r.Use(compressor.New(log))
-			r.Post("/orders", postorders.New(log, storage, storage))
-			r.Get("/orders", getorders.New(log, storage))
+			// Загрузка заказов нагружает систему начислений, поэтому у неё отдельный, более строгий лимит.
+			ordersLimit := limit("orders", o.rateLimits.Orders, throttle.ByLogin)
+			r.With(auth.RequireScope(log, models.ScopeOrdersWrite), ordersLimit).
+				Post("/orders", postorders.New(log, storage, storage))
+			r.With(auth.RequireScope(log, models.ScopeOrdersWrite), ordersLimit).
+				Post("/orders/bulk", postordersbulk.New(log, storage, o.bulkOrdersLimit))
+			r.With(auth.RequireScope(log)).Get("/orders", getorders.New(log, storage))
+			r.With(auth.RequireScope(log)).Get("/orders/{number}", getorder.New(log, storage))
 
 			r.Route("/balance", func(r chi.Router) {
-				r.Get("/", getbalance.New(log, storage))
+				r.With(auth.RequireScope(log, models.ScopeBalanceRead)).Get("/", getbalance.New(log, storage))
+				r.With(auth.RequireScope(log, models.ScopeBalanceRead)).
+					Get("/statement", getstatement.New(log, storage))
+
+				r.With(auth.RequireScope(log, models.ScopeWithdraw)).
+					Post("/withdraw", postwithdraw.New(log, storage, storage, storage))
+				r.With(auth.RequireScope(log, models.ScopeWithdraw)).
+					Post("/transfer", posttransfer.New(log, storage, o.transferLimits))
+				r.With(auth.RequireScope(log, models.ScopeBalanceRead)).
+					Get("/transfers", gettransfers.New(log, storage))
+			})
+			r.With(auth.RequireScope(log, models.ScopeBalanceRead)).
+				Get("/withdrawals", getwithdrawals.New(log, storage))
+
+			// CSV сжимается компрессором как text/*, PDF уже сжат внутри и идёт как есть.
+			r.Route("/export", func(r chi.Router) {
+				// Выгрузка держит соединение с базой всё время отдачи, поэтому одновременных выгрузок немного.
+				r.Use(concurrency.New(log, "exports", o.exportLimit))
+				r.With(auth.RequireScope(log)).Get("/orders", exportorders.New(log, storage))
+				r.With(auth.RequireScope(log, models.ScopeBalanceRead)).
+					Get("/withdrawals", exportwithdrawals.New(log, storage))
+				r.With(auth.RequireScope(log, models.ScopeBalanceRead)).
+					Get("/statement", exportstatement.New(log, storage))
+			})
+
+			// Ключами нельзя управлять ключами, только через токен.
+			r.Route("/apikeys", func(r chi.Router) {
+				r.Use(auth.RequireScope(log))
+				r.Post("/", postapikey.New(log, storage))
+				r.Get("/", getapikeys.New(log, storage))
+				r.Delete("/{id}", deleteapikey.New(log, storage))
+			})
 
-				r.Post("/withdraw", postwithdraw.New(log, storage, storage, storage))
+			r.Route("/sessions", func(r chi.Router) {
+				r.Use(auth.RequireScope(log))
+				r.Get("/", getsessions.New(log, storage))
+				r.Delete("/{id}", deletesession.New(log, storage))
 			})
-			r.Get("/withdrawals", getwithdrawals.New(log, storage))
+
+			r.Route("/totp", func(r chi.Router) {
+				r.Use(auth.RequireScope(log))
+				r.Post("/", posttotp.New(log, storage, o.totpIssuer))
+				r.Post("/confirm", postconfirm.New(log, storage))
+			})
+		})
+	})
+
+	r.Route("/api/admin", func(r chi.Router) {
+		r.Use(auth.New(log, tokenSecret, nil, storage))
+		r.Use(rbac.New(log, models.RoleAdmin))
+		r.Use(compressor.New(log))
+
+		r.Get("/users", getusers.New(log, storage))
+		r.Route("/users/{login}", func(r chi.Router) {
+			r.Get("/orders", getuserorders.New(log, storage, storage))
+			r.Get("/withdrawals", getuserwithdrawals.New(log, storage, storage))
+			r.Get("/balance/adjustments", getadjustments.New(log, storage))
+			r.Post("/balance/adjustments", postadjustment.New(log, storage))
 		})
 	})
 	return r
@@ -0,0 +1,98 @@
+package servertls
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/lib/logger/sl"
+)
+
+// DefaultReloadInterval is how often the certificate files are checked for changes.
+const DefaultReloadInterval = 10 * time.Second
+
+// Reloader serves a certificate pair from disk and picks up a new one when the files change,
+// e.g. after cert-manager or certbot renewed it.
+type Reloader struct {
+	log      *slog.Logger
+	certFile string
+	keyFile  string
+
+	cert    atomic.Pointer[tls.Certificate]
+	version string
+}
+
+// NewReloader loads the certificate pair. A broken pair fails the start instead of the first handshake.
+func NewReloader(log *slog.Logger, certFile, keyFile string) (*Reloader, error) {
+	r := &Reloader{log: log, certFile: certFile, keyFile: keyFile}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.cert.Load(), nil
+}
+
+// reload reads the pair again. On error the previous certificate keeps being served.
+func (r *Reloader) reload() error {
+	version, err := r.fileVersion()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load certificate: %w", err)
+	}
+	r.cert.Store(&cert)
+	r.version = version
+	return nil
+}
+
+// Watch reloads the certificate whenever the files change until ctx is done.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			version, err := r.fileVersion()
+			if err != nil {
+				r.log.ErrorContext(ctx, "failed to stat certificate", sl.Err(err))
+				continue
+			}
+			if version == r.version {
+				continue
+			}
+			// Файлы сертификата и ключа обновляются не атомарно вместе: если пара ещё не сходится,
+			// продолжаем отдавать старую и пробуем на следующем тике.
+			if err := r.reload(); err != nil {
+				r.log.ErrorContext(ctx, "failed to reload certificate", sl.Err(err))
+				continue
+			}
+			r.log.InfoContext(ctx, "certificate reloaded", "cert", r.certFile)
+		}
+	}
+}
+
+// fileVersion identifies the current contents of both files by their size and modification time.
+// os.Stat follows symlinks, so the swap of a mounted Kubernetes secret is noticed as well.
+func (r *Reloader) fileVersion() (string, error) {
+	var version string
+	for _, name := range []string{r.certFile, r.keyFile} {
+		fi, err := os.Stat(name)
+		if err != nil {
+			return "", fmt.Errorf("failed to stat %s: %w", name, err)
+		}
+		version += fmt.Sprintf("%d:%d;", fi.Size(), fi.ModTime().UnixNano())
+	}
+	return version, nil
+}
@@ -0,0 +1,112 @@
+// Package servertls builds the TLS configuration of the HTTP listeners: certificates reloaded from disk,
+// HTTP/2, the minimum version and cipher suites, and optional client certificate verification.
+package servertls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Protocols offered via ALPN. h2 goes first so that clients that support it don't fall back to HTTP/1.1.
+var nextProtos = []string{"h2", "http/1.1"}
+
+// Config describes the TLS of one listener.
+type Config struct {
+	// MinVersion is "1.2" or "1.3".
+	MinVersion string
+	// CipherSuites are names as in crypto/tls, e.g. TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256.
+	// They apply to TLS 1.2 only: TLS 1.3 suites are not configurable. Empty means the Go defaults.
+	CipherSuites []string
+	// ClientCAFile enables mTLS: clients must present a certificate signed by one of these CAs.
+	ClientCAFile string
+}
+
+var (
+	ErrUnknownVersion     = errors.New("unknown TLS version")
+	ErrUnknownCipherSuite = errors.New("unknown or insecure cipher suite")
+	// ErrHTTP2CipherSuite is returned if no suite required by RFC 7540 section 9.2.2 is enabled:
+	// net/http refuses to serve HTTP/2 with such a configuration.
+	ErrHTTP2CipherSuite = errors.New("cipher suites must include an AES_128_GCM_SHA256 suite for HTTP/2")
+)
+
+// ParseVersion converts "1.2" or "1.3" into the crypto/tls constant. Older versions are not supported.
+func ParseVersion(s string) (uint16, error) {
+	switch strings.TrimSpace(s) {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("%w %q", ErrUnknownVersion, s)
+	}
+}
+
+// ParseCipherSuites converts suite names into IDs. Only suites that crypto/tls considers secure are accepted.
+func ParseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	known := make(map[string]uint16)
+	for _, s := range tls.CipherSuites() {
+		known[s.Name] = s.ID
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("%w %q", ErrUnknownCipherSuite, name)
+		}
+		ids = append(ids, id)
+	}
+
+	h2 := []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}
+	if !slices.ContainsFunc(ids, func(id uint16) bool { return slices.Contains(h2, id) }) {
+		return nil, ErrHTTP2CipherSuite
+	}
+	return ids, nil
+}
+
+// ServerConfig returns the listener configuration. Certificates are taken from getCertificate
+// on every handshake, so a reloaded certificate is used without restarting the server.
+func ServerConfig(cfg Config,
+	getCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error),
+) (*tls.Config, error) {
+	minVersion, err := ParseVersion(cfg.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+	suites, err := ParseCipherSuites(cfg.CipherSuites)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsCfg := &tls.Config{
+		MinVersion:     minVersion,
+		CipherSuites:   suites,
+		NextProtos:     nextProtos,
+		GetCertificate: getCertificate,
+	}
+
+	if cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in client CA %s", cfg.ClientCAFile)
+		}
+		tlsCfg.ClientCAs = pool
+		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return tlsCfg, nil
+}
@@ -0,0 +1,243 @@
+package servertls_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/logger"
+	"github.com/VanGoghDev/gophermart/internal/servertls"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    uint16
+		wantErr bool
+	}{
+		{in: "", want: tls.VersionTLS12},
+		{in: "1.2", want: tls.VersionTLS12},
+		{in: "1.3", want: tls.VersionTLS13},
+		{in: "1.1", wantErr: true},
+		{in: "tls13", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := servertls.ParseVersion(tt.in)
+		if tt.wantErr {
+			require.ErrorIs(t, err, servertls.ErrUnknownVersion, tt.in)
+			continue
+		}
+		require.NoError(t, err, tt.in)
+		assert.Equal(t, tt.want, got, tt.in)
+	}
+}
+
+func TestParseCipherSuites(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      []string
+		want    []uint16
+		wantErr error
+	}{
+		{
+			name: "must return nil for defaults",
+		},
+		{
+			name: "must parse names",
+			in:   []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256", " TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256"},
+			want: []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256},
+		},
+		{
+			name:    "must reject insecure suites",
+			in:      []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256", "TLS_RSA_WITH_RC4_128_SHA"},
+			wantErr: servertls.ErrUnknownCipherSuite,
+		},
+		{
+			name:    "must reject unknown suites",
+			in:      []string{"TLS_NOPE"},
+			wantErr: servertls.ErrUnknownCipherSuite,
+		},
+		{
+			name:    "must require http2 suite",
+			in:      []string{"TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256"},
+			wantErr: servertls.ErrHTTP2CipherSuite,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := servertls.ParseCipherSuites(tt.in)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestEnsureDevCert(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls", "cert.pem"), filepath.Join(dir, "tls", "key.pem")
+
+	created, err := servertls.EnsureDevCert(certFile, keyFile, "gophermart.local")
+	require.NoError(t, err)
+	assert.True(t, created)
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	assert.NoError(t, leaf.VerifyHostname("gophermart.local"))
+	assert.NoError(t, leaf.VerifyHostname("localhost"))
+	assert.NoError(t, leaf.VerifyHostname("127.0.0.1"))
+
+	fi, err := os.Stat(keyFile)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+
+	// Существующую пару не перезаписываем.
+	created, err = servertls.EnsureDevCert(certFile, keyFile)
+	require.NoError(t, err)
+	assert.False(t, created)
+}
+
+func TestServe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	_, err := servertls.EnsureDevCert(certFile, keyFile)
+	require.NoError(t, err)
+
+	reloader, err := servertls.NewReloader(logger.New("dev"), certFile, keyFile)
+	require.NoError(t, err)
+	go reloader.Watch(ctx, 10*time.Millisecond)
+
+	tlsCfg, err := servertls.ServerConfig(servertls.Config{MinVersion: "1.2"}, reloader.GetCertificate)
+	require.NoError(t, err)
+	url := serve(t, tlsCfg)
+
+	resp, err := client(t, certFile, nil).Get(url)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 2, resp.ProtoMajor, "must negotiate http2")
+
+	// Выпускаем новый сертификат на место старого: клиент, доверяющий только новому, должен дождаться подмены.
+	require.NoError(t, os.Remove(certFile))
+	require.NoError(t, os.Remove(keyFile))
+	_, err = servertls.EnsureDevCert(certFile, keyFile)
+	require.NoError(t, err)
+	renewed := client(t, certFile, nil)
+	assert.Eventually(t, func() bool {
+		resp, err := renewed.Get(url)
+		if err != nil {
+			return false
+		}
+		_ = resp.Body.Close()
+		return true
+	}, 5*time.Second, 20*time.Millisecond)
+}
+
+func TestClientCA(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	_, err := servertls.EnsureDevCert(certFile, keyFile)
+	require.NoError(t, err)
+	reloader, err := servertls.NewReloader(logger.New("dev"), certFile, keyFile)
+	require.NoError(t, err)
+
+	clientCert, clientCAFile := clientCertificate(t, dir)
+	tlsCfg, err := servertls.ServerConfig(servertls.Config{ClientCAFile: clientCAFile}, reloader.GetCertificate)
+	require.NoError(t, err)
+	url := serve(t, tlsCfg)
+
+	t.Run("must reject client without certificate", func(t *testing.T) {
+		_, err := client(t, certFile, nil).Get(url)
+		assert.Error(t, err)
+	})
+
+	t.Run("must accept client with certificate", func(t *testing.T) {
+		resp, err := client(t, certFile, &clientCert).Get(url)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+}
+
+func TestServerConfigErrors(t *testing.T) {
+	_, err := servertls.ServerConfig(servertls.Config{MinVersion: "1.0"}, nil)
+	require.ErrorIs(t, err, servertls.ErrUnknownVersion)
+
+	_, err = servertls.ServerConfig(servertls.Config{ClientCAFile: filepath.Join(t.TempDir(), "missing.pem")}, nil)
+	require.Error(t, err)
+}
+
+func serve(t *testing.T, tlsCfg *tls.Config) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv := &http.Server{
+		Handler:           http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		TLSConfig:         tlsCfg,
+		ReadHeaderTimeout: time.Second,
+	}
+	go func() { _ = srv.ServeTLS(lis, "", "") }()
+	t.Cleanup(func() { _ = srv.Close() })
+
+	return "https://" + lis.Addr().String()
+}
+
+func client(t *testing.T, caFile string, cert *tls.Certificate) *http.Client {
+	t.Helper()
+	caPEM, err := os.ReadFile(caFile)
+	require.NoError(t, err)
+	pool := x509.NewCertPool()
+	require.True(t, pool.AppendCertsFromPEM(caPEM))
+
+	tlsCfg := &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	if cert != nil {
+		tlsCfg.Certificates = []tls.Certificate{*cert}
+	}
+	tr := &http.Transport{TLSClientConfig: tlsCfg, ForceAttemptHTTP2: true}
+	t.Cleanup(tr.CloseIdleConnections)
+	return &http.Client{Transport: tr, Timeout: time.Second}
+}
+
+// clientCertificate issues a self-signed client certificate and writes it as the CA file.
+func clientCertificate(t *testing.T, dir string) (tls.Certificate, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	caFile := filepath.Join(dir, "client-ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, caFile
+}
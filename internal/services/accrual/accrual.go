@@ -7,8 +7,10 @@ import (
 	"sync"
 
 	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/metrics"
 	"github.com/VanGoghDev/gophermart/internal/services/accrual/dispatcher"
 	"github.com/VanGoghDev/gophermart/internal/services/accrual/orderspool"
+	"github.com/VanGoghDev/gophermart/internal/services/events"
 	"github.com/VanGoghDev/gophermart/internal/storage"
 	"golang.org/x/sync/errgroup"
 )
@@ -19,6 +21,7 @@ type AccrualFetcher struct {
 	ordrPool   *orderspool.OrdersPool
 	dispatcher *dispatcher.Dispatcher
 
+	metrics      *metrics.Metrics
 	workersCount int32
 }
 
@@ -26,14 +29,17 @@ func New(
 	log *slog.Logger,
 	oPool *orderspool.OrdersPool,
 	s *storage.Storage,
+	b *events.Broker,
 	a string,
 	wrkrsCount int32,
+	m *metrics.Metrics,
 ) *AccrualFetcher {
-	d := dispatcher.New(log, s, a, wrkrsCount)
+	d := dispatcher.New(log, s, b, a, wrkrsCount, m)
 	return &AccrualFetcher{
 		log:          log,
 		ordrPool:     oPool,
 		dispatcher:   d,
+		metrics:      m,
 		workersCount: wrkrsCount,
 	}
 }
@@ -52,6 +58,7 @@ func (a *AccrualFetcher) RunService(ctx context.Context, g *errgroup.Group, wg *
 func (a *AccrualFetcher) Run(ctx context.Context, g *errgroup.Group, wg *sync.WaitGroup) error {
 	// Здесь образуется очередь из заказов, которые нужно обновить
 	ordersCh := make(chan models.Order, a.workersCount)
+	a.metrics.ObserveQueue(func() int { return len(ordersCh) })
 
 	g.Go(func() error {
 		err := a.ordrPool.GetOrders(ctx, ordersCh, wg)
@@ -76,3 +83,13 @@ func (a *AccrualFetcher) Run(ctx context.Context, g *errgroup.Group, wg *sync.Wa
 
 	return nil
 }
+
+// Running reports whether the dispatcher is processing orders.
+func (a *AccrualFetcher) Running() bool {
+	return a.dispatcher.Running()
+}
+
+// Ping checks that the accrual system is reachable.
+func (a *AccrualFetcher) Ping(ctx context.Context) error {
+	return a.dispatcher.Ping(ctx) //nolint:wrapcheck // already wrapped
+}
@@ -9,22 +9,30 @@ import (
 	"time"
 
 	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/metrics"
+	"github.com/VanGoghDev/gophermart/internal/tracing"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Client общается с внешним сервисом Accrual.
 type Client struct {
-	host   string
-	client http.Client
+	host    string
+	client  http.Client
+	metrics *metrics.Metrics
 }
 
 var (
 	ErrToManyRequests = errors.New("too many requests")
 )
 
-func New(client http.Client, accrlHost string) *Client {
+func New(client http.Client, accrlHost string, m *metrics.Metrics) *Client {
 	return &Client{
-		client: client,
-		host:   accrlHost,
+		client:  client,
+		host:    accrlHost,
+		metrics: m,
 	}
 }
 
@@ -32,6 +40,18 @@ func (c *Client) GetAccrual(
 	ctx context.Context,
 	orderNum string,
 ) (order models.Accrual, timeout time.Duration, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "GET /api/orders/{number}",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.HTTPRequestMethodGet),
+	)
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
 	req, err := http.NewRequestWithContext(
 		ctx,
 		http.MethodGet,
@@ -42,10 +62,17 @@ func (c *Client) GetAccrual(
 		return models.Accrual{}, 0, fmt.Errorf("failed to init request: %w", err)
 	}
 
+	// Система начислений продолжит нашу трассу, если поддерживает W3C trace-context.
+	tracing.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	start := time.Now()
 	r, err := c.client.Do(req)
 	if err != nil {
+		c.metrics.ObserveAccrualRequest(metrics.OutcomeError, time.Since(start))
 		return models.Accrual{}, 0, fmt.Errorf("failed to send request: %w", err)
 	}
+	c.metrics.ObserveAccrualRequest(metrics.AccrualOutcome(r.StatusCode), time.Since(start))
+	span.SetAttributes(semconv.HTTPResponseStatusCode(r.StatusCode))
 
 	defer func() {
 		errc := r.Body.Close()
@@ -70,3 +97,19 @@ func (c *Client) GetAccrual(
 
 	return accrl, 0, nil
 }
+
+// Ping checks that the accrual system answers HTTP at all; any status counts as reachable.
+func (c *Client) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, c.host, http.NoBody)
+	if err != nil {
+		return fmt.Errorf("failed to init request: %w", err)
+	}
+	r, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach accrual: %w", err)
+	}
+	if err := r.Body.Close(); err != nil {
+		return fmt.Errorf("failed to close body: %w", err)
+	}
+	return nil
+}
@@ -6,29 +6,53 @@ import (
 	"log/slog"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/metrics"
 	"github.com/VanGoghDev/gophermart/internal/services/accrual/client"
+	"github.com/VanGoghDev/gophermart/internal/services/events"
 	"github.com/VanGoghDev/gophermart/internal/storage"
+	"github.com/VanGoghDev/gophermart/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/sync/errgroup"
 )
 
+// Publisher delivers changes to the users' event streams.
+type Publisher interface {
+	Publish(login string, typ events.Type, data any)
+}
+
 type Dispatcher struct {
 	client *client.Client
 	s      *storage.Storage
+	pub    Publisher
 
+	metrics      *metrics.Metrics
 	log          *slog.Logger
+	running      atomic.Bool
 	mu           sync.Mutex
 	workersCount int32
 }
 
-func New(log *slog.Logger, strg *storage.Storage, accrlHost string, workersCount int32) *Dispatcher {
-	clnt := client.New(http.Client{}, accrlHost)
+func New(
+	log *slog.Logger,
+	strg *storage.Storage,
+	pub Publisher,
+	accrlHost string,
+	workersCount int32,
+	m *metrics.Metrics,
+) *Dispatcher {
+	clnt := client.New(http.Client{}, accrlHost, m)
 	d := &Dispatcher{
 		log:          log,
 		s:            strg,
+		pub:          pub,
 		client:       clnt,
+		metrics:      m,
 		workersCount: workersCount,
 	}
 	return d
@@ -41,6 +65,7 @@ func (d *Dispatcher) Run(
 	ordersCh chan models.Order,
 ) error {
 	defer wg.Done()
+	d.running.Store(true)
 	notifyCh := make(chan time.Duration)
 	waitCh := make(chan time.Time, d.workersCount)
 	blackList := make(map[int32]int32, d.workersCount)
@@ -74,6 +99,8 @@ func (d *Dispatcher) Run(
 		wg.Add(1)
 		g.Go(func() error {
 			defer wg.Done()
+			// Воркер выходит только с ошибкой, после этого заказы больше не обрабатываются.
+			defer d.running.Store(false)
 			for {
 				err := d.trySendRequest(ctx, notifyCh, waitCh, ordersCh, blackList, id)
 				if err != nil {
@@ -85,6 +112,16 @@ func (d *Dispatcher) Run(
 	return nil
 }
 
+// Running reports whether the workers are processing orders.
+func (d *Dispatcher) Running() bool {
+	return d.running.Load()
+}
+
+// Ping checks that the accrual system is reachable.
+func (d *Dispatcher) Ping(ctx context.Context) error {
+	return d.client.Ping(ctx) //nolint:wrapcheck // already wrapped
+}
+
 func (d *Dispatcher) trySendRequest(
 	ctx context.Context,
 	notifyCh chan time.Duration,
@@ -120,27 +157,68 @@ func (d *Dispatcher) trySendRequest(
 	default:
 
 		for order := range ordersCh {
-			accrl, timeout, err := d.client.GetAccrual(ctx, order.Number)
-			if err != nil {
-				d.log.ErrorContext(ctx, "%w: failed to get accrual", "order.Number", order.Number, "workerID", workerID)
-			}
-			if timeout > 0 {
-				notifyCh <- timeout
-			}
-
-			err = d.s.UpdateStatusAndBalance(ctx, accrl)
-			if err != nil {
-				d.log.ErrorContext(ctx, "%w: failed to update order status and balance",
-					"order.Number", order.Number,
-					"workerID", workerID,
-				)
-				return fmt.Errorf("%w: failed to update order status and balance in storage", err)
+			if err := d.process(ctx, notifyCh, order, workerID); err != nil {
+				return err
 			}
 		}
 	}
 	return nil
 }
 
+// process fetches the accrual of one order and applies it; the worker counts as busy meanwhile.
+func (d *Dispatcher) process(
+	ctx context.Context,
+	notifyCh chan time.Duration,
+	order models.Order,
+	workerID int32,
+) error {
+	done := d.metrics.WorkerBusy()
+	defer done()
+
+	// Заказ обрабатывается вне запроса загрузки, поэтому спан начинает новую трассу со ссылкой на неё.
+	ctx, span := tracing.Tracer().Start(ctx, "dispatcher.process",
+		trace.WithNewRoot(),
+		trace.WithLinks(tracing.LinkTo(order.TraceParent)),
+		trace.WithAttributes(
+			attribute.String("order.number", order.Number),
+			attribute.Int("worker.id", int(workerID)),
+		),
+	)
+	defer span.End()
+
+	accrl, timeout, err := d.client.GetAccrual(ctx, order.Number)
+	if err != nil {
+		d.log.ErrorContext(ctx, "%w: failed to get accrual", "order.Number", order.Number, "workerID", workerID)
+	}
+	if timeout > 0 {
+		notifyCh <- timeout
+	}
+
+	res, err := d.s.UpdateStatusAndBalance(ctx, accrl)
+	if err != nil {
+		d.log.ErrorContext(ctx, "%w: failed to update order status and balance",
+			"order.Number", order.Number,
+			"workerID", workerID,
+		)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("%w: failed to update order status and balance in storage", err)
+	}
+	span.SetAttributes(attribute.String("order.status", string(res.Order.Status)))
+	d.publish(res)
+	return nil
+}
+
+// publish is called only after the transaction is committed, so clients never see a change that was rolled back.
+func (d *Dispatcher) publish(res models.AccrualResult) {
+	if res.Order.Status != res.PreviousStatus {
+		d.pub.Publish(res.UserLogin, events.TypeOrder, res.Order)
+	}
+	if res.Order.Accrual != 0 {
+		d.pub.Publish(res.UserLogin, events.TypeBalance, res.Balance)
+	}
+}
+
 func SendRequest(ctx context.Context) (timeout time.Duration) {
 	return time.Second
 }
@@ -9,6 +9,9 @@ import (
 
 	"github.com/VanGoghDev/gophermart/internal/domain/models"
 	"github.com/VanGoghDev/gophermart/internal/lib/logger/sl"
+	"github.com/VanGoghDev/gophermart/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
 type storage interface {
@@ -40,12 +43,18 @@ func (o *OrdersPool) GetOrders(
 	defer wg.Done()
 
 	for {
-		orders, err := o.s.GetOrdersByStatus(ctx, models.New, models.Processing, models.Registered)
+		spanCtx, span := tracing.Tracer().Start(ctx, "orderspool.GetOrders")
+		orders, err := o.s.GetOrdersByStatus(spanCtx, models.New, models.Processing, models.Registered)
 		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			span.End()
 			log.WarnContext(ctx, "", sl.Err(err))
 			close(ordersCh)
 			return fmt.Errorf("%s: %w", op, err)
 		}
+		span.SetAttributes(attribute.Int("orders.count", len(orders)))
+		span.End()
 
 		for _, v := range orders {
 			time.Sleep(o.timeOut)
@@ -0,0 +1,53 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"slices"
+
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+)
+
+const (
+	keyPrefix    = "gm_"
+	prefixBytes  = 4
+	secretBytes  = 32
+	prefixLength = len(keyPrefix) + prefixBytes*2
+)
+
+// Generate returns a new random key and its public prefix. The key is shown to the user once.
+func Generate() (key string, prefix string, err error) {
+	p := make([]byte, prefixBytes)
+	if _, err := rand.Read(p); err != nil {
+		return "", "", fmt.Errorf("failed to generate key prefix: %w", err)
+	}
+	secret := make([]byte, secretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", fmt.Errorf("failed to generate key secret: %w", err)
+	}
+
+	prefix = keyPrefix + hex.EncodeToString(p)
+	return prefix + "_" + base64.RawURLEncoding.EncodeToString(secret), prefix, nil
+}
+
+// Hash returns the value stored instead of the key. Keys carry 256 bits of entropy,
+// so a fast hash is enough and lets keys be looked up directly.
+func Hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Prefix returns the public part of the key, or empty string if key is malformed.
+func Prefix(key string) string {
+	if len(key) <= prefixLength || key[:len(keyPrefix)] != keyPrefix {
+		return ""
+	}
+	return key[:prefixLength]
+}
+
+func ValidScope(scope models.Scope) bool {
+	return slices.Contains(models.Scopes, scope)
+}
@@ -0,0 +1,45 @@
+package apikey_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
+	"github.com/VanGoghDev/gophermart/internal/services/auth/apikey"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerate(t *testing.T) {
+	key, prefix, err := apikey.Generate()
+	assert.Empty(t, err)
+	assert.True(t, strings.HasPrefix(key, prefix+"_"))
+	assert.Equal(t, prefix, apikey.Prefix(key))
+
+	other, _, err := apikey.Generate()
+	assert.Empty(t, err)
+	assert.NotEqual(t, key, other)
+	assert.NotEqual(t, apikey.Hash(key), apikey.Hash(other))
+	assert.Len(t, apikey.Hash(key), 64)
+}
+
+func TestPrefix(t *testing.T) {
+	tests := []struct {
+		name string
+		key  string
+		want string
+	}{
+		{name: "valid key", key: "gm_0a1b2c3d_secret", want: "gm_0a1b2c3d"},
+		{name: "too short", key: "gm_0a1b", want: ""},
+		{name: "foreign key", key: "xx_0a1b2c3d_secret", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, apikey.Prefix(tt.key))
+		})
+	}
+}
+
+func TestValidScope(t *testing.T) {
+	assert.True(t, apikey.ValidScope(models.ScopeWithdraw))
+	assert.False(t, apikey.ValidScope("admin"))
+}
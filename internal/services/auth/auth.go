@@ -1,24 +1,39 @@
 package auth
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"time"
 
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
 	"github.com/golang-jwt/jwt/v4"
 )
 
 type Claims struct {
 	jwt.RegisteredClaims
 	UserLogin string
+	Role      models.Role `json:",omitempty"`
+	SessionID int64       `json:",omitempty"`
 }
 
 func GrantToken(login string, secret string, tokenExpire time.Duration) (tokenStr string, err error) {
+	return GrantRoleToken(login, models.RoleUser, secret, tokenExpire)
+}
+
+// GrantRoleToken issues a token carrying the user's role claim.
+func GrantRoleToken(
+	login string,
+	role models.Role,
+	secret string,
+	tokenExpire time.Duration,
+) (tokenStr string, err error) {
 	if login == "" || secret == "" || tokenExpire == 0 {
 		return "", fmt.Errorf("given parameters is not valid: %w", errors.New("invalid token data"))
 	}
 
-	tokenString, err := GenerateToken(login, secret, tokenExpire)
+	tokenString, err := GenerateRoleToken(login, role, secret, tokenExpire)
 	if err != nil {
 		return "", fmt.Errorf("failed to generate token: %w", err)
 	}
@@ -26,12 +41,50 @@ func GrantToken(login string, secret string, tokenExpire time.Duration) (tokenSt
 	return tokenString, nil
 }
 
+// GrantSessionToken issues a token bound to a session, so that it stops working once the session is revoked.
+func GrantSessionToken(
+	login string,
+	role models.Role,
+	sessionID int64,
+	secret string,
+	tokenExpire time.Duration,
+) (tokenStr string, err error) {
+	if login == "" || secret == "" || tokenExpire == 0 || sessionID == 0 {
+		return "", fmt.Errorf("given parameters is not valid: %w", errors.New("invalid token data"))
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(tokenExpire)),
+		},
+		UserLogin: login,
+		Role:      role,
+		SessionID: sessionID,
+	})
+
+	tokenString, err := token.SignedString([]byte(secret))
+	if err != nil {
+		return "", fmt.Errorf("failed to sign string: %w", err)
+	}
+	return tokenString, nil
+}
+
 func GenerateToken(login string, secret string, tokenExpire time.Duration) (tokenStr string, err error) {
+	return GenerateRoleToken(login, models.RoleUser, secret, tokenExpire)
+}
+
+func GenerateRoleToken(
+	login string,
+	role models.Role,
+	secret string,
+	tokenExpire time.Duration,
+) (tokenStr string, err error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(tokenExpire)),
 		},
 		UserLogin: login,
+		Role:      role,
 	})
 
 	tokenString, err := token.SignedString([]byte(secret))
@@ -80,3 +133,110 @@ func ExtractLoginFromToken(token string, secret string) (string, error) {
 	}
 	return login, nil
 }
+
+// ExtractRoleFromToken returns the role claim. Tokens issued before roles were introduced belong to users.
+func ExtractRoleFromToken(token string, secret string) (models.Role, error) {
+	tkn, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method :%v", token)
+		}
+		return []byte(secret), nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to parse jwt:%w ", err)
+	}
+
+	claims, ok := tkn.Claims.(jwt.MapClaims)
+	if !ok || !tkn.Valid {
+		return "", errors.New("invalid token")
+	}
+
+	role, ok := claims["Role"].(string)
+	if !ok || role == "" {
+		return models.RoleUser, nil
+	}
+	return models.Role(role), nil
+}
+
+// ExtractSessionFromToken returns the session claim. Zero means the token was issued before sessions were tracked.
+func ExtractSessionFromToken(token string, secret string) (int64, error) {
+	claims := &Claims{}
+	tkn, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method :%v", token)
+		}
+		return []byte(secret), nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse jwt:%w ", err)
+	}
+	if !tkn.Valid {
+		return 0, errors.New("invalid token")
+	}
+	return claims.SessionID, nil
+}
+
+const (
+	// ChallengePurpose marks tokens issued after the first login step of users with two-factor auth.
+	ChallengePurpose = "2fa"
+	// ChallengeTTL is how long the user has to enter the second factor.
+	ChallengeTTL = 5 * time.Minute
+)
+
+// ChallengeClaims deliberately lack UserLogin, so a challenge token is never accepted as a regular one.
+type ChallengeClaims struct {
+	jwt.RegisteredClaims
+	Purpose string
+}
+
+// Challenge is the second login step a challenge token was issued for.
+type Challenge struct {
+	ExpiresAt time.Time
+	Login     string
+	// ID tells challenges apart, so attempts to pass each of them are counted separately.
+	ID string
+}
+
+func GrantChallengeToken(login string, secret string, ttl time.Duration) (string, error) {
+	if login == "" || secret == "" || ttl == 0 {
+		return "", fmt.Errorf("given parameters is not valid: %w", errors.New("invalid token data"))
+	}
+
+	id := make([]byte, 16)
+	// crypto/rand.Read не возвращает ошибок на поддерживаемых платформах.
+	_, _ = rand.Read(id)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, ChallengeClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        hex.EncodeToString(id),
+			Subject:   login,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+		},
+		Purpose: ChallengePurpose,
+	})
+
+	tokenString, err := token.SignedString([]byte(secret))
+	if err != nil {
+		return "", fmt.Errorf("failed to sign string: %w", err)
+	}
+	return tokenString, nil
+}
+
+// ParseChallengeToken validates the challenge token and returns the challenge it was issued for.
+func ParseChallengeToken(token string, secret string) (Challenge, error) {
+	claims := &ChallengeClaims{}
+	tkn, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method :%v", token)
+		}
+		return []byte(secret), nil
+	})
+	if err != nil {
+		return Challenge{}, fmt.Errorf("failed to parse challenge jwt: %w", err)
+	}
+	// Без идентификатора попытки нельзя посчитать, поэтому такие токены не принимаются.
+	if !tkn.Valid || claims.Purpose != ChallengePurpose || claims.Subject == "" || claims.ID == "" ||
+		claims.ExpiresAt == nil {
+		return Challenge{}, errors.New("invalid challenge token")
+	}
+	return Challenge{ExpiresAt: claims.ExpiresAt.Time, Login: claims.Subject, ID: claims.ID}, nil
+}
@@ -4,6 +4,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/VanGoghDev/gophermart/internal/domain/models"
 	"github.com/VanGoghDev/gophermart/internal/services/auth"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/stretchr/testify/assert"
@@ -344,3 +345,100 @@ func TestExtractLoginFromTokenDifferAlg(t *testing.T) {
 		})
 	}
 }
+
+func TestExtractRoleFromToken(t *testing.T) {
+	tests := []struct {
+		name     string
+		role     models.Role
+		noRole   bool
+		wantRole models.Role
+	}{
+		{
+			name:     "admin role",
+			role:     models.RoleAdmin,
+			wantRole: models.RoleAdmin,
+		},
+		{
+			name:     "token without role claim",
+			noRole:   true,
+			wantRole: models.RoleUser,
+		},
+	}
+	secret := "secret"
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var token string
+			var err error
+			if tt.noRole {
+				tkn := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+					"exp":       time.Now().Add(time.Second * 5).Unix(),
+					"UserLogin": "test",
+				})
+				token, err = tkn.SignedString([]byte(secret))
+			} else {
+				token, err = auth.GrantRoleToken("test", tt.role, secret, time.Second*5)
+			}
+			assert.Empty(t, err)
+
+			role, err := auth.ExtractRoleFromToken(token, secret)
+			assert.Empty(t, err)
+			assert.Equal(t, tt.wantRole, role)
+		})
+	}
+}
+
+func TestChallengeToken(t *testing.T) {
+	secret := "secret"
+
+	challenge, err := auth.GrantChallengeToken("test", secret, time.Minute)
+	assert.Empty(t, err)
+
+	c, err := auth.ParseChallengeToken(challenge, secret)
+	assert.Empty(t, err)
+	assert.Equal(t, "test", c.Login)
+	assert.NotEmpty(t, c.ID)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), c.ExpiresAt, time.Second*2)
+
+	other, err := auth.GrantChallengeToken("test", secret, time.Minute)
+	assert.Empty(t, err)
+	c2, err := auth.ParseChallengeToken(other, secret)
+	assert.Empty(t, err)
+	assert.NotEqual(t, c.ID, c2.ID, "attempts of every challenge must be counted separately")
+
+	// Токен второго шага нельзя использовать как обычный и наоборот.
+	_, err = auth.ExtractLoginFromToken(challenge, secret)
+	assert.NotEmpty(t, err)
+
+	regular, err := auth.GrantToken("test", secret, time.Minute)
+	assert.Empty(t, err)
+	_, err = auth.ParseChallengeToken(regular, secret)
+	assert.NotEmpty(t, err)
+
+	_, err = auth.ParseChallengeToken(challenge, "secret2")
+	assert.NotEmpty(t, err)
+}
+
+func TestSessionToken(t *testing.T) {
+	secret := "secret"
+
+	token, err := auth.GrantSessionToken("test", models.RoleUser, 42, secret, time.Minute)
+	assert.Empty(t, err)
+
+	id, err := auth.ExtractSessionFromToken(token, secret)
+	assert.Empty(t, err)
+	assert.Equal(t, int64(42), id)
+
+	login, err := auth.ExtractLoginFromToken(token, secret)
+	assert.Empty(t, err)
+	assert.Equal(t, "test", login)
+
+	// Старые токены без сессии по-прежнему разбираются.
+	legacy, err := auth.GrantToken("test", secret, time.Minute)
+	assert.Empty(t, err)
+	id, err = auth.ExtractSessionFromToken(legacy, secret)
+	assert.Empty(t, err)
+	assert.Zero(t, id)
+
+	_, err = auth.GrantSessionToken("test", models.RoleUser, 0, secret, time.Minute)
+	assert.NotEmpty(t, err)
+}
@@ -0,0 +1,201 @@
+package hasher
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Supported algorithms.
+const (
+	Argon2id = "argon2id"
+	Bcrypt   = "bcrypt"
+)
+
+const (
+	defaultArgon2Memory      = 64 * 1024
+	defaultArgon2Iterations  = 3
+	defaultArgon2Parallelism = 2
+	defaultSaltLength        = 16
+	defaultKeyLength         = 32
+)
+
+var (
+	ErrUnknownAlgorithm = errors.New("unknown hash algorithm")
+	ErrInvalidHash      = errors.New("invalid hash format")
+)
+
+var b64 = base64.RawStdEncoding
+
+// Argon2Params are the tunable Argon2id parameters. Memory is in KiB.
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	SaltLength  uint32
+	KeyLength   uint32
+	Parallelism uint8
+}
+
+// Hasher hashes passwords into PHC strings and verifies them.
+// New hashes are produced with the configured algorithm, while verification
+// accepts any supported one so that existing users keep working.
+type Hasher struct {
+	algorithm  string
+	argon2     Argon2Params
+	bcryptCost int
+}
+
+func Default() *Hasher {
+	h, _ := New(Argon2id, Argon2Params{
+		Memory:      defaultArgon2Memory,
+		Iterations:  defaultArgon2Iterations,
+		Parallelism: defaultArgon2Parallelism,
+		SaltLength:  defaultSaltLength,
+		KeyLength:   defaultKeyLength,
+	}, bcrypt.DefaultCost)
+	return h
+}
+
+func New(algorithm string, params Argon2Params, bcryptCost int) (*Hasher, error) {
+	switch algorithm {
+	case Argon2id:
+		if params.Memory == 0 || params.Iterations == 0 || params.Parallelism == 0 {
+			return nil, errors.New("argon2id memory, iterations and parallelism must be positive")
+		}
+		if params.SaltLength == 0 {
+			params.SaltLength = defaultSaltLength
+		}
+		if params.KeyLength == 0 {
+			params.KeyLength = defaultKeyLength
+		}
+	case Bcrypt:
+		if bcryptCost < bcrypt.MinCost || bcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("bcrypt cost must be in %d..%d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownAlgorithm, algorithm)
+	}
+
+	return &Hasher{
+		algorithm:  algorithm,
+		argon2:     params,
+		bcryptCost: bcryptCost,
+	}, nil
+}
+
+// Hash returns the PHC encoded hash of the password.
+func (h *Hasher) Hash(password string) (string, error) {
+	if h.algorithm == Bcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.bcryptCost)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate bcrypt hash: %w", err)
+		}
+		return string(hash), nil
+	}
+
+	salt := make([]byte, h.argon2.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt,
+		h.argon2.Iterations, h.argon2.Memory, h.argon2.Parallelism, h.argon2.KeyLength)
+
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		Argon2id, argon2.Version,
+		h.argon2.Memory, h.argon2.Iterations, h.argon2.Parallelism,
+		b64.EncodeToString(salt), b64.EncodeToString(key),
+	), nil
+}
+
+// Verify reports whether password matches the encoded hash.
+func (h *Hasher) Verify(password string, encoded string) (bool, error) {
+	switch {
+	case isBcrypt(encoded):
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if err != nil {
+			if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+				return false, nil
+			}
+			return false, fmt.Errorf("failed to compare bcrypt hash: %w", err)
+		}
+		return true, nil
+	case strings.HasPrefix(encoded, "$"+Argon2id+"$"):
+		params, salt, key, err := decodeArgon2id(encoded)
+		if err != nil {
+			return false, err
+		}
+		other := argon2.IDKey([]byte(password), salt,
+			params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+		return subtle.ConstantTimeCompare(key, other) == 1, nil
+	default:
+		return false, ErrUnknownAlgorithm
+	}
+}
+
+// NeedsRehash reports whether the encoded hash was produced with another algorithm or parameters.
+func (h *Hasher) NeedsRehash(encoded string) bool {
+	if h.algorithm == Bcrypt {
+		if !isBcrypt(encoded) {
+			return true
+		}
+		cost, err := bcrypt.Cost([]byte(encoded))
+		return err != nil || cost != h.bcryptCost
+	}
+
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+	return params.Memory != h.argon2.Memory ||
+		params.Iterations != h.argon2.Iterations ||
+		params.Parallelism != h.argon2.Parallelism ||
+		len(salt) != int(h.argon2.SaltLength) ||
+		len(key) != int(h.argon2.KeyLength)
+}
+
+func isBcrypt(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") ||
+		strings.HasPrefix(encoded, "$2b$") ||
+		strings.HasPrefix(encoded, "$2y$")
+}
+
+// decodeArgon2id parses $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>.
+func decodeArgon2id(encoded string) (params Argon2Params, salt []byte, key []byte, err error) {
+	parts := strings.Split(encoded, "$")
+	const partsCount = 6
+	if len(parts) != partsCount || parts[1] != Argon2id {
+		return Argon2Params{}, nil, nil, ErrInvalidHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return Argon2Params{}, nil, nil, fmt.Errorf("%w: %w", ErrInvalidHash, err)
+	}
+	if version != argon2.Version {
+		return Argon2Params{}, nil, nil, fmt.Errorf("%w: unsupported argon2 version %d", ErrInvalidHash, version)
+	}
+
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism)
+	if err != nil {
+		return Argon2Params{}, nil, nil, fmt.Errorf("%w: %w", ErrInvalidHash, err)
+	}
+
+	salt, err = b64.DecodeString(parts[4])
+	if err != nil {
+		return Argon2Params{}, nil, nil, fmt.Errorf("%w: %w", ErrInvalidHash, err)
+	}
+	key, err = b64.DecodeString(parts[5])
+	if err != nil {
+		return Argon2Params{}, nil, nil, fmt.Errorf("%w: %w", ErrInvalidHash, err)
+	}
+	params.SaltLength, params.KeyLength = uint32(len(salt)), uint32(len(key))
+
+	return params, salt, key, nil
+}
@@ -0,0 +1,108 @@
+package hasher_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/VanGoghDev/gophermart/internal/services/auth/hasher"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHashAndVerify(t *testing.T) {
+	argon, err := hasher.New(hasher.Argon2id, hasher.Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1}, 0)
+	assert.Empty(t, err)
+	bcr, err := hasher.New(hasher.Bcrypt, hasher.Argon2Params{}, bcrypt.MinCost)
+	assert.Empty(t, err)
+
+	tests := []struct {
+		name       string
+		h          *hasher.Hasher
+		wantPrefix string
+	}{
+		{
+			name:       "argon2id",
+			h:          argon,
+			wantPrefix: "$argon2id$v=19$m=1024,t=1,p=1$",
+		},
+		{
+			name:       "bcrypt",
+			h:          bcr,
+			wantPrefix: "$2a$04$",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hash, err := tt.h.Hash("Sup3rSecret")
+			assert.Empty(t, err)
+			assert.True(t, strings.HasPrefix(hash, tt.wantPrefix), hash)
+
+			ok, err := tt.h.Verify("Sup3rSecret", hash)
+			assert.Empty(t, err)
+			assert.True(t, ok)
+
+			ok, err = tt.h.Verify("wrong", hash)
+			assert.Empty(t, err)
+			assert.False(t, ok)
+
+			assert.False(t, tt.h.NeedsRehash(hash))
+		})
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	h, err := hasher.New(hasher.Argon2id, hasher.Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1}, 0)
+	assert.Empty(t, err)
+	stronger, err := hasher.New(hasher.Argon2id, hasher.Argon2Params{Memory: 2048, Iterations: 2, Parallelism: 1}, 0)
+	assert.Empty(t, err)
+
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("Sup3rSecret"), bcrypt.MinCost)
+	assert.Empty(t, err)
+	argonHash, err := h.Hash("Sup3rSecret")
+	assert.Empty(t, err)
+
+	// Старые bcrypt хэши по-прежнему проверяются, но требуют перехеширования.
+	ok, err := h.Verify("Sup3rSecret", string(bcryptHash))
+	assert.Empty(t, err)
+	assert.True(t, ok)
+	assert.True(t, h.NeedsRehash(string(bcryptHash)))
+
+	assert.False(t, h.NeedsRehash(argonHash))
+	assert.True(t, stronger.NeedsRehash(argonHash))
+
+	ok, err = stronger.Verify("Sup3rSecret", argonHash)
+	assert.Empty(t, err)
+	assert.True(t, ok)
+}
+
+func TestVerifyInvalidHash(t *testing.T) {
+	h := hasher.Default()
+
+	tests := []struct {
+		name    string
+		encoded string
+	}{
+		{name: "empty", encoded: ""},
+		{name: "unknown algorithm", encoded: "$md5$abc"},
+		{name: "broken argon2id", encoded: "$argon2id$v=19$m=x$salt$key"},
+		{name: "wrong version", encoded: "$argon2id$v=16$m=1024,t=1,p=1$c2FsdA$a2V5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := h.Verify("Sup3rSecret", tt.encoded)
+			assert.NotEmpty(t, err)
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	_, err := hasher.New("md5", hasher.Argon2Params{}, 0)
+	assert.ErrorIs(t, err, hasher.ErrUnknownAlgorithm)
+
+	_, err = hasher.New(hasher.Argon2id, hasher.Argon2Params{}, 0)
+	assert.NotEmpty(t, err)
+
+	_, err = hasher.New(hasher.Bcrypt, hasher.Argon2Params{}, bcrypt.MaxCost+1)
+	assert.NotEmpty(t, err)
+}
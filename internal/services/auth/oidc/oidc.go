@@ -0,0 +1,194 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/golang-jwt/jwt/v4"
+	"golang.org/x/oauth2"
+)
+
+// FlowCookie keeps the signed flow on the client between the redirect and the callback.
+const FlowCookie = "gm_oidc_flow"
+
+// FlowTTL is how long the user has to come back from the identity provider.
+const FlowTTL = 10 * time.Minute
+
+const flowPurpose = "oidc"
+
+var ErrInvalidFlow = errors.New("invalid oidc flow")
+
+// Identity is the verified user info from the ID token.
+type Identity struct {
+	Issuer            string
+	Subject           string
+	Email             string
+	PreferredUsername string
+}
+
+// Flow is the state kept between the redirect to the identity provider and the callback.
+type Flow struct {
+	State    string
+	Verifier string
+	Nonce    string
+}
+
+// Provider runs the authorization code flow with PKCE against a single issuer.
+type Provider struct {
+	verifier *oidc.IDTokenVerifier
+	config   oauth2.Config
+	issuer   string
+}
+
+// New discovers the issuer configuration from <issuer>/.well-known/openid-configuration.
+func New(
+	ctx context.Context,
+	issuer string,
+	clientID string,
+	clientSecret string,
+	redirectURL string,
+	scopes []string,
+) (*Provider, error) {
+	p, err := oidc.NewProvider(ctx, issuer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover oidc issuer: %w", err)
+	}
+
+	return &Provider{
+		verifier: p.Verifier(&oidc.Config{ClientID: clientID}),
+		config: oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			RedirectURL:  redirectURL,
+			Endpoint:     p.Endpoint(),
+			Scopes:       append([]string{oidc.ScopeOpenID}, scopes...),
+		},
+		issuer: issuer,
+	}, nil
+}
+
+// NewFlow generates random state, PKCE verifier and nonce.
+func NewFlow() (Flow, error) {
+	var f Flow
+	for _, v := range []*string{&f.State, &f.Verifier, &f.Nonce} {
+		s, err := randomString()
+		if err != nil {
+			return Flow{}, err
+		}
+		*v = s
+	}
+	return f, nil
+}
+
+// AuthCodeURL is where the user is redirected to sign in.
+func (p *Provider) AuthCodeURL(f Flow) string {
+	return p.config.AuthCodeURL(f.State,
+		oauth2.SetAuthURLParam("code_challenge", challenge(f.Verifier)),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+		oidc.Nonce(f.Nonce),
+	)
+}
+
+// Exchange trades the authorization code for tokens and verifies the ID token.
+func (p *Provider) Exchange(ctx context.Context, code string, f Flow) (Identity, error) {
+	tkn, err := p.config.Exchange(ctx, code, oauth2.SetAuthURLParam("code_verifier", f.Verifier))
+	if err != nil {
+		return Identity{}, fmt.Errorf("failed to exchange code: %w", err)
+	}
+
+	rawIDToken, ok := tkn.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return Identity{}, errors.New("token response has no id_token")
+	}
+
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return Identity{}, fmt.Errorf("failed to verify id token: %w", err)
+	}
+	if idToken.Nonce != f.Nonce {
+		return Identity{}, errors.New("id token nonce mismatch")
+	}
+
+	var claims struct {
+		Email             string `json:"email"`
+		PreferredUsername string `json:"preferred_username"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return Identity{}, fmt.Errorf("failed to parse id token claims: %w", err)
+	}
+
+	return Identity{
+		Issuer:            idToken.Issuer,
+		Subject:           idToken.Subject,
+		Email:             claims.Email,
+		PreferredUsername: claims.PreferredUsername,
+	}, nil
+}
+
+type flowClaims struct {
+	jwt.RegisteredClaims
+	Purpose  string
+	Verifier string
+	Nonce    string
+}
+
+// EncodeFlow signs the flow so that it can be kept in a cookie on the client.
+func EncodeFlow(f Flow, secret string) (string, error) {
+	// Подпись пустым ключом подделает кто угодно.
+	if secret == "" {
+		return "", errors.New("failed to sign oidc flow: empty secret")
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, flowClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        f.State,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(FlowTTL)),
+		},
+		Purpose:  flowPurpose,
+		Verifier: f.Verifier,
+		Nonce:    f.Nonce,
+	})
+
+	s, err := token.SignedString([]byte(secret))
+	if err != nil {
+		return "", fmt.Errorf("failed to sign oidc flow: %w", err)
+	}
+	return s, nil
+}
+
+// DecodeFlow validates the signed flow and checks that it belongs to the given state.
+func DecodeFlow(encoded string, state string, secret string) (Flow, error) {
+	claims := &flowClaims{}
+	tkn, err := jwt.ParseWithClaims(encoded, claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method :%v", t.Header["alg"])
+		}
+		return []byte(secret), nil
+	})
+	if err != nil {
+		return Flow{}, fmt.Errorf("%w: %w", ErrInvalidFlow, err)
+	}
+	if !tkn.Valid || claims.Purpose != flowPurpose || claims.ID == "" || claims.ID != state {
+		return Flow{}, ErrInvalidFlow
+	}
+	return Flow{State: claims.ID, Verifier: claims.Verifier, Nonce: claims.Nonce}, nil
+}
+
+func challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func randomString() (string, error) {
+	const size = 32
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random string: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,110 @@
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/VanGoghDev/gophermart/internal/services/auth/oidc"
+	"github.com/VanGoghDev/gophermart/internal/services/auth/oidc/oidctest"
+	"github.com/stretchr/testify/assert"
+)
+
+const redirectURL = "http://localhost/api/user/oidc/callback"
+
+// authorize follows the authorization URL and returns the code from the redirect back.
+func authorize(t *testing.T, authURL string) (code string, state string) {
+	t.Helper()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	assert.Empty(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+
+	loc, err := url.Parse(resp.Header.Get("Location"))
+	assert.Empty(t, err)
+	return loc.Query().Get("code"), loc.Query().Get("state")
+}
+
+func TestExchange(t *testing.T) {
+	idp := oidctest.New("gophermart", "client-secret")
+	defer idp.Close()
+	idp.SignIn(oidctest.User{Subject: "42", Email: "john@corp.example", PreferredUsername: "john"})
+
+	ctx := context.Background()
+	p, err := oidc.New(ctx, idp.URL, "gophermart", "client-secret", redirectURL, []string{"email", "profile"})
+	assert.Empty(t, err)
+
+	tests := []struct {
+		name    string
+		tamper  func(f *oidc.Flow)
+		wantErr bool
+	}{
+		{
+			name: "valid flow",
+		},
+		{
+			name:    "wrong pkce verifier",
+			tamper:  func(f *oidc.Flow) { f.Verifier = "wrong" },
+			wantErr: true,
+		},
+		{
+			name:    "wrong nonce",
+			tamper:  func(f *oidc.Flow) { f.Nonce = "wrong" },
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := oidc.NewFlow()
+			assert.Empty(t, err)
+
+			code, state := authorize(t, p.AuthCodeURL(f))
+			assert.Equal(t, f.State, state)
+
+			if tt.tamper != nil {
+				tt.tamper(&f)
+			}
+			id, err := p.Exchange(ctx, code, f)
+			if tt.wantErr {
+				assert.NotEmpty(t, err)
+				return
+			}
+			assert.Empty(t, err)
+			assert.Equal(t, oidc.Identity{
+				Issuer:            idp.URL,
+				Subject:           "42",
+				Email:             "john@corp.example",
+				PreferredUsername: "john",
+			}, id)
+		})
+	}
+}
+
+func TestNewUnknownIssuer(t *testing.T) {
+	_, err := oidc.New(context.Background(), "http://127.0.0.1:1", "id", "secret", redirectURL, nil)
+	assert.NotEmpty(t, err)
+}
+
+func TestFlowEncoding(t *testing.T) {
+	f, err := oidc.NewFlow()
+	assert.Empty(t, err)
+
+	encoded, err := oidc.EncodeFlow(f, "secret")
+	assert.Empty(t, err)
+
+	decoded, err := oidc.DecodeFlow(encoded, f.State, "secret")
+	assert.Empty(t, err)
+	assert.Equal(t, f, decoded)
+
+	_, err = oidc.DecodeFlow(encoded, "other state", "secret")
+	assert.True(t, errors.Is(err, oidc.ErrInvalidFlow))
+
+	_, err = oidc.DecodeFlow(encoded, f.State, "secret2")
+	assert.True(t, errors.Is(err, oidc.ErrInvalidFlow))
+}
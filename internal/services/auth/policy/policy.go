@@ -0,0 +1,204 @@
+package policy
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Rule names reported in violations.
+const (
+	RuleRequired       = "required"
+	RuleLoginLength    = "login_length"
+	RuleLoginCharset   = "login_charset"
+	RulePasswordMin    = "password_min_length"
+	RulePasswordClass  = "password_char_classes"
+	RulePasswordCommon = "password_common"
+)
+
+const (
+	defaultLoginMinLength     = 3
+	defaultLoginMaxLength     = 64
+	defaultLoginCharset       = "a-zA-Z0-9._@-"
+	defaultPasswordMinLength  = 8
+	defaultPasswordMinClasses = 2
+)
+
+//go:embed common_passwords.txt
+var commonPasswordsFile string
+
+var commonPasswords = loadCommonPasswords(commonPasswordsFile)
+
+// Violation describes a single broken credential rule.
+type Violation struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Policy describes requirements for user credentials.
+type Policy struct {
+	loginCharset *regexp.Regexp
+
+	LoginCharset       string
+	LoginMinLength     int
+	LoginMaxLength     int
+	PasswordMinLength  int
+	PasswordMinClasses int
+	RejectCommon       bool
+}
+
+func Default() *Policy {
+	p, _ := New(
+		defaultLoginMinLength,
+		defaultLoginMaxLength,
+		defaultLoginCharset,
+		defaultPasswordMinLength,
+		defaultPasswordMinClasses,
+		true,
+	)
+	return p
+}
+
+// New builds a policy. Charset is the body of a regexp character class, e.g. "a-z0-9_".
+func New(
+	loginMin, loginMax int,
+	loginCharset string,
+	passwordMin, passwordClasses int,
+	rejectCommon bool,
+) (*Policy, error) {
+	if loginMin < 1 || loginMax < loginMin {
+		return nil, fmt.Errorf("invalid login length bounds %d..%d", loginMin, loginMax)
+	}
+	if passwordClasses < 0 || passwordClasses > 4 {
+		return nil, fmt.Errorf("password char classes must be in 0..4, got %d", passwordClasses)
+	}
+
+	p := &Policy{
+		LoginCharset:       loginCharset,
+		LoginMinLength:     loginMin,
+		LoginMaxLength:     loginMax,
+		PasswordMinLength:  passwordMin,
+		PasswordMinClasses: passwordClasses,
+		RejectCommon:       rejectCommon,
+	}
+
+	if loginCharset != "" {
+		re, err := regexp.Compile("^[" + loginCharset + "]*$")
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile login charset: %w", err)
+		}
+		p.loginCharset = re
+	}
+
+	return p, nil
+}
+
+// Validate returns every rule the credentials break. Empty result means credentials are acceptable.
+func (p *Policy) Validate(login string, password string) []Violation {
+	violations := make([]Violation, 0)
+
+	if login == "" {
+		violations = append(violations, Violation{Field: "login", Rule: RuleRequired, Message: "login is required"})
+	} else {
+		violations = append(violations, p.validateLogin(login)...)
+	}
+
+	if password == "" {
+		violations = append(violations, Violation{Field: "password", Rule: RuleRequired, Message: "password is required"})
+	} else {
+		violations = append(violations, p.validatePassword(password)...)
+	}
+
+	return violations
+}
+
+func (p *Policy) validateLogin(login string) []Violation {
+	violations := make([]Violation, 0)
+
+	if l := utf8.RuneCountInString(login); l < p.LoginMinLength || l > p.LoginMaxLength {
+		violations = append(violations, Violation{
+			Field: "login",
+			Rule:  RuleLoginLength,
+			Message: fmt.Sprintf("login must be between %d and %d characters long",
+				p.LoginMinLength, p.LoginMaxLength),
+		})
+	}
+
+	if p.loginCharset != nil && !p.loginCharset.MatchString(login) {
+		violations = append(violations, Violation{
+			Field:   "login",
+			Rule:    RuleLoginCharset,
+			Message: fmt.Sprintf("login may only contain characters [%s]", p.LoginCharset),
+		})
+	}
+
+	return violations
+}
+
+func (p *Policy) validatePassword(password string) []Violation {
+	violations := make([]Violation, 0)
+
+	if utf8.RuneCountInString(password) < p.PasswordMinLength {
+		violations = append(violations, Violation{
+			Field:   "password",
+			Rule:    RulePasswordMin,
+			Message: fmt.Sprintf("password must be at least %d characters long", p.PasswordMinLength),
+		})
+	}
+
+	if charClasses(password) < p.PasswordMinClasses {
+		violations = append(violations, Violation{
+			Field: "password",
+			Rule:  RulePasswordClass,
+			Message: fmt.Sprintf("password must contain at least %d of: lowercase, uppercase, digits, symbols",
+				p.PasswordMinClasses),
+		})
+	}
+
+	if p.RejectCommon {
+		if _, ok := commonPasswords[strings.ToLower(password)]; ok {
+			violations = append(violations, Violation{
+				Field:   "password",
+				Rule:    RulePasswordCommon,
+				Message: "password is too common",
+			})
+		}
+	}
+
+	return violations
+}
+
+func charClasses(s string) int {
+	var lower, upper, digit, symbol int
+	for _, r := range s {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+	return lower + upper + digit + symbol
+}
+
+func loadCommonPasswords(list string) map[string]struct{} {
+	passwords := make(map[string]struct{})
+	sc := bufio.NewScanner(strings.NewReader(list))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		passwords[strings.ToLower(line)] = struct{}{}
+	}
+	return passwords
+}
@@ -0,0 +1,136 @@
+package policy_test
+
+import (
+	"testing"
+
+	"github.com/VanGoghDev/gophermart/internal/services/auth/policy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	type args struct {
+		login    string
+		password string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		wantRules []string
+	}{
+		{
+			name: "valid credentials",
+			args: args{
+				login:    "john.doe",
+				password: "Sup3rSecret",
+			},
+			wantRules: []string{},
+		},
+		{
+			name: "empty credentials",
+			args: args{
+				login:    "",
+				password: "",
+			},
+			wantRules: []string{policy.RuleRequired, policy.RuleRequired},
+		},
+		{
+			name: "short login",
+			args: args{
+				login:    "jo",
+				password: "Sup3rSecret",
+			},
+			wantRules: []string{policy.RuleLoginLength},
+		},
+		{
+			name: "login with forbidden characters",
+			args: args{
+				login:    "john doe!",
+				password: "Sup3rSecret",
+			},
+			wantRules: []string{policy.RuleLoginCharset},
+		},
+		{
+			name: "short single class password",
+			args: args{
+				login:    "john",
+				password: "abcdef",
+			},
+			wantRules: []string{policy.RulePasswordMin, policy.RulePasswordClass},
+		},
+		{
+			name: "common password",
+			args: args{
+				login:    "john",
+				password: "Password123",
+			},
+			wantRules: []string{policy.RulePasswordCommon},
+		},
+	}
+	p := policy.Default()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations := p.Validate(tt.args.login, tt.args.password)
+
+			rules := make([]string, 0, len(violations))
+			for _, v := range violations {
+				assert.NotEmpty(t, v.Message)
+				rules = append(rules, v.Rule)
+			}
+			assert.Equal(t, tt.wantRules, rules)
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name         string
+		loginMin     int
+		loginMax     int
+		charset      string
+		classes      int
+		wantErr      bool
+		login        string
+		wantViolated bool
+	}{
+		{
+			name:     "invalid login bounds",
+			loginMin: 10,
+			loginMax: 5,
+			wantErr:  true,
+		},
+		{
+			name:     "invalid char classes",
+			loginMin: 1,
+			loginMax: 5,
+			classes:  5,
+			wantErr:  true,
+		},
+		{
+			name:     "invalid charset",
+			loginMin: 1,
+			loginMax: 5,
+			charset:  "z-a",
+			wantErr:  true,
+		},
+		{
+			name:         "custom charset",
+			loginMin:     1,
+			loginMax:     10,
+			charset:      "a-z",
+			login:        "ABC",
+			wantViolated: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := policy.New(tt.loginMin, tt.loginMax, tt.charset, 1, tt.classes, false)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr {
+				assert.Equal(t, tt.wantViolated, len(p.Validate(tt.login, "x")) > 0)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package totp_test
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/services/auth/totp"
+	"github.com/stretchr/testify/assert"
+)
+
+// Секрет и значения из приложения B RFC 6238 (последние 6 цифр).
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestCode(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "287082"},
+		{unix: 1111111109, want: "081804"},
+		{unix: 1111111111, want: "050471"},
+		{unix: 1234567890, want: "005924"},
+		{unix: 2000000000, want: "279037"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			code, err := totp.Code(rfcSecret, totp.Step(time.Unix(tt.unix, 0)))
+			assert.Empty(t, err)
+			assert.Equal(t, tt.want, code)
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+
+	step, ok, err := totp.Validate(rfcSecret, "081804", now)
+	assert.Empty(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, totp.Step(now), step)
+
+	// Допускается расхождение часов на один шаг.
+	_, ok, err = totp.Validate(rfcSecret, "081804", now.Add(totp.Period))
+	assert.Empty(t, err)
+	assert.True(t, ok)
+
+	_, ok, err = totp.Validate(rfcSecret, "081804", now.Add(3*totp.Period))
+	assert.Empty(t, err)
+	assert.False(t, ok)
+
+	_, ok, err = totp.Validate(rfcSecret, "12345", now)
+	assert.Empty(t, err)
+	assert.False(t, ok)
+
+	_, _, err = totp.Validate("not base32!", "123456", now)
+	assert.ErrorIs(t, err, totp.ErrInvalidSecret)
+}
+
+func TestURI(t *testing.T) {
+	secret, err := totp.GenerateSecret()
+	assert.Empty(t, err)
+
+	u, err := url.Parse(totp.URI("Gophermart", "john", secret))
+	assert.Empty(t, err)
+	assert.Equal(t, "otpauth", u.Scheme)
+	assert.Equal(t, "totp", u.Host)
+	assert.Equal(t, "/Gophermart:john", u.Path)
+	assert.Equal(t, secret, u.Query().Get("secret"))
+	assert.Equal(t, "Gophermart", u.Query().Get("issuer"))
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := totp.GenerateRecoveryCodes(10)
+	assert.Empty(t, err)
+	assert.Len(t, codes, 10)
+
+	seen := make(map[string]struct{})
+	for _, c := range codes {
+		assert.Len(t, c, 9)
+		seen[c] = struct{}{}
+	}
+	assert.Len(t, seen, 10)
+
+	assert.Equal(t, totp.HashRecoveryCode(codes[0]), totp.HashRecoveryCode(" "+strings.ToUpper(codes[0])))
+}
@@ -0,0 +1,230 @@
+// Package events fans out changes of user data to the streams the user has open.
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Type names an event in the stream.
+type Type string
+
+const (
+	TypeOrder   Type = "order"
+	TypeBalance Type = "balance"
+	// TypeResync tells the client that events were lost and the data must be fetched again.
+	TypeResync Type = "resync"
+)
+
+const (
+	// DefaultHistorySize is how many recent events of a user are kept for Last-Event-ID resume.
+	DefaultHistorySize = 100
+	// DefaultHistoryTTL is how long an event is kept for resume. A client that was away longer resyncs.
+	DefaultHistoryTTL = 10 * time.Minute
+
+	subscriptionBuffer = 16
+)
+
+type Event struct {
+	Data any
+	Type Type
+	ID   uint64
+}
+
+type entry struct {
+	publishedAt time.Time
+	Event
+}
+
+type history struct {
+	events []entry
+	// evicted is the ID of the newest event dropped from events.
+	evicted uint64
+}
+
+// expire drops the events published before the deadline.
+func (h *history) expire(deadline time.Time) {
+	drop := 0
+	for drop < len(h.events) && h.events[drop].publishedAt.Before(deadline) {
+		drop++
+	}
+	h.trim(drop)
+}
+
+func (h *history) trim(drop int) {
+	if drop == 0 {
+		return
+	}
+	h.evicted = h.events[drop-1].ID
+	h.events = append(h.events[:0:0], h.events[drop:]...)
+}
+
+// Broker is an in-process pub/sub keyed by user login. Event IDs grow monotonically
+// for the life of the process, so a client can resume after a reconnect.
+type Broker struct {
+	subs        map[string]map[*Subscription]struct{}
+	history     map[string]*history
+	sweptAt     time.Time
+	mu          sync.Mutex
+	historySize int
+	historyTTL  time.Duration
+	lastID      uint64
+	// forgotten is the ID of the newest event of the histories released by sweep.
+	forgotten uint64
+	closed    bool
+}
+
+// New returns a broker that keeps up to historySize events of a user, each for historyTTL.
+func New(historySize int, historyTTL time.Duration) *Broker {
+	return &Broker{
+		subs:        make(map[string]map[*Subscription]struct{}),
+		history:     make(map[string]*history),
+		sweptAt:     time.Now(),
+		historySize: historySize,
+		historyTTL:  historyTTL,
+	}
+}
+
+// Subscription receives events of one user until it is closed.
+// C is closed when the subscriber falls behind or the broker shuts down.
+type Subscription struct {
+	C <-chan Event
+	// Replay holds the events published after the Last-Event-ID the client passed.
+	Replay []Event
+	// Resync is set when some of the events after Last-Event-ID are no longer kept.
+	Resync bool
+
+	b     *Broker
+	c     chan Event
+	login string
+}
+
+// Publish sends the event to every open stream of the user. Subscribers that do not keep up are dropped
+// instead of blocking the publisher; they reconnect and resume from history.
+func (b *Broker) Publish(login string, typ Type, data any) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+
+	now := time.Now()
+	b.sweep(now)
+
+	b.lastID++
+	e := Event{ID: b.lastID, Type: typ, Data: data}
+
+	h, ok := b.history[login]
+	if !ok {
+		h = &history{}
+		b.history[login] = h
+	}
+	h.expire(now.Add(-b.historyTTL))
+	h.events = append(h.events, entry{Event: e, publishedAt: now})
+	if len(h.events) > b.historySize {
+		h.trim(len(h.events) - b.historySize)
+	}
+
+	for sub := range b.subs[login] {
+		select {
+		case sub.c <- e:
+		default:
+			b.remove(sub)
+		}
+	}
+}
+
+// Subscribe opens a stream of the user's events. If lastEventID is not zero,
+// the events published after it are returned in Replay.
+func (b *Broker) Subscribe(login string, lastEventID uint64) *Subscription {
+	c := make(chan Event, subscriptionBuffer)
+	sub := &Subscription{C: c, b: b, c: c, login: login}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(c)
+		return sub
+	}
+
+	now := time.Now()
+	b.sweep(now)
+
+	if lastEventID > 0 {
+		h := b.history[login]
+		if h != nil {
+			h.expire(now.Add(-b.historyTTL))
+		}
+		// ID из будущего означает, что сервер перезапускался и счётчик начался заново.
+		// Без истории неизвестно, были ли у пользователя события среди забытых, поэтому клиент перечитывает данные.
+		sub.Resync = lastEventID > b.lastID ||
+			(h != nil && lastEventID < h.evicted) ||
+			(h == nil && lastEventID < b.forgotten)
+		if h != nil {
+			for _, e := range h.events {
+				if e.ID > lastEventID {
+					sub.Replay = append(sub.Replay, e.Event)
+				}
+			}
+		}
+	}
+
+	if b.subs[login] == nil {
+		b.subs[login] = make(map[*Subscription]struct{})
+	}
+	b.subs[login][sub] = struct{}{}
+	return sub
+}
+
+// Close stops the subscription. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.b.mu.Lock()
+	defer s.b.mu.Unlock()
+	s.b.remove(s)
+}
+
+// Close ends all subscriptions, so that open streams finish and the server can shut down.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for _, subs := range b.subs {
+		for sub := range subs {
+			b.remove(sub)
+		}
+	}
+}
+
+// sweep releases the histories of users without open streams whose newest event is older than the TTL.
+// It walks all users, so it runs at most once per TTL.
+func (b *Broker) sweep(now time.Time) {
+	if now.Sub(b.sweptAt) < b.historyTTL {
+		return
+	}
+	b.sweptAt = now
+
+	deadline := now.Add(-b.historyTTL)
+	for login, h := range b.history {
+		h.expire(deadline)
+		if len(h.events) > 0 || len(b.subs[login]) > 0 {
+			continue
+		}
+		b.forgotten = max(b.forgotten, h.evicted)
+		delete(b.history, login)
+	}
+}
+
+func (b *Broker) remove(sub *Subscription) {
+	subs, ok := b.subs[sub.login]
+	if !ok {
+		return
+	}
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+	delete(subs, sub)
+	close(sub.c)
+	if len(subs) == 0 {
+		delete(b.subs, sub.login)
+	}
+}
@@ -0,0 +1,141 @@
+package events_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/services/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func ids(es []events.Event) []uint64 {
+	out := make([]uint64, 0, len(es))
+	for _, e := range es {
+		out = append(out, e.ID)
+	}
+	return out
+}
+
+func TestSubscribe(t *testing.T) {
+	tests := []struct {
+		name        string
+		lastEventID uint64
+		wantReplay  []uint64
+		wantResync  bool
+	}{
+		{
+			name: "new stream gets no replay",
+		},
+		{
+			name:        "resume replays only the user's missed events",
+			lastEventID: 4,
+			wantReplay:  []uint64{5, 6},
+		},
+		{
+			name:        "resume from evicted event asks to resync",
+			lastEventID: 1,
+			wantReplay:  []uint64{4, 5, 6},
+			wantResync:  true,
+		},
+		{
+			name:        "unknown event id asks to resync",
+			lastEventID: 100,
+			wantResync:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := events.New(3, time.Hour)
+			// IDs 1..6, у пользователя "test" события 1, 2, 4, 5, 6; событие 1 вытеснено из истории.
+			b.Publish("test", events.TypeOrder, 1)
+			b.Publish("test", events.TypeOrder, 2)
+			b.Publish("other", events.TypeOrder, 3)
+			b.Publish("test", events.TypeBalance, 4)
+			b.Publish("test", events.TypeBalance, 5)
+			b.Publish("test", events.TypeOrder, 6)
+
+			sub := b.Subscribe("test", tt.lastEventID)
+			defer sub.Close()
+
+			if tt.wantReplay == nil {
+				assert.Empty(t, sub.Replay)
+			} else {
+				assert.Equal(t, tt.wantReplay, ids(sub.Replay))
+			}
+			assert.Equal(t, tt.wantResync, sub.Resync)
+		})
+	}
+}
+
+func TestPublish(t *testing.T) {
+	b := events.New(events.DefaultHistorySize, events.DefaultHistoryTTL)
+	sub := b.Subscribe("test", 0)
+	other := b.Subscribe("other", 0)
+
+	b.Publish("test", events.TypeOrder, "data")
+
+	e := <-sub.C
+	assert.Equal(t, uint64(1), e.ID)
+	assert.Equal(t, events.TypeOrder, e.Type)
+	assert.Equal(t, "data", e.Data)
+	assert.Empty(t, other.C)
+
+	sub.Close()
+	sub.Close()
+	_, ok := <-sub.C
+	assert.False(t, ok)
+
+	b.Close()
+	_, ok = <-other.C
+	assert.False(t, ok)
+
+	_, ok = <-b.Subscribe("test", 0).C
+	assert.False(t, ok)
+}
+
+func TestPublishDropsSlowSubscriber(t *testing.T) {
+	b := events.New(events.DefaultHistorySize, events.DefaultHistoryTTL)
+	sub := b.Subscribe("test", 0)
+
+	// Подписчик ничего не читает: после заполнения буфера его отключают, а не блокируют публикацию.
+	for i := 0; i < 100; i++ {
+		b.Publish("test", events.TypeOrder, i)
+	}
+
+	n := 0
+	for range sub.C {
+		n++
+	}
+	assert.Less(t, n, 100)
+}
+
+func TestHistoryTTL(t *testing.T) {
+	const ttl = 20 * time.Millisecond
+	b := events.New(events.DefaultHistorySize, ttl)
+
+	b.Publish("idle", events.TypeOrder, 1)
+	b.Publish("idle", events.TypeOrder, 2)
+	b.Publish("online", events.TypeOrder, 3)
+	online := b.Subscribe("online", 0)
+	defer online.Close()
+
+	sub := b.Subscribe("idle", 1)
+	sub.Close()
+	assert.Equal(t, []uint64{2}, ids(sub.Replay))
+	assert.False(t, sub.Resync)
+
+	time.Sleep(2 * ttl)
+	// Публикация другому пользователю запускает очистку: история "idle" без подписчиков освобождается.
+	b.Publish("online", events.TypeOrder, 4)
+
+	sub = b.Subscribe("idle", 1)
+	sub.Close()
+	assert.Empty(t, sub.Replay)
+	assert.True(t, sub.Resync, "events of a released history must not be lost silently")
+
+	// У пользователя с открытым потоком история остаётся, но только со свежими событиями.
+	sub = b.Subscribe("online", 3)
+	sub.Close()
+	assert.Equal(t, []uint64{4}, ids(sub.Replay))
+	assert.False(t, sub.Resync)
+}
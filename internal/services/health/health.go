@@ -0,0 +1,96 @@
+// Package health runs the readiness checks reported by /readyz.
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+	StatusDraining    = "draining"
+)
+
+// DefaultTimeout bounds every check so that a hung dependency can't hold the probe.
+const DefaultTimeout = 2 * time.Second
+
+var ErrDraining = errors.New("server is shutting down")
+
+// CheckFunc returns nil if the dependency is ready.
+type CheckFunc func(ctx context.Context) error
+
+type check struct {
+	name string
+	fn   CheckFunc
+}
+
+// Result is the outcome of one check. Err is not exposed to clients, only logged.
+type Result struct {
+	Name string
+	Err  error
+}
+
+// Report is the outcome of all checks.
+type Report struct {
+	Status  string
+	Results []Result
+}
+
+func (r Report) Ready() bool {
+	return r.Status == StatusOK
+}
+
+type Checker struct {
+	timeout  time.Duration
+	checks   []check
+	draining atomic.Bool
+}
+
+func New(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// Add registers a check. Checks must be added before the checker is used.
+func (c *Checker) Add(name string, fn CheckFunc) {
+	c.checks = append(c.checks, check{name: name, fn: fn})
+}
+
+// Drain marks the server as not ready for good. It is called as soon as shutdown begins,
+// so that load balancers stop sending requests before the server stops accepting them.
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+// Check runs all checks concurrently.
+func (c *Checker) Check(ctx context.Context) Report {
+	if c.draining.Load() {
+		return Report{Status: StatusDraining, Results: []Result{{Name: "shutdown", Err: ErrDraining}}}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	results := make([]Result, len(c.checks))
+	var wg sync.WaitGroup
+	for i, ch := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = Result{Name: ch.name, Err: ch.fn(ctx)}
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Results: results}
+	for _, r := range results {
+		if r.Err != nil {
+			report.Status = StatusUnavailable
+			break
+		}
+	}
+	return report
+}
@@ -0,0 +1,84 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/services/health"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecker(t *testing.T) {
+	errDown := errors.New("down")
+	tests := []struct {
+		name       string
+		checks     map[string]health.CheckFunc
+		drain      bool
+		wantStatus string
+		wantFailed []string
+	}{
+		{
+			name: "must be ready",
+			checks: map[string]health.CheckFunc{
+				"database": func(context.Context) error { return nil },
+				"accrual":  func(context.Context) error { return nil },
+			},
+			wantStatus: health.StatusOK,
+		},
+		{
+			name: "must be unavailable",
+			checks: map[string]health.CheckFunc{
+				"database": func(context.Context) error { return errDown },
+				"accrual":  func(context.Context) error { return nil },
+			},
+			wantStatus: health.StatusUnavailable,
+			wantFailed: []string{"database"},
+		},
+		{
+			name: "must time out hung checks",
+			checks: map[string]health.CheckFunc{
+				"accrual": func(ctx context.Context) error {
+					<-ctx.Done()
+					return ctx.Err()
+				},
+			},
+			wantStatus: health.StatusUnavailable,
+			wantFailed: []string{"accrual"},
+		},
+		{
+			name: "must be draining",
+			checks: map[string]health.CheckFunc{
+				"database": func(context.Context) error { return nil },
+			},
+			drain:      true,
+			wantStatus: health.StatusDraining,
+			wantFailed: []string{"shutdown"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := health.New(50 * time.Millisecond)
+			for name, fn := range tt.checks {
+				c.Add(name, fn)
+			}
+			if tt.drain {
+				c.Drain()
+			}
+
+			report := c.Check(context.Background())
+			require.Equal(t, tt.wantStatus, report.Status)
+			assert.Equal(t, tt.wantStatus == health.StatusOK, report.Ready())
+
+			failed := make([]string, 0)
+			for _, r := range report.Results {
+				if r.Err != nil {
+					failed = append(failed, r.Name)
+				}
+			}
+			assert.ElementsMatch(t, tt.wantFailed, failed)
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+const sweepInterval = time.Minute
+
+type bucket struct {
+	updated time.Time
+	limit   Limit
+	tokens  float64
+}
+
+// refill adds the tokens accumulated since the last request.
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.updated).Seconds()
+	if elapsed > 0 {
+		b.tokens = math.Min(float64(b.limit.Capacity()), b.tokens+elapsed*b.limit.rate())
+	}
+	b.updated = now
+}
+
+// Memory keeps buckets in the process. Limits are not shared between instances.
+type Memory struct {
+	now       func() time.Time
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	mu        sync.Mutex
+}
+
+func NewMemory() *Memory {
+	return NewMemoryWithClock(time.Now)
+}
+
+// NewMemoryWithClock is NewMemory with a custom clock, for tests.
+func NewMemoryWithClock(now func() time.Time) *Memory {
+	return &Memory{
+		now:       now,
+		buckets:   make(map[string]*bucket),
+		lastSweep: now(),
+	}
+}
+
+func (m *Memory) Take(_ context.Context, key string, l Limit) (Result, error) {
+	if l.Disabled() {
+		return Result{}, ErrDisabled
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.sweep(now)
+
+	b, ok := m.buckets[key]
+	if !ok || b.limit != l {
+		b = &bucket{limit: l, tokens: float64(l.Capacity()), updated: now}
+		m.buckets[key] = b
+	}
+	b.refill(now)
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	return newResult(l, b.tokens, allowed), nil
+}
+
+// sweep drops full buckets: they behave exactly like missing ones, so memory does not grow with every client seen.
+func (m *Memory) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < sweepInterval {
+		return
+	}
+	m.lastSweep = now
+	for key, b := range m.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.limit.Capacity()) {
+			delete(m.buckets, key)
+		}
+	}
+}
+
+// Len returns the number of buckets kept.
+func (m *Memory) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.buckets)
+}
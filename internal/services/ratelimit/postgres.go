@@ -0,0 +1,42 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// BucketStorage atomically refills the bucket and takes a token from it if there is one.
+type BucketStorage interface {
+	TakeRateLimitToken(ctx context.Context, key string, rate float64, burst int) (tokens float64, allowed bool, err error)
+	DeleteIdleRateLimits(ctx context.Context, idle time.Duration) (int64, error)
+}
+
+// Postgres keeps buckets in the database, so every instance of the service shares them.
+type Postgres struct {
+	s BucketStorage
+}
+
+func NewPostgres(s BucketStorage) *Postgres {
+	return &Postgres{s: s}
+}
+
+func (p *Postgres) Take(ctx context.Context, key string, l Limit) (Result, error) {
+	if l.Disabled() {
+		return Result{}, ErrDisabled
+	}
+	tokens, allowed, err := p.s.TakeRateLimitToken(ctx, key, l.rate(), l.Capacity())
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to take token: %w", err)
+	}
+	return newResult(l, tokens, allowed), nil
+}
+
+// Prune deletes buckets nobody used for idle. They are full by then and are recreated on demand.
+func (p *Postgres) Prune(ctx context.Context, idle time.Duration) (int64, error) {
+	n, err := p.s.DeleteIdleRateLimits(ctx, idle)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune buckets: %w", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,127 @@
+// Package ratelimit implements token buckets shared by the rate limiting middleware.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit lets Requests requests through per Period on average, and up to Burst at once.
+// The zero Limit disables limiting.
+type Limit struct {
+	Period   time.Duration
+	Requests int
+	Burst    int
+}
+
+// Disabled reports whether the limit lets everything through.
+func (l Limit) Disabled() bool {
+	return l.Requests <= 0 || l.Period <= 0
+}
+
+// rate is how many tokens are added per second.
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// Capacity is the bucket size: Burst if it is set, otherwise Requests.
+func (l Limit) Capacity() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return l.Requests
+}
+
+// ParseLimit parses limits like "60/1m" or "10/1m:20", where 20 is the burst.
+// The burst defaults to the number of requests. An empty string or "0" disables the limit.
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "0" {
+		return Limit{}, nil
+	}
+
+	spec, burst, hasBurst := strings.Cut(s, ":")
+	requests, period, ok := strings.Cut(spec, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("limit %q must look like 60/1m", s)
+	}
+
+	var (
+		l   Limit
+		err error
+	)
+	l.Requests, err = strconv.Atoi(requests)
+	if err != nil || l.Requests <= 0 {
+		return Limit{}, fmt.Errorf("limit %q has invalid number of requests", s)
+	}
+	l.Period, err = time.ParseDuration(period)
+	if err != nil || l.Period <= 0 {
+		return Limit{}, fmt.Errorf("limit %q has invalid period", s)
+	}
+	if hasBurst {
+		l.Burst, err = strconv.Atoi(burst)
+		if err != nil || l.Burst <= 0 {
+			return Limit{}, fmt.Errorf("limit %q has invalid burst", s)
+		}
+	}
+	return l, nil
+}
+
+// Limits are the limits of the route groups. A zero Limit turns limiting of its group off.
+type Limits struct {
+	// Auth applies per client IP to register and login.
+	Auth Limit
+	// Orders applies per user to order uploads on top of API.
+	Orders Limit
+	// API applies per user to every authenticated route.
+	API Limit
+}
+
+// Result is the state of the bucket after a request took a token from it.
+type Result struct {
+	// Limit is the bucket size.
+	Limit int
+	// Remaining is how many requests can be made right now.
+	Remaining int
+	// Reset is when the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is when the next request will be allowed. It is zero if this one was.
+	RetryAfter time.Duration
+	Allowed    bool
+}
+
+// Store keeps the buckets. Implementations must be safe for concurrent use.
+type Store interface {
+	Take(ctx context.Context, key string, l Limit) (Result, error)
+}
+
+// ErrDisabled is returned by stores for a disabled limit.
+var ErrDisabled = errors.New("limit is disabled")
+
+// newResult describes the bucket that holds tokens after the request.
+func newResult(l Limit, tokens float64, allowed bool) Result {
+	rate := l.rate()
+	burst := l.Capacity()
+	res := Result{
+		Limit:     burst,
+		Remaining: int(math.Max(0, math.Floor(tokens))),
+		Reset:     seconds((float64(burst) - tokens) / rate),
+		Allowed:   allowed,
+	}
+	if !allowed {
+		res.RetryAfter = seconds((1 - tokens) / rate)
+	}
+	return res
+}
+
+func seconds(s float64) time.Duration {
+	if s <= 0 {
+		return 0
+	}
+	return time.Duration(s * float64(time.Second))
+}
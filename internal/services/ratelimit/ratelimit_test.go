@@ -0,0 +1,83 @@
+package ratelimit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/VanGoghDev/gophermart/internal/services/ratelimit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    ratelimit.Limit
+		wantErr bool
+	}{
+		{name: "empty disables", in: ""},
+		{name: "zero disables", in: "0"},
+		{name: "requests per period", in: "60/1m", want: ratelimit.Limit{Requests: 60, Period: time.Minute}},
+		{name: "with burst", in: "10/1s:20", want: ratelimit.Limit{Requests: 10, Period: time.Second, Burst: 20}},
+		{name: "no period", in: "60", wantErr: true},
+		{name: "bad requests", in: "x/1m", wantErr: true},
+		{name: "bad period", in: "60/minute", wantErr: true},
+		{name: "bad burst", in: "60/1m:0", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ratelimit.ParseLimit(tt.in)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMemoryTake(t *testing.T) {
+	now := time.Unix(0, 0)
+	m := ratelimit.NewMemoryWithClock(func() time.Time { return now })
+	l := ratelimit.Limit{Requests: 2, Period: time.Second, Burst: 3}
+	ctx := context.Background()
+
+	// Полный бакет пропускает burst запросов подряд.
+	for i := 2; i >= 0; i-- {
+		res, err := m.Take(ctx, "a", l)
+		require.NoError(t, err)
+		assert.True(t, res.Allowed)
+		assert.Equal(t, 3, res.Limit)
+		assert.Equal(t, i, res.Remaining)
+	}
+
+	res, err := m.Take(ctx, "a", l)
+	require.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 500*time.Millisecond, res.RetryAfter)
+	assert.Equal(t, 1500*time.Millisecond, res.Reset)
+
+	// Другой ключ не делит бакет.
+	res, err = m.Take(ctx, "b", l)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+
+	// За полсекунды набегает один токен.
+	now = now.Add(500 * time.Millisecond)
+	res, err = m.Take(ctx, "a", l)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+
+	// Полные бакеты удаляются при очистке.
+	now = now.Add(2 * time.Minute)
+	_, err = m.Take(ctx, "c", l)
+	require.NoError(t, err)
+	assert.Equal(t, 1, m.Len())
+
+	_, err = m.Take(ctx, "a", ratelimit.Limit{})
+	assert.ErrorIs(t, err, ratelimit.ErrDisabled)
+}
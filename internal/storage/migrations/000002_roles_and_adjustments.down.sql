@@ -0,0 +1,5 @@
+BEGIN;
+DROP INDEX IF EXISTS idx_balance_adjustments_user_login;
+DROP TABLE IF EXISTS balance_adjustments;
+ALTER TABLE users DROP COLUMN IF EXISTS role;
+COMMIT;
@@ -0,0 +1,12 @@
+BEGIN TRANSACTION;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'user';
+CREATE TABLE IF NOT EXISTS balance_adjustments (
+    id BIGSERIAL PRIMARY KEY,
+    user_login VARCHAR(500) NOT NULL REFERENCES users (login),
+    admin_login VARCHAR(500) NOT NULL,
+    amount DECIMAL NOT NULL,
+    reason TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_balance_adjustments_user_login ON balance_adjustments(user_login);
+COMMIT TRANSACTION;
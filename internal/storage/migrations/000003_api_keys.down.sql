@@ -0,0 +1,4 @@
+BEGIN;
+DROP INDEX IF EXISTS idx_api_keys_user_login;
+DROP TABLE IF EXISTS api_keys;
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+DROP INDEX IF EXISTS idx_recovery_codes_user_login;
+DROP TABLE IF EXISTS recovery_codes;
+DROP TABLE IF EXISTS user_totp;
+COMMIT;
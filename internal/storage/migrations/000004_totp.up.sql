@@ -0,0 +1,16 @@
+BEGIN TRANSACTION;
+CREATE TABLE IF NOT EXISTS user_totp (
+    user_login VARCHAR(500) PRIMARY KEY REFERENCES users (login),
+    secret VARCHAR(64) NOT NULL,
+    confirmed_at TIMESTAMP,
+    last_used_step BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id BIGSERIAL PRIMARY KEY,
+    user_login VARCHAR(500) NOT NULL REFERENCES users (login),
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user_login ON recovery_codes(user_login);
+COMMIT TRANSACTION;
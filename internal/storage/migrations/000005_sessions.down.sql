@@ -0,0 +1,4 @@
+BEGIN;
+DROP INDEX IF EXISTS idx_sessions_user_login;
+DROP TABLE IF EXISTS sessions;
+COMMIT;
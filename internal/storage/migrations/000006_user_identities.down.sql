@@ -0,0 +1,4 @@
+BEGIN;
+DROP INDEX IF EXISTS idx_user_identities_user_login;
+DROP TABLE IF EXISTS user_identities;
+COMMIT;
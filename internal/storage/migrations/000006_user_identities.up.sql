@@ -0,0 +1,10 @@
+BEGIN TRANSACTION;
+CREATE TABLE IF NOT EXISTS user_identities (
+    issuer VARCHAR(500) NOT NULL,
+    subject VARCHAR(500) NOT NULL,
+    user_login VARCHAR(500) NOT NULL REFERENCES users (login),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (issuer, subject)
+);
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_login ON user_identities(user_login);
+COMMIT TRANSACTION;
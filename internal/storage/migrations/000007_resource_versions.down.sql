@@ -0,0 +1,5 @@
+BEGIN;
+ALTER TABLE orders DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE users DROP COLUMN IF EXISTS balance_updated_at;
+ALTER TABLE users DROP COLUMN IF EXISTS balance_version;
+COMMIT;
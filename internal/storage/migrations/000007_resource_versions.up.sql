@@ -0,0 +1,8 @@
+BEGIN TRANSACTION;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS balance_version BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS balance_updated_at TIMESTAMP NOT NULL DEFAULT NOW();
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP;
+UPDATE orders SET updated_at = uploaded_at WHERE updated_at IS NULL;
+ALTER TABLE orders ALTER COLUMN updated_at SET DEFAULT NOW();
+ALTER TABLE orders ALTER COLUMN updated_at SET NOT NULL;
+COMMIT TRANSACTION;
@@ -0,0 +1,4 @@
+BEGIN;
+DROP INDEX IF EXISTS idx_rate_limits_updated_at;
+DROP TABLE IF EXISTS rate_limits;
+COMMIT;
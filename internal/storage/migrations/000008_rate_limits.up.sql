@@ -0,0 +1,10 @@
+BEGIN TRANSACTION;
+-- Бакеты можно потерять при сбое без последствий, поэтому таблица не пишется в WAL.
+CREATE UNLOGGED TABLE IF NOT EXISTS rate_limits (
+    key VARCHAR(600) PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    allowed BOOLEAN NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_rate_limits_updated_at ON rate_limits(updated_at);
+COMMIT TRANSACTION;
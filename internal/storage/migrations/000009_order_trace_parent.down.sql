@@ -0,0 +1,3 @@
+BEGIN;
+ALTER TABLE orders DROP COLUMN IF EXISTS trace_parent;
+COMMIT;
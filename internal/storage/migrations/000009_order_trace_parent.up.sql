@@ -0,0 +1,4 @@
+BEGIN TRANSACTION;
+-- W3C traceparent загрузки: обработка заказа в диспетчере ссылается на неё в трассировке.
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS trace_parent VARCHAR(64) NOT NULL DEFAULT '';
+COMMIT TRANSACTION;
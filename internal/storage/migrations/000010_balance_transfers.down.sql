@@ -0,0 +1,5 @@
+BEGIN;
+DROP INDEX IF EXISTS idx_balance_transfers_to_login;
+DROP INDEX IF EXISTS idx_balance_transfers_from_login;
+DROP TABLE IF EXISTS balance_transfers;
+COMMIT;
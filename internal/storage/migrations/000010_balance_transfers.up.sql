@@ -0,0 +1,15 @@
+BEGIN TRANSACTION;
+CREATE TABLE IF NOT EXISTS balance_transfers (
+    id BIGSERIAL PRIMARY KEY,
+    from_login VARCHAR(500) NOT NULL REFERENCES users (login),
+    to_login VARCHAR(500) NOT NULL REFERENCES users (login),
+    amount DECIMAL NOT NULL CHECK (amount > 0),
+    -- Ключ идемпотентности уникален в пределах отправителя: повтор запроса возвращает уже сделанный перевод.
+    idempotency_key VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CHECK (from_login <> to_login),
+    UNIQUE (from_login, idempotency_key)
+);
+CREATE INDEX IF NOT EXISTS idx_balance_transfers_from_login ON balance_transfers(from_login, created_at);
+CREATE INDEX IF NOT EXISTS idx_balance_transfers_to_login ON balance_transfers(to_login, created_at);
+COMMIT TRANSACTION;
@@ -0,0 +1,4 @@
+BEGIN;
+DROP INDEX IF EXISTS idx_totp_challenges_expires_at;
+DROP TABLE IF EXISTS totp_challenges;
+COMMIT;
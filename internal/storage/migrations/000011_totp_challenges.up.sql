@@ -0,0 +1,11 @@
+BEGIN TRANSACTION;
+-- Попытки второго шага входа считаются по идентификатору токена: после лимита или успешного входа токен не принимается.
+CREATE TABLE IF NOT EXISTS totp_challenges (
+    jti VARCHAR(64) PRIMARY KEY,
+    user_login VARCHAR(500) NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    completed_at TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_totp_challenges_expires_at ON totp_challenges(expires_at);
+COMMIT TRANSACTION;
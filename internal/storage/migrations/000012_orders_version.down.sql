@@ -0,0 +1,4 @@
+BEGIN;
+ALTER TABLE users DROP COLUMN IF EXISTS orders_updated_at;
+ALTER TABLE users DROP COLUMN IF EXISTS orders_version;
+COMMIT;
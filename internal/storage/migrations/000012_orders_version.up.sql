@@ -0,0 +1,6 @@
+BEGIN TRANSACTION;
+-- Счётчик меняется в тех же транзакциях, что и заказы: по времени изменения нельзя понять,
+-- что транзакция, начатая раньше, зафиксировала изменение позже.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS orders_version BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS orders_updated_at TIMESTAMP NOT NULL DEFAULT NOW();
+COMMIT TRANSACTION;
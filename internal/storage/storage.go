@@ -5,11 +5,14 @@ import (
 	"embed"
 	"errors"
 	"fmt"
+	"io/fs"
 	"log/slog"
+	"strings"
 	"time"
 
 	"github.com/VanGoghDev/gophermart/internal/domain/models"
 	"github.com/VanGoghDev/gophermart/internal/lib/logger/sl"
+	"github.com/VanGoghDev/gophermart/internal/tracing"
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
@@ -18,7 +21,6 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
-	"golang.org/x/crypto/bcrypt"
 )
 
 var (
@@ -27,6 +29,7 @@ var (
 	ErrNotEnoughFunds = errors.New("not enough funds")
 	ErrConflict       = errors.New("conflict")
 	ErrGoodConflict   = errors.New("positive conflict")
+	ErrLimitExceeded  = errors.New("limit exceeded")
 )
 
 var (
@@ -39,7 +42,13 @@ type Storage struct {
 }
 
 func New(ctx context.Context, slg *slog.Logger, storagePath string) (*Storage, error) {
-	pool, err := pgxpool.New(ctx, storagePath)
+	poolCfg, err := pgxpool.ParseConfig(storagePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse connection string: %w", err)
+	}
+	poolCfg.ConnConfig.Tracer = queryTracer{}
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to init pool connection: %w", err)
 	}
@@ -57,91 +66,963 @@ func New(ctx context.Context, slg *slog.Logger, storagePath string) (*Storage, e
 	return s, nil
 }
 
-func (s *Storage) RegisterUser(ctx context.Context, login string, password string) (lgn string, err error) {
-	passHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+func (s *Storage) RegisterUser(ctx context.Context, login string, passHash string) (lgn string, err error) {
+	_, err = s.db.Exec(ctx, "INSERT INTO users(login, pass_hash) VALUES($1, $2)",
+		login, passHash)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			if pgErr.Code == pgerrcode.UniqueViolation {
+				return "", fmt.Errorf("%w: user with login %s exists", ErrAlreadyExists, login)
+			}
+		}
+		return "", fmt.Errorf("failed to insert users: %w", err)
+	}
+
+	return login, nil
+}
+
+func (s *Storage) GetUser(ctx context.Context, login string) (user models.User, err error) {
+	row := s.db.QueryRow(ctx, "SELECT login, pass_hash, balance, role FROM users WHERE login = $1", login)
+	err = row.Scan(&user.Login, &user.PassHash, &user.Balance, &user.Role)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.User{}, fmt.Errorf("%w: user with login %s not found", ErrNotFound, login)
+		}
+		return models.User{}, fmt.Errorf("failed to select users: %w", err)
+	}
+	return user, nil
+}
+
+func (s *Storage) UpdatePassHash(ctx context.Context, login string, passHash string) error {
+	tag, err := s.db.Exec(ctx, "UPDATE users SET pass_hash = $1 WHERE login = $2", passHash, login)
+	if err != nil {
+		return fmt.Errorf("failed to update pass_hash: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: user with login %s not found", ErrNotFound, login)
+	}
+	return nil
+}
+
+// GetOrCreateOIDCUser returns the user linked to the identity provider subject. On the first login
+// a user without a password is created with the first free login of candidates.
+func (s *Storage) GetOrCreateOIDCUser(
+	ctx context.Context,
+	issuer string,
+	subject string,
+	candidates []string,
+) (user models.User, err error) {
+	tx, err := s.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return models.User{}, fmt.Errorf("failed to init transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if err := tx.Rollback(ctx); err != nil {
+				s.log.ErrorContext(ctx, failedToRollbackLogMsg, sl.Err(err))
+			}
+		}
+	}()
+
+	row := tx.QueryRow(ctx,
+		"SELECT u.login, u.pass_hash, u.balance, u.role FROM user_identities i "+
+			"JOIN users u ON u.login = i.user_login WHERE i.issuer = $1 AND i.subject = $2",
+		issuer, subject,
+	)
+	err = row.Scan(&user.Login, &user.PassHash, &user.Balance, &user.Role)
+	if err == nil {
+		err = tx.Commit(ctx)
+		if err != nil {
+			return models.User{}, fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		return user, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return models.User{}, fmt.Errorf("failed to select user identity: %w", err)
+	}
+
+	// Существующие аккаунты с паролем не привязываются: иначе IdP мог бы войти в чужой аккаунт по логину.
+	for _, login := range candidates {
+		var tag pgconn.CommandTag
+		tag, err = tx.Exec(ctx,
+			"INSERT INTO users(login, pass_hash) VALUES($1, '') ON CONFLICT (login) DO NOTHING", login)
+		if err != nil {
+			return models.User{}, fmt.Errorf("failed to insert users: %w", err)
+		}
+		if tag.RowsAffected() == 1 {
+			user = models.User{Login: login, Role: models.RoleUser}
+			break
+		}
+	}
+	if user.Login == "" {
+		err = fmt.Errorf("%w: no free login for subject %s", ErrAlreadyExists, subject)
+		return models.User{}, err
+	}
+
+	_, err = tx.Exec(ctx,
+		"INSERT INTO user_identities(issuer, subject, user_login) VALUES($1, $2, $3)",
+		issuer, subject, user.Login,
+	)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+			return models.User{}, fmt.Errorf("%w: identity %s is being linked concurrently", ErrConflict, subject)
+		}
+		return models.User{}, fmt.Errorf("failed to insert user identity: %w", err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return models.User{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return user, nil
+}
+
+// likeEscaper makes a search term match literally: % and _ in it are not wildcards.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// ListUsers returns users whose login contains search, ordered by login.
+func (s *Storage) ListUsers(ctx context.Context, search string, limit int, offset int) (users []models.User, err error) {
+	users = make([]models.User, 0)
+
+	rows, err := s.db.Query(
+		ctx,
+		"SELECT login, balance, role FROM users WHERE login ILIKE '%' || $1 || '%' ESCAPE '\\' "+
+			"ORDER BY login LIMIT $2 OFFSET $3",
+		likeEscaper.Replace(search), limit, offset,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select users: %w", err)
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var user = models.User{}
+		err = rows.Scan(&user.Login, &user.Balance, &user.Role)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan users: %w", err)
+		}
+		users = append(users, user)
+	}
+
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("failed to iterate through rows: %w", rows.Err())
+	}
+
+	return users, nil
+}
+
+// SetRole assigns role to the given users and returns how many of them exist.
+func (s *Storage) SetRole(ctx context.Context, logins []string, role models.Role) (int64, error) {
+	tag, err := s.db.Exec(ctx, "UPDATE users SET role = $1 WHERE login = ANY($2)", role, logins)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update role: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+func (s *Storage) SaveAPIKey(ctx context.Context, key models.APIKey) (models.APIKey, error) {
+	scopes := make([]string, 0, len(key.Scopes))
+	for _, sc := range key.Scopes {
+		scopes = append(scopes, string(sc))
+	}
+
+	err := s.db.QueryRow(ctx,
+		"INSERT INTO api_keys(user_login, name, prefix, key_hash, scopes) VALUES($1, $2, $3, $4, $5) "+
+			"RETURNING id, created_at",
+		key.UserLogin, key.Name, key.Prefix, key.KeyHash, scopes,
+	).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return models.APIKey{}, fmt.Errorf("failed to insert api key: %w", err)
+	}
+	key.CreatedAtFormated = key.CreatedAt.Format(time.RFC3339)
+
+	return key, nil
+}
+
+// GetAPIKeys returns not revoked keys of the user.
+func (s *Storage) GetAPIKeys(ctx context.Context, userLogin string) (keys []models.APIKey, err error) {
+	keys = make([]models.APIKey, 0)
+
+	rows, err := s.db.Query(ctx,
+		"SELECT id, user_login, name, prefix, scopes, created_at, last_used_at FROM api_keys "+
+			"WHERE user_login = $1 AND revoked_at IS NULL ORDER BY created_at",
+		userLogin,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select api keys: %w", err)
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("failed to iterate through rows: %w", rows.Err())
+	}
+
+	return keys, nil
+}
+
+func (s *Storage) RevokeAPIKey(ctx context.Context, userLogin string, id int64) error {
+	tag, err := s.db.Exec(ctx,
+		"UPDATE api_keys SET revoked_at = NOW() WHERE id = $1 AND user_login = $2 AND revoked_at IS NULL",
+		id, userLogin,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to revoke api key: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: api key %d of user %s", ErrNotFound, id, userLogin)
+	}
+	return nil
+}
+
+// UseAPIKey finds an active key by hash and marks it as used.
+func (s *Storage) UseAPIKey(ctx context.Context, keyHash string) (models.APIKey, error) {
+	row := s.db.QueryRow(ctx,
+		"UPDATE api_keys SET last_used_at = NOW() WHERE key_hash = $1 AND revoked_at IS NULL "+
+			"RETURNING id, user_login, name, prefix, scopes, created_at, last_used_at",
+		keyHash,
+	)
+	key, err := scanAPIKey(row)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.APIKey{}, fmt.Errorf("%w: api key", ErrNotFound)
+		}
+		return models.APIKey{}, err
+	}
+	return key, nil
+}
+
+func scanAPIKey(row pgx.Row) (models.APIKey, error) {
+	var key models.APIKey
+	var scopes []string
+	err := row.Scan(&key.ID, &key.UserLogin, &key.Name, &key.Prefix, &scopes, &key.CreatedAt, &key.LastUsedAt)
+	if err != nil {
+		return models.APIKey{}, fmt.Errorf("failed to scan api key: %w", err)
+	}
+
+	key.Scopes = make([]models.Scope, 0, len(scopes))
+	for _, sc := range scopes {
+		key.Scopes = append(key.Scopes, models.Scope(sc))
+	}
+	key.CreatedAtFormated = key.CreatedAt.Format(time.RFC3339)
+	if key.LastUsedAt != nil {
+		key.LastUsedAtFormated = key.LastUsedAt.Format(time.RFC3339)
+	}
+	return key, nil
+}
+
+func (s *Storage) GetTOTP(ctx context.Context, login string) (t models.TOTP, err error) {
+	row := s.db.QueryRow(ctx,
+		"SELECT user_login, secret, confirmed_at, last_used_step FROM user_totp WHERE user_login = $1", login)
+	err = row.Scan(&t.UserLogin, &t.Secret, &t.ConfirmedAt, &t.LastUsedStep)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.TOTP{}, fmt.Errorf("%w: totp of user %s not found", ErrNotFound, login)
+		}
+		return models.TOTP{}, fmt.Errorf("failed to select totp: %w", err)
+	}
+	return t, nil
+}
+
+// SaveTOTPSecret starts enrollment. A pending secret is replaced, a confirmed one is kept.
+func (s *Storage) SaveTOTPSecret(ctx context.Context, login string, secret string) error {
+	tag, err := s.db.Exec(ctx,
+		"INSERT INTO user_totp(user_login, secret) VALUES($1, $2) "+
+			"ON CONFLICT (user_login) DO UPDATE SET secret = EXCLUDED.secret, created_at = NOW() "+
+			"WHERE user_totp.confirmed_at IS NULL",
+		login, secret,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save totp secret: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: totp of user %s is already confirmed", ErrAlreadyExists, login)
+	}
+	return nil
+}
+
+// ConfirmTOTP enables the second factor and replaces recovery codes.
+func (s *Storage) ConfirmTOTP(ctx context.Context, login string, step int64, recoveryHashes []string) (err error) {
+	tx, err := s.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to init transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if err := tx.Rollback(ctx); err != nil {
+				s.log.ErrorContext(ctx, failedToRollbackLogMsg, sl.Err(err))
+			}
+		}
+	}()
+
+	tag, err := tx.Exec(ctx,
+		"UPDATE user_totp SET confirmed_at = NOW(), last_used_step = $2 "+
+			"WHERE user_login = $1 AND confirmed_at IS NULL",
+		login, step,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to confirm totp: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: no pending totp for user %s", ErrNotFound, login)
+	}
+
+	_, err = tx.Exec(ctx, "DELETE FROM recovery_codes WHERE user_login = $1", login)
+	if err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	_, err = tx.Exec(ctx,
+		"INSERT INTO recovery_codes(user_login, code_hash) SELECT $1, unnest($2::varchar[])",
+		login, recoveryHashes,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert recovery codes: %w", err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// UseTOTPStep remembers the step of an accepted code. Codes of the same or earlier steps are rejected
+// with ErrConflict, so an intercepted code cannot be replayed.
+func (s *Storage) UseTOTPStep(ctx context.Context, login string, step int64) error {
+	tag, err := s.db.Exec(ctx,
+		"UPDATE user_totp SET last_used_step = $2 WHERE user_login = $1 AND last_used_step < $2",
+		login, step,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update totp step: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: totp code of user %s already used", ErrConflict, login)
+	}
+	return nil
+}
+
+func (s *Storage) UseRecoveryCode(ctx context.Context, login string, codeHash string) error {
+	tag, err := s.db.Exec(ctx,
+		"UPDATE recovery_codes SET used_at = NOW() WHERE user_login = $1 AND code_hash = $2 AND used_at IS NULL",
+		login, codeHash,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: recovery code of user %s", ErrNotFound, login)
+	}
+	return nil
+}
+
+// UseChallengeAttempt counts an attempt to pass the second login step of the challenge.
+// It returns ErrLimitExceeded when the challenge already had maxAttempts attempts or was passed.
+// Counting is a single statement, so concurrent guesses can't slip past the limit.
+func (s *Storage) UseChallengeAttempt(
+	ctx context.Context,
+	jti string,
+	login string,
+	expiresAt time.Time,
+	maxAttempts int,
+) error {
+	// Просроченные записи уже не нужны: их токены не пройдут проверку срока. Свою запись не трогаем,
+	// потому что одна строка не может меняться и в CTE, и в основном запросе.
+	var attempts int
+	err := s.db.QueryRow(ctx,
+		"WITH expired AS (DELETE FROM totp_challenges WHERE expires_at < NOW() AND jti <> $1) "+
+			"INSERT INTO totp_challenges AS c (jti, user_login, attempts, expires_at) VALUES ($1, $2, 1, $3) "+
+			"ON CONFLICT (jti) DO UPDATE SET attempts = c.attempts + 1 "+
+			"WHERE c.completed_at IS NULL AND c.attempts < $4 "+
+			"RETURNING attempts",
+		jti, login, expiresAt, maxAttempts,
+	).Scan(&attempts)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("%w: challenge %s of user %s", ErrLimitExceeded, jti, login)
+		}
+		return fmt.Errorf("failed to count challenge attempt: %w", err)
+	}
+	return nil
+}
+
+// CompleteChallenge marks the challenge passed, so its token can't be used again.
+func (s *Storage) CompleteChallenge(ctx context.Context, jti string) error {
+	_, err := s.db.Exec(ctx, "UPDATE totp_challenges SET completed_at = NOW() WHERE jti = $1", jti)
+	if err != nil {
+		return fmt.Errorf("failed to complete challenge: %w", err)
+	}
+	return nil
+}
+
+func (s *Storage) CreateSession(ctx context.Context, session models.Session) (models.Session, error) {
+	err := s.db.QueryRow(ctx,
+		"INSERT INTO sessions(user_login, device, user_agent, ip) VALUES($1, $2, $3, $4) "+
+			"RETURNING id, created_at, last_used_at",
+		session.UserLogin, session.Device, session.UserAgent, session.IP,
+	).Scan(&session.ID, &session.CreatedAt, &session.LastUsedAt)
+	if err != nil {
+		return models.Session{}, fmt.Errorf("failed to insert session: %w", err)
+	}
+	session.CreatedAtFormated = session.CreatedAt.Format(time.RFC3339)
+	session.LastUsedAtFormated = session.LastUsedAt.Format(time.RFC3339)
+
+	return session, nil
+}
+
+// GetSessions returns not revoked sessions of the user, recently used first.
+func (s *Storage) GetSessions(ctx context.Context, userLogin string) (sessions []models.Session, err error) {
+	sessions = make([]models.Session, 0)
+
+	rows, err := s.db.Query(ctx,
+		"SELECT id, user_login, device, user_agent, ip, created_at, last_used_at FROM sessions "+
+			"WHERE user_login = $1 AND revoked_at IS NULL ORDER BY last_used_at DESC",
+		userLogin,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select sessions: %w", err)
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("failed to iterate through rows: %w", rows.Err())
+	}
+
+	return sessions, nil
+}
+
+func (s *Storage) RevokeSession(ctx context.Context, userLogin string, id int64) error {
+	tag, err := s.db.Exec(ctx,
+		"UPDATE sessions SET revoked_at = NOW() WHERE id = $1 AND user_login = $2 AND revoked_at IS NULL",
+		id, userLogin,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: session %d of user %s", ErrNotFound, id, userLogin)
+	}
+	return nil
+}
+
+// TouchSession finds an active session and marks it as used.
+func (s *Storage) TouchSession(ctx context.Context, id int64) (models.Session, error) {
+	row := s.db.QueryRow(ctx,
+		"UPDATE sessions SET last_used_at = NOW() WHERE id = $1 AND revoked_at IS NULL "+
+			"RETURNING id, user_login, device, user_agent, ip, created_at, last_used_at",
+		id,
+	)
+	session, err := scanSession(row)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Session{}, fmt.Errorf("%w: session %d", ErrNotFound, id)
+		}
+		return models.Session{}, err
+	}
+	return session, nil
+}
+
+func scanSession(row pgx.Row) (models.Session, error) {
+	var session models.Session
+	err := row.Scan(&session.ID, &session.UserLogin, &session.Device, &session.UserAgent, &session.IP,
+		&session.CreatedAt, &session.LastUsedAt)
+	if err != nil {
+		return models.Session{}, fmt.Errorf("failed to scan session: %w", err)
+	}
+	session.CreatedAtFormated = session.CreatedAt.Format(time.RFC3339)
+	session.LastUsedAtFormated = session.LastUsedAt.Format(time.RFC3339)
+	return session, nil
+}
+
+func (s *Storage) GetOrder(ctx context.Context, number string) (order models.Order, err error) {
+	row := s.db.QueryRow(
+		ctx,
+		"SELECT number, user_login, status, accrual, uploaded_at FROM orders WHERE number = $1",
+		number,
+	)
+	err = row.Scan(&order.Number, &order.UserLogin, &order.Status, &order.Accrual, &order.UploadedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Order{}, fmt.Errorf("%w: order with number %s not found", ErrNotFound, number)
+		}
+		return models.Order{}, fmt.Errorf("failed to select order: %w", err)
+	}
+	order.UploadedAtFormated = order.UploadedAt.Format(time.RFC3339)
+
+	return order, nil
+}
+
+func (s *Storage) GetOrders(ctx context.Context, userLogin string) (orders []models.Order, err error) {
+	orders = make([]models.Order, 0)
+
+	rows, err := s.db.Query(
+		ctx,
+		"SELECT number, status, accrual, uploaded_at FROM orders WHERE user_login = $1 ORDER by uploaded_at",
+		userLogin,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%w: user %s have no orders", ErrNotFound, userLogin)
+		}
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var order = models.Order{}
+		err = rows.Scan(&order.Number, &order.Status, &order.Accrual, &order.UploadedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to select orders: %w", err)
+		}
+		order.UploadedAtFormated = order.UploadedAt.Format(time.RFC3339)
+		orders = append(orders, order)
+	}
+
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("failed to iterate through rows: %w", err)
+	}
+
+	return orders, nil
+}
+
+// GetOrdersVersion returns the change counter of the user's orders.
+func (s *Storage) GetOrdersVersion(ctx context.Context, userLogin string) (models.Version, error) {
+	var v models.Version
+	err := s.db.QueryRow(ctx,
+		"SELECT orders_version, orders_updated_at FROM users WHERE login = $1",
+		userLogin,
+	).Scan(&v.Counter, &v.LastModified)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Version{}, fmt.Errorf("%w: user %s not found", ErrNotFound, userLogin)
+		}
+		return models.Version{}, fmt.Errorf("failed to select orders version: %w", err)
+	}
+	return v, nil
+}
+
+func (s *Storage) SaveOrder(
+	ctx context.Context,
+	number string,
+	userLogin string,
+	status models.OrderStatus,
+) (err error) {
+	tx, err := s.db.BeginTx(ctx, pgx.TxOptions{})
+	defer func() {
+		if err != nil {
+			if err := tx.Rollback(ctx); err != nil {
+				s.log.ErrorContext(ctx, failedToRollbackLogMsg, sl.Err(err))
+			}
+		}
+	}()
+
+	var ordrNum, usrLogin string
+	err = tx.QueryRow(ctx, "SELECT number, user_login FROM orders WHERE number = $1", number).Scan(&ordrNum, &usrLogin)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("failed to select orders: %w", err)
+		}
+	}
+	if ordrNum != "" {
+		if userLogin == usrLogin {
+			return fmt.Errorf("%w: order %s belongs to another user", ErrGoodConflict, number)
+		}
+		return ErrConflict
+	}
+
+	_, err = tx.Prepare(ctx, "saveOrder",
+		"INSERT INTO orders(number, user_login, status, trace_parent) VALUES($1, $2, $3, $4)")
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement saveOrder: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, "saveOrder", number, userLogin, status, tracing.TraceParent(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to execute saveOrder: %w", err)
+	}
+	// Версия меняется в той же транзакции, что и заказы, поэтому ETag не может отстать от списка.
+	_, err = tx.Exec(ctx, "UPDATE users SET orders_version = orders_version + 1, "+
+		"orders_updated_at = NOW() WHERE login = $1", userLogin)
+	if err != nil {
+		return fmt.Errorf("failed to update orders version: %w", err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// SaveOrders saves the numbers in one transaction and reports the result for each of them.
+// Numbers uploaded before by the same user are already_yours, by another user are conflict.
+func (s *Storage) SaveOrders(
+	ctx context.Context,
+	numbers []string,
+	userLogin string,
+	status models.OrderStatus,
+) (results map[string]models.UploadResult, err error) {
+	tx, err := s.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to init transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if err := tx.Rollback(ctx); err != nil {
+				s.log.ErrorContext(ctx, failedToRollbackLogMsg, sl.Err(err))
+			}
+		}
+	}()
+
+	traceParent := tracing.TraceParent(ctx)
+	batch := &pgx.Batch{}
+	for _, number := range numbers {
+		batch.Queue(
+			`INSERT INTO orders(number, user_login, status, trace_parent) VALUES($1, $2, $3, $4)
+			ON CONFLICT (number) DO NOTHING`,
+			number, userLogin, status, traceParent,
+		)
+	}
+	br := tx.SendBatch(ctx, batch)
+
+	results = make(map[string]models.UploadResult, len(numbers))
+	existing := make([]string, 0)
+	for _, number := range numbers {
+		tag, err := br.Exec()
+		if err != nil {
+			_ = br.Close()
+			return nil, fmt.Errorf("failed to insert order %s: %w", number, err)
+		}
+		if tag.RowsAffected() == 1 {
+			results[number] = models.UploadAccepted
+			continue
+		}
+		existing = append(existing, number)
+	}
+	if err = br.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close batch: %w", err)
+	}
+	if len(existing) < len(numbers) {
+		_, err = tx.Exec(ctx, "UPDATE users SET orders_version = orders_version + 1, "+
+			"orders_updated_at = NOW() WHERE login = $1", userLogin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update orders version: %w", err)
+		}
+	}
+
+	if len(existing) > 0 {
+		rows, err := tx.Query(ctx, "SELECT number, user_login FROM orders WHERE number = ANY($1)", existing)
+		if err != nil {
+			return nil, fmt.Errorf("failed to select existing orders: %w", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var number, owner string
+			if err = rows.Scan(&number, &owner); err != nil {
+				return nil, fmt.Errorf("failed to scan existing order: %w", err)
+			}
+			if owner == userLogin {
+				results[number] = models.UploadAlreadyYours
+			} else {
+				results[number] = models.UploadConflict
+			}
+		}
+		if err = rows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to iterate through rows: %w", err)
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return results, nil
+}
+
+func (s *Storage) GetBalance(ctx context.Context, userLogin string) (balance models.Balance, err error) {
+	tx, err := s.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return models.Balance{}, fmt.Errorf("failed to init transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if err := tx.Rollback(ctx); err != nil {
+				s.log.ErrorContext(ctx, failedToRollbackLogMsg, sl.Err(err))
+			}
+		}
+	}()
+
+	var blnc, withdraw float64
+	err = tx.QueryRow(ctx,
+		"SELECT balance, coalesce(withdrawal_sum , 0) as withdrawal_sum FROM users as u "+
+			"LEFT JOIN (SELECT user_login, COALESCE(SUM(withdrawal_sum), 0) AS withdrawal_sum 	FROM withdrawals "+
+			"GROUP BY user_login) "+
+			"as w ON u.login = $1",
+		userLogin).Scan(&blnc, &withdraw)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Balance{}, fmt.Errorf("%w: user %s not found", ErrNotFound, userLogin)
+		}
+		return models.Balance{}, fmt.Errorf("failed to select balance: %w", err)
+	}
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Balance{}, fmt.Errorf("%w: no withdrawals for user %s", ErrNotFound, userLogin)
+		}
+		return models.Balance{}, fmt.Errorf("failed to select withdrawals: %w", err)
+	}
+
+	return models.Balance{
+		Current:   blnc,
+		Withdrawn: withdraw,
+	}, nil
+}
+
+// GetBalanceVersion returns the change counter of the user's balance.
+func (s *Storage) GetBalanceVersion(ctx context.Context, userLogin string) (models.Version, error) {
+	var v models.Version
+	err := s.db.QueryRow(ctx,
+		"SELECT balance_version, balance_updated_at FROM users WHERE login = $1",
+		userLogin,
+	).Scan(&v.Counter, &v.LastModified)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Version{}, fmt.Errorf("%w: user %s not found", ErrNotFound, userLogin)
+		}
+		return models.Version{}, fmt.Errorf("failed to select balance version: %w", err)
+	}
+	return v, nil
+}
+
+// GetWithdrawalsVersion returns the number of the user's withdrawals and the time of the last one.
+func (s *Storage) GetWithdrawalsVersion(ctx context.Context, userLogin string) (models.Version, error) {
+	return s.getVersion(ctx,
+		"SELECT COUNT(*), MAX(processed_at) FROM withdrawals WHERE user_login = $1", userLogin)
+}
+
+// getVersion runs a query returning the row count and the latest change time of an append-mostly list.
+func (s *Storage) getVersion(ctx context.Context, query string, userLogin string) (models.Version, error) {
+	var (
+		v            models.Version
+		lastModified *time.Time
+	)
+	err := s.db.QueryRow(ctx, query, userLogin).Scan(&v.Counter, &lastModified)
+	if err != nil {
+		return models.Version{}, fmt.Errorf("failed to select version: %w", err)
+	}
+	if lastModified != nil {
+		v.LastModified = *lastModified
+	}
+	return v, nil
+}
+
+func (s *Storage) GetWithdrawals(ctx context.Context, userLogin string) (withdrawals []models.Withdrawal, err error) {
+	withdrawals = make([]models.Withdrawal, 0)
+
+	rows, err := s.db.Query(
+		ctx,
+		"SELECT order_id, withdrawal_sum, processed_at FROM withdrawals WHERE user_login = $1 ORDER by processed_at",
+		userLogin,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%w: no withdrawals for user %s", ErrNotFound, userLogin)
+		}
+		return nil, fmt.Errorf("failed to select order: %w", err)
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var w = models.Withdrawal{}
+		err = rows.Scan(&w.OrderNumber, &w.Sum, &w.ProcessedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan rows: %w", err)
+		}
+		w.ProcessedAtFormated = w.ProcessedAt.Format(time.RFC3339)
+		withdrawals = append(withdrawals, w)
+	}
+
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("failed to iterate through rows: %w", err)
+	}
+
+	return withdrawals, nil
+}
+
+func (s *Storage) SaveWithdrawal(ctx context.Context, userLogin string, orderNum string, sum float64) (err error) {
+	tx, err := s.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to init transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if err := tx.Rollback(ctx); err != nil {
+				s.log.ErrorContext(ctx, failedToRollbackLogMsg, sl.Err(err))
+			}
+		}
+	}()
+
+	var balance float64
+	err = tx.QueryRow(ctx, "SELECT balance FROM users WHERE login=$1 FOR UPDATE", userLogin).Scan(&balance)
+	if err != nil {
+		return fmt.Errorf("failed to select balance: %w", err)
+	}
+	if balance < sum {
+		return fmt.Errorf("%w: user %s has balance < sum", ErrNotEnoughFunds, userLogin)
+	}
+
+	_, err = tx.Prepare(ctx, "insrtWithdrawals", "INSERT INTO withdrawals(user_login, order_id, withdrawal_sum)"+
+		"VALUES($1, $2, $3)")
+	if err != nil {
+		return fmt.Errorf("failed to prepare insrtWithdrawals: %w", err)
+	}
+
+	_, err = tx.Prepare(ctx, "updBalance", "UPDATE users SET balance = balance - $1, "+
+		"balance_version = balance_version + 1, balance_updated_at = NOW() WHERE login = $2")
+	if err != nil {
+		return fmt.Errorf("failed to prepare updBalance: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, "insrtWithdrawals", userLogin, orderNum, sum)
+	if err != nil {
+		return fmt.Errorf("failed to execute insrtWithdrawals: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, "updBalance", sum, userLogin)
+	if err != nil {
+		return fmt.Errorf("failed to execute updBalance: %w", err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// AdjustBalance changes the user's balance by adj.Amount and records the adjustment in the audit trail.
+func (s *Storage) AdjustBalance(
+	ctx context.Context,
+	adj models.BalanceAdjustment,
+) (adjustment models.BalanceAdjustment, err error) {
+	tx, err := s.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return models.BalanceAdjustment{}, fmt.Errorf("failed to init transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if err := tx.Rollback(ctx); err != nil {
+				s.log.ErrorContext(ctx, failedToRollbackLogMsg, sl.Err(err))
+			}
+		}
+	}()
+
+	var balance float64
+	err = tx.QueryRow(ctx, "SELECT balance FROM users WHERE login=$1 FOR UPDATE", adj.UserLogin).Scan(&balance)
 	if err != nil {
-		return "", fmt.Errorf("failed to generate password: %w", err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.BalanceAdjustment{}, fmt.Errorf("%w: user %s not found", ErrNotFound, adj.UserLogin)
+		}
+		return models.BalanceAdjustment{}, fmt.Errorf("failed to select balance: %w", err)
+	}
+	if balance+adj.Amount < 0 {
+		return models.BalanceAdjustment{}, fmt.Errorf("%w: user %s has balance < %v",
+			ErrNotEnoughFunds, adj.UserLogin, -adj.Amount)
 	}
 
-	_, err = s.db.Exec(ctx, "INSERT INTO users(login, pass_hash) VALUES($1, $2)",
-		login, passHash)
+	_, err = tx.Exec(ctx, "UPDATE users SET balance = balance + $1, "+
+		"balance_version = balance_version + 1, balance_updated_at = NOW() WHERE login = $2",
+		adj.Amount, adj.UserLogin)
 	if err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) {
-			if pgErr.Code == pgerrcode.UniqueViolation {
-				return "", fmt.Errorf("%w: user with login %s exists", ErrAlreadyExists, login)
-			}
-		}
-		return "", fmt.Errorf("failed to insert users: %w", err)
+		return models.BalanceAdjustment{}, fmt.Errorf("failed to update balance: %w", err)
 	}
 
-	return login, nil
-}
-
-func (s *Storage) GetUser(ctx context.Context, login string) (user models.User, err error) {
-	row := s.db.QueryRow(ctx, "SELECT login, pass_hash, balance FROM users WHERE login = $1", login)
-	err = row.Scan(&user.Login, &user.PassHash, &user.Balance)
+	err = tx.QueryRow(ctx,
+		"INSERT INTO balance_adjustments(user_login, admin_login, amount, reason) VALUES($1, $2, $3, $4) "+
+			"RETURNING id, created_at",
+		adj.UserLogin, adj.AdminLogin, adj.Amount, adj.Reason,
+	).Scan(&adj.ID, &adj.CreatedAt)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return models.User{}, fmt.Errorf("%w: user with login %s not found", ErrNotFound, login)
-		}
-		return models.User{}, fmt.Errorf("failed to select users: %w", err)
+		return models.BalanceAdjustment{}, fmt.Errorf("failed to insert balance adjustment: %w", err)
 	}
-	return user, nil
-}
+	adj.CreatedAtFormated = adj.CreatedAt.Format(time.RFC3339)
 
-func (s *Storage) GetOrder(ctx context.Context, number string) (order models.Order, err error) {
-	row := s.db.QueryRow(ctx, "SELECT number, status, accrual, uploaded_at FROM orders WHERE number = $1", number)
-	err = row.Scan(&order.Number, &order.Status, &order.Accrual, &order.UploadedAt)
+	err = tx.Commit(ctx)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return models.Order{}, fmt.Errorf("%w: order with number %s not found", ErrNotFound, number)
-		}
-		return models.Order{}, fmt.Errorf("failed to select order: %w", err)
+		return models.BalanceAdjustment{}, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	return order, nil
+	return adj, nil
 }
 
-func (s *Storage) GetOrders(ctx context.Context, userLogin string) (orders []models.Order, err error) {
-	orders = make([]models.Order, 0)
+func (s *Storage) GetBalanceAdjustments(
+	ctx context.Context,
+	userLogin string,
+) (adjustments []models.BalanceAdjustment, err error) {
+	adjustments = make([]models.BalanceAdjustment, 0)
 
 	rows, err := s.db.Query(
 		ctx,
-		"SELECT number, status, accrual, uploaded_at FROM orders WHERE user_login = $1 ORDER by uploaded_at",
+		"SELECT id, user_login, admin_login, amount, reason, created_at FROM balance_adjustments "+
+			"WHERE user_login = $1 ORDER BY created_at",
 		userLogin,
 	)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, fmt.Errorf("%w: user %s have no orders", ErrNotFound, userLogin)
-		}
+		return nil, fmt.Errorf("failed to select balance adjustments: %w", err)
 	}
 
 	defer rows.Close()
 	for rows.Next() {
-		var order = models.Order{}
-		err = rows.Scan(&order.Number, &order.Status, &order.Accrual, &order.UploadedAt)
+		var a = models.BalanceAdjustment{}
+		err = rows.Scan(&a.ID, &a.UserLogin, &a.AdminLogin, &a.Amount, &a.Reason, &a.CreatedAt)
 		if err != nil {
-			return nil, fmt.Errorf("failed to select orders: %w", err)
+			return nil, fmt.Errorf("failed to scan rows: %w", err)
 		}
-		order.UploadedAtFormated = order.UploadedAt.Format(time.RFC3339)
-		orders = append(orders, order)
+		a.CreatedAtFormated = a.CreatedAt.Format(time.RFC3339)
+		adjustments = append(adjustments, a)
 	}
 
 	if rows.Err() != nil {
-		return nil, fmt.Errorf("failed to iterate through rows: %w", err)
+		return nil, fmt.Errorf("failed to iterate through rows: %w", rows.Err())
 	}
 
-	return orders, nil
+	return adjustments, nil
 }
 
-func (s *Storage) SaveOrder(
+// SaveTransfer moves t.Amount from t.From to t.To. Both users' rows are locked in login order,
+// so that concurrent transfers in opposite directions can't deadlock.
+// dailyLimit caps the sum the sender transfers within 24 hours; 0 disables it.
+// A repeated idempotency key returns the saved transfer with ErrGoodConflict, or ErrConflict
+// if the saved transfer has a different recipient or amount.
+func (s *Storage) SaveTransfer(
 	ctx context.Context,
-	number string,
-	userLogin string,
-	status models.OrderStatus,
-) (err error) {
+	t models.Transfer,
+	dailyLimit float64,
+) (transfer models.Transfer, err error) {
 	tx, err := s.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return models.Transfer{}, fmt.Errorf("failed to init transaction: %w", err)
+	}
 	defer func() {
 		if err != nil {
 			if err := tx.Rollback(ctx); err != nil {
@@ -150,112 +1031,191 @@ func (s *Storage) SaveOrder(
 		}
 	}()
 
-	var ordrNum, usrLogin string
-	err = tx.QueryRow(ctx, "SELECT number, user_login FROM orders WHERE number = $1", number).Scan(&ordrNum, &usrLogin)
+	// FOR UPDATE блокирует строки в порядке выдачи, то есть по логину, независимо от направления перевода.
+	rows, err := tx.Query(ctx, "SELECT login, balance FROM users WHERE login = ANY($1) ORDER BY login FOR UPDATE",
+		[]string{t.From, t.To})
 	if err != nil {
-		if !errors.Is(err, pgx.ErrNoRows) {
-			return fmt.Errorf("failed to select orders: %w", err)
+		return models.Transfer{}, fmt.Errorf("failed to lock users: %w", err)
+	}
+	balances := make(map[string]float64, 2)
+	for rows.Next() {
+		var (
+			login   string
+			balance float64
+		)
+		if err = rows.Scan(&login, &balance); err != nil {
+			rows.Close()
+			return models.Transfer{}, fmt.Errorf("failed to scan rows: %w", err)
 		}
+		balances[login] = balance
 	}
-	if ordrNum != "" {
-		if userLogin == usrLogin {
-			return fmt.Errorf("%w: order %s belongs to another user", ErrGoodConflict, number)
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return models.Transfer{}, fmt.Errorf("failed to iterate through rows: %w", err)
+	}
+	for _, login := range []string{t.From, t.To} {
+		if _, ok := balances[login]; !ok {
+			return models.Transfer{}, fmt.Errorf("%w: user %s not found", ErrNotFound, login)
 		}
-		return ErrConflict
 	}
 
-	_, err = tx.Prepare(ctx, "saveOrder", "INSERT INTO orders(number, user_login, status) VALUES($1, $2, $3)")
-	if err != nil {
-		return fmt.Errorf("failed to prepare statement saveOrder: %w", err)
+	// Ключ проверяем под блокировкой отправителя: параллельные повторы выполняются по очереди
+	// и видят уже сохранённый перевод.
+	saved := models.Transfer{From: t.From, IdempotencyKey: t.IdempotencyKey}
+	err = tx.QueryRow(ctx,
+		"SELECT id, to_login, amount, created_at FROM balance_transfers WHERE from_login = $1 AND idempotency_key = $2",
+		t.From, t.IdempotencyKey,
+	).Scan(&saved.ID, &saved.To, &saved.Amount, &saved.CreatedAt)
+	if err == nil {
+		if saved.To != t.To || saved.Amount != t.Amount {
+			return models.Transfer{}, fmt.Errorf("%w: idempotency key %s is used by transfer %d",
+				ErrConflict, t.IdempotencyKey, saved.ID)
+		}
+		saved.CreatedAtFormated = saved.CreatedAt.Format(time.RFC3339)
+		return saved, fmt.Errorf("%w: transfer %d already made", ErrGoodConflict, saved.ID)
 	}
-
-	_, err = tx.Exec(ctx, "saveOrder", number, userLogin, status)
-	if err != nil {
-		return fmt.Errorf("failed to execute saveOrder: %w", err)
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return models.Transfer{}, fmt.Errorf("failed to select transfer: %w", err)
 	}
 
-	err = tx.Commit(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+	if balances[t.From] < t.Amount {
+		return models.Transfer{}, fmt.Errorf("%w: user %s has balance < amount", ErrNotEnoughFunds, t.From)
 	}
 
-	return nil
-}
+	if dailyLimit > 0 {
+		var sent float64
+		err = tx.QueryRow(ctx,
+			"SELECT COALESCE(SUM(amount), 0) FROM balance_transfers "+
+				"WHERE from_login = $1 AND created_at > NOW() - INTERVAL '24 hours'",
+			t.From,
+		).Scan(&sent)
+		if err != nil {
+			return models.Transfer{}, fmt.Errorf("failed to select transferred sum: %w", err)
+		}
+		if sent+t.Amount > dailyLimit {
+			return models.Transfer{}, fmt.Errorf("%w: user %s transferred %v of %v in 24 hours",
+				ErrLimitExceeded, t.From, sent, dailyLimit)
+		}
+	}
 
-func (s *Storage) GetBalance(ctx context.Context, userLogin string) (balance models.Balance, err error) {
-	tx, err := s.db.BeginTx(ctx, pgx.TxOptions{})
+	_, err = tx.Exec(ctx, "UPDATE users SET balance = balance - $1, "+
+		"balance_version = balance_version + 1, balance_updated_at = NOW() WHERE login = $2",
+		t.Amount, t.From)
 	if err != nil {
-		return models.Balance{}, fmt.Errorf("failed to init transaction: %w", err)
+		return models.Transfer{}, fmt.Errorf("failed to update sender balance: %w", err)
+	}
+	_, err = tx.Exec(ctx, "UPDATE users SET balance = balance + $1, "+
+		"balance_version = balance_version + 1, balance_updated_at = NOW() WHERE login = $2",
+		t.Amount, t.To)
+	if err != nil {
+		return models.Transfer{}, fmt.Errorf("failed to update recipient balance: %w", err)
 	}
-	defer func() {
-		if err != nil {
-			if err := tx.Rollback(ctx); err != nil {
-				s.log.ErrorContext(ctx, failedToRollbackLogMsg, sl.Err(err))
-			}
-		}
-	}()
 
-	var blnc, withdraw float64
 	err = tx.QueryRow(ctx,
-		"SELECT balance, coalesce(withdrawal_sum , 0) as withdrawal_sum FROM users as u "+
-			"LEFT JOIN (SELECT user_login, COALESCE(SUM(withdrawal_sum), 0) AS withdrawal_sum 	FROM withdrawals "+
-			"GROUP BY user_login) "+
-			"as w ON u.login = $1",
-		userLogin).Scan(&blnc, &withdraw)
+		"INSERT INTO balance_transfers(from_login, to_login, amount, idempotency_key) VALUES($1, $2, $3, $4) "+
+			"RETURNING id, created_at",
+		t.From, t.To, t.Amount, t.IdempotencyKey,
+	).Scan(&t.ID, &t.CreatedAt)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return models.Balance{}, fmt.Errorf("%w: user %s not found", ErrNotFound, userLogin)
-		}
-		return models.Balance{}, fmt.Errorf("failed to select balance: %w", err)
+		return models.Transfer{}, fmt.Errorf("failed to insert transfer: %w", err)
 	}
+	t.CreatedAtFormated = t.CreatedAt.Format(time.RFC3339)
+
+	err = tx.Commit(ctx)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return models.Balance{}, fmt.Errorf("%w: no withdrawals for user %s", ErrNotFound, userLogin)
-		}
-		return models.Balance{}, fmt.Errorf("failed to select withdrawals: %w", err)
+		return models.Transfer{}, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	return models.Balance{
-		Current:   blnc,
-		Withdrawn: withdraw,
-	}, nil
+	return t, nil
 }
 
-func (s *Storage) GetWithdrawals(ctx context.Context, userLogin string) (withdrawals []models.Withdrawal, err error) {
-	withdrawals = make([]models.Withdrawal, 0)
+// GetTransfers returns the transfers the user sent or received, oldest first.
+func (s *Storage) GetTransfers(ctx context.Context, userLogin string) (transfers []models.Transfer, err error) {
+	transfers = make([]models.Transfer, 0)
 
 	rows, err := s.db.Query(
 		ctx,
-		"SELECT order_id, withdrawal_sum, processed_at FROM withdrawals WHERE user_login = $1 ORDER by processed_at",
+		"SELECT id, from_login, to_login, amount, created_at FROM balance_transfers "+
+			"WHERE from_login = $1 OR to_login = $1 ORDER BY created_at, id",
 		userLogin,
 	)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, fmt.Errorf("%w: no withdrawals for user %s", ErrNotFound, userLogin)
-		}
-		return nil, fmt.Errorf("failed to select order: %w", err)
+		return nil, fmt.Errorf("failed to select transfers: %w", err)
 	}
 
 	defer rows.Close()
 	for rows.Next() {
-		var w = models.Withdrawal{}
-		err = rows.Scan(&w.OrderNumber, &w.Sum, &w.ProcessedAt)
+		var t = models.Transfer{}
+		err = rows.Scan(&t.ID, &t.From, &t.To, &t.Amount, &t.CreatedAt)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan rows: %w", err)
 		}
-		w.ProcessedAtFormated = w.ProcessedAt.Format(time.RFC3339)
-		withdrawals = append(withdrawals, w)
+		t.CreatedAtFormated = t.CreatedAt.Format(time.RFC3339)
+		t.Direction = models.TransferIn
+		if t.From == userLogin {
+			t.Direction = models.TransferOut
+		}
+		transfers = append(transfers, t)
 	}
 
 	if rows.Err() != nil {
-		return nil, fmt.Errorf("failed to iterate through rows: %w", err)
+		return nil, fmt.Errorf("failed to iterate through rows: %w", rows.Err())
 	}
 
-	return withdrawals, nil
+	return transfers, nil
 }
 
-func (s *Storage) SaveWithdrawal(ctx context.Context, userLogin string, orderNum string, sum float64) (err error) {
-	tx, err := s.db.BeginTx(ctx, pgx.TxOptions{})
+// statementEntries selects every change of the balance of user $1 as (at, type, reference, amount).
+var statementEntries = fmt.Sprintf(
+	"SELECT updated_at AS at, '%s' AS type, number AS reference, accrual AS amount FROM orders "+
+		"WHERE user_login = $1 AND status = '%s' AND accrual > 0 "+
+		"UNION ALL SELECT processed_at, '%s', order_id, -withdrawal_sum FROM withdrawals WHERE user_login = $1 "+
+		"UNION ALL SELECT created_at, '%s', id::text, amount FROM balance_adjustments WHERE user_login = $1 "+
+		"UNION ALL SELECT created_at, '%s', from_login, amount FROM balance_transfers WHERE to_login = $1 "+
+		"UNION ALL SELECT created_at, '%s', to_login, -amount FROM balance_transfers WHERE from_login = $1",
+	models.EntryAccrual, models.Processed, models.EntryWithdrawal, models.EntryAdjustment,
+	models.EntryTransferIn, models.EntryTransferOut,
+)
+
+// GetStatement returns the balance changes of the user in [from, to) with running balances.
+// The opening balance is the sum of all changes before from.
+func (s *Storage) GetStatement(
+	ctx context.Context,
+	userLogin string,
+	from time.Time,
+	to time.Time,
+) (models.Statement, error) {
+	statement := models.Statement{From: from, To: to, Entries: make([]models.StatementEntry, 0)}
+	err := s.EachStatementEntry(ctx, userLogin, from, to, func(e models.StatementEntry) error {
+		switch e.Type {
+		case models.EntryOpening:
+			statement.OpeningBalance = e.Balance
+		case models.EntryClosing:
+			statement.ClosingBalance = e.Balance
+		default:
+			statement.Entries = append(statement.Entries, e)
+		}
+		return nil
+	})
+	if err != nil {
+		return models.Statement{}, err
+	}
+	return statement, nil
+}
+
+// EachStatementEntry calls fn for every balance change of the user in [from, to) while the rows are read,
+// so that a long statement is never held in memory. The changes are preceded by an EntryOpening
+// and followed by an EntryClosing entry carrying the opening and closing balances.
+// An error returned by fn stops the iteration and is returned as is.
+func (s *Storage) EachStatementEntry(
+	ctx context.Context,
+	userLogin string,
+	from time.Time,
+	to time.Time,
+	fn func(models.StatementEntry) error,
+) (err error) {
+	// Оба запроса должны видеть один снимок, иначе входящий остаток разойдётся со строками.
+	tx, err := s.db.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
 	if err != nil {
 		return fmt.Errorf("failed to init transaction: %w", err)
 	}
@@ -267,41 +1227,131 @@ func (s *Storage) SaveWithdrawal(ctx context.Context, userLogin string, orderNum
 		}
 	}()
 
-	var balance float64
-	err = tx.QueryRow(ctx, "SELECT balance FROM users WHERE login=$1 FOR UPDATE", userLogin).Scan(&balance)
+	opening := models.StatementEntry{At: from, Type: models.EntryOpening}
+	err = tx.QueryRow(ctx,
+		"SELECT COALESCE(SUM(amount), 0) FROM ("+statementEntries+") AS e WHERE at < $2",
+		userLogin, from.UTC(),
+	).Scan(&opening.Balance)
 	if err != nil {
-		return fmt.Errorf("failed to select balance: %w", err)
+		return fmt.Errorf("failed to select opening balance: %w", err)
 	}
-	if balance < sum {
-		return fmt.Errorf("%w: user %s has balance < sum", ErrNotEnoughFunds, userLogin)
+	if !from.IsZero() {
+		opening.AtFormated = from.Format(time.RFC3339)
+	}
+	if err = fn(opening); err != nil {
+		return err
 	}
 
-	_, err = tx.Prepare(ctx, "insrtWithdrawals", "INSERT INTO withdrawals(user_login, order_id, withdrawal_sum)"+
-		"VALUES($1, $2, $3)")
+	// Нарастающий итог считаем в NUMERIC по всей истории до to: сумма float64 накапливала бы ошибку округления.
+	rows, err := tx.Query(ctx,
+		"SELECT at, type, reference, amount, balance FROM ("+
+			"SELECT *, SUM(amount) OVER (ORDER BY at, type, reference ROWS UNBOUNDED PRECEDING) AS balance "+
+			"FROM ("+statementEntries+") AS e WHERE at < $3"+
+			") AS r WHERE at >= $2 ORDER BY at, type, reference",
+		userLogin, from.UTC(), to.UTC(),
+	)
 	if err != nil {
-		return fmt.Errorf("failed to prepare insrtWithdrawals: %w", err)
+		return fmt.Errorf("failed to select statement entries: %w", err)
 	}
+	defer rows.Close()
 
-	_, err = tx.Prepare(ctx, "updBalance", "UPDATE users SET balance = balance - $1 WHERE login = $2")
-	if err != nil {
-		return fmt.Errorf("failed to prepare updBalance: %w", err)
+	closing := models.StatementEntry{At: to, AtFormated: to.Format(time.RFC3339), Type: models.EntryClosing,
+		Balance: opening.Balance}
+	for rows.Next() {
+		var e = models.StatementEntry{}
+		err = rows.Scan(&e.At, &e.Type, &e.Reference, &e.Amount, &e.Balance)
+		if err != nil {
+			return fmt.Errorf("failed to scan rows: %w", err)
+		}
+		e.AtFormated = e.At.Format(time.RFC3339)
+		if err = fn(e); err != nil {
+			return err
+		}
+		closing.Balance = e.Balance
+	}
+	if rows.Err() != nil {
+		err = rows.Err()
+		return fmt.Errorf("failed to iterate through rows: %w", err)
 	}
+	rows.Close()
 
-	_, err = tx.Exec(ctx, "insrtWithdrawals", userLogin, orderNum, sum)
+	err = tx.Commit(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to execute insrtWithdrawals: %w", err)
+		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	_, err = tx.Exec(ctx, "updBalance", sum, userLogin)
+	return fn(closing)
+}
+
+// EachOrder calls fn for every order the user uploaded in [from, to), oldest first, while the rows are read.
+// An error returned by fn stops the iteration and is returned as is.
+func (s *Storage) EachOrder(
+	ctx context.Context,
+	userLogin string,
+	from time.Time,
+	to time.Time,
+	fn func(models.Order) error,
+) error {
+	rows, err := s.db.Query(ctx,
+		"SELECT number, status, COALESCE(accrual, 0), uploaded_at FROM orders "+
+			"WHERE user_login = $1 AND uploaded_at >= $2 AND uploaded_at < $3 ORDER BY uploaded_at, number",
+		userLogin, from.UTC(), to.UTC(),
+	)
 	if err != nil {
-		return fmt.Errorf("failed to execute updBalance: %w", err)
+		return fmt.Errorf("failed to select orders: %w", err)
 	}
+	defer rows.Close()
 
-	err = tx.Commit(ctx)
+	for rows.Next() {
+		var order = models.Order{UserLogin: userLogin}
+		err = rows.Scan(&order.Number, &order.Status, &order.Accrual, &order.UploadedAt)
+		if err != nil {
+			return fmt.Errorf("failed to scan rows: %w", err)
+		}
+		order.UploadedAtFormated = order.UploadedAt.Format(time.RFC3339)
+		if err = fn(order); err != nil {
+			return err
+		}
+	}
+	if rows.Err() != nil {
+		return fmt.Errorf("failed to iterate through rows: %w", rows.Err())
+	}
+	return nil
+}
+
+// EachWithdrawal calls fn for every withdrawal of the user in [from, to), oldest first, while the rows are read.
+// An error returned by fn stops the iteration and is returned as is.
+func (s *Storage) EachWithdrawal(
+	ctx context.Context,
+	userLogin string,
+	from time.Time,
+	to time.Time,
+	fn func(models.Withdrawal) error,
+) error {
+	rows, err := s.db.Query(ctx,
+		"SELECT order_id, withdrawal_sum, processed_at FROM withdrawals "+
+			"WHERE user_login = $1 AND processed_at >= $2 AND processed_at < $3 ORDER BY processed_at, order_id",
+		userLogin, from.UTC(), to.UTC(),
+	)
 	if err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+		return fmt.Errorf("failed to select withdrawals: %w", err)
 	}
+	defer rows.Close()
 
+	for rows.Next() {
+		var w = models.Withdrawal{}
+		err = rows.Scan(&w.OrderNumber, &w.Sum, &w.ProcessedAt)
+		if err != nil {
+			return fmt.Errorf("failed to scan rows: %w", err)
+		}
+		w.ProcessedAtFormated = w.ProcessedAt.Format(time.RFC3339)
+		if err = fn(w); err != nil {
+			return err
+		}
+	}
+	if rows.Err() != nil {
+		return fmt.Errorf("failed to iterate through rows: %w", rows.Err())
+	}
 	return nil
 }
 
@@ -311,7 +1361,7 @@ func (s *Storage) GetOrdersByStatus(
 ) (orders []models.Order, err error) {
 	orders = make([]models.Order, 0)
 
-	rows, err := s.db.Query(ctx, "SELECT number FROM orders WHERE status = ANY($1)", statuses)
+	rows, err := s.db.Query(ctx, "SELECT number, trace_parent FROM orders WHERE status = ANY($1)", statuses)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, fmt.Errorf("%w: orders not found for given statuses %v", ErrNotFound, statuses)
@@ -320,7 +1370,7 @@ func (s *Storage) GetOrdersByStatus(
 	defer rows.Close()
 	for rows.Next() {
 		var order = models.Order{}
-		err = rows.Scan(&order.Number)
+		err = rows.Scan(&order.Number, &order.TraceParent)
 		if err != nil {
 			return nil, fmt.Errorf("failed to select order by status: %w", err)
 		}
@@ -333,10 +1383,37 @@ func (s *Storage) GetOrdersByStatus(
 	return orders, nil
 }
 
-func (s *Storage) UpdateStatusAndBalance(ctx context.Context, accrual models.Accrual) error {
+// CountOrdersByStatus returns the number of orders in every status that has at least one order.
+func (s *Storage) CountOrdersByStatus(ctx context.Context) (map[models.OrderStatus]int64, error) {
+	rows, err := s.db.Query(ctx, "SELECT status, count(*) FROM orders GROUP BY status")
+	if err != nil {
+		return nil, fmt.Errorf("failed to count orders by status: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[models.OrderStatus]int64)
+	for rows.Next() {
+		var status models.OrderStatus
+		var n int64
+		if err := rows.Scan(&status, &n); err != nil {
+			return nil, fmt.Errorf("failed to scan orders count: %w", err)
+		}
+		counts[status] = n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate through rows: %w", err)
+	}
+	return counts, nil
+}
+
+// UpdateStatusAndBalance applies the accrual to the order and the user balance and returns their new state.
+func (s *Storage) UpdateStatusAndBalance(
+	ctx context.Context,
+	accrual models.Accrual,
+) (result models.AccrualResult, err error) {
 	tx, err := s.db.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
-		return fmt.Errorf("failed to init transaction: %w", err)
+		return models.AccrualResult{}, fmt.Errorf("failed to init transaction: %w", err)
 	}
 	defer func() {
 		if err != nil {
@@ -345,40 +1422,148 @@ func (s *Storage) UpdateStatusAndBalance(ctx context.Context, accrual models.Acc
 			}
 		}
 	}()
-	var userLogin string
-	err = tx.QueryRow(ctx, "SELECT user_login FROM orders WHERE number = $1", accrual.OrderNum).Scan(&userLogin)
+	err = tx.QueryRow(
+		ctx,
+		"SELECT user_login, status FROM orders WHERE number = $1 FOR UPDATE",
+		accrual.OrderNum,
+	).Scan(&result.UserLogin, &result.PreviousStatus)
+	if err != nil {
+		return models.AccrualResult{}, fmt.Errorf("failed to select user_login: %w", err)
+	}
+
+	_, err = tx.Prepare(ctx, "updtBalance", "UPDATE users SET balance = balance + $1, "+
+		"balance_version = balance_version + 1, balance_updated_at = NOW(), "+
+		"orders_version = orders_version + 1, orders_updated_at = NOW() WHERE login = $2 RETURNING balance")
 	if err != nil {
-		return fmt.Errorf("failed to select user_login: %w", err)
+		return models.AccrualResult{}, fmt.Errorf("failed to prepare updtBalance: %w", err)
 	}
 
-	_, err = tx.Prepare(ctx, "updtBalance", "UPDATE users SET balance = balance + $1 WHERE login = $2")
+	_, err = tx.Prepare(ctx, "updStatus",
+		"UPDATE orders SET status = $1, accrual = $2, updated_at = NOW() WHERE number = $3 "+
+			"RETURNING number, status, accrual, uploaded_at")
 	if err != nil {
-		return fmt.Errorf("failed to prepare updtBalance: %w", err)
+		return models.AccrualResult{}, fmt.Errorf("failed to prepare updStatus: %w", err)
 	}
 
-	_, err = tx.Prepare(ctx, "updStatus", "UPDATE orders SET status = $1, accrual = $2 WHERE number = $3")
+	err = tx.QueryRow(ctx, "updtBalance", accrual.Accrual, result.UserLogin).Scan(&result.Balance.Current)
 	if err != nil {
-		return fmt.Errorf("failed to prepare updStatus: %w", err)
+		return models.AccrualResult{}, fmt.Errorf("failed to execute updtBalance: %w", err)
 	}
 
-	_, err = tx.Exec(ctx, "updtBalance", accrual.Accrual, userLogin)
+	err = tx.QueryRow(ctx, "updStatus", accrual.Status, accrual.Accrual, accrual.OrderNum).Scan(
+		&result.Order.Number, &result.Order.Status, &result.Order.Accrual, &result.Order.UploadedAt,
+	)
 	if err != nil {
-		return fmt.Errorf("failed to execute updtBalance: %w", err)
+		return models.AccrualResult{}, fmt.Errorf("failed to execute updStatus: %w", err)
 	}
+	result.Order.UserLogin = result.UserLogin
+	result.Order.UploadedAtFormated = result.Order.UploadedAt.Format(time.RFC3339)
 
-	_, err = tx.Exec(ctx, "updStatus", accrual.Status, accrual.Accrual, accrual.OrderNum)
+	err = tx.QueryRow(ctx,
+		"SELECT COALESCE(SUM(withdrawal_sum), 0) FROM withdrawals WHERE user_login = $1",
+		result.UserLogin,
+	).Scan(&result.Balance.Withdrawn)
 	if err != nil {
-		return fmt.Errorf("failed to execute updStatus: %w", err)
+		return models.AccrualResult{}, fmt.Errorf("failed to select withdrawals sum: %w", err)
 	}
 
 	err = tx.Commit(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to commit: %w", err)
+		return models.AccrualResult{}, fmt.Errorf("failed to commit: %w", err)
+	}
+
+	return result, nil
+}
+
+// TakeRateLimitToken refills the bucket by rate tokens per second up to burst and takes one token if there is one.
+// The whole step is a single statement, so concurrent instances never take the same token twice.
+func (s *Storage) TakeRateLimitToken(
+	ctx context.Context,
+	key string,
+	rate float64,
+	burst int,
+) (tokens float64, allowed bool, err error) {
+	const refilled = "LEAST($3::float8, rl.tokens + " +
+		"GREATEST(0, EXTRACT(EPOCH FROM NOW() - rl.updated_at)::float8) * $2::float8)"
+	err = s.db.QueryRow(ctx,
+		"INSERT INTO rate_limits AS rl (key, tokens, allowed, updated_at) VALUES ($1, $3::float8 - 1, TRUE, NOW()) "+
+			"ON CONFLICT (key) DO UPDATE SET "+
+			"tokens = CASE WHEN "+refilled+" >= 1 THEN "+refilled+" - 1 ELSE "+refilled+" END, "+
+			"allowed = "+refilled+" >= 1, "+
+			"updated_at = NOW() "+
+			"RETURNING tokens, allowed",
+		key, rate, float64(burst),
+	).Scan(&tokens, &allowed)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to take rate limit token: %w", err)
+	}
+	return tokens, allowed, nil
+}
+
+// DeleteIdleRateLimits deletes buckets that were not used for idle.
+func (s *Storage) DeleteIdleRateLimits(ctx context.Context, idle time.Duration) (int64, error) {
+	tag, err := s.db.Exec(ctx,
+		"DELETE FROM rate_limits WHERE updated_at < NOW() - make_interval(secs => $1)", idle.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete idle rate limits: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// Ping checks that a connection can be acquired from the pool and the database answers.
+func (s *Storage) Ping(ctx context.Context) error {
+	if err := s.db.Ping(ctx); err != nil {
+		return fmt.Errorf("failed to ping database: %w", err)
+	}
+	return nil
+}
+
+// CheckMigrations returns an error if the schema is dirty or not at the version of the embedded migrations.
+func (s *Storage) CheckMigrations(ctx context.Context) error {
+	want, err := latestMigration()
+	if err != nil {
+		return err
 	}
 
+	var version int64
+	var dirty bool
+	err = s.db.QueryRow(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if err != nil {
+		return fmt.Errorf("failed to select migration version: %w", err)
+	}
+	if dirty {
+		return fmt.Errorf("migration %d is dirty", version)
+	}
+	if uint(version) != want {
+		return fmt.Errorf("schema is at version %d, want %d", version, want)
+	}
 	return nil
 }
 
+// latestMigration returns the version of the last embedded migration.
+func latestMigration() (uint, error) {
+	d, err := iofs.New(migrationsDir, "migrations")
+	if err != nil {
+		return 0, fmt.Errorf("failed to open migrations: %w", err)
+	}
+	defer func() { _ = d.Close() }()
+
+	v, err := d.First()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read first migration: %w", err)
+	}
+	for {
+		next, err := d.Next(v)
+		if errors.Is(err, fs.ErrNotExist) {
+			return v, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to read next migration: %w", err)
+		}
+		v = next
+	}
+}
+
 //go:embed migrations/*.sql
 var migrationsDir embed.FS
 
@@ -407,6 +1592,11 @@ func (s *Storage) RunMigrations(dsn string) error {
 	return nil
 }
 
+// PoolStat returns a snapshot of the connection pool statistics.
+func (s *Storage) PoolStat() *pgxpool.Stat {
+	return s.db.Stat()
+}
+
 func (s *Storage) Close() {
 	s.db.Close()
 }
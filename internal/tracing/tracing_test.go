@@ -0,0 +1,83 @@
+package tracing_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/VanGoghDev/gophermart/internal/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const traceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     tracing.Config
+		wantErr bool
+	}{
+		{
+			name: "must accept none",
+			cfg:  tracing.Config{Exporter: tracing.ExporterNone},
+		},
+		{
+			name: "must accept stdout",
+			cfg:  tracing.Config{Exporter: tracing.ExporterStdout, SampleRatio: 1},
+		},
+		{
+			name:    "must reject file without path",
+			cfg:     tracing.Config{Exporter: tracing.ExporterFile},
+			wantErr: true,
+		},
+		{
+			name:    "must reject unknown exporter",
+			cfg:     tracing.Config{Exporter: "jaeger"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shutdown, err := tracing.Setup(context.Background(), tt.cfg)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.NoError(t, shutdown(context.Background()))
+		})
+	}
+}
+
+func TestFileExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	shutdown, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:    tracing.ExporterFile,
+		File:        path,
+		SampleRatio: 1,
+	})
+	require.NoError(t, err)
+
+	ctx, span := tracing.Tracer().Start(context.Background(), "upload")
+	tp := tracing.TraceParent(ctx)
+	span.End()
+	require.NoError(t, shutdown(context.Background()))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"Name":"upload"`)
+	assert.True(t, strings.HasPrefix(tp, "00-"+span.SpanContext().TraceID().String()))
+}
+
+func TestTraceParent(t *testing.T) {
+	assert.Empty(t, tracing.TraceParent(context.Background()))
+
+	link := tracing.LinkTo(traceParent)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", link.SpanContext.TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", link.SpanContext.SpanID().String())
+
+	assert.False(t, tracing.LinkTo("").SpanContext.IsValid())
+}